@@ -27,7 +27,8 @@ func run() error {
 	flag.Usage = printUsage
 
 	storePath := flag.String("path", ".store", "Path to the shelve store")
-	codecName := flag.String("codec", "json", "value serialization format: gob, json, or text")
+	codecName := flag.String("codec", "json", "value serialization format: gob, json, raw, or text")
+	readOnly := flag.Bool("readonly", false, "open the store in read-only mode")
 	flag.Parse()
 
 	args := flag.Args()
@@ -44,11 +45,25 @@ func run() error {
 		return fmt.Errorf("get codec: %w", err)
 	}
 
+	open := func(codec shelve.Codec) (*Shelf, error) {
+		opts := []shelve.Option{shelve.WithCodec(codec)}
+		if *readOnly {
+			opts = append(opts, shelve.WithReadOnly())
+		}
+		return shelve.Open[string, string](*storePath, opts...)
+	}
+
+	// The transfer commands open the store themselves, since they can
+	// replace the codec.
+	switch command {
+	case "export":
+		return handleExport(open, codec, commandArgs)
+	case "import":
+		return handleImport(open, codec, commandArgs)
+	}
+
 	// Open the shelve store
-	store, err := shelve.Open[string, string](
-		*storePath,
-		shelve.WithCodec(codec),
-	)
+	store, err := open(codec)
 	if err != nil {
 		return fmt.Errorf("open store: %w", err)
 	}
@@ -85,6 +100,8 @@ func getCodec(name string) (shelve.Codec, error) {
 		return shelve.JSONCodec(), nil
 	case "text":
 		return shelve.TextCodec(), nil
+	case "raw":
+		return shelve.RawCodec(), nil
 	default:
 		return nil, fmt.Errorf("unsupported codec: %s", name)
 	}
@@ -189,11 +206,11 @@ func handleItems(store *Shelf, mode string, args []string) error {
 
 	switch mode {
 	case "items":
-		return printItems(store, start, end, order, *limit)
+		return printItems(store, bound(start), bound(end), order, *limit)
 	case "keys":
-		return printKeys(store, start, end, order, *limit)
+		return printKeys(store, bound(start), bound(end), order, *limit)
 	case "values":
-		return printValues(store, start, end, order, *limit)
+		return printValues(store, bound(start), bound(end), order, *limit)
 	default:
 		return fmt.Errorf("invalid mode: %s", mode)
 	}
@@ -201,10 +218,7 @@ func handleItems(store *Shelf, mode string, args []string) error {
 
 // Helper: Print key-value pairs.
 func printItems(store *Shelf, start, end *string, order, limit int) error {
-	return store.Items(start, limit, order, func(key, value string) (bool, error) {
-		if *end != "" && key >= *end {
-			return false, nil
-		}
+	return store.ItemsRange(start, end, limit, order, func(key, value string) (bool, error) {
 		fmt.Println(key, value)
 		return true, nil
 	})
@@ -212,10 +226,7 @@ func printItems(store *Shelf, start, end *string, order, limit int) error {
 
 // Helper: Print keys only.
 func printKeys(store *Shelf, start, end *string, order, limit int) error {
-	return store.Keys(start, limit, order, func(key, _ string) (bool, error) {
-		if *end != "" && key >= *end {
-			return false, nil
-		}
+	return store.KeysRange(start, end, limit, order, func(key, _ string) (bool, error) {
 		fmt.Println(key)
 		return true, nil
 	})
@@ -223,15 +234,21 @@ func printKeys(store *Shelf, start, end *string, order, limit int) error {
 
 // Helper: Print values only.
 func printValues(store *Shelf, start, end *string, order, limit int) error {
-	return store.Items(start, limit, order, func(key, value string) (bool, error) {
-		if *end != "" && key >= *end {
-			return false, nil
-		}
+	return store.ValuesRange(start, end, limit, order, func(_, value string) (bool, error) {
 		fmt.Println(value)
 		return true, nil
 	})
 }
 
+// Helper: Convert an optional flag value to a range bound. An empty value
+// leaves the range open.
+func bound(s *string) *string {
+	if *s == "" {
+		return nil
+	}
+	return s
+}
+
 func printUsage() {
 	fmt.Println(`shelve is a CLI tool for managing a shelve key-value store.
 
@@ -249,6 +266,8 @@ The commands are:
     items       list key-value pairs
     keys        list only the keys
     values      list only the values
+    export      write the items to a file or stdout
+    import      read items from a file or stdin
 
 Options:
  `)
@@ -71,6 +71,13 @@ func setupTestDB(t *testing.T) string {
 	return dbPath
 }
 
+// tempPath returns a path for a store in a temporary directory, removed
+// when the test finishes.
+func tempPath(t *testing.T) string {
+	t.Helper()
+	return filepath.Join(t.TempDir(), "store")
+}
+
 func TestCLIPut(t *testing.T) {
 	path := setupTestDB(t)
 
@@ -247,6 +254,11 @@ func TestCLIItems(t *testing.T) {
 		expectOutputLines(t, got, []string{"c 3", "b 2", "a 1"})
 	})
 
+	t.Run("items descending with start/end", func(t *testing.T) {
+		got := runCLI(t, "-path", path, "items", "-desc", "-start", "c", "-end", "a")
+		expectOutputLines(t, got, []string{"c 3", "b 2"})
+	})
+
 	t.Run("invalid items - Shelve error", func(t *testing.T) {
 		err := handleItems(newFakeShelve(t), "items", []string{})
 		if !errors.Is(err, TestError) {
@@ -294,6 +306,11 @@ func TestCLIKeys(t *testing.T) {
 		expectOutputLines(t, got, []string{"c", "b", "a"})
 	})
 
+	t.Run("keys descending with end", func(t *testing.T) {
+		got := runCLI(t, "-path", path, "keys", "-desc", "-end", "a")
+		expectOutputLines(t, got, []string{"c", "b"})
+	})
+
 	t.Run("invalid keys - Shelve error", func(t *testing.T) {
 		err := handleItems(newFakeShelve(t), "keys", []string{})
 		if !errors.Is(err, TestError) {
@@ -332,37 +349,72 @@ func TestCLIValues(t *testing.T) {
 
 func TestCodecs(t *testing.T) {
 	t.Run("gob", func(t *testing.T) {
-		got := runCLI(t, "-codec", "gob", "put", "a", "1")
+		got := runCLI(t, "-path", tempPath(t), "-codec", "gob", "put", "a", "1")
 		if got != "OK" {
 			t.Errorf("expected 'OK', got %q", got)
 		}
 	})
 
 	t.Run("json", func(t *testing.T) {
-		got := runCLI(t, "-codec", "json", "put", "a", "1")
+		got := runCLI(t, "-path", tempPath(t), "-codec", "json", "put", "a", "1")
 		if got != "OK" {
 			t.Errorf("expected 'OK', got %q", got)
 		}
 	})
 
 	t.Run("text", func(t *testing.T) {
-		got := runCLI(t, "-codec", "text", "put", "a", "1")
+		got := runCLI(t, "-path", tempPath(t), "-codec", "text", "put", "a", "1")
 		if got != "OK" {
 			t.Errorf("expected 'OK', got %q", got)
 		}
 	})
 
+	t.Run("raw", func(t *testing.T) {
+		path := tempPath(t)
+		runCLI(t, "-path", path, "-codec", "raw", "put", "a", "1")
+		got := runCLI(t, "-path", path, "-codec", "raw", "get", "a")
+		if got != "1" {
+			t.Errorf("expected '1', got %q", got)
+		}
+	})
+
 	t.Run("invalid codec", func(t *testing.T) {
-		got := runCLI(t, "-codec", "foo", "put", "a", "1")
+		got := runCLI(t, "-path", tempPath(t), "-codec", "foo", "put", "a", "1")
 		if !strings.Contains(got, "unsupported codec") {
 			t.Errorf("expected error, got %q", got)
 		}
 	})
 }
 
+func TestReadOnly(t *testing.T) {
+	path := setupTestDB(t)
+	runCLI(t, "-path", path, "put", "a", "1")
+
+	t.Run("get", func(t *testing.T) {
+		got := runCLI(t, "-path", path, "-readonly", "get", "a")
+		if got != "1" {
+			t.Errorf("expected '1', got %q", got)
+		}
+	})
+
+	t.Run("put", func(t *testing.T) {
+		got := runCLI(t, "-path", path, "-readonly", "put", "b", "2")
+		if !strings.Contains(got, "read-only") {
+			t.Errorf("expected error, got %q", got)
+		}
+	})
+
+	t.Run("missing store", func(t *testing.T) {
+		got := runCLI(t, "-path", path+"-missing", "-readonly", "len")
+		if !strings.Contains(got, "run failed") {
+			t.Errorf("expected error, got %q", got)
+		}
+	})
+}
+
 func TestEdgeCases(t *testing.T) {
 	t.Run("no args - print usage", func(t *testing.T) {
-		got := runCLI(t)
+		got := runCLI(t, "-path", tempPath(t))
 		if !strings.Contains(got, "Usage:") {
 			t.Errorf("expected empty string, got %q", got)
 		}
@@ -376,7 +428,7 @@ func TestEdgeCases(t *testing.T) {
 	})
 
 	t.Run("invalid command", func(t *testing.T) {
-		got := runCLI(t, "foo")
+		got := runCLI(t, "-path", tempPath(t), "foo")
 		if !strings.Contains(got, "unknown command") {
 			t.Errorf("expected error, got %q", got)
 		}
@@ -398,7 +450,7 @@ func TestMainExitOnError(t *testing.T) {
 
 	}
 
-	runCLI(t, "items", "-unknownFlag")
+	runCLI(t, "-path", tempPath(t), "items", "-unknownFlag")
 
 	if exitCode == 0 {
 		t.Error("expected exit code to be non-zero")
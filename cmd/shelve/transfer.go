@@ -0,0 +1,378 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/lucmq/go-shelve/shelve"
+)
+
+// binaryMagic starts the dumps written with the binary format.
+const binaryMagic = "SHELVE\x00\x01"
+
+// openFunc opens the store with the given value codec.
+type openFunc func(codec shelve.Codec) (*Shelf, error)
+
+// transferOptions holds the flags shared by the export and import commands.
+type transferOptions struct {
+	format   string
+	raw      bool
+	file     string
+	progress int
+}
+
+func (o *transferOptions) register(fs *flag.FlagSet, fileUsage string) {
+	fs.StringVar(&o.format, "format", "jsonl", "Format of the items: jsonl, csv, or binary")
+	fs.BoolVar(&o.raw, "raw", false, "Transfer the encoded values, without decoding them")
+	fs.StringVar(&o.file, "file", "-", fileUsage)
+	fs.IntVar(&o.progress, "progress", 10_000, "Report the progress every N items (0 disables it)")
+}
+
+// open opens the store, with the text codec if the values are transferred as
+// they are stored, since it reads and writes the strings verbatim.
+func (o *transferOptions) open(open openFunc, codec shelve.Codec) (*Shelf, error) {
+	if o.raw {
+		codec = shelve.TextCodec()
+	}
+	return open(codec)
+}
+
+// report prints the number of items transferred to stderr, every o.progress
+// items, or at the end if done is true.
+func (o *transferOptions) report(verb string, n int64, done bool) {
+	if done || (o.progress > 0 && n%int64(o.progress) == 0) {
+		_, _ = fmt.Fprintf(os.Stderr, "%s %d items\n", verb, n)
+	}
+}
+
+// Export items to a file or stdout.
+func handleExport(open openFunc, codec shelve.Codec, args []string) error {
+	var opts transferOptions
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	opts.register(fs, "Output file (- for stdout)")
+	start := fs.String("start", "", "Inclusive start key")
+	end := fs.String("end", "", "Exclusive end key")
+	prefix := fs.String("prefix", "", "Export only the keys with this prefix")
+
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("parse flags: %w", err)
+	}
+	if *prefix != "" && (*start != "" || *end != "") {
+		return errors.New("export: -prefix can't be used with -start or -end")
+	}
+
+	out := io.Writer(os.Stdout)
+	if opts.file != "-" {
+		f, err := os.Create(opts.file)
+		if err != nil {
+			return fmt.Errorf("create output: %w", err)
+		}
+		defer f.Close()
+		out = f
+	}
+	buf := bufio.NewWriter(out)
+	w, err := newRecordWriter(opts.format, opts.raw, buf)
+	if err != nil {
+		return err
+	}
+
+	store, err := opts.open(open, codec)
+	if err != nil {
+		return fmt.Errorf("open store: %w", err)
+	}
+	defer store.Close()
+
+	var n int64
+	fn := func(key, value string) (bool, error) {
+		if err := w.Write(key, value); err != nil {
+			return false, fmt.Errorf("write item: %w", err)
+		}
+		n++
+		opts.report("exported", n, false)
+		return true, nil
+	}
+	if *prefix != "" {
+		err = store.ItemsPrefix(*prefix, shelve.All, shelve.Asc, fn)
+	} else {
+		err = store.ItemsRange(bound(start), bound(end), shelve.All, shelve.Asc, fn)
+	}
+	if err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	if err = w.Flush(); err != nil {
+		return fmt.Errorf("write items: %w", err)
+	}
+	if err = buf.Flush(); err != nil {
+		return fmt.Errorf("write items: %w", err)
+	}
+
+	opts.report("exported", n, true)
+	return nil
+}
+
+// Import items from a file or stdin.
+func handleImport(open openFunc, codec shelve.Codec, args []string) error {
+	var opts transferOptions
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	opts.register(fs, "Input file (- for stdin)")
+	batchSize := fs.Int("batch", 1000, "Number of items written together")
+
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("parse flags: %w", err)
+	}
+	if *batchSize < 1 {
+		return errors.New("import: -batch must be positive")
+	}
+
+	in := io.Reader(os.Stdin)
+	if opts.file != "-" {
+		f, err := os.Open(opts.file)
+		if err != nil {
+			return fmt.Errorf("open input: %w", err)
+		}
+		defer f.Close()
+		in = f
+	}
+	r, err := newRecordReader(opts.format, opts.raw, bufio.NewReader(in))
+	if err != nil {
+		return err
+	}
+
+	store, err := opts.open(open, codec)
+	if err != nil {
+		return fmt.Errorf("open store: %w", err)
+	}
+	defer store.Close()
+
+	var n int64
+	batch := store.NewBatch()
+	for {
+		key, value, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read item %d: %w", n+1, err)
+		}
+		if err = batch.Put(key, value); err != nil {
+			return fmt.Errorf("put key %s: %w", key, err)
+		}
+		if batch.Len() >= *batchSize {
+			if err = batch.Write(); err != nil {
+				return fmt.Errorf("write items: %w", err)
+			}
+		}
+		n++
+		opts.report("imported", n, false)
+	}
+	if err = batch.Write(); err != nil {
+		return fmt.Errorf("write items: %w", err)
+	}
+
+	opts.report("imported", n, true)
+	return nil
+}
+
+// Formats
+
+type recordWriter interface {
+	Write(key, value string) error
+	Flush() error
+}
+
+type recordReader interface {
+	// Read returns the next item, or io.EOF at the end of the input.
+	Read() (key, value string, err error)
+}
+
+func newRecordWriter(format string, raw bool, w io.Writer) (recordWriter, error) {
+	switch format {
+	case "jsonl":
+		return &jsonlWriter{enc: json.NewEncoder(w), raw: raw}, nil
+	case "csv":
+		cw := csv.NewWriter(w)
+		if err := cw.Write([]string{"key", "value"}); err != nil {
+			return nil, err
+		}
+		return &csvWriter{w: cw, raw: raw}, nil
+	case "binary":
+		if _, err := io.WriteString(w, binaryMagic); err != nil {
+			return nil, err
+		}
+		return &binaryWriter{w: w}, nil
+	default:
+		return nil, fmt.Errorf("unsupported format: %s", format)
+	}
+}
+
+func newRecordReader(format string, raw bool, r *bufio.Reader) (recordReader, error) {
+	switch format {
+	case "jsonl":
+		return &jsonlReader{dec: json.NewDecoder(r), raw: raw}, nil
+	case "csv":
+		cr := csv.NewReader(r)
+		cr.FieldsPerRecord = 2
+		header, err := cr.Read()
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("read header: %w", err)
+		}
+		if err == nil && (header[0] != "key" || header[1] != "value") {
+			return nil, fmt.Errorf("invalid csv header: %q", header)
+		}
+		return &csvReader{r: cr, raw: raw}, nil
+	case "binary":
+		magic := make([]byte, len(binaryMagic))
+		if _, err := io.ReadFull(r, magic); err != nil || string(magic) != binaryMagic {
+			return nil, errors.New("input is not a binary dump")
+		}
+		return &binaryReader{r: r}, nil
+	default:
+		return nil, fmt.Errorf("unsupported format: %s", format)
+	}
+}
+
+// encodeRaw returns the value to write to a text format: the raw values are
+// encoded with base64, since they can hold any bytes.
+func encodeRaw(value string, raw bool) string {
+	if !raw {
+		return value
+	}
+	return base64.StdEncoding.EncodeToString([]byte(value))
+}
+
+func decodeRaw(value string, raw bool) (string, error) {
+	if !raw {
+		return value, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return "", fmt.Errorf("decode raw value: %w", err)
+	}
+	return string(data), nil
+}
+
+// JSON Lines
+
+type jsonlRecord struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type jsonlWriter struct {
+	enc *json.Encoder
+	raw bool
+}
+
+func (w *jsonlWriter) Write(key, value string) error {
+	return w.enc.Encode(jsonlRecord{Key: key, Value: encodeRaw(value, w.raw)})
+}
+
+func (w *jsonlWriter) Flush() error { return nil }
+
+type jsonlReader struct {
+	dec *json.Decoder
+	raw bool
+}
+
+func (r *jsonlReader) Read() (string, string, error) {
+	var rec jsonlRecord
+	if err := r.dec.Decode(&rec); err != nil {
+		return "", "", err
+	}
+	value, err := decodeRaw(rec.Value, r.raw)
+	return rec.Key, value, err
+}
+
+// CSV
+
+type csvWriter struct {
+	w   *csv.Writer
+	raw bool
+}
+
+func (w *csvWriter) Write(key, value string) error {
+	return w.w.Write([]string{key, encodeRaw(value, w.raw)})
+}
+
+func (w *csvWriter) Flush() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+type csvReader struct {
+	r   *csv.Reader
+	raw bool
+}
+
+func (r *csvReader) Read() (string, string, error) {
+	rec, err := r.r.Read()
+	if err != nil {
+		return "", "", err
+	}
+	value, err := decodeRaw(rec[1], r.raw)
+	return rec[0], value, err
+}
+
+// Binary
+//
+// After the magic header, each item is stored as the length of the key, the
+// key, the length of the value and the value, with the lengths encoded as
+// unsigned varints.
+
+type binaryWriter struct {
+	w io.Writer
+}
+
+func (w *binaryWriter) Write(key, value string) error {
+	buf := make([]byte, 0, 2*binary.MaxVarintLen64+len(key)+len(value))
+	buf = binary.AppendUvarint(buf, uint64(len(key)))
+	buf = append(buf, key...)
+	buf = binary.AppendUvarint(buf, uint64(len(value)))
+	buf = append(buf, value...)
+	_, err := w.w.Write(buf)
+	return err
+}
+
+func (w *binaryWriter) Flush() error { return nil }
+
+type binaryReader struct {
+	r *bufio.Reader
+}
+
+func (r *binaryReader) Read() (string, string, error) {
+	key, err := r.field()
+	if err != nil {
+		return "", "", err
+	}
+	value, err := r.field()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return key, value, err
+}
+
+func (r *binaryReader) field() (string, error) {
+	n, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return "", err
+	}
+	// Don't trust the length for the allocation, since the input might be
+	// truncated or corrupted.
+	var buf bytes.Buffer
+	if _, err = io.CopyN(&buf, r.r, int64(n)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return "", err
+	}
+	return buf.String(), nil
+}
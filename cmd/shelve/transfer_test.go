@@ -0,0 +1,164 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExportImport(t *testing.T) {
+	for _, format := range []string{"jsonl", "csv", "binary"} {
+		t.Run(format, func(t *testing.T) {
+			// Arrange
+			path := setupTestDB(t)
+			file := filepath.Join(t.TempDir(), "dump")
+			runCLI(t, "-path", path, "put", "a", "1", "b", "line\nbreak", "c", `"quoted", value`)
+
+			// Act
+			got := runCLI(t, "-path", path, "export", "-format", format, "-file", file)
+			if got != "exported 3 items" {
+				t.Errorf("expected 'exported 3 items', got %q", got)
+			}
+			restored := filepath.Join(t.TempDir(), "restored")
+			got = runCLI(t, "-path", restored, "import", "-format", format, "-file", file)
+
+			// Assert
+			if got != "imported 3 items" {
+				t.Errorf("expected 'imported 3 items', got %q", got)
+			}
+			if got = runCLI(t, "-path", restored, "get", "b"); got != "line\nbreak" {
+				t.Errorf("expected 'line\\nbreak', got %q", got)
+			}
+			if got = runCLI(t, "-path", restored, "get", "c"); got != `"quoted", value` {
+				t.Errorf("expected '\"quoted\", value', got %q", got)
+			}
+			if got = runCLI(t, "-path", restored, "len"); got != "3" {
+				t.Errorf("expected '3', got %q", got)
+			}
+		})
+	}
+}
+
+func TestExport(t *testing.T) {
+	path := setupTestDB(t)
+	runCLI(t, "-path", path, "put", "a", "1", "b1", "2", "b2", "3", "c", "4")
+
+	t.Run("stdout", func(t *testing.T) {
+		got := runCLI(t, "-path", path, "export", "-end", "b1")
+		want := "{\"key\":\"a\",\"value\":\"1\"}\nexported 1 items"
+		if got != want {
+			t.Errorf("expected %q, got %q", want, got)
+		}
+	})
+
+	t.Run("prefix", func(t *testing.T) {
+		got := runCLI(t, "-path", path, "export", "-format", "csv", "-prefix", "b")
+		want := "key,value\nb1,2\nb2,3\nexported 2 items"
+		if got != want {
+			t.Errorf("expected %q, got %q", want, got)
+		}
+	})
+
+	t.Run("progress", func(t *testing.T) {
+		got := runCLI(t, "-path", path, "export", "-progress", "2", "-file", filepath.Join(t.TempDir(), "dump"))
+		want := "exported 2 items\nexported 4 items\nexported 4 items"
+		if got != want {
+			t.Errorf("expected %q, got %q", want, got)
+		}
+	})
+
+	t.Run("invalid flags", func(t *testing.T) {
+		got := runCLI(t, "-path", path, "export", "-prefix", "b", "-start", "a")
+		if !strings.Contains(got, "can't be used") {
+			t.Errorf("expected error, got %q", got)
+		}
+		got = runCLI(t, "-path", path, "export", "-format", "xml")
+		if !strings.Contains(got, "unsupported format") {
+			t.Errorf("expected error, got %q", got)
+		}
+	})
+}
+
+func TestImport(t *testing.T) {
+	t.Run("raw", func(t *testing.T) {
+		// Arrange
+		path := setupTestDB(t)
+		file := filepath.Join(t.TempDir(), "dump")
+		runCLI(t, "-path", path, "-codec", "gob", "put", "a", "1")
+		runCLI(t, "-path", path, "export", "-raw", "-file", file)
+
+		// Act
+		restored := filepath.Join(t.TempDir(), "restored")
+		runCLI(t, "-path", restored, "import", "-raw", "-file", file)
+
+		// Assert
+		got := runCLI(t, "-path", restored, "-codec", "gob", "get", "a")
+		if got != "1" {
+			t.Errorf("expected '1', got %q", got)
+		}
+	})
+
+	t.Run("raw verbatim values", func(t *testing.T) {
+		// Arrange
+		path := setupTestDB(t)
+		file := filepath.Join(t.TempDir(), "dump")
+		runCLI(t, "-path", path, "-codec", "text", "put", "a", "\xf8\x01")
+		runCLI(t, "-path", path, "export", "-raw", "-format", "binary", "-file", file)
+
+		// Act
+		restored := filepath.Join(t.TempDir(), "restored")
+		runCLI(t, "-path", restored, "import", "-raw", "-format", "binary", "-file", file)
+
+		// Assert
+		got := runCLI(t, "-path", restored, "-codec", "text", "get", "a")
+		if got != "\xf8\x01" {
+			t.Errorf("expected '\\xf8\\x01', got %q", got)
+		}
+	})
+
+	t.Run("stdin", func(t *testing.T) {
+		// Arrange
+		path := setupTestDB(t)
+		file := filepath.Join(t.TempDir(), "dump")
+		_ = os.WriteFile(file, []byte(`{"key":"a","value":"1"}`+"\n"), 0600)
+		f, _ := os.Open(file)
+		defer f.Close()
+		stdin := os.Stdin
+		os.Stdin = f
+		defer func() { os.Stdin = stdin }()
+
+		// Act
+		got := runCLI(t, "-path", path, "import", "-batch", "1")
+
+		// Assert
+		if got != "imported 1 items" {
+			t.Errorf("expected 'imported 1 items', got %q", got)
+		}
+		if got = runCLI(t, "-path", path, "get", "a"); got != "1" {
+			t.Errorf("expected '1', got %q", got)
+		}
+	})
+
+	t.Run("invalid input", func(t *testing.T) {
+		path := setupTestDB(t)
+		file := filepath.Join(t.TempDir(), "dump")
+		inputs := map[string]string{
+			"binary": binaryMagic + "\x05ab",
+			"csv":    "name,value\n",
+			"jsonl":  "{",
+		}
+		for format, input := range inputs {
+			_ = os.WriteFile(file, []byte(input), 0600)
+			got := runCLI(t, "-path", path, "import", "-format", format, "-file", file)
+			if !strings.Contains(got, "run failed") {
+				t.Errorf("%s: expected error, got %q", format, got)
+			}
+		}
+		_ = os.WriteFile(file, []byte("not a dump"), 0600)
+		got := runCLI(t, "-path", path, "import", "-format", "binary", "-file", file)
+		if !strings.Contains(got, "not a binary dump") {
+			t.Errorf("expected error, got %q", got)
+		}
+	})
+}
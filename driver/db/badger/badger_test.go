@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/dgraph-io/badger/v3"
 
@@ -31,6 +32,11 @@ func TestDB(t *testing.T) {
 	tests := shelvetest.NewDBTests(OpenTestDB, ReopenTestDB)
 	tests.SupportsSeeking = true
 	tests.SupportsReverseIteration = true
+	tests.SupportsRange = true
+	tests.SupportsBatch = true
+	tests.SupportsTx = true
+	tests.SupportsTTL = true
+	tests.SupportsContext = true
 	tests.TestAll(t)
 }
 
@@ -125,3 +131,43 @@ func TestDB_Items_CopyError(t *testing.T) {
 		t.Errorf("Expected %v, but got %v", shelvetest.TestError, err)
 	}
 }
+
+func TestDB_ReplaceBatchIf(t *testing.T) {
+	if err := os.RemoveAll(dbPath); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	db, err := NewDefault(dbPath)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer db.Close()
+
+	_ = db.PutWithTTL([]byte("key-1"), []byte("value"), time.Hour)
+	ok, err := db.ReplaceBatchIf(
+		[][]byte{[]byte("key-1")}, [][]byte{[]byte("value")},
+		[][]byte{[]byte("key-1"), []byte("key-2")},
+		[][]byte{[]byte("new"), []byte("new")},
+	)
+	if !ok || err != nil {
+		t.Fatalf("Expected the batch to be written, but got %v, %v", ok, err)
+	}
+
+	expiresAt := func(key string) uint64 {
+		var at uint64
+		_ = db.db.View(func(tx *badger.Txn) error {
+			item, err := tx.Get([]byte(key))
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			at = item.ExpiresAt()
+			return nil
+		})
+		return at
+	}
+	if expiresAt("key-1") == 0 {
+		t.Errorf("Expected key-1 to keep its expiration")
+	}
+	if expiresAt("key-2") != 0 {
+		t.Errorf("Expected key-2 not to expire")
+	}
+}
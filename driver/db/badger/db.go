@@ -2,9 +2,11 @@
 package badgerd
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
-	"slices"
+	"time"
 
 	"github.com/dgraph-io/badger/v3"
 	"github.com/lucmq/go-shelve/shelve"
@@ -16,8 +18,12 @@ type Store struct {
 	valueCopy copyFunc
 }
 
-// Assert Store implements shelve.DB
-var _ shelve.DB = (*Store)(nil)
+// Assert Store implements shelve.DB, shelve.ContextDB and shelve.ExpiryDB
+var (
+	_ shelve.DB        = (*Store)(nil)
+	_ shelve.ContextDB = (*Store)(nil)
+	_ shelve.ExpiryDB  = (*Store)(nil)
+)
 
 type copyFunc func(item *badger.Item, dest []byte) ([]byte, error)
 
@@ -90,8 +96,17 @@ func (s *Store) Sync() error {
 
 // Has reports whether a key exists in the store.
 func (s *Store) Has(key []byte) (bool, error) {
+	return s.HasContext(context.Background(), key)
+}
+
+// HasContext works like [Store.Has], but returns the context error if ctx is
+// done before the key is read.
+func (s *Store) HasContext(ctx context.Context, key []byte) (bool, error) {
 	var has bool
 	err := s.db.View(func(txn *badger.Txn) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		_, err := txn.Get(key)
 		if errors.Is(err, badger.ErrKeyNotFound) {
 			return nil
@@ -108,37 +123,188 @@ func (s *Store) Has(key []byte) (bool, error) {
 // Get retrieves the value associated with a key from the store. If the key is
 // not found, it returns nil.
 func (s *Store) Get(key []byte) ([]byte, error) {
+	return s.GetContext(context.Background(), key)
+}
+
+// GetContext works like [Store.Get], but returns the context error if ctx is
+// done before the key is read.
+func (s *Store) GetContext(ctx context.Context, key []byte) ([]byte, error) {
 	var val []byte
 	err := s.db.View(func(txn *badger.Txn) error {
-		item, err := txn.Get(key)
-		if errors.Is(err, badger.ErrKeyNotFound) {
-			return nil
-		}
-		if err != nil {
-			return fmt.Errorf("get: %w", err)
+		if err := ctx.Err(); err != nil {
+			return err
 		}
-		slices.Grow(val, int(item.ValueSize()))
-		val, err = item.ValueCopy(val)
+		var err error
+		val, err = get(txn, key)
 		return err
 	})
 	return val, err
 }
 
+func get(txn *badger.Txn, key []byte) ([]byte, error) {
+	item, err := txn.Get(key)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get: %w", err)
+	}
+	val := make([]byte, 0, item.ValueSize())
+	return item.ValueCopy(val)
+}
+
 // Put stores a key-value pair in the store. If the key already exists, it
 // overwrites the existing value.
 func (s *Store) Put(key, value []byte) error {
+	return s.PutContext(context.Background(), key, value)
+}
+
+// PutContext works like [Store.Put], but returns the context error, without
+// writing the value, if ctx is done before the write transaction starts.
+func (s *Store) PutContext(ctx context.Context, key, value []byte) error {
 	return s.db.Update(func(tx *badger.Txn) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		return tx.Set(key, value)
 	})
 }
 
+// PutWithTTL works like [Store.Put], but the key expires after the given
+// time-to-live, using the native TTL of BadgerDB entries. Expired keys are
+// hidden by BadgerDB and removed during its compactions. If ttl is zero or
+// negative, the key doesn't expire.
+//
+// Note that BadgerDB stores the expiration with a resolution of one second.
+func (s *Store) PutWithTTL(key, value []byte, ttl time.Duration) error {
+	if ttl <= 0 {
+		return s.Put(key, value)
+	}
+	return s.db.Update(func(tx *badger.Txn) error {
+		return tx.SetEntry(badger.NewEntry(key, value).WithTTL(ttl))
+	})
+}
+
 // Delete removes a key-value pair from the store.
 func (s *Store) Delete(key []byte) error {
+	return s.DeleteContext(context.Background(), key)
+}
+
+// DeleteContext works like [Store.Delete], but returns the context error,
+// without deleting the key, if ctx is done before the write transaction
+// starts.
+func (s *Store) DeleteContext(ctx context.Context, key []byte) error {
 	return s.db.Update(func(tx *badger.Txn) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		return tx.Delete(key)
 	})
 }
 
+// WriteBatch atomically writes the given key-value pairs to the store. A nil
+// value deletes the key, while an empty, non-nil value is stored as is. If a
+// key appears more than once, the last value wins.
+//
+// The batch is written in a single transaction, instead of a badger
+// WriteBatch, since the latter commits the writes in multiple transactions
+// when they don't fit in one, and wouldn't be atomic. Batches that are too
+// big for a transaction fail with [badger.ErrTxnTooBig], without writing
+// anything.
+func (s *Store) WriteBatch(keys, values [][]byte) error {
+	_, err := s.WriteBatchIf(nil, nil, keys, values)
+	return err
+}
+
+// WriteBatchIf works like [Store.WriteBatch], but the batch is only written
+// if each of the checked keys holds the expected value, with a nil value
+// meaning that the key must not exist. The values are checked in the same
+// transaction that writes the batch, and if a checked key is modified by a
+// concurrent transaction, the batch is not written. It reports whether the
+// batch was written.
+func (s *Store) WriteBatchIf(checkKeys, checkValues, keys, values [][]byte) (bool, error) {
+	return s.writeBatchIf(checkKeys, checkValues, keys, values, false)
+}
+
+// ReplaceBatchIf works like [Store.WriteBatchIf], but the keys written keep
+// the expiration set with [Store.PutWithTTL], if they have one. The deleted
+// keys lose it.
+func (s *Store) ReplaceBatchIf(checkKeys, checkValues, keys, values [][]byte) (bool, error) {
+	return s.writeBatchIf(checkKeys, checkValues, keys, values, true)
+}
+
+// writeBatchIf checks the values and writes the batch in a transaction,
+// keeping the expiration of the keys written if keepExpiry is true.
+func (s *Store) writeBatchIf(
+	checkKeys, checkValues, keys, values [][]byte,
+	keepExpiry bool,
+) (bool, error) {
+	if len(checkKeys) != len(checkValues) {
+		return false, fmt.Errorf(
+			"batch has %d checked keys and %d expected values",
+			len(checkKeys), len(checkValues),
+		)
+	}
+	if len(keys) != len(values) {
+		return false, fmt.Errorf("batch has %d keys and %d values", len(keys), len(values))
+	}
+	written := false
+	err := s.db.Update(func(tx *badger.Txn) error {
+		for i, key := range checkKeys {
+			value, err := get(tx, key)
+			if err != nil {
+				return err
+			}
+			if !sameValue(value, checkValues[i]) {
+				return nil
+			}
+		}
+		written = true
+		for i, key := range keys {
+			var err error
+			switch {
+			case values[i] == nil:
+				err = tx.Delete(key)
+			case keepExpiry:
+				err = replace(tx, key, values[i])
+			default:
+				err = tx.Set(key, values[i])
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, badger.ErrConflict) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return written, nil
+}
+
+// replace sets the value of a key, keeping the expiration of the current
+// entry, if any.
+func replace(tx *badger.Txn, key, value []byte) error {
+	e := badger.NewEntry(key, value)
+	item, err := tx.Get(key)
+	if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+		return fmt.Errorf("get: %w", err)
+	}
+	if err == nil {
+		e.ExpiresAt = item.ExpiresAt()
+	}
+	return tx.SetEntry(e)
+}
+
+// sameValue reports whether two values are equal, considering a nil value
+// (a missing key) to be different from an empty one.
+func sameValue(a, b []byte) bool {
+	return (a == nil) == (b == nil) && bytes.Equal(a, b)
+}
+
 // Items iterates over key-value pairs in the database, calling fn(k, v)
 // for each pair in the sequence. The iteration stops early if the function
 // fn returns false.
@@ -159,6 +325,33 @@ func (s *Store) Items(
 	start []byte,
 	order int,
 	fn func(key, value []byte) (bool, error),
+) error {
+	return s.ItemsRange(start, nil, order, fn)
+}
+
+// ItemsRange works like [Store.Items], but stops the iteration before the
+// first key that is beyond the end bound. The end parameter is exclusive: in
+// ascending order, only keys k < end are yielded, and in descending order,
+// only keys k > end are yielded. If end is nil or empty, the iteration is
+// unbounded.
+//
+// The bound is checked on the raw keys, before the values are fetched.
+func (s *Store) ItemsRange(
+	start, end []byte,
+	order int,
+	fn func(key, value []byte) (bool, error),
+) error {
+	return s.ItemsRangeContext(context.Background(), start, end, order, fn)
+}
+
+// ItemsRangeContext works like [Store.ItemsRange], but the context is checked
+// before each step of the iterator, and the iteration stops with an error
+// wrapping the context error once ctx is done.
+func (s *Store) ItemsRangeContext(
+	ctx context.Context,
+	start, end []byte,
+	order int,
+	fn func(key, value []byte) (bool, error),
 ) error {
 	// Initialize the value variable as a buffer to be reused during
 	// the iteration
@@ -177,8 +370,14 @@ func (s *Store) Items(
 
 		// Iterate over the items until the end key is reached
 		for it.Seek(start); it.Valid(); it.Next() {
+			if err := ctx.Err(); err != nil {
+				return fmt.Errorf("iterate: %w", err)
+			}
 			item := it.Item()
 			key := item.Key()
+			if !inRange(key, end, order) {
+				return nil
+			}
 
 			// Fetch the value for the key
 			var err error
@@ -202,6 +401,55 @@ func (s *Store) Items(
 	})
 }
 
+// ItemsPrefixContext works like [Store.ItemsRangeContext], but only yields
+// the keys that start with the given prefix. An empty prefix yields all the
+// keys of the store.
+func (s *Store) ItemsPrefixContext(
+	ctx context.Context,
+	prefix []byte,
+	order int,
+	fn func(key, value []byte) (bool, error),
+) error {
+	start := prefix
+	if order < 0 {
+		// Start from the first key after the prefix range, which is skipped
+		// below. A nil end means the range reaches the last key.
+		start = prefixEnd(prefix)
+	}
+	return s.ItemsRangeContext(ctx, start, nil, order, func(k, v []byte) (bool, error) {
+		if !bytes.HasPrefix(k, prefix) {
+			return order < 0 && bytes.Compare(k, prefix) > 0, nil
+		}
+		return fn(k, v)
+	})
+}
+
 func valueCopy(item *badger.Item, dst []byte) ([]byte, error) {
 	return item.ValueCopy(dst)
 }
+
+// inRange reports whether k is before the exclusive end bound, considering
+// the iteration order. An empty end bound means the range is unbounded.
+func inRange(k, end []byte, order int) bool {
+	if len(end) == 0 {
+		return true
+	}
+	if order < 0 {
+		return bytes.Compare(k, end) > 0
+	}
+	return bytes.Compare(k, end) < 0
+}
+
+// prefixEnd returns the smallest key that is greater than all the keys
+// starting with prefix, or nil if there is no such key (i.e., the prefix
+// only contains 0xff bytes).
+func prefixEnd(prefix []byte) []byte {
+	end := bytes.Clone(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
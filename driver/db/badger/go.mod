@@ -27,3 +27,8 @@ require (
 	golang.org/x/net v0.38.0 // indirect
 	golang.org/x/sys v0.31.0 // indirect
 )
+
+replace (
+	github.com/lucmq/go-shelve => ../../..
+	github.com/lucmq/go-shelve/driver => ../..
+)
@@ -3,6 +3,7 @@ package bboltd
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	shelvetest "github.com/lucmq/go-shelve/driver/test"
@@ -31,6 +32,10 @@ func TestDB(t *testing.T) {
 	tests := shelvetest.NewDBTests(OpenTestDB, ReopenTestDB)
 	tests.SupportsSeeking = true
 	tests.SupportsReverseIteration = true
+	tests.SupportsRange = true
+	tests.SupportsBatch = true
+	tests.SupportsTx = true
+	tests.SupportsContext = true
 	tests.TestAll(t)
 }
 
@@ -112,3 +117,73 @@ func TestDB_BucketError(t *testing.T) {
 		}
 	})
 }
+
+func TestDB_Namespace(t *testing.T) {
+	db, err := OpenTestDB()
+	if err != nil {
+		t.Fatalf("open: %s", err)
+	}
+	defer db.Close()
+	store := db.(*Store)
+	_ = store.Put([]byte("key"), []byte("root"))
+
+	t.Run("Isolated", func(t *testing.T) {
+		users, err := store.Namespace("users")
+		if err != nil {
+			t.Fatalf("namespace: %s", err)
+		}
+		_ = users.Put([]byte("key"), []byte("user"))
+		_ = users.Put([]byte("other"), []byte("user"))
+		if err = users.Close(); err != nil {
+			t.Fatalf("close: %s", err)
+		}
+
+		users, _ = store.Namespace("users")
+		if n := users.Len(); n != 2 {
+			t.Errorf("expected 2 items, got %d", n)
+		}
+		if n := store.Len(); n != 1 {
+			t.Errorf("expected 1 item, got %d", n)
+		}
+		if v, _ := store.Get([]byte("key")); string(v) != "root" {
+			t.Errorf("expected root, got %q", v)
+		}
+	})
+
+	t.Run("Namespaces", func(t *testing.T) {
+		orders, _ := store.Namespace("orders")
+		_, _ = orders.(*Store).Namespace("nested")
+
+		names, err := store.Namespaces()
+		if err != nil {
+			t.Fatalf("namespaces: %s", err)
+		}
+		if len(names) != 2 || names[0] != "orders" || names[1] != "users" {
+			t.Errorf("expected [orders users], got %v", names)
+		}
+	})
+
+	t.Run("Drop", func(t *testing.T) {
+		if err := store.DropNamespace("users"); err != nil {
+			t.Fatalf("drop: %s", err)
+		}
+		if err := store.DropNamespace("missing"); err != nil {
+			t.Fatalf("drop missing: %s", err)
+		}
+		users, _ := store.Namespace("users")
+		if n := users.Len(); n != 0 {
+			t.Errorf("expected 0 items, got %d", n)
+		}
+	})
+
+	t.Run("Invalid name", func(t *testing.T) {
+		for _, name := range []string{"", "a\x00b", strings.Repeat("a", 32768)} {
+			if _, err := store.Namespace(name); err == nil {
+				t.Errorf("expected error for %q", name)
+			}
+			if err := store.DropNamespace(name); err == nil {
+				t.Errorf("expected drop error for %q", name)
+			}
+		}
+	})
+}
@@ -3,8 +3,11 @@ package bboltd
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/lucmq/go-shelve/shelve"
 	"go.etcd.io/bbolt"
@@ -14,10 +17,15 @@ import (
 type Store struct {
 	db     *bbolt.DB
 	bucket []byte
+	shared bool // A namespace, which doesn't own the database
 }
 
-// Assert Store implements shelve.DB
-var _ shelve.DB = (*Store)(nil)
+// Assert Store implements shelve.DB, shelve.ContextDB and shelve.NamespaceDB
+var (
+	_ shelve.DB          = (*Store)(nil)
+	_ shelve.ContextDB   = (*Store)(nil)
+	_ shelve.NamespaceDB = (*Store)(nil)
+)
 
 // New creates a new BoltDB store. The bucket is created if it doesn't exist.
 func New(db *bbolt.DB, bucket []byte) (*Store, error) {
@@ -47,11 +55,83 @@ func Open(path string, mode os.FileMode, options *bbolt.Options) (*bbolt.DB, err
 	return bbolt.Open(path, mode, options)
 }
 
-// Close closes the underlying BoltDB database.
+// Close closes the underlying BoltDB database. Closing the store of a
+// namespace doesn't close the database.
 func (s *Store) Close() error {
+	if s.shared {
+		return nil
+	}
 	return s.db.Close()
 }
 
+// Namespace returns the store of the namespace with the given name, creating
+// it if it doesn't exist. A namespace is a top-level bucket named after the
+// bucket of the store, followed by a zero byte and the name, so its keys are
+// independent of the ones of the store. The name must not be empty, contain
+// a zero byte or make the bucket name longer than the maximum key size.
+func (s *Store) Namespace(name string) (shelve.DB, error) {
+	if err := s.checkNamespace(name); err != nil {
+		return nil, err
+	}
+	ns, err := New(s.db, s.namespaceBucket(name))
+	if err != nil {
+		return nil, err
+	}
+	ns.shared = true
+	return ns, nil
+}
+
+// Namespaces returns the names of the namespaces of the store, sorted like
+// the buckets.
+func (s *Store) Namespaces() ([]string, error) {
+	prefix := s.namespaceBucket("")
+	var names []string
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		c := tx.Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			name := k[len(prefix):]
+			if bytes.IndexByte(name, 0) >= 0 {
+				// A namespace of a namespace
+				continue
+			}
+			names = append(names, string(name))
+		}
+		return nil
+	})
+	return names, err
+}
+
+// DropNamespace deletes the bucket of the namespace with the given name.
+// Dropping a namespace that doesn't exist is not an error.
+func (s *Store) DropNamespace(name string) error {
+	if err := s.checkNamespace(name); err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		err := tx.DeleteBucket(s.namespaceBucket(name))
+		if errors.Is(err, bbolt.ErrBucketNotFound) {
+			return nil
+		}
+		return err
+	})
+}
+
+// checkNamespace returns an error if the name of a namespace is invalid.
+func (s *Store) checkNamespace(name string) error {
+	if name == "" || strings.IndexByte(name, 0) >= 0 ||
+		len(s.bucket)+1+len(name) > bbolt.MaxKeySize {
+		return fmt.Errorf("invalid namespace name: %q", name)
+	}
+	return nil
+}
+
+func (s *Store) namespaceBucket(name string) []byte {
+	b := make([]byte, 0, len(s.bucket)+1+len(name))
+	b = append(b, s.bucket...)
+	b = append(b, 0)
+	return append(b, name...)
+}
+
 // Len returns the number of items in the store. It returns -1 if an error
 // occurs.
 func (s *Store) Len() int64 {
@@ -78,12 +158,21 @@ func (s *Store) Sync() error {
 
 // Has reports whether a key exists in the store.
 func (s *Store) Has(key []byte) (bool, error) {
+	return s.HasContext(context.Background(), key)
+}
+
+// HasContext works like [Store.Has], but returns the context error if ctx is
+// done before the key is read.
+func (s *Store) HasContext(ctx context.Context, key []byte) (bool, error) {
 	var exists bool
 	err := s.db.View(func(tx *bbolt.Tx) error {
 		b := tx.Bucket(s.bucket)
 		if b == nil {
 			return fmt.Errorf("bucket not found")
 		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		value := b.Get(key)
 		if value != nil {
 			exists = true
@@ -96,12 +185,21 @@ func (s *Store) Has(key []byte) (bool, error) {
 // Get retrieves the value associated with a key from the store. If the key is
 // not found, it returns nil.
 func (s *Store) Get(key []byte) ([]byte, error) {
+	return s.GetContext(context.Background(), key)
+}
+
+// GetContext works like [Store.Get], but returns the context error if ctx is
+// done before the key is read.
+func (s *Store) GetContext(ctx context.Context, key []byte) ([]byte, error) {
 	var val []byte
 	err := s.db.View(func(tx *bbolt.Tx) error {
 		b := tx.Bucket(s.bucket)
 		if b == nil {
 			return fmt.Errorf("bucket not found")
 		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		val = b.Get(key)
 		return nil
 	})
@@ -111,26 +209,106 @@ func (s *Store) Get(key []byte) ([]byte, error) {
 // Put adds a key-value pair to the store. If the key already exists, it
 // overwrites the existing value.
 func (s *Store) Put(key, value []byte) error {
+	return s.PutContext(context.Background(), key, value)
+}
+
+// PutContext works like [Store.Put], but returns the context error, without
+// writing the value, if ctx is done before the write transaction starts.
+func (s *Store) PutContext(ctx context.Context, key, value []byte) error {
 	return s.db.Update(func(tx *bbolt.Tx) error {
 		b := tx.Bucket(s.bucket)
 		if b == nil {
 			return fmt.Errorf("bucket not found")
 		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		return b.Put(key, value)
 	})
 }
 
 // Delete removes a key from the store.
 func (s *Store) Delete(key []byte) error {
+	return s.DeleteContext(context.Background(), key)
+}
+
+// DeleteContext works like [Store.Delete], but returns the context error,
+// without deleting the key, if ctx is done before the write transaction
+// starts.
+func (s *Store) DeleteContext(ctx context.Context, key []byte) error {
 	return s.db.Update(func(tx *bbolt.Tx) error {
 		b := tx.Bucket(s.bucket)
 		if b == nil {
 			return fmt.Errorf("bucket not found")
 		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		return b.Delete(key)
 	})
 }
 
+// WriteBatch atomically writes the given key-value pairs to the store, in a
+// single BoltDB transaction. A nil value deletes the key, while an empty,
+// non-nil value is stored as is. If a key appears more than once, the last
+// value wins.
+func (s *Store) WriteBatch(keys, values [][]byte) error {
+	_, err := s.WriteBatchIf(nil, nil, keys, values)
+	return err
+}
+
+// WriteBatchIf works like [Store.WriteBatch], but the batch is only written
+// if each of the checked keys holds the expected value, with a nil value
+// meaning that the key must not exist. The values are checked in the same
+// BoltDB transaction that writes the batch. It reports whether the batch was
+// written.
+func (s *Store) WriteBatchIf(checkKeys, checkValues, keys, values [][]byte) (bool, error) {
+	if len(checkKeys) != len(checkValues) {
+		return false, fmt.Errorf(
+			"batch has %d checked keys and %d expected values",
+			len(checkKeys), len(checkValues),
+		)
+	}
+	if len(keys) != len(values) {
+		return false, fmt.Errorf("batch has %d keys and %d values", len(keys), len(values))
+	}
+	written := false
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(s.bucket)
+		if b == nil {
+			return fmt.Errorf("bucket not found")
+		}
+		for i, key := range checkKeys {
+			if !sameValue(b.Get(key), checkValues[i]) {
+				return nil
+			}
+		}
+		written = true
+		for i, key := range keys {
+			var err error
+			if values[i] == nil {
+				err = b.Delete(key)
+			} else {
+				err = b.Put(key, values[i])
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return written, nil
+}
+
+// sameValue reports whether two values are equal, considering a nil value
+// (a missing key) to be different from an empty one.
+func sameValue(a, b []byte) bool {
+	return (a == nil) == (b == nil) && bytes.Equal(a, b)
+}
+
 // Items iterates over key-value pairs in the database, calling fn(k, v)
 // for each pair in the sequence. The iteration stops early if the function
 // fn returns false.
@@ -148,6 +326,31 @@ func (s *Store) Items(
 	start []byte,
 	order int,
 	fn func(key, value []byte) (bool, error),
+) error {
+	return s.ItemsRange(start, nil, order, fn)
+}
+
+// ItemsRange works like [Store.Items], but stops the iteration before the
+// first key that is beyond the end bound. The end parameter is exclusive: in
+// ascending order, only keys k < end are yielded, and in descending order,
+// only keys k > end are yielded. If end is nil or empty, the iteration is
+// unbounded.
+func (s *Store) ItemsRange(
+	start, end []byte,
+	order int,
+	fn func(key, value []byte) (bool, error),
+) error {
+	return s.ItemsRangeContext(context.Background(), start, end, order, fn)
+}
+
+// ItemsRangeContext works like [Store.ItemsRange], but the context is checked
+// before each step of the cursor, and the iteration stops with an error
+// wrapping the context error once ctx is done.
+func (s *Store) ItemsRangeContext(
+	ctx context.Context,
+	start, end []byte,
+	order int,
+	fn func(key, value []byte) (bool, error),
 ) error {
 	return s.db.View(func(tx *bbolt.Tx) error {
 		b := tx.Bucket(s.bucket)
@@ -156,7 +359,10 @@ func (s *Store) Items(
 		}
 		c := b.Cursor()
 
-		for k, v := seek(c, start, order); k != nil; {
+		for k, v := seek(c, start, order); k != nil && inRange(k, end, order); {
+			if err := ctx.Err(); err != nil {
+				return fmt.Errorf("iterate: %w", err)
+			}
 			ok, err := fn(k, v)
 			if err != nil {
 				return fmt.Errorf("call fn: %w", err)
@@ -171,6 +377,29 @@ func (s *Store) Items(
 	})
 }
 
+// ItemsPrefixContext works like [Store.ItemsRangeContext], but only yields
+// the keys that start with the given prefix. An empty prefix yields all the
+// keys of the store.
+func (s *Store) ItemsPrefixContext(
+	ctx context.Context,
+	prefix []byte,
+	order int,
+	fn func(key, value []byte) (bool, error),
+) error {
+	start := prefix
+	if order < 0 {
+		// Start from the first key after the prefix range, which is skipped
+		// below. A nil end means the range reaches the last key.
+		start = prefixEnd(prefix)
+	}
+	return s.ItemsRangeContext(ctx, start, nil, order, func(k, v []byte) (bool, error) {
+		if !bytes.HasPrefix(k, prefix) {
+			return order < 0 && bytes.Compare(k, prefix) > 0, nil
+		}
+		return fn(k, v)
+	})
+}
+
 func seek(c *bbolt.Cursor, start []byte, order int) (k, v []byte) {
 	// 1. Ascending
 	if order >= 0 {
@@ -203,3 +432,29 @@ func next(c *bbolt.Cursor, order int) (k, v []byte) {
 	}
 	return c.Next()
 }
+
+// inRange reports whether k is before the exclusive end bound, considering
+// the iteration order. An empty end bound means the range is unbounded.
+func inRange(k, end []byte, order int) bool {
+	if len(end) == 0 {
+		return true
+	}
+	if order < 0 {
+		return bytes.Compare(k, end) > 0
+	}
+	return bytes.Compare(k, end) < 0
+}
+
+// prefixEnd returns the smallest key that is greater than all the keys
+// starting with prefix, or nil if there is no such key (i.e., the prefix
+// only contains 0xff bytes).
+func prefixEnd(prefix []byte) []byte {
+	end := bytes.Clone(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
@@ -1,6 +1,6 @@
 module github.com/lucmq/go-shelve/driver/db/bolt
 
-go 1.22.0
+go 1.23.0
 
 require (
 	github.com/boltdb/bolt v1.3.1
@@ -9,3 +9,8 @@ require (
 )
 
 require golang.org/x/sys v0.20.0 // indirect
+
+replace (
+	github.com/lucmq/go-shelve => ../../..
+	github.com/lucmq/go-shelve/driver => ../..
+)
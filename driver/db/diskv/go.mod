@@ -1,6 +1,6 @@
 module github.com/lucmq/go-shelve/driver/db/diskv
 
-go 1.22.0
+go 1.23.0
 
 require (
 	github.com/lucmq/go-shelve v1.2.0
@@ -9,3 +9,8 @@ require (
 )
 
 require github.com/google/btree v1.0.0
+
+replace (
+	github.com/lucmq/go-shelve => ../../..
+	github.com/lucmq/go-shelve/driver => ../..
+)
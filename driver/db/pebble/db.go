@@ -2,8 +2,11 @@
 package pebbled
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"sync"
 
 	"github.com/cockroachdb/pebble"
 	"github.com/lucmq/go-shelve/sdb"
@@ -14,10 +17,17 @@ import (
 type Store struct {
 	db        *pebble.DB
 	newIterFn newIterFunc
+
+	// Serializes the writes, so that the checks done by WriteBatchIf
+	// aren't invalidated before its batch is committed.
+	mu sync.Mutex
 }
 
-// Assert Store implements shelve.DB
-var _ shelve.DB = (*Store)(nil)
+// Assert Store implements shelve.DB and shelve.ContextDB
+var (
+	_ shelve.DB        = (*Store)(nil)
+	_ shelve.ContextDB = (*Store)(nil)
+)
 
 type newIterFunc func(*pebble.DB, *pebble.IterOptions) (*pebble.Iterator, error)
 
@@ -73,6 +83,15 @@ func (s *Store) Sync() error {
 
 // Has reports whether a key exists in the store.
 func (s *Store) Has(key []byte) (bool, error) {
+	return s.HasContext(context.Background(), key)
+}
+
+// HasContext works like [Store.Has], but returns the context error if ctx is
+// done before the key is read.
+func (s *Store) HasContext(ctx context.Context, key []byte) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
 	value, closer, err := s.db.Get(key)
 	if err != nil && errors.Is(err, pebble.ErrNotFound) {
 		return false, nil
@@ -84,6 +103,15 @@ func (s *Store) Has(key []byte) (bool, error) {
 // Get retrieves the value associated with a key from the store. If the key is
 // not found, it returns nil.
 func (s *Store) Get(key []byte) ([]byte, error) {
+	return s.GetContext(context.Background(), key)
+}
+
+// GetContext works like [Store.Get], but returns the context error if ctx is
+// done before the key is read.
+func (s *Store) GetContext(ctx context.Context, key []byte) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	value, closer, err := s.db.Get(key)
 	if err != nil && errors.Is(err, pebble.ErrNotFound) {
 		return nil, nil
@@ -99,14 +127,107 @@ func (s *Store) Get(key []byte) ([]byte, error) {
 // Put stores a key-value pair in the store. If the key already exists, it
 // overwrites the existing value.
 func (s *Store) Put(key, value []byte) error {
+	return s.PutContext(context.Background(), key, value)
+}
+
+// PutContext works like [Store.Put], but returns the context error, without
+// writing the value, if ctx is done before the write starts.
+func (s *Store) PutContext(ctx context.Context, key, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	return s.db.Set(key, value, pebble.Sync)
 }
 
 // Delete removes a key-value pair from the store.
 func (s *Store) Delete(key []byte) error {
+	return s.DeleteContext(context.Background(), key)
+}
+
+// DeleteContext works like [Store.Delete], but returns the context error,
+// without deleting the key, if ctx is done before the write starts.
+func (s *Store) DeleteContext(ctx context.Context, key []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	return s.db.Delete(key, pebble.Sync)
 }
 
+// WriteBatch atomically writes the given key-value pairs to the store, with
+// a single pebble Batch. A nil value deletes the key, while an empty, non-nil
+// value is stored as is. If a key appears more than once, the last value
+// wins.
+func (s *Store) WriteBatch(keys, values [][]byte) error {
+	_, err := s.WriteBatchIf(nil, nil, keys, values)
+	return err
+}
+
+// WriteBatchIf works like [Store.WriteBatch], but the batch is only written
+// if each of the checked keys holds the expected value, with a nil value
+// meaning that the key must not exist. Pebble doesn't detect conflicts, so
+// the writes to the store are serialized while the values are checked and
+// the batch is committed. It reports whether the batch was written.
+func (s *Store) WriteBatchIf(checkKeys, checkValues, keys, values [][]byte) (bool, error) {
+	if len(checkKeys) != len(checkValues) {
+		return false, fmt.Errorf(
+			"batch has %d checked keys and %d expected values",
+			len(checkKeys), len(checkValues),
+		)
+	}
+	if len(keys) != len(values) {
+		return false, fmt.Errorf("batch has %d keys and %d values", len(keys), len(values))
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, key := range checkKeys {
+		ok, err := s.holds(key, checkValues[i])
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+
+	b := s.db.NewBatch()
+	defer b.Close()
+
+	for i, key := range keys {
+		var err error
+		if values[i] == nil {
+			err = b.Delete(key, nil)
+		} else {
+			err = b.Set(key, values[i], nil)
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	if err := b.Commit(pebble.Sync); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// holds reports whether the key holds the expected value, with a nil value
+// meaning that the key must not exist.
+func (s *Store) holds(key, expected []byte) (bool, error) {
+	value, closer, err := s.db.Get(key)
+	if errors.Is(err, pebble.ErrNotFound) {
+		return expected == nil, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer closer.Close()
+	return expected != nil && bytes.Equal(value, expected), nil
+}
+
 // Items iterates over key-value pairs in the database, calling fn(k, v)
 // for each pair in the sequence. The iteration stops early if the function
 // fn returns false.
@@ -124,7 +245,59 @@ func (s *Store) Delete(key []byte) error {
 // The value parameter must only be used inside fn, as it is reused during the
 // iteration.
 func (s *Store) Items(start []byte, order int, fn sdb.Yield) error {
-	iter, err := s.newIterFn(s.db, nil)
+	return s.ItemsRange(start, nil, order, fn)
+}
+
+// ItemsRange works like [Store.Items], but stops the iteration before the
+// first key that is beyond the end bound. The end parameter is exclusive: in
+// ascending order, only keys k < end are yielded, and in descending order,
+// only keys k > end are yielded. If end is nil or empty, the iteration is
+// unbounded.
+//
+// The end bound is enforced by Pebble through the iterator bounds.
+func (s *Store) ItemsRange(start, end []byte, order int, fn sdb.Yield) error {
+	return s.ItemsRangeContext(context.Background(), start, end, order, fn)
+}
+
+// ItemsRangeContext works like [Store.ItemsRange], but the context is checked
+// before each step of the iterator, and the iteration stops with an error
+// wrapping the context error once ctx is done.
+func (s *Store) ItemsRangeContext(
+	ctx context.Context,
+	start, end []byte,
+	order int,
+	fn sdb.Yield,
+) error {
+	return s.iterate(ctx, iterOptions(end, order), start, order, fn)
+}
+
+// ItemsPrefixContext works like [Store.ItemsRangeContext], but only yields
+// the keys that start with the given prefix, using the bounds of the Pebble
+// iterator. An empty prefix yields all the keys of the store.
+func (s *Store) ItemsPrefixContext(
+	ctx context.Context,
+	prefix []byte,
+	order int,
+	fn sdb.Yield,
+) error {
+	var opts *pebble.IterOptions
+	if len(prefix) != 0 {
+		opts = &pebble.IterOptions{
+			LowerBound: prefix,
+			UpperBound: prefixEnd(prefix), // Nil when there is no upper bound
+		}
+	}
+	return s.iterate(ctx, opts, nil, order, fn)
+}
+
+func (s *Store) iterate(
+	ctx context.Context,
+	opts *pebble.IterOptions,
+	start []byte,
+	order int,
+	fn sdb.Yield,
+) error {
+	iter, err := s.newIterFn(s.db, opts)
 	if err != nil {
 		return fmt.Errorf("new iter: %w", err)
 	}
@@ -135,6 +308,9 @@ func (s *Store) Items(start []byte, order int, fn sdb.Yield) error {
 	seek(iter, start, order)
 
 	for iter.Valid() {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("iterate: %w", err)
+		}
 		key := iter.Key()
 		value := iter.Value()
 		cont, err := fn(key, value)
@@ -178,8 +354,38 @@ func next(it *pebble.Iterator, order int) bool {
 	return it.Next()
 }
 
+// iterOptions returns the Pebble iterator options that enforce the exclusive
+// end bound of a range, or nil if the range is unbounded.
+func iterOptions(end []byte, order int) *pebble.IterOptions {
+	if len(end) == 0 {
+		return nil
+	}
+	if order >= 0 {
+		// UpperBound is exclusive, as end.
+		return &pebble.IterOptions{UpperBound: end}
+	}
+	// LowerBound is inclusive, so use the immediate successor of end.
+	lower := make([]byte, len(end)+1)
+	copy(lower, end)
+	return &pebble.IterOptions{LowerBound: lower}
+}
+
 func defaultNewIterFn() newIterFunc {
 	return func(db *pebble.DB, o *pebble.IterOptions) (*pebble.Iterator, error) {
 		return db.NewIter(o)
 	}
 }
+
+// prefixEnd returns the smallest key that is greater than all the keys
+// starting with prefix, or nil if there is no such key (i.e., the prefix
+// only contains 0xff bytes).
+func prefixEnd(prefix []byte) []byte {
+	end := bytes.Clone(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
@@ -32,6 +32,10 @@ func TestDB(t *testing.T) {
 	tests := shelvetest.NewDBTests(OpenTestDB, ReopenTestDB)
 	tests.SupportsSeeking = true
 	tests.SupportsReverseIteration = true
+	tests.SupportsRange = true
+	tests.SupportsBatch = true
+	tests.SupportsTx = true
+	tests.SupportsContext = true
 	tests.TestAll(t)
 }
 
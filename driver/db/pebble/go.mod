@@ -1,6 +1,6 @@
 module github.com/lucmq/go-shelve/driver/db/pebble
 
-go 1.22.0
+go 1.23.0
 
 require (
 	github.com/cockroachdb/pebble v1.1.5
@@ -36,3 +36,8 @@ require (
 	golang.org/x/text v0.14.0 // indirect
 	google.golang.org/protobuf v1.33.0 // indirect
 )
+
+replace (
+	github.com/lucmq/go-shelve => ../../..
+	github.com/lucmq/go-shelve/driver => ../..
+)
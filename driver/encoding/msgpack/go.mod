@@ -1,6 +1,6 @@
 module github.com/lucmq/go-shelve/driver/encoding/msgpack
 
-go 1.22.0
+go 1.23.0
 
 require (
 	github.com/lucmq/go-shelve v1.2.0
@@ -9,3 +9,8 @@ require (
 )
 
 require github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
+
+replace (
+	github.com/lucmq/go-shelve => ../../..
+	github.com/lucmq/go-shelve/driver => ../..
+)
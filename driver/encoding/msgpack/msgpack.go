@@ -1,4 +1,7 @@
 // Package msgpack provides a MessagePack driver for go-shelve.
+//
+// The Codec is registered with [shelve.RegisterCodec] under [CodecID], so
+// importing the package lets [shelve.EnvelopeCodec] use it.
 package msgpack
 
 import (
@@ -10,9 +13,17 @@ import (
 // Codec is a [shelve.Codec] driver for msgpack.
 type Codec struct{}
 
+// CodecID is the ID of the Codec in the envelope of [shelve.EnvelopeCodec].
+// It is one of the IDs reserved for the codecs of go-shelve and its drivers.
+const CodecID shelve.CodecID = 5
+
 // Assert Codec implements shelve.Codec
 var _ shelve.Codec = (*Codec)(nil)
 
+func init() {
+	shelve.RegisterCodec(CodecID, NewDefault())
+}
+
 // NewDefault creates a new Codec with default values.
 func NewDefault() *Codec {
 	return &Codec{}
@@ -10,6 +10,7 @@ import (
 	"github.com/vmihailenco/msgpack/v5"
 
 	shelvetest "github.com/lucmq/go-shelve/driver/test"
+	"github.com/lucmq/go-shelve/shelve"
 )
 
 type Product struct {
@@ -132,6 +133,26 @@ func TestMsgpackDecode(t *testing.T) {
 	})
 }
 
+func TestMsgpackEnvelope(t *testing.T) {
+	if _, ok := shelve.LookupCodec(CodecID); !ok {
+		t.Fatalf("Expected the codec to be registered with ID %d", CodecID)
+	}
+
+	codec, err := shelve.EnvelopeCodec(CodecID)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	encoded, err := codec.Encode("value")
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	var decoded string
+	if err = codec.Decode(encoded, &decoded); err != nil || decoded != "value" {
+		t.Errorf("Expected value, but got %q, %v", decoded, err)
+	}
+}
+
 // Benchmarks
 
 func BenchmarkEncode(b *testing.B) {
@@ -1,5 +1,7 @@
 module github.com/lucmq/go-shelve/driver
 
-go 1.22.0
+go 1.23.0
 
 require github.com/lucmq/go-shelve v1.2.0
+
+replace github.com/lucmq/go-shelve => ..
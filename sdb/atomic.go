@@ -0,0 +1,96 @@
+package sdb
+
+import "fmt"
+
+// CompareAndSwap replaces the value of a key with new, but only if the key
+// currently holds the old value. A nil old value means that the key must not
+// exist, and a nil new value deletes the key. It reports whether the value
+// was swapped.
+//
+// The value is compared and replaced while holding the write lock, so no
+// other mutation can happen between them.
+func (db *DB) CompareAndSwap(key, old, new []byte) (bool, error) {
+	var swapped bool
+	err := db.update(key, func(current []byte) ([]byte, bool, error) {
+		swapped = sameValue(current, old)
+		return new, swapped, nil
+	})
+	return swapped, err
+}
+
+// PutIfAbsent adds a key-value pair to the database, but only if the key
+// doesn't exist yet. It reports whether the value was stored.
+func (db *DB) PutIfAbsent(key, value []byte) (bool, error) {
+	return db.CompareAndSwap(key, nil, value)
+}
+
+// GetAndDelete removes a key-value pair from the database and returns the
+// removed value. If the key is not found, it returns nil.
+func (db *DB) GetAndDelete(key []byte) ([]byte, error) {
+	var value []byte
+	err := db.update(key, func(current []byte) ([]byte, bool, error) {
+		value = current
+		return nil, current != nil, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// Update replaces the value of a key with the value returned by fn, which
+// is called with the current value, or nil if the key doesn't exist. If fn
+// returns a nil value, the key is deleted, and if it returns an error, the
+// database is left unchanged and the error is returned.
+//
+// The write lock is held while fn runs, so fn must be fast and must not
+// access the database, as this would cause a deadlock.
+func (db *DB) Update(key []byte, fn func(old []byte) ([]byte, error)) error {
+	return db.update(key, func(current []byte) ([]byte, bool, error) {
+		value, err := fn(current)
+		return value, err == nil, err
+	})
+}
+
+// update reads the value of a key and, if fn asks for it, writes the value
+// returned by fn, with a nil value deleting the key. The read and the write
+// are done while holding the write lock.
+func (db *DB) update(
+	key []byte,
+	fn func(current []byte) (value []byte, write bool, err error),
+) error {
+	if err := db.lockProcess(true); err != nil {
+		return err
+	}
+	defer db.unlockProcess(true)
+
+	if err := prepareForMutation(db); err != nil {
+		return fmt.Errorf("prepare for mutation: %w", err)
+	}
+	if len(key) > db.keys.maxKeyLength() {
+		return ErrKeyTooLarge
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.closed {
+		return ErrDatabaseClosed
+	}
+	if err := completePendingBatch(db); err != nil {
+		return fmt.Errorf("complete pending batch: %w", err)
+	}
+
+	current, err := getInternal(db, key)
+	if err != nil {
+		return fmt.Errorf("get: %w", err)
+	}
+	value, write, err := fn(current)
+	if err != nil || !write {
+		return err
+	}
+	if value == nil {
+		return deleteInternal(db, key)
+	}
+	return putInternal(db, key, value)
+}
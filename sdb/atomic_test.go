@@ -0,0 +1,239 @@
+package sdb
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestDB_CompareAndSwap(t *testing.T) {
+	seed := map[string]string{"key-1": "value-1", "key-2": "value-2"}
+
+	tests := []struct {
+		name     string
+		key      string
+		old, new []byte
+		swapped  bool
+		expected map[string]string
+	}{
+		{
+			name:     "Matching value",
+			key:      "key-1",
+			old:      []byte("value-1"),
+			new:      []byte("new-1"),
+			swapped:  true,
+			expected: map[string]string{"key-1": "new-1", "key-2": "value-2"},
+		},
+		{
+			name:     "Different value",
+			key:      "key-1",
+			old:      []byte("other"),
+			new:      []byte("new-1"),
+			expected: seed,
+		},
+		{
+			name:     "Missing key",
+			key:      "key-3",
+			old:      []byte("value-3"),
+			new:      []byte("new-3"),
+			expected: seed,
+		},
+		{
+			name:    "Nil old value",
+			key:     "key-3",
+			new:     []byte("value-3"),
+			swapped: true,
+			expected: map[string]string{
+				"key-1": "value-1", "key-2": "value-2", "key-3": "value-3",
+			},
+		},
+		{
+			name:     "Nil old value with existing key",
+			key:      "key-1",
+			new:      []byte("new-1"),
+			expected: seed,
+		},
+		{
+			name:     "Nil new value",
+			key:      "key-2",
+			old:      []byte("value-2"),
+			swapped:  true,
+			expected: map[string]string{"key-1": "value-1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			db := StartDatabase(t, OpenTestDB, seed)
+			defer db.Close()
+
+			// Act
+			swapped, err := db.CompareAndSwap([]byte(tt.key), tt.old, tt.new)
+
+			// Assert
+			if err != nil {
+				t.Errorf("Expected no error, but got %v", err)
+			}
+			if swapped != tt.swapped {
+				t.Errorf("Expected swapped to be %v, but got %v", tt.swapped, swapped)
+			}
+			checkDatabase(t, db, tt.expected)
+			if db.Len() != int64(len(tt.expected)) {
+				t.Errorf("Expected len %d, but got %d", len(tt.expected), db.Len())
+			}
+		})
+	}
+
+	t.Run("Key too large", func(t *testing.T) {
+		db := StartDatabase(t, OpenTestDB, nil)
+		defer db.Close()
+
+		key := []byte(strings.Repeat("k", MaxKeyLength+1))
+		_, err := db.CompareAndSwap(key, nil, []byte("value"))
+		if !errors.Is(err, ErrKeyTooLarge) {
+			t.Errorf("Expected ErrKeyTooLarge, but got %v", err)
+		}
+	})
+
+	t.Run("Closed database", func(t *testing.T) {
+		db := getClosedDB(t, nil)
+
+		_, err := db.CompareAndSwap([]byte("key"), nil, []byte("value"))
+		if !errors.Is(err, ErrDatabaseClosed) {
+			t.Errorf("Expected ErrDatabaseClosed, but got %v", err)
+		}
+	})
+
+	t.Run("Concurrent claims", func(t *testing.T) {
+		db := StartDatabase(t, OpenTestDB, nil)
+		defer db.Close()
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		var claims int
+		for range 16 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ok, err := db.PutIfAbsent([]byte("job"), []byte("claimed"))
+				if err != nil {
+					t.Errorf("Expected no error, but got %v", err)
+				}
+				if ok {
+					mu.Lock()
+					claims++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+
+		if claims != 1 {
+			t.Errorf("Expected 1 claim, but got %d", claims)
+		}
+	})
+}
+
+func TestDB_PutIfAbsent(t *testing.T) {
+	db := StartDatabase(t, OpenTestDB, map[string]string{"key-1": "value-1"})
+	defer db.Close()
+
+	ok, err := db.PutIfAbsent([]byte("key-1"), []byte("new-1"))
+	if err != nil || ok {
+		t.Errorf("Expected false and no error, but got %v, %v", ok, err)
+	}
+	ok, err = db.PutIfAbsent([]byte("key-2"), []byte("value-2"))
+	if err != nil || !ok {
+		t.Errorf("Expected true and no error, but got %v, %v", ok, err)
+	}
+	checkDatabase(t, db, map[string]string{"key-1": "value-1", "key-2": "value-2"})
+}
+
+func TestDB_GetAndDelete(t *testing.T) {
+	db := StartDatabase(t, OpenTestDB, map[string]string{"key-1": "value-1"})
+	defer db.Close()
+
+	value, err := db.GetAndDelete([]byte("key-1"))
+	if err != nil {
+		t.Errorf("Expected no error, but got %v", err)
+	}
+	if !bytes.Equal(value, []byte("value-1")) {
+		t.Errorf("Expected value-1, but got %q", value)
+	}
+	if db.Len() != 0 {
+		t.Errorf("Expected len 0, but got %d", db.Len())
+	}
+
+	value, err = db.GetAndDelete([]byte("key-1"))
+	if err != nil {
+		t.Errorf("Expected no error, but got %v", err)
+	}
+	if value != nil {
+		t.Errorf("Expected nil, but got %q", value)
+	}
+}
+
+func TestDB_Update(t *testing.T) {
+	t.Run("Counter", func(t *testing.T) {
+		db := StartDatabase(t, OpenTestDB, nil)
+		defer db.Close()
+
+		const workers, increments = 8, 50
+
+		var wg sync.WaitGroup
+		for range workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for range increments {
+					err := db.Update([]byte("counter"), func(old []byte) ([]byte, error) {
+						return append(bytes.Clone(old), 'x'), nil
+					})
+					if err != nil {
+						t.Errorf("Expected no error, but got %v", err)
+					}
+				}
+			}()
+		}
+		wg.Wait()
+
+		value, err := db.Get([]byte("counter"))
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		if len(value) != workers*increments {
+			t.Errorf("Expected %d, but got %d", workers*increments, len(value))
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		db := StartDatabase(t, OpenTestDB, map[string]string{"key-1": "value-1"})
+		defer db.Close()
+
+		err := db.Update([]byte("key-1"), func(old []byte) ([]byte, error) {
+			return nil, nil
+		})
+		if err != nil {
+			t.Errorf("Expected no error, but got %v", err)
+		}
+		if db.Len() != 0 {
+			t.Errorf("Expected len 0, but got %d", db.Len())
+		}
+	})
+
+	t.Run("Function error", func(t *testing.T) {
+		seed := map[string]string{"key-1": "value-1"}
+		db := StartDatabase(t, OpenTestDB, seed)
+		defer db.Close()
+
+		err := db.Update([]byte("key-1"), func(old []byte) ([]byte, error) {
+			return []byte("new-1"), TestError
+		})
+		if !errors.Is(err, TestError) {
+			t.Errorf("Expected TestError, but got %v", err)
+		}
+		checkDatabase(t, db, seed)
+	})
+}
@@ -0,0 +1,394 @@
+package sdb
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrInvalidBackup is returned by [Restore] when the archive isn't a backup
+// of an sdb database, or when its records don't match the count stored in its
+// metadata.
+var ErrInvalidBackup = errors.New("invalid backup")
+
+// Snapshot writes a consistent copy of the database to dir, which must not
+// exist. The record files are hard links to the ones of the database, so dir
+// must be on the same filesystem, and the snapshot takes little time and
+// space regardless of the size of the values. The metadata files are copied.
+//
+// Writes are blocked only while the links are created. The records of the
+// database that are still linked to a snapshot are replaced when they are
+// written, instead of written in place, so the snapshot is never changed.
+// The snapshot can be opened with [Open] like any database, or removed when
+// no longer needed.
+//
+// The namespaces of the database are included, and opened if needed. Each of
+// them is snapshotted in turn, under its own lock, so the snapshot of each
+// namespace is consistent, but not necessarily with the others.
+func (db *DB) Snapshot(dir string) error {
+	return db.SnapshotContext(context.Background(), dir)
+}
+
+// SnapshotContext works like [DB.Snapshot], but stops with the context error
+// once ctx is done. The context is checked before the records of each shard
+// and each namespace are linked. If the snapshot is stopped, dir is removed.
+func (db *DB) SnapshotContext(ctx context.Context, dir string) error {
+	if err := db.snapshotRoot(ctx, dir); err != nil {
+		return err
+	}
+	if err := db.snapshotNamespaces(ctx, dir); err != nil {
+		_ = db.fs.RemoveAll(dir)
+		return err
+	}
+	return nil
+}
+
+// snapshotRoot writes the snapshot of the database to dir, without its
+// namespaces.
+func (db *DB) snapshotRoot(ctx context.Context, dir string) error {
+	if err := db.lockProcess(false); err != nil {
+		return err
+	}
+	defer db.unlockProcess(false)
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.closed {
+		return ErrDatabaseClosed
+	}
+
+	_, err := fs.Stat(db.fs, dir)
+	if err == nil {
+		return fmt.Errorf("snapshot: %w", fs.ErrExist)
+	}
+	if !os.IsNotExist(err) {
+		return fmt.Errorf("stat: %w", err)
+	}
+
+	if err = snapshotInternal(ctx, db, dir); err != nil {
+		_ = db.fs.RemoveAll(dir)
+		return err
+	}
+	return nil
+}
+
+// snapshotNamespaces writes the snapshot of each namespace of the database to
+// the namespaces directory of the snapshot at dir.
+func (db *DB) snapshotNamespaces(ctx context.Context, dir string) error {
+	names, err := db.Namespaces()
+	if err != nil {
+		return fmt.Errorf("list namespaces: %w", err)
+	}
+	for _, name := range names {
+		ns, err := db.Namespace(name)
+		if err != nil {
+			return fmt.Errorf("namespace %q: %w", name, err)
+		}
+		nsDir := filepath.Join(dir, namespaceDirectory, db.keys.encode([]byte(name)))
+		if err = db.fs.MkdirAll(filepath.Dir(nsDir), defaultDirPermissions); err != nil {
+			return fmt.Errorf("create namespaces directory: %w", err)
+		}
+		if err = ns.SnapshotContext(ctx, nsDir); err != nil {
+			return fmt.Errorf("namespace %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// snapshotInternal links the records of the database into dir, and writes
+// the metadata as of the snapshot. The caller must hold the lock.
+func snapshotInternal(ctx context.Context, db *DB, dir string) error {
+	paths := []string{
+		dir,
+		filepath.Join(dir, dataDirectory),
+		filepath.Join(dir, metadataDirectory),
+	}
+	if err := mkdirs(db.fs, paths, defaultDirPermissions); err != nil {
+		return fmt.Errorf("create directories: %w", err)
+	}
+
+	for i, sh := range db.shards {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		shardDir := filepath.Join(dir, dataDirectory, sh.maxKey)
+		if err := db.fs.MkdirAll(shardDir, defaultDirPermissions); err != nil {
+			return fmt.Errorf("create shard: %w", err)
+		}
+		names, err := readdirnames(db.fs, db.shardPath(i))
+		if err != nil {
+			return fmt.Errorf("read shard dir: %w", err)
+		}
+		for _, name := range names {
+			err = db.fs.Link(
+				filepath.Join(db.shardPath(i), name),
+				filepath.Join(shardDir, name),
+			)
+			if err != nil {
+				return fmt.Errorf("link: %w", err)
+			}
+		}
+	}
+
+	// The metadata in memory is always accurate, while the lock is held.
+	meta := db.metadata
+	meta.Checkpoint = meta.Generation
+	data, err := db.metadataStore.marshal(meta)
+	if err != nil {
+		return fmt.Errorf("marshal metadata: %w", err)
+	}
+	writer := newAtomicWriter(db.fs, db.syncWrites)
+	err = writer.WriteFile(filepath.Join(dir, metadataDirectory, metadataFilename), data, true)
+	if err != nil {
+		return fmt.Errorf("write metadata: %w", err)
+	}
+
+	// The expiry log and the journal are written in place, so they can't
+	// be linked.
+	for _, name := range []string{expiryFilename, journalFilename} {
+		data, err = fs.ReadFile(db.fs, filepath.Join(db.path, metadataDirectory, name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("read %s: %w", name, err)
+		}
+		err = writer.WriteFile(filepath.Join(dir, metadataDirectory, name), data, true)
+		if err != nil {
+			return fmt.Errorf("write %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Backup writes a consistent copy of the database to w, as a tar archive
+// holding the data and metadata directories of the database and its
+// namespaces, which can be restored with [Restore].
+//
+// The copy is taken with [DB.Snapshot], in a temporary directory inside the
+// database path, so writes are blocked only while the snapshot is taken, and
+// not while the archive is written. For this reason, Backup returns
+// [ErrReadOnly] if the database is read-only.
+func (db *DB) Backup(w io.Writer) error {
+	return db.BackupContext(context.Background(), w)
+}
+
+// BackupContext works like [DB.Backup], but stops with the context error
+// once ctx is done, while the snapshot is taken or before each file is added
+// to the archive. The archive written to w until then is incomplete.
+func (db *DB) BackupContext(ctx context.Context, w io.Writer) error {
+	if db.readOnly {
+		return ErrReadOnly
+	}
+	dir := filepath.Join(db.path, fmt.Sprintf(
+		".backup-%d-%d", rand.Uint32(), time.Now().UnixNano(),
+	))
+	if err := db.SnapshotContext(ctx, dir); err != nil {
+		return fmt.Errorf("snapshot: %w", err)
+	}
+	defer db.fs.RemoveAll(dir)
+
+	return writeArchive(ctx, db.fs, dir, w)
+}
+
+// writeArchive writes the files of the database at root to w, as a tar
+// archive with paths relative to root.
+func writeArchive(ctx context.Context, fsys fileSystem, root string, w io.Writer) error {
+	tw := tar.NewWriter(w)
+	err := fs.WalkDir(fsys, root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == root {
+			return err
+		}
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if d.IsDir() {
+			return tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeDir,
+				Name:     name + "/",
+				Mode:     int64(defaultDirPermissions),
+			})
+		}
+
+		data, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		err = tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Mode:     int64(defaultPermissions),
+			Size:     int64(len(data)),
+		})
+		if err != nil {
+			return err
+		}
+		_, err = tw.Write(data)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("write archive: %w", err)
+	}
+	return tw.Close()
+}
+
+// Restore creates a database at path, which must not exist, from an archive
+// written by [DB.Backup], including its namespaces. The number of records
+// restored is checked against the count stored in the metadata of the backup,
+// for the database and each namespace, and Restore returns an error wrapping
+// [ErrInvalidBackup] if they differ.
+//
+// If Restore fails, the files it created are removed.
+func Restore(r io.Reader, path string) error {
+	return restore(&osFS{}, r, path)
+}
+
+func restore(fsys fileSystem, r io.Reader, path string) error {
+	_, err := fs.Stat(fsys, path)
+	if err == nil {
+		return fmt.Errorf("restore: %w", fs.ErrExist)
+	}
+	if !os.IsNotExist(err) {
+		return fmt.Errorf("stat: %w", err)
+	}
+
+	if err = restoreInternal(fsys, r, path); err != nil {
+		_ = fsys.RemoveAll(path)
+		return err
+	}
+	return nil
+}
+
+func restoreInternal(fsys fileSystem, r io.Reader, root string) error {
+	paths := []string{
+		root,
+		filepath.Join(root, dataDirectory),
+		filepath.Join(root, dataDirectory, sentinelDir),
+		filepath.Join(root, metadataDirectory),
+	}
+	if err := mkdirs(fsys, paths, defaultDirPermissions); err != nil {
+		return fmt.Errorf("create directories: %w", err)
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read archive: %w", err)
+		}
+		if !validArchivePath(h.Name, h.Typeflag == tar.TypeDir) {
+			return fmt.Errorf("%w: unexpected file %q", ErrInvalidBackup, h.Name)
+		}
+		p := filepath.Join(root, filepath.FromSlash(h.Name))
+
+		switch h.Typeflag {
+		case tar.TypeDir:
+			if err = fsys.MkdirAll(p, defaultDirPermissions); err != nil {
+				return fmt.Errorf("create directory: %w", err)
+			}
+		case tar.TypeReg:
+			if err = restoreFile(fsys, p, tr); err != nil {
+				return fmt.Errorf("restore %s: %w", h.Name, err)
+			}
+		default:
+			return fmt.Errorf("%w: unexpected file %q", ErrInvalidBackup, h.Name)
+		}
+	}
+
+	return checkRestored(fsys, root)
+}
+
+// checkRestored checks that the database restored at root, and its
+// namespaces, are complete.
+func checkRestored(fsys fileSystem, root string) error {
+	store := newMetadataStore(fsys, root)
+	meta, err := store.Load()
+	if err != nil {
+		return fmt.Errorf("%w: load metadata: %w", ErrInvalidBackup, err)
+	}
+	if err = meta.Validate(); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidBackup, err)
+	}
+	count, err := countItems(fsys, filepath.Join(root, dataDirectory))
+	if err != nil {
+		return fmt.Errorf("count items: %w", err)
+	}
+	if count != meta.TotalEntries {
+		return fmt.Errorf("%w: %d records, expected %d",
+			ErrInvalidBackup, count, meta.TotalEntries)
+	}
+
+	names, err := readdirnames(fsys, filepath.Join(root, namespaceDirectory))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read namespaces dir: %w", err)
+	}
+	for _, name := range names {
+		err = checkRestored(fsys, filepath.Join(root, namespaceDirectory, name))
+		if err != nil {
+			return fmt.Errorf("namespace %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// validArchivePath reports whether name is the path of a file or directory
+// of a database: a shard directory and its records, a metadata file, or the
+// path of a file or directory of a namespace.
+func validArchivePath(name string, dir bool) bool {
+	name = strings.TrimSuffix(name, "/")
+	if !fs.ValidPath(name) {
+		return false
+	}
+	return validDatabasePath(strings.Split(name, "/"), dir)
+}
+
+func validDatabasePath(parts []string, dir bool) bool {
+	switch parts[0] {
+	case dataDirectory:
+		if dir {
+			return len(parts) <= 2
+		}
+		return len(parts) == 3
+	case metadataDirectory:
+		return len(parts) == 1 && dir || len(parts) == 2 && !dir
+	case namespaceDirectory:
+		// The namespaces directory holds a database for each namespace.
+		if len(parts) <= 2 {
+			return dir
+		}
+		return validDatabasePath(parts[2:], dir)
+	}
+	return false
+}
+
+func restoreFile(fsys fileSystem, name string, r io.Reader) error {
+	f, err := fsys.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, defaultPermissions)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f.(io.Writer), r)
+	if err1 := f.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	return err
+}
@@ -0,0 +1,367 @@
+package sdb
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rewriteArchive copies the archive, skipping the files for which skip
+// returns true, and adding the given files.
+func rewriteArchive(t *testing.T, data []byte, skip func(name string) bool, add map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tr := tar.NewReader(bytes.NewReader(data))
+	tw := tar.NewWriter(&buf)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		if skip(h.Name) {
+			continue
+		}
+		_ = tw.WriteHeader(h)
+		_, _ = io.Copy(tw, tr)
+	}
+	for name, content := range add {
+		_ = tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg, Name: name, Mode: 0600, Size: int64(len(content)),
+		})
+		_, _ = tw.Write([]byte(content))
+	}
+	_ = tw.Close()
+	return buf.Bytes()
+}
+
+func TestDB_Snapshot(t *testing.T) {
+	seed := map[string]string{"key-1": "value-1", "key-2": "value-2", "key-3": "value-3", "key-4": "value-4"}
+
+	t.Run("Consistent copy", func(t *testing.T) {
+		// Arrange
+		db := StartDatabase(t, OpenTestDB, seed)
+		defer db.Close()
+		dir := filepath.Join(t.TempDir(), "snapshot")
+
+		// Act
+		if err := db.Snapshot(dir); err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		_ = db.Put([]byte("key-1"), []byte("new"))
+		_ = db.Put([]byte("key-5"), []byte("value-5"))
+		_ = db.Delete([]byte("key-2"))
+
+		// Assert
+		snapshot, err := Open(dir)
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		defer snapshot.Close()
+		checkDatabase(t, snapshot, seed)
+		checkDatabase(t, db, map[string]string{
+			"key-1": "new", "key-3": "value-3", "key-4": "value-4", "key-5": "value-5",
+		})
+	})
+
+	t.Run("Namespaces", func(t *testing.T) {
+		// Arrange
+		db := StartDatabase(t, OpenTestDB, seed)
+		defer db.Close()
+		users, _ := db.Namespace("users")
+		_ = users.Put([]byte("ada"), []byte("admin"))
+		admins, _ := users.Namespace("admins")
+		_ = admins.Put([]byte("ada"), []byte("root"))
+		dir := filepath.Join(t.TempDir(), "snapshot")
+
+		// Act
+		if err := db.Snapshot(dir); err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		_ = users.Put([]byte("alan"), []byte("user"))
+
+		// Assert
+		snapshot, err := Open(dir)
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		defer snapshot.Close()
+		checkDatabase(t, snapshot, seed)
+		users, _ = snapshot.Namespace("users")
+		checkDatabase(t, users, map[string]string{"ada": "admin"})
+		admins, _ = users.Namespace("admins")
+		checkDatabase(t, admins, map[string]string{"ada": "root"})
+	})
+
+	t.Run("Existing directory", func(t *testing.T) {
+		db := StartDatabase(t, OpenTestDB, seed)
+		defer db.Close()
+
+		err := db.Snapshot(t.TempDir())
+		if !errors.Is(err, fs.ErrExist) {
+			t.Errorf("Expected fs.ErrExist, but got %v", err)
+		}
+	})
+
+	t.Run("Link error", func(t *testing.T) {
+		// Arrange
+		db := StartDatabase(t, OpenTestDB, seed)
+		defer db.Close()
+		db.fs = &mockFS{
+			linkFunc: func(string, string) error { return TestError },
+		}
+		dir := filepath.Join(t.TempDir(), "snapshot")
+
+		// Act
+		err := db.Snapshot(dir)
+
+		// Assert
+		if !errors.Is(err, TestError) {
+			t.Errorf("Expected TestError, but got %v", err)
+		}
+		if _, err = os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("Expected the snapshot to be removed, but got %v", err)
+		}
+	})
+
+	t.Run("Context - Cancelled", func(t *testing.T) {
+		// Arrange
+		db := StartDatabase(t, OpenTestDB, seed)
+		defer db.Close()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		dir := filepath.Join(t.TempDir(), "snapshot")
+
+		// Act
+		err := db.SnapshotContext(ctx, dir)
+		backupErr := db.BackupContext(ctx, io.Discard)
+
+		// Assert
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, but got %v", err)
+		}
+		if !errors.Is(backupErr, context.Canceled) {
+			t.Errorf("Expected context.Canceled, but got %v", backupErr)
+		}
+		if _, err = os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("Expected the snapshot to be removed, but got %v", err)
+		}
+	})
+
+	t.Run("Closed", func(t *testing.T) {
+		db := StartDatabase(t, OpenTestDB, nil)
+		_ = db.Close()
+
+		err := db.Snapshot(filepath.Join(t.TempDir(), "snapshot"))
+		if !errors.Is(err, ErrDatabaseClosed) {
+			t.Errorf("Expected ErrDatabaseClosed, but got %v", err)
+		}
+	})
+}
+
+func TestDB_Backup(t *testing.T) {
+	seed := map[string]string{"key-1": "value-1", "key-2": "value-2", "key-3": "value-3", "key-4": "value-4"}
+
+	backup := func(t *testing.T, db *DB) []byte {
+		t.Helper()
+		var buf bytes.Buffer
+		if err := db.Backup(&buf); err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		return buf.Bytes()
+	}
+
+	t.Run("Restore", func(t *testing.T) {
+		// Arrange
+		setNow(t, time.Now())
+		db := StartDatabase(t, OpenTestDB, seed)
+		defer db.Close()
+		_ = db.PutWithTTL([]byte("key-5"), []byte("value-5"), time.Minute)
+		data := backup(t, db)
+		_ = db.Put([]byte("key-6"), []byte("value-6"))
+		path := filepath.Join(t.TempDir(), "restored")
+
+		// Act
+		err := Restore(bytes.NewReader(data), path)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		restored, err := Open(path)
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		defer restored.Close()
+		expected := map[string]string{"key-5": "value-5"}
+		for k, v := range seed {
+			expected[k] = v
+		}
+		checkDatabase(t, restored, expected)
+
+		setNow(t, time.Now().Add(time.Hour))
+		if ok, _ := restored.Has([]byte("key-5")); ok {
+			t.Errorf("Expected key-5 to be expired")
+		}
+
+		// The temporary snapshot is removed.
+		names, _ := os.ReadDir(TestDirectory)
+		if len(names) != 2 {
+			t.Errorf("Expected only the data and meta directories, but got %v", names)
+		}
+	})
+
+	t.Run("Namespaces", func(t *testing.T) {
+		// Arrange
+		db := StartDatabase(t, OpenTestDB, seed)
+		defer db.Close()
+		users, _ := db.Namespace("users")
+		_ = users.Put([]byte("ada"), []byte("admin"))
+		data := backup(t, db)
+		path := filepath.Join(t.TempDir(), "restored")
+
+		// Act
+		err := Restore(bytes.NewReader(data), path)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		restored, err := Open(path)
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		defer restored.Close()
+		checkDatabase(t, restored, seed)
+		users, _ = restored.Namespace("users")
+		checkDatabase(t, users, map[string]string{"ada": "admin"})
+	})
+
+	t.Run("Missing namespace records", func(t *testing.T) {
+		// Arrange
+		db := StartDatabase(t, OpenTestDB, seed)
+		defer db.Close()
+		users, _ := db.Namespace("users")
+		_ = users.Put([]byte("ada"), []byte("admin"))
+		data := rewriteArchive(t, backup(t, db), func(name string) bool {
+			return strings.HasPrefix(name, namespaceDirectory+"/") &&
+				strings.Contains(name, "/"+dataDirectory+"/") &&
+				!strings.HasSuffix(name, "/") && strings.Count(name, "/") == 4
+		}, nil)
+
+		// Act
+		err := Restore(bytes.NewReader(data), filepath.Join(t.TempDir(), "restored"))
+
+		// Assert
+		if !errors.Is(err, ErrInvalidBackup) {
+			t.Errorf("Expected ErrInvalidBackup, but got %v", err)
+		}
+	})
+
+	t.Run("Encrypted keys", func(t *testing.T) {
+		db := StartDatabase(t, NewOpenFunc(true, WithKeyEncryption(HashedKeys, TestSecret)), seed)
+		defer db.Close()
+		path := filepath.Join(t.TempDir(), "restored")
+
+		if err := Restore(bytes.NewReader(backup(t, db)), path); err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		restored, err := Open(path, WithKeyEncryption(HashedKeys, TestSecret))
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		defer restored.Close()
+		checkDatabase(t, restored, seed)
+	})
+
+	t.Run("Missing records", func(t *testing.T) {
+		// Arrange
+		db := StartDatabase(t, OpenTestDB, seed)
+		defer db.Close()
+		removed := false
+		data := rewriteArchive(t, backup(t, db), func(name string) bool {
+			record := strings.HasPrefix(name, dataDirectory+"/") &&
+				!strings.HasSuffix(name, "/") && strings.Count(name, "/") == 2
+			if record && !removed {
+				removed = true
+				return true
+			}
+			return false
+		}, nil)
+		path := filepath.Join(t.TempDir(), "restored")
+
+		// Act
+		err := Restore(bytes.NewReader(data), path)
+
+		// Assert
+		if !errors.Is(err, ErrInvalidBackup) {
+			t.Errorf("Expected ErrInvalidBackup, but got %v", err)
+		}
+		if _, err = os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected the restored files to be removed, but got %v", err)
+		}
+	})
+
+	t.Run("Unexpected files", func(t *testing.T) {
+		db := StartDatabase(t, OpenTestDB, seed)
+		defer db.Close()
+		data := backup(t, db)
+
+		for _, name := range []string{"../escape", "/abs", "data/record", "other/file", "meta/a/b",
+			"namespaces/file", "namespaces/users/file", "namespaces/users/data/record"} {
+			archive := rewriteArchive(t, data, func(string) bool { return false },
+				map[string]string{name: "content"})
+			err := Restore(bytes.NewReader(archive), filepath.Join(t.TempDir(), "restored"))
+			if !errors.Is(err, ErrInvalidBackup) {
+				t.Errorf("%s: Expected ErrInvalidBackup, but got %v", name, err)
+			}
+		}
+	})
+
+	t.Run("Missing metadata", func(t *testing.T) {
+		db := StartDatabase(t, OpenTestDB, seed)
+		defer db.Close()
+		data := rewriteArchive(t, backup(t, db), func(name string) bool {
+			return strings.HasPrefix(name, metadataDirectory+"/")
+		}, nil)
+
+		err := Restore(bytes.NewReader(data), filepath.Join(t.TempDir(), "restored"))
+		if !errors.Is(err, ErrInvalidBackup) {
+			t.Errorf("Expected ErrInvalidBackup, but got %v", err)
+		}
+	})
+
+	t.Run("Existing path", func(t *testing.T) {
+		db := StartDatabase(t, OpenTestDB, seed)
+		defer db.Close()
+
+		err := Restore(bytes.NewReader(backup(t, db)), t.TempDir())
+		if !errors.Is(err, fs.ErrExist) {
+			t.Errorf("Expected fs.ErrExist, but got %v", err)
+		}
+	})
+
+	t.Run("Write error", func(t *testing.T) {
+		db := StartDatabase(t, OpenTestDB, seed)
+		defer db.Close()
+
+		err := db.Backup(errorWriter{})
+		if !errors.Is(err, TestError) {
+			t.Errorf("Expected TestError, but got %v", err)
+		}
+	})
+}
+
+type errorWriter struct{}
+
+func (errorWriter) Write([]byte) (int, error) { return 0, TestError }
@@ -0,0 +1,328 @@
+package sdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// journalFilename is the name of the file, in the metadata directory, that
+// holds a batch of mutations while it is applied.
+const journalFilename = "batch.journal"
+
+// batchEntry is a single mutation of a batch, as stored in the journal.
+type batchEntry struct {
+	Key    []byte
+	Value  []byte
+	Delete bool
+
+	// KeepExpiry keeps the deadline of the record, if it has one.
+	KeepExpiry bool
+}
+
+// WriteBatch atomically writes the given key-value pairs to the database:
+// either all of them are applied or none are. A nil value deletes the key,
+// while an empty, non-nil value is stored as is. If a key appears more than
+// once, the last value wins.
+//
+// The batch is first saved to a journal file, which is synchronized to
+// persistent storage, and then applied to the database records. The records
+// are synchronized too before the journal is removed, even without
+// [WithSynchronousWrites]. If the process crashes while applying the batch,
+// it is completed when the database is opened again. If an error occurs
+// while applying the batch, it is completed before the next mutation of the
+// database.
+//
+// It returns an error if the number of keys and values differ, or if any
+// key is greater than [MaxKeyLength].
+func (db *DB) WriteBatch(keys, values [][]byte) error {
+	return db.WriteBatchContext(context.Background(), keys, values)
+}
+
+// WriteBatchContext works like [DB.WriteBatch], but returns the context
+// error, without writing the batch, if ctx is done once the locks are
+// acquired. Once the batch is saved to the journal, it is always applied.
+func (db *DB) WriteBatchContext(ctx context.Context, keys, values [][]byte) error {
+	_, err := db.WriteBatchIfContext(ctx, nil, nil, keys, values)
+	return err
+}
+
+// WriteBatchIf works like [DB.WriteBatch], but the batch is only written if
+// each of the checked keys holds the expected value, with a nil value
+// meaning that the key must not exist. The values are checked while holding
+// the write lock, so no other mutation can happen between the check and the
+// write. It reports whether the batch was written.
+//
+// It returns an error if the number of checked keys and expected values
+// differ.
+func (db *DB) WriteBatchIf(checkKeys, checkValues, keys, values [][]byte) (bool, error) {
+	return db.WriteBatchIfContext(context.Background(), checkKeys, checkValues, keys, values)
+}
+
+// WriteBatchIfContext works like [DB.WriteBatchIf], but returns the context
+// error, without checking the values or writing the batch, if ctx is done
+// once the locks are acquired.
+func (db *DB) WriteBatchIfContext(
+	ctx context.Context,
+	checkKeys, checkValues, keys, values [][]byte,
+) (bool, error) {
+	return db.writeBatchIf(ctx, checkKeys, checkValues, keys, values, false)
+}
+
+// ReplaceBatchIf works like [DB.WriteBatchIf], but the records written keep
+// the deadlines set with [DB.PutWithTTL]. It is meant for rewriting the
+// values of existing keys, like when they are encoded again, without
+// changing their expiration. The deleted keys lose their deadlines.
+func (db *DB) ReplaceBatchIf(checkKeys, checkValues, keys, values [][]byte) (bool, error) {
+	return db.ReplaceBatchIfContext(context.Background(), checkKeys, checkValues, keys, values)
+}
+
+// ReplaceBatchIfContext works like [DB.ReplaceBatchIf], but returns the
+// context error, without checking the values or writing the batch, if ctx
+// is done once the locks are acquired.
+func (db *DB) ReplaceBatchIfContext(
+	ctx context.Context,
+	checkKeys, checkValues, keys, values [][]byte,
+) (bool, error) {
+	return db.writeBatchIf(ctx, checkKeys, checkValues, keys, values, true)
+}
+
+// writeBatchIf checks the values and writes the batch, keeping the deadlines
+// of the records written if keepExpiry is true.
+func (db *DB) writeBatchIf(
+	ctx context.Context,
+	checkKeys, checkValues, keys, values [][]byte,
+	keepExpiry bool,
+) (bool, error) {
+	if len(checkKeys) != len(checkValues) {
+		return false, fmt.Errorf(
+			"batch has %d checked keys and %d expected values",
+			len(checkKeys), len(checkValues),
+		)
+	}
+	if len(keys) != len(values) {
+		return false, fmt.Errorf("batch has %d keys and %d values", len(keys), len(values))
+	}
+	for _, key := range keys {
+		if len(key) > db.keys.maxKeyLength() {
+			return false, ErrKeyTooLarge
+		}
+	}
+	// A batch without keys only checks the values, so it is allowed in a
+	// read-only database.
+	if err := db.lockProcess(len(keys) > 0); err != nil {
+		return false, err
+	}
+	defer db.unlockProcess(len(keys) > 0)
+
+	if len(keys) > 0 {
+		if err := prepareForMutation(db); err != nil {
+			return false, fmt.Errorf("prepare for mutation: %w", err)
+		}
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.closed {
+		return false, ErrDatabaseClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	if err := completePendingBatch(db); err != nil {
+		return false, fmt.Errorf("complete pending batch: %w", err)
+	}
+
+	for i, key := range checkKeys {
+		value, err := getInternal(db, key)
+		if err != nil {
+			return false, fmt.Errorf("get: %w", err)
+		}
+		if !sameValue(value, checkValues[i]) {
+			return false, nil
+		}
+	}
+	if len(keys) == 0 {
+		return true, nil
+	}
+
+	return true, writeBatchInternal(db, keys, values, keepExpiry)
+}
+
+// writeBatchInternal saves the batch to the journal and applies it. The
+// caller must hold the write lock.
+func writeBatchInternal(db *DB, keys, values [][]byte, keepExpiry bool) error {
+	entries := make([]batchEntry, len(keys))
+	for i := range keys {
+		entries[i] = batchEntry{
+			Key:        keys[i],
+			Value:      values[i],
+			Delete:     values[i] == nil,
+			KeepExpiry: keepExpiry,
+		}
+	}
+
+	if err := saveJournal(db, entries); err != nil {
+		return fmt.Errorf("save journal: %w", err)
+	}
+	db.pendingBatch = true
+
+	return completePendingBatch(db)
+}
+
+// sameValue reports whether two values are equal, considering a nil value
+// (a missing key) to be different from an empty one.
+func sameValue(a, b []byte) bool {
+	return (a == nil) == (b == nil) && bytes.Equal(a, b)
+}
+
+// completePendingBatch applies the batch saved in the journal, if any, and
+// removes the journal. Applying a batch more than once has the same effect
+// as applying it once, so it is safe to resume a partially applied batch.
+// The caller must hold the write lock.
+func completePendingBatch(db *DB) error {
+	if !db.pendingBatch {
+		return nil
+	}
+
+	entries, err := loadJournal(db)
+	if err != nil {
+		return fmt.Errorf("load journal: %w", err)
+	}
+	for _, e := range entries {
+		switch {
+		case e.Delete:
+			err = deleteInternal(db, e.Key)
+		case e.KeepExpiry:
+			err = putRecord(db, e.Key, e.Value)
+		default:
+			err = putInternal(db, e.Key, e.Value)
+		}
+		if err != nil {
+			return fmt.Errorf("apply batch: %w", err)
+		}
+	}
+	if err = syncBatch(db, entries); err != nil {
+		return fmt.Errorf("sync batch: %w", err)
+	}
+
+	err = db.fs.Remove(journalPath(db))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove journal: %w", err)
+	}
+	db.pendingBatch = false
+	return nil
+}
+
+// syncBatch syncs the records written by a batch and the shard directories
+// of its keys, so that the batch is durable before the journal is removed,
+// even if the writes aren't synchronous. The expiry log is synced too, since
+// the batch may have cleared the deadlines of its keys.
+func syncBatch(db *DB, entries []batchEntry) error {
+	// The paths are computed after the batch is applied, since it may have
+	// split some shards. Only the last entry of each key is applied in the
+	// end, so the entries are visited backwards.
+	dirs := make(map[string]struct{})
+	seen := make(map[string]struct{}, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		if _, ok := seen[string(e.Key)]; ok {
+			continue
+		}
+		seen[string(e.Key)] = struct{}{}
+
+		path, _ := keyPath(db, e.Key)
+		if !e.Delete && db.syncWrites {
+			// The record was synced with its directory when written.
+			continue
+		}
+		if !e.Delete {
+			if err := syncFile(db.fs, path); err != nil {
+				return fmt.Errorf("sync record: %w", err)
+			}
+		}
+		dirs[filepath.Dir(path)] = struct{}{}
+	}
+	for dir := range dirs {
+		if err := syncFile(db.fs, dir); err != nil {
+			return fmt.Errorf("sync shard: %w", err)
+		}
+	}
+	if db.syncWrites {
+		return nil
+	}
+
+	err := syncFile(db.fs, expiryPath(db))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("sync expiry log: %w", err)
+	}
+	return nil
+}
+
+// recoverBatch completes the batch left in the journal by a previous
+// process, if any. It returns true if a batch was recovered. A read-only
+// database can't complete the batch, so an error is returned instead.
+func recoverBatch(db *DB) (bool, error) {
+	_, err := fs.Stat(db.fs, journalPath(db))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("stat journal: %w", err)
+	}
+	if db.readOnly {
+		return false, fmt.Errorf("interrupted batch: %w", ErrReadOnly)
+	}
+
+	db.pendingBatch = true
+	if err = completePendingBatch(db); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func journalPath(db *DB) string {
+	return filepath.Join(db.path, metadataDirectory, journalFilename)
+}
+
+func saveJournal(db *DB, entries []batchEntry) error {
+	sealed := make([]batchEntry, len(entries))
+	for i, e := range entries {
+		sealed[i] = e
+		sealed[i].Key = db.keys.seal(e.Key)
+	}
+	data, err := gobEncode(sealed)
+	if err != nil {
+		return fmt.Errorf("marshal journal: %w", err)
+	}
+
+	// The journal must always reach persistent storage before the batch
+	// is applied.
+	writer := newAtomicWriter(db.fs, true)
+	return writer.WriteFile(journalPath(db), data, false)
+}
+
+func loadJournal(db *DB) ([]batchEntry, error) {
+	data, err := fs.ReadFile(db.fs, journalPath(db))
+	if err != nil {
+		return nil, fmt.Errorf("read file: %w", err)
+	}
+
+	var entries []batchEntry
+	dec := gob.NewDecoder(bytes.NewReader(data))
+	if err = dec.Decode(&entries); err != nil {
+		return nil, fmt.Errorf("unmarshal journal: %w", err)
+	}
+	for i := range entries {
+		if entries[i].Key, err = db.keys.open(entries[i].Key); err != nil {
+			return nil, fmt.Errorf("open key: %w", err)
+		}
+	}
+	return entries, nil
+}
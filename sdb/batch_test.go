@@ -0,0 +1,292 @@
+package sdb
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDB_WriteBatch(t *testing.T) {
+	t.Run("Key too large", func(t *testing.T) {
+		db := StartDatabase(t, OpenTestDB, nil)
+		defer db.Close()
+
+		key := []byte(strings.Repeat("k", MaxKeyLength+1))
+		err := db.WriteBatch([][]byte{key}, [][]byte{[]byte("value")})
+		if !errors.Is(err, ErrKeyTooLarge) {
+			t.Errorf("Expected ErrKeyTooLarge, but got %v", err)
+		}
+	})
+
+	t.Run("Closed database", func(t *testing.T) {
+		db := getClosedDB(t, nil)
+
+		err := db.WriteBatch([][]byte{[]byte("key")}, [][]byte{[]byte("value")})
+		if !errors.Is(err, ErrDatabaseClosed) {
+			t.Errorf("Expected ErrDatabaseClosed, but got %v", err)
+		}
+	})
+
+	t.Run("Context - Cancelled", func(t *testing.T) {
+		// Arrange
+		db := StartDatabase(t, OpenTestDB, map[string]string{"key-1": "value-1"})
+		defer db.Close()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		// Act
+		err := db.WriteBatchContext(ctx, [][]byte{[]byte("key-2")}, [][]byte{[]byte("value-2")})
+		_, errIf := db.WriteBatchIfContext(ctx, nil, nil, [][]byte{[]byte("key-1")}, [][]byte{nil})
+
+		// Assert
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, but got %v", err)
+		}
+		if !errors.Is(errIf, context.Canceled) {
+			t.Errorf("Expected context.Canceled, but got %v", errIf)
+		}
+		checkDatabase(t, db, map[string]string{"key-1": "value-1"})
+	})
+
+	t.Run("Splits shards", func(t *testing.T) {
+		db := StartDatabase(t, OpenTestDB, nil)
+		defer db.Close()
+
+		var keys, values [][]byte
+		expected := make(map[string]string)
+		for _, k := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+			keys = append(keys, []byte(k))
+			values = append(values, []byte("value-"+k))
+			expected[k] = "value-" + k
+		}
+
+		if err := db.WriteBatch(keys, values); err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		checkDatabase(t, db, expected)
+		CheckShardLayout(t, db, expected)
+		AssertItems(t, db, nil, Asc, []string{"a", "b", "c", "d", "e", "f", "g", "h"})
+	})
+
+	t.Run("Removes the journal", func(t *testing.T) {
+		db := StartDatabase(t, OpenTestDB, nil)
+		defer db.Close()
+
+		err := db.WriteBatch([][]byte{[]byte("key")}, [][]byte{[]byte("value")})
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		if _, err = os.Stat(journalPath(db)); !os.IsNotExist(err) {
+			t.Errorf("Expected journal to be removed, but got %v", err)
+		}
+	})
+}
+
+func TestDB_WriteBatch_Sync(t *testing.T) {
+	seed := map[string]string{"key-1": "value-1", "key-2": "value-2"}
+
+	t.Run("Syncs the batch before removing the journal", func(t *testing.T) {
+		// Arrange
+		db := StartDatabase(t, OpenTestDB, seed)
+		defer db.Close()
+
+		synced := make(map[string]bool)
+		var removed bool
+		db.fs = &mockFS{
+			openFunc: func(name string) (fs.File, error) {
+				if removed {
+					t.Errorf("Unexpected sync after the journal was removed")
+				}
+				synced[name] = true
+				return os.Open(name)
+			},
+			removeFunc: func(name string) error {
+				if name == journalPath(db) {
+					removed = true
+				}
+				return os.Remove(name)
+			},
+		}
+
+		// Act
+		err := db.WriteBatch(
+			[][]byte{[]byte("key-1"), []byte("key-3")},
+			[][]byte{nil, []byte("value-3")},
+		)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		deleted, _ := keyPath(db, []byte("key-1"))
+		written, _ := keyPath(db, []byte("key-3"))
+		for _, path := range []string{written, filepath.Dir(written), filepath.Dir(deleted)} {
+			if !synced[path] {
+				t.Errorf("Expected %s to be synced", path)
+			}
+		}
+		if !removed {
+			t.Errorf("Expected the journal to be removed")
+		}
+	})
+
+	t.Run("Sync error", func(t *testing.T) {
+		// Arrange
+		db := StartDatabase(t, OpenTestDB, seed)
+		defer db.Close()
+
+		written, _ := keyPath(db, []byte("key-3"))
+		db.fs = &mockFS{
+			openFunc: func(name string) (fs.File, error) {
+				if name == written {
+					return nil, TestError
+				}
+				return os.Open(name)
+			},
+		}
+
+		// Act
+		err := db.WriteBatch([][]byte{[]byte("key-3")}, [][]byte{[]byte("value-3")})
+
+		// Assert
+		if !errors.Is(err, TestError) {
+			t.Errorf("Expected TestError, but got %v", err)
+		}
+		if !db.pendingBatch {
+			t.Errorf("Expected a pending batch")
+		}
+		if _, err = os.Stat(journalPath(db)); err != nil {
+			t.Errorf("Expected the journal to be kept, but got %v", err)
+		}
+	})
+}
+
+func TestDB_WriteBatch_Recovery(t *testing.T) {
+	seed := map[string]string{"key-1": "value-1", "key-2": "value-2"}
+
+	t.Run("Completes an interrupted batch", func(t *testing.T) {
+		// Arrange: Simulate a crash after saving the journal, with the
+		// batch only partially applied.
+		db := StartDatabase(t, OpenTestDB, seed)
+		entries := []batchEntry{
+			{Key: []byte("key-1"), Delete: true},
+			{Key: []byte("key-3"), Value: []byte("value-3")},
+			{Key: []byte("key-4"), Value: []byte("value-4")},
+		}
+		if err := saveJournal(db, entries); err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		if err := db.Put([]byte("key-3"), []byte("value-3")); err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		db.Close()
+
+		// Act
+		db, err := ReopenTestDB()
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		defer db.Close()
+
+		// Assert
+		checkDatabase(t, db, map[string]string{
+			"key-2": "value-2", "key-3": "value-3", "key-4": "value-4",
+		})
+		if _, err = os.Stat(journalPath(db)); !os.IsNotExist(err) {
+			t.Errorf("Expected journal to be removed, but got %v", err)
+		}
+	})
+
+	t.Run("Corrupted journal", func(t *testing.T) {
+		db := StartDatabase(t, OpenTestDB, seed)
+		err := os.WriteFile(journalPath(db), []byte("corrupted"), 0600)
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		db.Close()
+
+		_, err = ReopenTestDB()
+		if err == nil {
+			t.Errorf("Expected error, but got nil")
+		}
+	})
+
+	t.Run("Completes a failed batch before the next mutation", func(t *testing.T) {
+		// Arrange
+		db := StartDatabase(t, OpenTestDB, seed)
+		defer db.Close()
+
+		failing := db.keys.encode([]byte("key-4"))
+		db.fs = &mockFS{
+			openFileFunc: func(name string, flag int, perm fs.FileMode) (fs.File, error) {
+				if strings.HasSuffix(name, failing) {
+					return nil, TestError
+				}
+				return os.OpenFile(name, flag, perm)
+			},
+		}
+
+		// Act
+		err := db.WriteBatch(
+			[][]byte{[]byte("key-3"), []byte("key-4")},
+			[][]byte{[]byte("value-3"), []byte("value-4")},
+		)
+
+		// Assert
+		if !errors.Is(err, TestError) {
+			t.Fatalf("Expected TestError, but got %v", err)
+		}
+		if !db.pendingBatch {
+			t.Errorf("Expected a pending batch")
+		}
+
+		// The next mutation fails while the batch can't be completed.
+		err = db.Delete([]byte("key-3"))
+		if !errors.Is(err, TestError) {
+			t.Errorf("Expected TestError, but got %v", err)
+		}
+
+		// And succeeds after the batch is completed.
+		db.fs = &osFS{}
+		if err = db.Delete([]byte("key-1")); err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		checkDatabase(t, db, map[string]string{
+			"key-2": "value-2", "key-3": "value-3", "key-4": "value-4",
+		})
+		if db.pendingBatch {
+			t.Errorf("Expected no pending batch")
+		}
+	})
+
+	t.Run("Journal write error", func(t *testing.T) {
+		// Arrange
+		db := StartDatabase(t, OpenTestDB, seed)
+		defer db.Close()
+
+		db.fs = &mockFS{
+			openFileFunc: func(name string, flag int, perm fs.FileMode) (fs.File, error) {
+				if strings.HasSuffix(name, journalFilename) {
+					return nil, TestError
+				}
+				return os.OpenFile(name, flag, perm)
+			},
+		}
+
+		// Act
+		err := db.WriteBatch([][]byte{[]byte("key-3")}, [][]byte{[]byte("value-3")})
+
+		// Assert
+		if !errors.Is(err, TestError) {
+			t.Errorf("Expected TestError, but got %v", err)
+		}
+		if db.pendingBatch {
+			t.Errorf("Expected no pending batch")
+		}
+		checkDatabase(t, db, seed)
+	})
+}
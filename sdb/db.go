@@ -18,6 +18,10 @@
 // hitting the maximum filename length or storing keys with forbidden
 // characters.
 //
+// The keys can also be encrypted before being encoded, with the
+// WithKeyEncryption option, so they can't be read by listing the data
+// directory.
+//
 // # Cache
 //
 // The sdb database uses a memory-based cache to speed up operations. By
@@ -36,6 +40,10 @@
 // This ensures that the database's methods are always performed with one
 // atomic operation, significantly simplifying the recovery process.
 //
+// Several records can be written atomically with DB.WriteBatch. The batch is
+// saved to a journal file before its records are written, and a batch
+// interrupted by a crash is completed when the database is opened again.
+//
 // Currently, the only data that can become inconsistent is the count of stored
 // records, but if this happens, it is detected and corrected at the DB
 // initialization.
@@ -54,6 +62,36 @@
 // For the highest level of durability, the WithSynchronousWrites option makes
 // the database synchronize data to persistent storage on each write.
 //
+// # Read-only Mode
+//
+// The WithReadOnly option opens an existing database without ever writing to
+// it, so it can be inspected by other processes or from a read-only mount.
+// The mutations return ErrReadOnly, and a count of records left inconsistent
+// by a crash is corrected in memory only.
+//
+// # Locking
+//
+// Open locks the database directory, so that it is written by only one
+// process: a second Open returns ErrLocked, while the read-only databases
+// share the lock. With WithMultiProcess, many processes can open and write
+// the same database, and each one reloads its state when another process
+// changes it. The locks use flock and are a no-op on the other platforms.
+//
+// # Namespaces
+//
+// DB.Namespace returns a database holding a separate keyspace, stored in a
+// subdirectory of the database path. The namespaces are opened with the
+// options of the database and closed with it.
+//
+// # Backups
+//
+// DB.Snapshot copies the database to another directory with hard links to
+// the record files, blocking the writes only while the links are created.
+// The records shared with a snapshot are always replaced, instead of written
+// in place, so the snapshot keeps a consistent view of the database. DB.Backup
+// writes a snapshot as a tar archive, which is restored with Restore. The
+// namespaces are included, each one snapshotted under its own lock.
+//
 // # Notes
 //
 // [1] https://datatracker.ietf.org/doc/html/rfc4648#section-7
@@ -66,12 +104,16 @@
 package sdb
 
 import (
+	"bytes"
+	"context"
 	"encoding/base32"
 	"errors"
 	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 	"unsafe"
@@ -118,6 +160,10 @@ var (
 
 	// ErrDatabaseClosed is returned when the database is closed.
 	ErrDatabaseClosed = errors.New("database is closed")
+
+	// ErrReadOnly is returned by the mutations of a database opened with
+	// WithReadOnly.
+	ErrReadOnly = errors.New("database is read-only")
 )
 
 // Yield is a function called when iterating over key-value pairs in the
@@ -137,14 +183,39 @@ type DB struct {
 	shards        []shard
 	cache         internal.Cache[cacheEntry]
 	fs            fileSystem
+	keys          keyScheme
 	closed        bool
 
-	// Controls the background sync loop.
+	// Set when a batch was saved to the journal, but not completely
+	// applied to the database records yet.
+	pendingBatch bool
+
+	// Deadlines of the records written with PutWithTTL, in Unix
+	// nanoseconds, by key.
+	expiry         map[string]int64
+	expiryInterval time.Duration
+
+	// The options given to Open, used to open the namespaces, and the open
+	// namespaces by name. The namespaces are opened while holding nsMu, not
+	// mu, so that the database can be used in the meantime.
+	options    []Option
+	namespaces map[string]*DB
+	nsMu       sync.Mutex
+
+	// Controls the background sync and expiry loops.
 	done chan struct{}
 	wg   sync.WaitGroup
 
 	maxFilesPerShard int64
+	cacheSize        int
 	syncWrites       bool
+	readOnly         bool
+	multiProcess     bool
+
+	// Lock of the database directory, held while the database is open, and
+	// lock of the operations, if the database is shared by many processes.
+	dirLock  fs.File
+	procLock *processLock
 
 	// autoSync enables the background sync loop. Can be removed if a WAL
 	// is adopted for consistency, since the WAL would handle the sync
@@ -165,35 +236,57 @@ func Open(path string, options ...Option) (*DB, error) {
 		path:             path,
 		metadata:         makeMetadata(),
 		shards:           []shard{{maxKey: sentinelDir}},
-		cache:            internal.NewCache[cacheEntry](-1),
+		cache:            internal.NewCache[cacheEntry](DefaultCacheSize),
 		fs:               &osFS{},
 		done:             make(chan struct{}),
 		maxFilesPerShard: defaultMaxFilesPerShard,
+		cacheSize:        DefaultCacheSize,
 		syncWrites:       false,
 		autoSync:         true,
 		syncInterval:     metadataSyncInterval,
+		expiry:           make(map[string]int64),
+		expiryInterval:   defaultExpiryInterval,
+		options:          options,
+		namespaces:       make(map[string]*DB),
 	}
 
 	// Apply options.
 	for _, option := range options {
 		option(&db)
 	}
+	if err := db.keys.init(); err != nil {
+		return nil, fmt.Errorf("key encryption: %w", err)
+	}
 
 	if err := initializeDatabase(&db); err != nil {
+		_ = db.unlock()
 		return nil, fmt.Errorf("initialize database: %w", err)
 	}
 
-	// Start the background loop if autoSync is enabled.
-	if db.autoSync {
+	// A read-only database is never written, so it doesn't need the
+	// background loops.
+	if db.readOnly {
+		return &db, nil
+	}
+
+	// Start the background loop if autoSync is enabled. A database shared
+	// by many processes is synced after each write.
+	if db.autoSync && !db.multiProcess {
 		db.wg.Add(1)
 		go syncMetadata(&db)
 	}
 
+	// Start the background loop that deletes the expired records.
+	if db.expiryInterval > 0 {
+		db.wg.Add(1)
+		go reapExpired(&db)
+	}
+
 	return &db, nil
 }
 
-// Close synchronizes and closes the database. Users must ensure no pending
-// operations are in progress before calling Close().
+// Close synchronizes and closes the database, and its open namespaces. Users
+// must ensure no pending operations are in progress before calling Close().
 //
 // Example:
 //
@@ -213,24 +306,40 @@ func Open(path string, options ...Option) (*DB, error) {
 //	db.Close() // Safe to close now
 func (db *DB) Close() error {
 	db.mu.Lock()
-	defer db.mu.Unlock()
-
 	if db.closed {
+		db.mu.Unlock()
 		return nil
 	}
 	db.closed = true
 
-	// Signal the background goroutine to stop.
+	// Signal the background goroutines to stop. They are waited for
+	// without holding the lock, since they might be waiting for it.
 	close(db.done)
+	db.mu.Unlock()
 	db.wg.Wait()
 
-	// Final sync.
-	return syncInternal(db)
+	db.mu.Lock()
+	nsErr := db.closeNamespaces()
+
+	// Final sync. The metadata of a database shared by many processes is
+	// already synced, and it might have been changed by another process.
+	var syncErr error
+	if !db.readOnly && !db.multiProcess {
+		syncErr = syncInternal(db)
+	}
+	db.mu.Unlock()
+
+	return errors.Join(nsErr, syncErr, db.unlock())
 }
 
 // Len returns the number of items in the database. If an error occurs, it
 // returns -1.
 func (db *DB) Len() int64 {
+	if err := db.lockProcess(false); err != nil {
+		return -1
+	}
+	defer db.unlockProcess(false)
+
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
@@ -238,29 +347,55 @@ func (db *DB) Len() int64 {
 		return -1
 	}
 
-	return int64(db.metadata.TotalEntries)
+	return int64(db.metadata.TotalEntries) - int64(countExpired(db))
 }
 
-// Sync synchronizes the database to persistent storage.
+// Sync synchronizes the database to persistent storage. It does nothing if
+// the database is read-only.
 func (db *DB) Sync() error {
+	if err := db.lockProcess(true); err != nil {
+		return err
+	}
+	defer db.unlockProcess(true)
+
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
 	if db.closed {
 		return ErrDatabaseClosed
 	}
+	if db.readOnly {
+		return nil
+	}
 
 	return syncInternal(db)
 }
 
 // Has reports whether a key exists in the database.
 func (db *DB) Has(key []byte) (bool, error) {
+	return db.HasContext(context.Background(), key)
+}
+
+// HasContext works like [DB.Has], but returns the context error if ctx is
+// done once the locks are acquired.
+func (db *DB) HasContext(ctx context.Context, key []byte) (bool, error) {
+	if err := db.lockProcess(false); err != nil {
+		return false, err
+	}
+	defer db.unlockProcess(false)
+
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
 	if db.closed {
 		return false, ErrDatabaseClosed
 	}
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	if isExpired(db, key) {
+		return false, nil
+	}
 
 	_, ok := cacheGet(db, key)
 	if ok {
@@ -280,12 +415,36 @@ func (db *DB) Has(key []byte) (bool, error) {
 // Get retrieves the value associated with a key from the database. If the key
 // is not found, it returns nil.
 func (db *DB) Get(key []byte) ([]byte, error) {
+	return db.GetContext(context.Background(), key)
+}
+
+// GetContext works like [DB.Get], but returns the context error if ctx is
+// done once the locks are acquired.
+func (db *DB) GetContext(ctx context.Context, key []byte) ([]byte, error) {
+	if err := db.lockProcess(false); err != nil {
+		return nil, err
+	}
+	defer db.unlockProcess(false)
+
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
 	if db.closed {
 		return nil, ErrDatabaseClosed
 	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return getInternal(db, key)
+}
+
+// getInternal retrieves the value associated with a key from the database.
+// The caller must hold the lock.
+func getInternal(db *DB, key []byte) ([]byte, error) {
+	if isExpired(db, key) {
+		return nil, nil
+	}
 
 	v, ok := cacheGet(db, key)
 	if ok {
@@ -308,12 +467,25 @@ func (db *DB) Get(key []byte) ([]byte, error) {
 // Put adds a key-value pair to the database. If the key already exists, it
 // overwrites the existing value.
 //
-// It returns an error if the key is greater than [MaxKeyLength].
+// It returns an error if the key is greater than [MaxKeyLength], or
+// [MaxOrderedKeyLength] if the keys are encrypted with [OrderedKeys].
 func (db *DB) Put(key, value []byte) error {
+	return db.PutContext(context.Background(), key, value)
+}
+
+// PutContext works like [DB.Put], but returns the context error, without
+// writing the value, if ctx is done once the locks are acquired. This stops
+// the writes that waited for a long iteration or batch to finish.
+func (db *DB) PutContext(ctx context.Context, key, value []byte) error {
+	if err := db.lockProcess(true); err != nil {
+		return err
+	}
+	defer db.unlockProcess(true)
+
 	if err := prepareForMutation(db); err != nil {
 		return fmt.Errorf("prepare for mutation: %w", err)
 	}
-	if len(key) > MaxKeyLength {
+	if len(key) > db.keys.maxKeyLength() {
 		return ErrKeyTooLarge
 	}
 
@@ -323,7 +495,28 @@ func (db *DB) Put(key, value []byte) error {
 	if db.closed {
 		return ErrDatabaseClosed
 	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := completePendingBatch(db); err != nil {
+		return fmt.Errorf("complete pending batch: %w", err)
+	}
 
+	return putInternal(db, key, value)
+}
+
+// putInternal adds a key-value pair to the database, without expiration. The
+// caller must hold the write lock.
+func putInternal(db *DB, key, value []byte) error {
+	if err := putRecord(db, key, value); err != nil {
+		return err
+	}
+	return clearExpiry(db, key)
+}
+
+// putRecord writes the record of a key-value pair. The caller must hold the
+// write lock.
+func putRecord(db *DB, key, value []byte) error {
 	path, shardID := keyPath(db, key)
 	sh := &db.shards[shardID]
 
@@ -350,7 +543,7 @@ func (db *DB) Put(key, value []byte) error {
 }
 
 func putPath(db *DB, path string, value []byte) (updated bool, err error) {
-	_, err = fs.Stat(db.fs, path)
+	fi, err := fs.Stat(db.fs, path)
 	if err != nil && !os.IsNotExist(err) {
 		return false, fmt.Errorf("stat: %w", err)
 	}
@@ -359,12 +552,28 @@ func putPath(db *DB, path string, value []byte) (updated bool, err error) {
 	}
 
 	writer := newAtomicWriter(db.fs, db.syncWrites)
+	if updated && isLinked(fi) {
+		// The file is shared with a snapshot, so it must be replaced
+		// instead of written in place.
+		writer.diskSectorSize = -1
+	}
 	err = writer.WriteFile(path, value, !updated)
 	return updated, err
 }
 
 // Delete removes a key-value pair from the database.
 func (db *DB) Delete(key []byte) error {
+	return db.DeleteContext(context.Background(), key)
+}
+
+// DeleteContext works like [DB.Delete], but returns the context error,
+// without removing the key, if ctx is done once the locks are acquired.
+func (db *DB) DeleteContext(ctx context.Context, key []byte) error {
+	if err := db.lockProcess(true); err != nil {
+		return err
+	}
+	defer db.unlockProcess(true)
+
 	if err := prepareForMutation(db); err != nil {
 		return fmt.Errorf("prepare for mutation: %w", err)
 	}
@@ -374,7 +583,28 @@ func (db *DB) Delete(key []byte) error {
 	if db.closed {
 		return ErrDatabaseClosed
 	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := completePendingBatch(db); err != nil {
+		return fmt.Errorf("complete pending batch: %w", err)
+	}
+
+	return deleteInternal(db, key)
+}
 
+// deleteInternal removes a key-value pair from the database. The caller must
+// hold the write lock.
+func deleteInternal(db *DB, key []byte) error {
+	if err := deleteRecord(db, key); err != nil {
+		return err
+	}
+	return clearExpiry(db, key)
+}
+
+// deleteRecord removes the record of a key-value pair. The caller must hold
+// the write lock.
+func deleteRecord(db *DB, key []byte) error {
 	path, shardID := keyPath(db, key)
 	sh := &db.shards[shardID]
 
@@ -420,6 +650,132 @@ func (db *DB) Delete(key []byte) error {
 // The user-provided fn(k, v) must not modify the database within the same
 // goroutine as the iteration, as this would cause a deadlock.
 func (db *DB) Items(start []byte, order int, fn Yield) error {
+	return db.ItemsRangeContext(context.Background(), start, nil, order, fn)
+}
+
+// ItemsContext works like [DB.Items], but the context is checked before each
+// shard directory and each record is read, and the iteration stops with an
+// error wrapping the context error once ctx is done.
+func (db *DB) ItemsContext(ctx context.Context, start []byte, order int, fn Yield) error {
+	return db.ItemsRangeContext(ctx, start, nil, order, fn)
+}
+
+// ItemsRange works like [DB.Items], but stops the iteration before the first
+// key that is beyond the end bound. The end key is exclusive:
+//
+//	Asc  – yields keys start <= k < end
+//	Desc – yields keys end < k <= start
+//
+// If end is nil or empty, the iteration is unbounded, as in [DB.Items].
+//
+// Since the shards are sorted, the iteration stops as soon as the end bound
+// is crossed, without reading the remaining shard directories.
+func (db *DB) ItemsRange(start, end []byte, order int, fn Yield) error {
+	return db.ItemsRangeContext(context.Background(), start, end, order, fn)
+}
+
+// ItemsRangeContext works like [DB.ItemsRange], but stops the iteration once
+// ctx is done, like [DB.ItemsContext].
+func (db *DB) ItemsRangeContext(
+	ctx context.Context,
+	start, end []byte,
+	order int,
+	fn Yield,
+) error {
+	if !db.keys.ordered() {
+		return db.scanSorted(ctx, order, func(key []byte) bool {
+			return keyInRange(key, start, end, order)
+		}, fn)
+	}
+
+	encEnd := db.keys.encode(end)
+	pastEnd := func(name string) bool {
+		if encEnd == "" {
+			return false
+		}
+		if order == Asc {
+			return name >= encEnd
+		}
+		return name <= encEnd
+	}
+	return db.scan(ctx, db.keys.encode(start), order, pastEnd, fn)
+}
+
+// ItemsPrefix works like [DB.Items], but only yields the keys that start with
+// the given prefix, in the order specified by the order parameter. If prefix
+// is nil or empty, all keys are yielded.
+//
+// The first shard is located from the encoded prefix, and the iteration stops
+// as soon as the record filenames no longer match it, without reading the
+// remaining shard directories.
+func (db *DB) ItemsPrefix(prefix []byte, order int, fn Yield) error {
+	return db.ItemsPrefixContext(context.Background(), prefix, order, fn)
+}
+
+// ItemsPrefixContext works like [DB.ItemsPrefix], but stops the iteration
+// once ctx is done, like [DB.ItemsContext].
+func (db *DB) ItemsPrefixContext(ctx context.Context, prefix []byte, order int, fn Yield) error {
+	if len(prefix) == 0 {
+		return db.ItemsRangeContext(ctx, nil, nil, order, fn)
+	}
+	if !db.keys.ordered() {
+		return db.scanSorted(ctx, order, func(key []byte) bool {
+			return bytes.HasPrefix(key, prefix)
+		}, fn)
+	}
+
+	// All filenames of keys with the prefix are in the interval [lo, hi].
+	lo, hi := prefixBounds(prefix)
+	outside := func(name string) bool {
+		return name < lo || name > hi
+	}
+	if db.keys.mode == OrderedKeys {
+		// The encrypted keys with the prefix are in the interval
+		// [prefix, end), where end is the successor of the prefix. The
+		// record of end itself, if any, is skipped below.
+		end := prefixEnd(prefix)
+		lo, hi = db.keys.encode(prefix), db.keys.encode(end)
+		outside = func(name string) bool {
+			return name < lo || (end != nil && name > hi)
+		}
+	}
+	start := lo
+	if order != Asc {
+		start = hi
+	}
+
+	return db.scan(ctx, start, order, outside, func(k, v []byte) (bool, error) {
+		// Plain filenames in the interval always have the prefix, but
+		// check the decoded key to be safe.
+		if !bytes.HasPrefix(k, prefix) {
+			return true, nil
+		}
+		return fn(k, v)
+	})
+}
+
+// scan iterates over the record files in the order specified by the order
+// parameter, starting from the encoded start key (inclusive), and calls fn
+// for each record. The iteration stops at the first filename for which
+// stop returns true.
+//
+// Once the iteration has moved past the first shard, the stop function is
+// also used with the shard bounds, to stop without reading shard directories
+// that cannot contain keys in the iteration.
+//
+// The context is checked before each shard directory and record is read.
+func (db *DB) scan(
+	ctx context.Context,
+	encStart string,
+	order int,
+	stop func(name string) bool,
+	fn Yield,
+) error {
+	if err := db.lockProcess(false); err != nil {
+		return err
+	}
+	defer db.unlockProcess(false)
+
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
@@ -429,29 +785,44 @@ func (db *DB) Items(start []byte, order int, fn Yield) error {
 
 	n := len(db.shards)
 	asc := order == Asc
-	encStart := encodeKey(start)
 
 	// Pick initial shard (Use the db.shards slice to prune the
 	// search space).
 	idx := 0
-	if len(start) != 0 {
+	if encStart != "" {
 		idx = db.shardForKey(encStart)
 	} else if !asc {
 		idx = n - 1
 	}
 
 	step := 1
-	stop := n
+	end := n
 	if !asc {
 		step = -1
-		stop = -1
+		end = -1
 	}
 
-	for k := idx; k != stop; k += step {
+	for k := idx; k != end; k += step {
+		// Keys in shard k are greater than the upper bound of shard k-1
+		// and lower or equal to the upper bound of shard k.
+		if asc && k > idx && stop(db.shards[k-1].maxKey) {
+			return nil
+		}
+		if !asc && k < idx && stop(db.shards[k].maxKey) {
+			return nil
+		}
+
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("scan: %w", err)
+		}
+
 		sh := db.shards[k]
 		dir := filepath.Join(db.path, dataDirectory, sh.maxKey)
 
-		keep, err := streamDir(db.fs, dir, encStart, order, func(filename string) (bool, error) {
+		keep, err := streamDir(db.fs, dir, encStart, order, stop, func(filename string) (bool, error) {
+			if err := ctx.Err(); err != nil {
+				return false, fmt.Errorf("scan: %w", err)
+			}
 			return handleFileWithLock(db, dir, filename, fn)
 		})
 		if err != nil {
@@ -465,11 +836,82 @@ func (db *DB) Items(start []byte, order int, fn Yield) error {
 	return nil
 }
 
+// scanSorted works like scan, for the keys whose filenames don't sort like the
+// keys. The keys of all the records for which include returns true are read
+// and sorted before fn is called for the first one.
+func (db *DB) scanSorted(
+	ctx context.Context,
+	order int,
+	include func(key []byte) bool,
+	fn Yield,
+) error {
+	if err := db.lockProcess(false); err != nil {
+		return err
+	}
+	defer db.unlockProcess(false)
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.closed {
+		return ErrDatabaseClosed
+	}
+
+	type record struct {
+		key  []byte
+		path string
+	}
+	var records []record
+	for i := range db.shards {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("scan: %w", err)
+		}
+		dir := db.shardPath(i)
+		names, err := readdirnames(db.fs, dir)
+		if err != nil {
+			return fmt.Errorf("read shard dir: %w", err)
+		}
+		for _, name := range names {
+			key, err := db.keys.decode(name)
+			if err != nil {
+				return fmt.Errorf("decode key: %w", err)
+			}
+			if include(key) {
+				records = append(records, record{key, filepath.Join(dir, name)})
+			}
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return bytes.Compare(records[i].key, records[j].key) == -order
+	})
+
+	for _, r := range records {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("scan: %w", err)
+		}
+		keep, err := handleRecordWithLock(db, r.key, r.path, fn)
+		if err != nil {
+			return fmt.Errorf("fn: %w", err)
+		}
+		if !keep {
+			return nil
+		}
+	}
+	return nil
+}
+
 func handleFileWithLock(db *DB, dir, name string, fn Yield) (bool, error) {
-	key, err := decodeKey(name)
+	key, err := db.keys.decode(name)
 	if err != nil {
 		return false, fmt.Errorf("decode key: %w", err)
 	}
+	return handleRecordWithLock(db, key, filepath.Join(dir, name), fn)
+}
+
+func handleRecordWithLock(db *DB, key []byte, path string, fn Yield) (bool, error) {
+	if isExpired(db, key) {
+		return true, nil
+	}
 
 	// Use the cache (but do not cache aside while iterating) because that would
 	// result in a lot of cache turnover with keys that might not be needed to be
@@ -480,7 +922,7 @@ func handleFileWithLock(db *DB, dir, name string, fn Yield) (bool, error) {
 	}
 
 	// Read from the disk.
-	v, err := fs.ReadFile(db.fs, filepath.Join(dir, name))
+	v, err := fs.ReadFile(db.fs, path)
 	if errors.Is(err, os.ErrNotExist) {
 		// Deleted while iterating? Ignore.
 		return true, nil
@@ -495,18 +937,34 @@ func handleFileWithLock(db *DB, dir, name string, fn Yield) (bool, error) {
 // Helpers
 
 func keyPath(db *DB, key []byte) (path string, shardID int) {
-	base := encodeKey(key)
+	base := db.keys.encode(key)
 	i := db.shardForKey(base)
 	dir := db.shardPath(i)
 	return filepath.Join(dir, base), i
 }
 
-func encodeKey(key []byte) string {
-	return base32.HexEncoding.EncodeToString(key)
-}
+// prefixBounds returns the interval [lo, hi] of the filenames of all keys
+// starting with prefix.
+//
+// The base32hex encoding of the complete 5-bit groups of the prefix is a
+// prefix of the filenames, while the remaining bits (if any) fix only the
+// high bits of the next character, which can range from those bits followed
+// by zeros to those bits followed by ones. Since "~" is greater than any
+// character of the base32hex alphabet, it is used to close the interval.
+func prefixBounds(prefix []byte) (lo, hi string) {
+	const alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUV"
+
+	enc := base32.HexEncoding.WithPadding(base32.NoPadding).EncodeToString(prefix)
+	full := len(prefix) * 8 / 5
+	rem := len(prefix) * 8 % 5
+	if rem == 0 {
+		return enc, enc + "~"
+	}
 
-func decodeKey(key string) ([]byte, error) {
-	return base32.HexEncoding.DecodeString(key)
+	// The unpadded encoding fills the remaining low bits with zeros.
+	v := strings.IndexByte(alphabet, enc[full])
+	vMax := v | (1<<(5-rem) - 1)
+	return enc, enc[:full] + string(alphabet[vMax]) + "~"
 }
 
 func cacheGet(db *DB, key []byte) (cacheEntry, bool) {
@@ -523,7 +981,13 @@ func cacheGet(db *DB, key []byte) (cacheEntry, bool) {
 // has pending state to be synced to persistent storage.
 //
 // The I/O done by this function should be amortized between many mutations.
+//
+// It returns ErrReadOnly if the database is read-only, so it must be called
+// before any mutation.
 func prepareForMutation(db *DB) error {
+	if db.readOnly {
+		return ErrReadOnly
+	}
 	ok := db.mu.TryLock()
 	if !ok {
 		return nil
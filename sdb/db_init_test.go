@@ -116,6 +116,7 @@ func TestDB_Init(t *testing.T) {
 		CheckInitialization(t, db)
 
 		// Reopen without closing
+		crashTestDB(db)
 		db, err := ReopenTestDB()
 		if err != nil {
 			t.Errorf("Expected no error, but got %v", err)
@@ -150,6 +151,7 @@ func TestDB_Init(t *testing.T) {
 		}
 
 		// Reopen without closing
+		crashTestDB(db)
 		open := NewOpenFunc(false, withFileSystem(fsys))
 
 		db, err := open()
@@ -1,6 +1,7 @@
 package sdb
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math/rand/v2"
@@ -35,6 +36,70 @@ type DBTests struct {
 	// Informs that the database supports iterating in
 	// descending order and enable additional tests.
 	SupportsReverseIteration bool
+
+	// Informs that the database supports bounded iteration
+	// with an ItemsRange method and enable additional tests.
+	SupportsRange bool
+
+	// Informs that the database supports atomic batches
+	// with a WriteBatch method and enable additional tests.
+	SupportsBatch bool
+
+	// Informs that the database supports conditional batches
+	// with a WriteBatchIf method and enable additional tests.
+	SupportsTx bool
+
+	// Informs that the database supports keys that expire
+	// with a PutWithTTL method and enable additional tests.
+	SupportsTTL bool
+
+	// Informs that the database supports cancelling operations
+	// with the Context methods and enable additional tests.
+	SupportsContext bool
+}
+
+// rangeDB is implemented by databases that support bounded iteration.
+type rangeDB interface {
+	ItemsRange(
+		start, end []byte,
+		order int,
+		fn func(key, value []byte) (bool, error),
+	) error
+}
+
+// batchDB is implemented by databases that support atomic batches.
+type batchDB interface {
+	WriteBatch(keys, values [][]byte) error
+}
+
+// txDB is implemented by databases that support conditional batches.
+type txDB interface {
+	WriteBatchIf(checkKeys, checkValues, keys, values [][]byte) (bool, error)
+}
+
+// ttlDB is implemented by databases that support keys that expire.
+type ttlDB interface {
+	PutWithTTL(key, value []byte, ttl time.Duration) error
+}
+
+// contextDB is implemented by databases that support cancelling operations.
+type contextDB interface {
+	HasContext(ctx context.Context, key []byte) (bool, error)
+	GetContext(ctx context.Context, key []byte) ([]byte, error)
+	PutContext(ctx context.Context, key, value []byte) error
+	DeleteContext(ctx context.Context, key []byte) error
+	ItemsRangeContext(
+		ctx context.Context,
+		start, end []byte,
+		order int,
+		fn func(key, value []byte) (bool, error),
+	) error
+	ItemsPrefixContext(
+		ctx context.Context,
+		prefix []byte,
+		order int,
+		fn func(key, value []byte) (bool, error),
+	) error
 }
 
 // NewDBTests creates a new instance of DBTests. It can be used to test
@@ -82,6 +147,23 @@ func (T *DBTests) TestAll(t *testing.T) {
 		T.SupportsReverseIteration {
 		T.TestItems_SeekReverse(t)
 	}
+	if T.SupportsRange {
+		T.TestItemsRange(t)
+	}
+	if T.SupportsBatch {
+		T.TestWriteBatch(t)
+	}
+	if T.SupportsTx {
+		T.TestWriteBatchIf(t)
+	}
+	if T.SupportsTTL {
+		T.TestPutWithTTL(t)
+	}
+	if T.SupportsContext {
+		T.TestContext(t)
+		T.TestItemsRangeContext(t)
+		T.TestItemsPrefixContext(t)
+	}
 
 	T.TestConcurrentOperations(t)
 	T.TestPersistence(t)
@@ -783,6 +865,594 @@ func (T *DBTests) TestItems_SeekReverse(t *testing.T) {
 	}
 }
 
+func (T *DBTests) TestItemsRange(t *testing.T) {
+	seed := map[string]string{
+		"key-01": "value-01", "key-02": "value-02", "key-03": "value-03",
+		"key-04": "value-04", "key-05": "value-05", "key-06": "value-06",
+		"key-07": "value-07", "key-08": "value-08", "key-09": "value-09",
+	}
+	tests := []struct {
+		name     string
+		start    string
+		end      string
+		order    int
+		expected []string
+	}{
+		{
+			name:     "Asc - unbounded",
+			order:    1,
+			expected: []string{"key-01", "key-02", "key-03", "key-04", "key-05", "key-06", "key-07", "key-08", "key-09"},
+		},
+		{
+			name:     "Asc - end only",
+			end:      "key-04",
+			order:    1,
+			expected: []string{"key-01", "key-02", "key-03"},
+		},
+		{
+			name:     "Asc - start and end",
+			start:    "key-03",
+			end:      "key-06",
+			order:    1,
+			expected: []string{"key-03", "key-04", "key-05"},
+		},
+		{
+			name:     "Asc - non-existing end",
+			start:    "key-07",
+			end:      "key-08z",
+			order:    1,
+			expected: []string{"key-07", "key-08"},
+		},
+		{
+			name:     "Asc - empty range",
+			start:    "key-05",
+			end:      "key-05",
+			order:    1,
+			expected: nil,
+		},
+		{
+			name:     "Desc - end only",
+			end:      "key-06",
+			order:    -1,
+			expected: []string{"key-09", "key-08", "key-07"},
+		},
+		{
+			name:     "Desc - start and end",
+			start:    "key-06",
+			end:      "key-03",
+			order:    -1,
+			expected: []string{"key-06", "key-05", "key-04"},
+		},
+		{
+			name:     "Desc - non-existing end",
+			start:    "key-03",
+			end:      "key-00",
+			order:    -1,
+			expected: []string{"key-03", "key-02", "key-01"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			db := StartDatabase(t, T.Open, seed)
+			defer db.Close()
+			rdb, ok := any(db).(rangeDB)
+			if !ok {
+				t.Fatalf("Expected db to implement ItemsRange")
+			}
+
+			// Act
+			var keys []string
+			err := rdb.ItemsRange(
+				[]byte(test.start),
+				[]byte(test.end),
+				test.order,
+				func(k, v []byte) (bool, error) {
+					if string(v) != seed[string(k)] {
+						t.Errorf("Expected value %v, but got %v",
+							seed[string(k)], string(v))
+					}
+					keys = append(keys, string(k))
+					return true, nil
+				},
+			)
+			if err != nil {
+				t.Errorf("Expected no error, but got %v", err)
+			}
+
+			// Assert
+			if !reflect.DeepEqual(keys, test.expected) {
+				t.Errorf("Expected %v, but got %v", test.expected, keys)
+			}
+		})
+	}
+}
+
+func (T *DBTests) TestWriteBatch(t *testing.T) {
+	seed := map[string]string{
+		"key-1": "value-1", "key-2": "value-2", "key-3": "value-3",
+	}
+	start := func(t *testing.T) (TDB, batchDB) {
+		t.Helper()
+		db := StartDatabase(t, T.Open, seed)
+		bdb, ok := any(db).(batchDB)
+		if !ok {
+			t.Fatalf("Expected db to implement WriteBatch")
+		}
+		return db, bdb
+	}
+
+	t.Run("Puts and deletes", func(t *testing.T) {
+		// Arrange
+		db, bdb := start(t)
+
+		// Act
+		err := bdb.WriteBatch(
+			[][]byte{[]byte("key-1"), []byte("key-2"), []byte("key-4"), []byte("key-5")},
+			[][]byte{[]byte("new-1"), nil, []byte("value-4"), nil},
+		)
+		if err != nil {
+			t.Errorf("Expected no error, but got %v", err)
+		}
+
+		// Assert
+		expected := map[string]string{
+			"key-1": "new-1", "key-3": "value-3", "key-4": "value-4",
+		}
+		checkDatabase(t, db, expected)
+		if ok, _ := db.Has([]byte("key-2")); ok {
+			t.Errorf("Expected key-2 to be deleted")
+		}
+
+		// Check persistence
+		if err = db.Close(); err != nil {
+			t.Errorf("Expected no error, but got %v", err)
+		}
+		db, err = T.Reopen()
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		defer db.Close()
+		checkDatabase(t, db, expected)
+	})
+
+	t.Run("Last value wins", func(t *testing.T) {
+		// Arrange
+		db, bdb := start(t)
+		defer db.Close()
+
+		// Act
+		err := bdb.WriteBatch(
+			[][]byte{[]byte("key-1"), []byte("key-1"), []byte("key-4"), []byte("key-4")},
+			[][]byte{nil, []byte("new-1"), []byte("value-4"), nil},
+		)
+		if err != nil {
+			t.Errorf("Expected no error, but got %v", err)
+		}
+
+		// Assert
+		checkDatabase(t, db, map[string]string{
+			"key-1": "new-1", "key-2": "value-2", "key-3": "value-3",
+		})
+	})
+
+	t.Run("Empty value", func(t *testing.T) {
+		// Arrange
+		db, bdb := start(t)
+		defer db.Close()
+
+		// Act
+		err := bdb.WriteBatch([][]byte{[]byte("key-4")}, [][]byte{{}})
+		if err != nil {
+			t.Errorf("Expected no error, but got %v", err)
+		}
+
+		// Assert
+		ok, err := db.Has([]byte("key-4"))
+		if err != nil {
+			t.Errorf("Expected no error, but got %v", err)
+		}
+		if !ok {
+			t.Errorf("Expected key-4 to exist")
+		}
+		if db.Len() != int64(len(seed)+1) {
+			t.Errorf("Expected len to be %v, but got %v", len(seed)+1, db.Len())
+		}
+	})
+
+	t.Run("Empty batch", func(t *testing.T) {
+		db, bdb := start(t)
+		defer db.Close()
+
+		if err := bdb.WriteBatch(nil, nil); err != nil {
+			t.Errorf("Expected no error, but got %v", err)
+		}
+		checkDatabase(t, db, seed)
+	})
+
+	t.Run("Mismatched lengths", func(t *testing.T) {
+		db, bdb := start(t)
+		defer db.Close()
+
+		err := bdb.WriteBatch([][]byte{[]byte("key-4")}, nil)
+		if err == nil {
+			t.Errorf("Expected error, but got nil")
+		}
+		checkDatabase(t, db, seed)
+	})
+}
+
+func (T *DBTests) TestWriteBatchIf(t *testing.T) {
+	seed := map[string]string{
+		"key-1": "value-1", "key-2": "value-2", "key-3": "value-3",
+	}
+	start := func(t *testing.T) (TDB, txDB) {
+		t.Helper()
+		db := StartDatabase(t, T.Open, seed)
+		tdb, ok := any(db).(txDB)
+		if !ok {
+			t.Fatalf("Expected db to implement WriteBatchIf")
+		}
+		return db, tdb
+	}
+	keys := [][]byte{[]byte("key-1"), []byte("key-2"), []byte("key-4")}
+	values := [][]byte{[]byte("new-1"), nil, []byte("value-4")}
+
+	t.Run("Matching values", func(t *testing.T) {
+		// Arrange
+		db, tdb := start(t)
+		defer db.Close()
+
+		// Act
+		ok, err := tdb.WriteBatchIf(
+			[][]byte{[]byte("key-1"), []byte("key-4")},
+			[][]byte{[]byte("value-1"), nil},
+			keys, values,
+		)
+
+		// Assert
+		if err != nil {
+			t.Errorf("Expected no error, but got %v", err)
+		}
+		if !ok {
+			t.Errorf("Expected the batch to be written")
+		}
+		checkDatabase(t, db, map[string]string{
+			"key-1": "new-1", "key-3": "value-3", "key-4": "value-4",
+		})
+	})
+
+	tests := []struct {
+		name        string
+		checkKeys   [][]byte
+		checkValues [][]byte
+	}{
+		{
+			name:        "Different value",
+			checkKeys:   [][]byte{[]byte("key-1"), []byte("key-2")},
+			checkValues: [][]byte{[]byte("value-1"), []byte("other")},
+		},
+		{
+			name:        "Expected missing key",
+			checkKeys:   [][]byte{[]byte("key-3")},
+			checkValues: [][]byte{nil},
+		},
+		{
+			name:        "Expected existing key",
+			checkKeys:   [][]byte{[]byte("key-5")},
+			checkValues: [][]byte{[]byte("value-5")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			db, tdb := start(t)
+			defer db.Close()
+
+			// Act
+			ok, err := tdb.WriteBatchIf(tt.checkKeys, tt.checkValues, keys, values)
+
+			// Assert
+			if err != nil {
+				t.Errorf("Expected no error, but got %v", err)
+			}
+			if ok {
+				t.Errorf("Expected the batch to not be written")
+			}
+			checkDatabase(t, db, seed)
+		})
+	}
+
+	t.Run("Only checks", func(t *testing.T) {
+		db, tdb := start(t)
+		defer db.Close()
+
+		ok, err := tdb.WriteBatchIf(
+			[][]byte{[]byte("key-1")}, [][]byte{[]byte("value-1")}, nil, nil,
+		)
+		if err != nil {
+			t.Errorf("Expected no error, but got %v", err)
+		}
+		if !ok {
+			t.Errorf("Expected the check to succeed")
+		}
+		checkDatabase(t, db, seed)
+	})
+
+	t.Run("Mismatched lengths", func(t *testing.T) {
+		db, tdb := start(t)
+		defer db.Close()
+
+		_, err := tdb.WriteBatchIf([][]byte{[]byte("key-1")}, nil, keys, values)
+		if err == nil {
+			t.Errorf("Expected error, but got nil")
+		}
+		checkDatabase(t, db, seed)
+	})
+}
+
+func (T *DBTests) TestPutWithTTL(t *testing.T) {
+	t.Run("Put With TTL", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("Skipping test in short mode")
+		}
+
+		// Arrange
+		db := StartDatabase(t, T.Open, map[string]string{"key-1": "value-1"})
+		defer db.Close()
+		tdb, ok := any(db).(ttlDB)
+		if !ok {
+			t.Fatalf("Expected db to implement PutWithTTL")
+		}
+
+		// Act
+		ttls := map[string]time.Duration{
+			"key-2": time.Second,
+			"key-3": time.Hour,
+			"key-4": 0,
+		}
+		for k, ttl := range ttls {
+			err := tdb.PutWithTTL([]byte(k), []byte("value"), ttl)
+			if err != nil {
+				t.Fatalf("Expected no error, but got %v", err)
+			}
+		}
+		checkDatabase(t, db, map[string]string{
+			"key-1": "value-1", "key-2": "value", "key-3": "value", "key-4": "value",
+		})
+
+		// Badger stores the expiration with a resolution of one second.
+		time.Sleep(2 * time.Second)
+
+		// Assert
+		checkDatabase(t, db, map[string]string{
+			"key-1": "value-1", "key-3": "value", "key-4": "value",
+		})
+		if ok, _ := db.Has([]byte("key-2")); ok {
+			t.Errorf("Expected key-2 to be expired")
+		}
+		if v, _ := db.Get([]byte("key-2")); v != nil {
+			t.Errorf("Expected nil value, but got %v", v)
+		}
+		var keys []string
+		err := db.Items(nil, 1, func(k, _ []byte) (bool, error) {
+			keys = append(keys, string(k))
+			return true, nil
+		})
+		if err != nil {
+			t.Errorf("Expected no error, but got %v", err)
+		}
+		expected := []string{"key-1", "key-3", "key-4"}
+		if !reflect.DeepEqual(keys, expected) {
+			t.Errorf("Expected keys %v, but got %v", expected, keys)
+		}
+	})
+}
+
+func (T *DBTests) TestItemsRangeContext(t *testing.T) {
+	seed := map[string]string{
+		"key-1": "value-1", "key-2": "value-2", "key-3": "value-3", "key-4": "value-4",
+	}
+
+	t.Run("Items Range Context", func(t *testing.T) {
+		// Arrange
+		db := StartDatabase(t, T.Open, seed)
+		defer db.Close()
+		cdb, ok := any(db).(contextDB)
+		if !ok {
+			t.Fatalf("Expected db to implement ItemsRangeContext")
+		}
+
+		// Act
+		var keys []string
+		err := cdb.ItemsRangeContext(
+			context.Background(), []byte("key-2"), []byte("key-4"), 1,
+			func(k, _ []byte) (bool, error) {
+				keys = append(keys, string(k))
+				return true, nil
+			},
+		)
+
+		// Assert
+		if err != nil {
+			t.Errorf("Expected no error, but got %v", err)
+		}
+		expected := []string{"key-2", "key-3"}
+		if !reflect.DeepEqual(keys, expected) {
+			t.Errorf("Expected keys %v, but got %v", expected, keys)
+		}
+	})
+
+	t.Run("Items Range Context - Cancelled", func(t *testing.T) {
+		// Arrange
+		db := StartDatabase(t, T.Open, seed)
+		defer db.Close()
+		cdb, ok := any(db).(contextDB)
+		if !ok {
+			t.Fatalf("Expected db to implement ItemsRangeContext")
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+
+		// Act
+		var keys []string
+		err := cdb.ItemsRangeContext(ctx, nil, nil, 1, func(k, _ []byte) (bool, error) {
+			keys = append(keys, string(k))
+			cancel()
+			return true, nil
+		})
+
+		// Assert
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, but got %v", err)
+		}
+		if len(keys) != 1 {
+			t.Errorf("Expected 1 key, but got %v", keys)
+		}
+
+		// Already cancelled
+		keys = nil
+		err = cdb.ItemsRangeContext(ctx, nil, nil, 1, func(k, _ []byte) (bool, error) {
+			keys = append(keys, string(k))
+			return true, nil
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, but got %v", err)
+		}
+		if len(keys) != 0 {
+			t.Errorf("Expected no keys, but got %v", keys)
+		}
+	})
+}
+
+func (T *DBTests) TestContext(t *testing.T) {
+	seed := map[string]string{"key-1": "value-1", "key-2": "value-2"}
+
+	t.Run("Context", func(t *testing.T) {
+		// Arrange
+		db := StartDatabase(t, T.Open, seed)
+		defer db.Close()
+		cdb, ok := any(db).(contextDB)
+		if !ok {
+			t.Fatalf("Expected db to implement the Context methods")
+		}
+		ctx := context.Background()
+
+		// Act
+		putErr := cdb.PutContext(ctx, []byte("key-3"), []byte("value-3"))
+		deleteErr := cdb.DeleteContext(ctx, []byte("key-1"))
+		has, hasErr := cdb.HasContext(ctx, []byte("key-2"))
+		value, getErr := cdb.GetContext(ctx, []byte("key-3"))
+
+		// Assert
+		for _, err := range []error{putErr, deleteErr, hasErr, getErr} {
+			if err != nil {
+				t.Errorf("Expected no error, but got %v", err)
+			}
+		}
+		if !has {
+			t.Errorf("Expected key-2 to exist")
+		}
+		if string(value) != "value-3" {
+			t.Errorf("Expected value-3, but got %q", value)
+		}
+		checkDatabase(t, db, map[string]string{"key-2": "value-2", "key-3": "value-3"})
+	})
+
+	t.Run("Context - Cancelled", func(t *testing.T) {
+		// Arrange
+		db := StartDatabase(t, T.Open, seed)
+		defer db.Close()
+		cdb, ok := any(db).(contextDB)
+		if !ok {
+			t.Fatalf("Expected db to implement the Context methods")
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		// Act
+		putErr := cdb.PutContext(ctx, []byte("key-3"), []byte("value-3"))
+		deleteErr := cdb.DeleteContext(ctx, []byte("key-1"))
+		_, hasErr := cdb.HasContext(ctx, []byte("key-2"))
+		_, getErr := cdb.GetContext(ctx, []byte("key-2"))
+
+		// Assert
+		for _, err := range []error{putErr, deleteErr, hasErr, getErr} {
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("Expected context.Canceled, but got %v", err)
+			}
+		}
+		checkDatabase(t, db, seed)
+	})
+}
+
+func (T *DBTests) TestItemsPrefixContext(t *testing.T) {
+	seed := map[string]string{
+		"a/1": "value-1", "a/2": "value-2", "b/1": "value-3", "b/2": "value-4",
+		"c": "value-5",
+	}
+
+	t.Run("Items Prefix Context", func(t *testing.T) {
+		// Arrange
+		db := StartDatabase(t, T.Open, seed)
+		defer db.Close()
+		cdb, ok := any(db).(contextDB)
+		if !ok {
+			t.Fatalf("Expected db to implement ItemsPrefixContext")
+		}
+
+		for _, order := range []int{1, -1} {
+			// Act
+			var keys []string
+			err := cdb.ItemsPrefixContext(context.Background(), []byte("b/"), order,
+				func(k, _ []byte) (bool, error) {
+					keys = append(keys, string(k))
+					return true, nil
+				},
+			)
+
+			// Assert
+			if err != nil {
+				t.Errorf("Expected no error, but got %v", err)
+			}
+			expected := []string{"b/1", "b/2"}
+			if order < 0 {
+				expected = []string{"b/2", "b/1"}
+			}
+			if !reflect.DeepEqual(keys, expected) {
+				t.Errorf("Expected keys %v, but got %v", expected, keys)
+			}
+		}
+	})
+
+	t.Run("Items Prefix Context - Cancelled", func(t *testing.T) {
+		// Arrange
+		db := StartDatabase(t, T.Open, seed)
+		defer db.Close()
+		cdb, ok := any(db).(contextDB)
+		if !ok {
+			t.Fatalf("Expected db to implement ItemsPrefixContext")
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+
+		// Act
+		var keys []string
+		err := cdb.ItemsPrefixContext(ctx, []byte("a/"), 1, func(k, _ []byte) (bool, error) {
+			keys = append(keys, string(k))
+			cancel()
+			return true, nil
+		})
+
+		// Assert
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, but got %v", err)
+		}
+		if len(keys) != 1 {
+			t.Errorf("Expected 1 key, but got %v", keys)
+		}
+	})
+}
+
 func (T *DBTests) TestConcurrentOperations(t *testing.T) {
 	t.Run("Concurrent Operations", func(t *testing.T) {
 		if testing.Short() {
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"maps"
 	"os"
@@ -11,6 +12,7 @@ import (
 	"reflect"
 	"slices"
 	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -24,7 +26,8 @@ var (
 	TestFilesPerShardOption   = withMaxFilesPerShard(3)
 	TestMillisecondSyncOption = withSyncInterval(30 * time.Millisecond)
 
-	TestError = errors.New("test error")
+	TestError  = errors.New("test error")
+	TestSecret = []byte("0123456789abcdef0123456789abcdef")
 )
 
 type TDB = *DB
@@ -98,6 +101,20 @@ func AssertItems(t *testing.T, db *DB, start []byte, order int, expect []string)
 	}
 }
 
+// crashTestDB simulates a crash of the process using the database, which is
+// left without the final sync. Its background goroutines are stopped and its
+// locks are released, so it can be opened again.
+func crashTestDB(db *DB) {
+	db.mu.Lock()
+	if !db.closed {
+		db.closed = true
+		close(db.done)
+	}
+	db.mu.Unlock()
+	db.wg.Wait()
+	_ = db.unlock()
+}
+
 func getClosedDB(t *testing.T, seed map[string]string) *DB {
 	t.Helper()
 
@@ -120,6 +137,11 @@ func TestDB(t *testing.T) {
 	tests.CheckInitialization = CheckInitialization
 	tests.SupportsSeeking = true
 	tests.SupportsReverseIteration = true
+	tests.SupportsRange = true
+	tests.SupportsBatch = true
+	tests.SupportsTx = true
+	tests.SupportsTTL = true
+	tests.SupportsContext = true
 	tests.TestAll(t)
 }
 
@@ -131,6 +153,11 @@ func TestDB_NoCache(t *testing.T) {
 	tests.CheckInitialization = CheckInitialization
 	tests.SupportsSeeking = true
 	tests.SupportsReverseIteration = true
+	tests.SupportsRange = true
+	tests.SupportsBatch = true
+	tests.SupportsTx = true
+	tests.SupportsTTL = true
+	tests.SupportsContext = true
 	tests.TestAll(t)
 }
 
@@ -142,6 +169,11 @@ func TestDB_MillisecondSync(t *testing.T) {
 	tests.CheckInitialization = CheckInitialization
 	tests.SupportsSeeking = true
 	tests.SupportsReverseIteration = true
+	tests.SupportsRange = true
+	tests.SupportsBatch = true
+	tests.SupportsTx = true
+	tests.SupportsTTL = true
+	tests.SupportsContext = true
 	tests.TestAll(t)
 }
 
@@ -153,6 +185,11 @@ func TestDB_SyncWrites(t *testing.T) {
 	tests.CheckInitialization = CheckInitialization
 	tests.SupportsSeeking = true
 	tests.SupportsReverseIteration = true
+	tests.SupportsRange = true
+	tests.SupportsBatch = true
+	tests.SupportsTx = true
+	tests.SupportsTTL = true
+	tests.SupportsContext = true
 
 	if testing.Short() {
 		tests.TestGet(t)
@@ -162,6 +199,40 @@ func TestDB_SyncWrites(t *testing.T) {
 	tests.TestAll(t)
 }
 
+func TestDB_OrderedKeys(t *testing.T) {
+	option := WithKeyEncryption(OrderedKeys, TestSecret)
+	tests := NewDBTests(
+		NewOpenFunc(true, option, TestFilesPerShardOption),
+		NewOpenFunc(false, option, TestFilesPerShardOption),
+	)
+	tests.CheckInitialization = CheckInitialization
+	tests.SupportsSeeking = true
+	tests.SupportsReverseIteration = true
+	tests.SupportsRange = true
+	tests.SupportsBatch = true
+	tests.SupportsTx = true
+	tests.SupportsTTL = true
+	tests.SupportsContext = true
+	tests.TestAll(t)
+}
+
+func TestDB_HashedKeys(t *testing.T) {
+	option := WithKeyEncryption(HashedKeys, TestSecret)
+	tests := NewDBTests(
+		NewOpenFunc(true, option, TestFilesPerShardOption),
+		NewOpenFunc(false, option, TestFilesPerShardOption),
+	)
+	tests.CheckInitialization = CheckInitialization
+	tests.SupportsSeeking = true
+	tests.SupportsReverseIteration = true
+	tests.SupportsRange = true
+	tests.SupportsBatch = true
+	tests.SupportsTx = true
+	tests.SupportsTTL = true
+	tests.SupportsContext = true
+	tests.TestAll(t)
+}
+
 // Additional Tests
 
 func TestOpen_WithOptions(t *testing.T) {
@@ -492,6 +563,229 @@ func TestDB_Items_ShardBoundaries(t *testing.T) {
 	}
 }
 
+func TestDB_ItemsRange_ShardBoundaries(t *testing.T) {
+	seed := make(map[string]string)
+	for i := 0; i < 30; i++ {
+		k := fmt.Sprintf("%03d", i)
+		seed[k] = "v" + k
+	}
+
+	db := StartDatabase(t, OpenTestDB, seed)
+	defer db.Close()
+
+	keysAsc := slices.Sorted(maps.Keys(seed))
+	keysDesc := slices.Clone(keysAsc)
+	slices.Reverse(keysDesc)
+
+	cases := []struct {
+		name  string
+		order int
+		keys  []string
+	}{
+		{"asc", Asc, keysAsc},
+		{"desc", Desc, keysDesc},
+	}
+
+	for _, tc := range cases {
+		for i, start := range tc.keys {
+			for j := i; j < len(tc.keys); j += 7 {
+				end := tc.keys[j]
+				t.Run(fmt.Sprintf("%s/from=%s/to=%s", tc.name, start, end), func(t *testing.T) {
+					var got []string
+					err := db.ItemsRange([]byte(start), []byte(end), tc.order, func(k, _ []byte) (bool, error) {
+						got = append(got, string(k))
+						return true, nil
+					})
+					if err != nil {
+						t.Fatalf("ItemsRange: %v", err)
+					}
+					expect := tc.keys[i:j]
+					if len(expect) == 0 {
+						expect = nil
+					}
+					if !reflect.DeepEqual(got, expect) {
+						t.Fatalf("want %v\ngot  %v", expect, got)
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestDB_ItemsRange_StopsEarly(t *testing.T) {
+	seed := make(map[string]string)
+	for i := 0; i < 30; i++ {
+		k := fmt.Sprintf("%03d", i)
+		seed[k] = "v" + k
+	}
+
+	var opened []string
+	fsys := &mockFS{
+		openFunc: func(name string) (fs.File, error) {
+			opened = append(opened, name)
+			return (&osFS{}).Open(name)
+		},
+	}
+	open := NewOpenFunc(true, withFileSystem(fsys))
+	db := StartDatabase(t, open, seed)
+	defer db.Close()
+
+	cases := []struct {
+		name  string
+		start string
+		end   string
+		order int
+	}{
+		{"asc", "", "001", Asc},
+		{"desc", "", "028", Desc},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			opened = nil
+			err := db.ItemsRange([]byte(tc.start), []byte(tc.end), tc.order, func(_, _ []byte) (bool, error) {
+				return true, nil
+			})
+			if err != nil {
+				t.Fatalf("ItemsRange: %v", err)
+			}
+
+			// A single shard holds the range, so no other shard should be read.
+			if len(opened) != 1 {
+				t.Errorf("Expected 1 shard to be read, but got %d: %v", len(opened), opened)
+			}
+		})
+	}
+}
+
+func TestDB_ItemsPrefix(t *testing.T) {
+	seed := make(map[string]string)
+	for _, user := range []string{"1", "2", "10", "11", "42"} {
+		for _, field := range []string{"email", "name", "settings"} {
+			k := "user/" + user + "/" + field
+			seed[k] = "v-" + k
+		}
+	}
+	for _, k := range []string{"post/1", "post/2", "user", "user/", "usera", "\xff", "\xff\xff"} {
+		seed[k] = "v-" + k
+	}
+
+	db := StartDatabase(t, OpenTestDB, seed)
+	defer db.Close()
+
+	prefixes := []string{
+		"", "u", "us", "use", "user", "user/", "user/1", "user/1/",
+		"user/1/e", "user/4", "user/42/settings", "user/42/settingsX",
+		"post/", "p", "z", "\xff",
+	}
+
+	for _, prefix := range prefixes {
+		var expect []string
+		for _, k := range slices.Sorted(maps.Keys(seed)) {
+			if strings.HasPrefix(k, prefix) {
+				expect = append(expect, k)
+			}
+		}
+		expectDesc := slices.Clone(expect)
+		slices.Reverse(expectDesc)
+
+		cases := []struct {
+			name   string
+			order  int
+			expect []string
+		}{
+			{"asc", Asc, expect},
+			{"desc", Desc, expectDesc},
+		}
+
+		for _, tc := range cases {
+			t.Run(fmt.Sprintf("%s/prefix=%q", tc.name, prefix), func(t *testing.T) {
+				var got []string
+				err := db.ItemsPrefix([]byte(prefix), tc.order, func(k, v []byte) (bool, error) {
+					if string(v) != seed[string(k)] {
+						t.Errorf("Expected value %s, but got %s", seed[string(k)], v)
+					}
+					got = append(got, string(k))
+					return true, nil
+				})
+				if err != nil {
+					t.Fatalf("ItemsPrefix: %v", err)
+				}
+				if !reflect.DeepEqual(got, tc.expect) {
+					t.Fatalf("want %q\ngot  %q", tc.expect, got)
+				}
+			})
+		}
+	}
+
+	t.Run("Prefix iterator", func(t *testing.T) {
+		var got []string
+		items, errf := db.Prefix([]byte("user/1/"))
+		for k := range items {
+			got = append(got, string(k))
+		}
+		expect := []string{"user/1/email", "user/1/name", "user/1/settings"}
+		if !reflect.DeepEqual(got, expect) {
+			t.Errorf("want %q\ngot  %q", expect, got)
+		}
+		if err := errf(); err != nil {
+			t.Errorf("want no error, got %v", err)
+		}
+	})
+
+	t.Run("Closed database", func(t *testing.T) {
+		db := getClosedDB(t, seed)
+		err := db.ItemsPrefix([]byte("user/"), Asc, func(_, _ []byte) (bool, error) {
+			return true, nil
+		})
+		if !errors.Is(err, ErrDatabaseClosed) {
+			t.Errorf("Expected %v, but got %v", ErrDatabaseClosed, err)
+		}
+	})
+}
+
+func TestDB_ItemsPrefix_StopsEarly(t *testing.T) {
+	seed := make(map[string]string)
+	for i := 0; i < 30; i++ {
+		k := fmt.Sprintf("%03d", i)
+		seed[k] = "v" + k
+	}
+
+	var opened []string
+	fsys := &mockFS{
+		openFunc: func(name string) (fs.File, error) {
+			opened = append(opened, name)
+			return (&osFS{}).Open(name)
+		},
+	}
+	open := NewOpenFunc(true, withFileSystem(fsys))
+	db := StartDatabase(t, open, seed)
+	defer db.Close()
+
+	for _, order := range []int{Asc, Desc} {
+		opened = nil
+		var got []string
+		err := db.ItemsPrefix([]byte("01"), order, func(k, _ []byte) (bool, error) {
+			got = append(got, string(k))
+			return true, nil
+		})
+		if err != nil {
+			t.Fatalf("ItemsPrefix: %v", err)
+		}
+		if len(got) != 10 {
+			t.Errorf("Expected 10 keys, but got %v", got)
+		}
+
+		// Only the shards holding the keys 010 ... 019 should be read,
+		// plus at most one neighbour where the prefix stops matching.
+		maxShards := int(10/db.maxFilesPerShard) + 3
+		if len(opened) > maxShards {
+			t.Errorf("Expected at most %d shards to be read, but got %d: %v",
+				maxShards, len(opened), opened)
+		}
+	}
+}
+
 func TestDB_Items_ShardBoundariesAfterDelete(t *testing.T) {
 	// Seed & Delete
 	seed := make(map[string]string)
@@ -775,3 +1069,163 @@ func TestOperationsOnClosedDB(t *testing.T) {
 		wg.Wait()
 	})
 }
+
+func TestDB_ReadOnly(t *testing.T) {
+	seed := map[string]string{
+		"key-1": "value-1", "key-2": "value-2",
+		"key-3": "value-3", "key-4": "value-4",
+	}
+	openReadOnly := NewOpenFunc(false, WithReadOnly(true))
+	metaPath := filepath.Join(TestDirectory, metadataDirectory, metadataFilename)
+
+	t.Run("Reads", func(t *testing.T) {
+		// Arrange
+		db := StartDatabase(t, OpenTestDB, seed)
+		_ = db.Close()
+
+		// Act
+		db, err := openReadOnly()
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		defer db.Close()
+
+		// Assert
+		checkDatabase(t, db, seed)
+		AssertItems(t, db, nil, Desc, []string{"key-4", "key-3", "key-2", "key-1"})
+	})
+
+	t.Run("Mutations", func(t *testing.T) {
+		// Arrange
+		db := StartDatabase(t, OpenTestDB, seed)
+		_ = db.Close()
+		meta, _ := os.ReadFile(metaPath)
+		db, err := openReadOnly()
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+
+		// Act
+		errs := []error{
+			db.Put([]byte("key"), []byte("value")),
+			db.Delete([]byte("key-1")),
+			db.PutWithTTL([]byte("key"), []byte("value"), time.Hour),
+			db.WriteBatch([][]byte{[]byte("key")}, [][]byte{[]byte("value")}),
+			db.Update([]byte("key"), func([]byte) ([]byte, error) { return nil, nil }),
+			db.DropNamespace("users"),
+			db.Backup(io.Discard),
+		}
+		ok, txErr := db.WriteBatchIf([][]byte{[]byte("key-1")}, [][]byte{[]byte("value-1")}, nil, nil)
+		syncErr := db.Sync()
+		closeErr := db.Close()
+
+		// Assert
+		for i, err := range errs {
+			if !errors.Is(err, ErrReadOnly) {
+				t.Errorf("%d: Expected ErrReadOnly, but got %v", i, err)
+			}
+		}
+		if !ok || txErr != nil {
+			t.Errorf("Expected the values to be checked, but got %v, %v", ok, txErr)
+		}
+		if syncErr != nil || closeErr != nil {
+			t.Errorf("Expected no error, but got %v, %v", syncErr, closeErr)
+		}
+		if data, _ := os.ReadFile(metaPath); !bytes.Equal(data, meta) {
+			t.Errorf("Expected the metadata to be unchanged")
+		}
+		db, _ = ReopenTestDB()
+		defer db.Close()
+		checkDatabase(t, db, seed)
+	})
+
+	t.Run("Unclean database", func(t *testing.T) {
+		// Arrange
+		db := StartDatabase(t, NewOpenFunc(true, WithExpiryInterval(0)), seed)
+		_ = db.Put([]byte("key-5"), []byte("value-5"))
+		meta, _ := os.ReadFile(metaPath)
+		crashTestDB(db)
+
+		// Act
+		ro, err := openReadOnly()
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		_ = ro.Close()
+
+		// Assert
+		if n := ro.metadata.TotalEntries; n != uint64(len(seed)+1) {
+			t.Errorf("Expected %d entries, but got %d", len(seed)+1, n)
+		}
+		if data, _ := os.ReadFile(metaPath); !bytes.Equal(data, meta) {
+			t.Errorf("Expected the metadata to be unchanged")
+		}
+	})
+
+	t.Run("Interrupted batch", func(t *testing.T) {
+		db := StartDatabase(t, OpenTestDB, seed)
+		_ = db.Close()
+		_ = os.WriteFile(journalPath(db), []byte("journal"), defaultPermissions)
+
+		_, err := openReadOnly()
+		if !errors.Is(err, ErrReadOnly) {
+			t.Errorf("Expected ErrReadOnly, but got %v", err)
+		}
+	})
+
+	t.Run("Read permissions", func(t *testing.T) {
+		db := StartDatabase(t, OpenTestDB, seed)
+		_ = db.Close()
+		if err := os.Chmod(TestDirectory, 0500); err != nil {
+			t.Fatalf("chmod: %v", err)
+		}
+		defer os.Chmod(TestDirectory, defaultDirPermissions)
+
+		db, err := openReadOnly()
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		defer db.Close()
+		checkDatabase(t, db, seed)
+	})
+
+	t.Run("Missing database", func(t *testing.T) {
+		_ = os.RemoveAll(TestDirectory)
+
+		_, err := openReadOnly()
+		if !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("Expected fs.ErrNotExist, but got %v", err)
+		}
+		if _, err = os.Stat(TestDirectory); !os.IsNotExist(err) {
+			t.Errorf("Expected the database not to be created, but got %v", err)
+		}
+	})
+
+	t.Run("Namespaces", func(t *testing.T) {
+		// Arrange
+		db := StartDatabase(t, OpenTestDB, nil)
+		users, _ := db.Namespace("users")
+		_ = users.Put([]byte("key"), []byte("value"))
+		_ = db.Close()
+
+		// Act
+		db, err := openReadOnly()
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		defer db.Close()
+		users, err = db.Namespace("users")
+
+		// Assert
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		checkDatabase(t, users, map[string]string{"key": "value"})
+		if err = users.Put([]byte("key"), []byte("new")); !errors.Is(err, ErrReadOnly) {
+			t.Errorf("Expected ErrReadOnly, but got %v", err)
+		}
+		if _, err = db.Namespace("orders"); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("Expected fs.ErrNotExist, but got %v", err)
+		}
+	})
+}
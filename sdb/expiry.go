@@ -0,0 +1,304 @@
+package sdb
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// expiryFilename is the name of the file, in the metadata directory,
+	// that logs the deadlines of the records written with DB.PutWithTTL.
+	expiryFilename = "expiry.log"
+
+	// defaultExpiryInterval is the default interval at which the expired
+	// records are deleted.
+	defaultExpiryInterval = 1 * time.Minute
+)
+
+// now returns the current time. It can be replaced in tests.
+var now = time.Now
+
+// PutWithTTL works like [DB.Put], but the record expires after the given
+// time-to-live. Once expired, the record is no longer returned by DB.Get,
+// DB.Has and the iteration methods, and it isn't counted by DB.Len. If ttl
+// is zero or negative, the record doesn't expire.
+//
+// The expired records are deleted by a background goroutine, at the interval
+// set with [WithExpiryInterval], or by [DB.DeleteExpired].
+//
+// The deadlines are kept in memory and logged to a file in the metadata
+// directory. The record is written before its deadline is logged, so if the
+// process crashes in between, the record keeps the expiration of the value
+// it replaced.
+func (db *DB) PutWithTTL(key, value []byte, ttl time.Duration) error {
+	return db.PutWithTTLContext(context.Background(), key, value, ttl)
+}
+
+// PutWithTTLContext works like [DB.PutWithTTL], but returns the context
+// error, without writing the value, if ctx is done once the locks are
+// acquired.
+func (db *DB) PutWithTTLContext(ctx context.Context, key, value []byte, ttl time.Duration) error {
+	if ttl <= 0 {
+		return db.PutContext(ctx, key, value)
+	}
+	if err := db.lockProcess(true); err != nil {
+		return err
+	}
+	defer db.unlockProcess(true)
+
+	if err := prepareForMutation(db); err != nil {
+		return fmt.Errorf("prepare for mutation: %w", err)
+	}
+	if len(key) > db.keys.maxKeyLength() {
+		return ErrKeyTooLarge
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.closed {
+		return ErrDatabaseClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := completePendingBatch(db); err != nil {
+		return fmt.Errorf("complete pending batch: %w", err)
+	}
+
+	deadline := now().Add(ttl).UnixNano()
+	if err := putRecord(db, key, value); err != nil {
+		return err
+	}
+	if err := appendExpiry(db, key, deadline); err != nil {
+		return fmt.Errorf("log expiry: %w", err)
+	}
+	db.expiry[string(key)] = deadline
+	return nil
+}
+
+// DeleteExpired deletes the expired records from the database and returns
+// the number of deleted records.
+func (db *DB) DeleteExpired() (int, error) {
+	return db.DeleteExpiredContext(context.Background())
+}
+
+// DeleteExpiredContext works like [DB.DeleteExpired], but stops with the
+// context error once ctx is done. The records deleted before that stay
+// deleted, and their number is returned with the error.
+func (db *DB) DeleteExpiredContext(ctx context.Context) (int, error) {
+	if err := db.lockProcess(true); err != nil {
+		return 0, err
+	}
+	defer db.unlockProcess(true)
+
+	db.mu.RLock()
+	n := countExpired(db)
+	db.mu.RUnlock()
+	if n == 0 {
+		return 0, nil
+	}
+
+	if err := prepareForMutation(db); err != nil {
+		return 0, fmt.Errorf("prepare for mutation: %w", err)
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.closed {
+		return 0, ErrDatabaseClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	var count int
+	var ctxErr error
+	t := now().UnixNano()
+	for key, deadline := range db.expiry {
+		if t < deadline {
+			continue
+		}
+		if ctxErr = ctx.Err(); ctxErr != nil {
+			// Keep the deletions done so far in the expiry log.
+			break
+		}
+		if err := deleteRecord(db, []byte(key)); err != nil {
+			return count, err
+		}
+		delete(db.expiry, key)
+		count++
+	}
+
+	if err := rewriteExpiry(db); err != nil {
+		return count, fmt.Errorf("rewrite expiry log: %w", err)
+	}
+	return count, ctxErr
+}
+
+// isExpired reports whether the record of the key has expired. The caller
+// must hold the lock.
+func isExpired(db *DB, key []byte) bool {
+	deadline, ok := db.expiry[string(key)]
+	return ok && now().UnixNano() >= deadline
+}
+
+// countExpired returns the number of expired records. The caller must hold
+// the lock.
+func countExpired(db *DB) int {
+	var count int
+	t := now().UnixNano()
+	for _, deadline := range db.expiry {
+		if t >= deadline {
+			count++
+		}
+	}
+	return count
+}
+
+// clearExpiry removes the deadline of the key, if any. The caller must hold
+// the write lock.
+func clearExpiry(db *DB, key []byte) error {
+	if _, ok := db.expiry[string(key)]; !ok {
+		return nil
+	}
+	// A zero deadline removes the key from the log when it is loaded.
+	if err := appendExpiry(db, key, 0); err != nil {
+		return fmt.Errorf("log expiry: %w", err)
+	}
+	delete(db.expiry, string(key))
+	return nil
+}
+
+// reapExpired periodically deletes the expired records.
+func reapExpired(db *DB) {
+	defer db.wg.Done()
+
+	ticker := time.NewTicker(db.expiryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			// Errors are ignored, since the records are deleted again in
+			// the next tick.
+			_, _ = db.DeleteExpired()
+
+		case <-db.done:
+			// The channel is closed in Close(); exit the goroutine.
+			return
+		}
+	}
+}
+
+// Expiry Log
+//
+// Each entry of the log has the length of the key (uvarint), the key and the
+// deadline in Unix nanoseconds (varint). Later entries override earlier
+// ones, and a zero deadline removes the key. The log is compacted when it is
+// loaded and when the expired records are deleted.
+
+func expiryPath(db *DB) string {
+	return filepath.Join(db.path, metadataDirectory, expiryFilename)
+}
+
+func appendExpiryEntry(data, key []byte, deadline int64) []byte {
+	data = binary.AppendUvarint(data, uint64(len(key)))
+	data = append(data, key...)
+	return binary.AppendVarint(data, deadline)
+}
+
+func appendExpiry(db *DB, key []byte, deadline int64) error {
+	flag := os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	if db.syncWrites {
+		flag |= os.O_SYNC
+	}
+	f, err := db.fs.OpenFile(expiryPath(db), flag, defaultPermissions)
+	if err != nil {
+		return err
+	}
+	_, err = f.(io.Writer).Write(appendExpiryEntry(nil, db.keys.seal(key), deadline))
+	if err1 := f.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	return err
+}
+
+// loadExpiry loads the deadlines from the expiry log, compacting it if some
+// of its entries were overridden and compact is true.
+func loadExpiry(db *DB, compact bool) error {
+	data, err := fs.ReadFile(db.fs, expiryPath(db))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read file: %w", err)
+	}
+
+	var entries int
+	var truncated bool
+	for len(data) > 0 {
+		n, size := binary.Uvarint(data)
+		if size <= 0 || uint64(len(data)-size) < n {
+			// Entry truncated by a crash while it was written.
+			truncated = true
+			break
+		}
+		key, err := db.keys.open(data[size : size+int(n)])
+		if err != nil {
+			return fmt.Errorf("open key: %w", err)
+		}
+		data = data[size+int(n):]
+
+		deadline, size := binary.Varint(data)
+		if size <= 0 {
+			truncated = true
+			break
+		}
+		data = data[size:]
+
+		if deadline == 0 {
+			delete(db.expiry, string(key))
+		} else {
+			db.expiry[string(key)] = deadline
+		}
+		entries++
+	}
+
+	// Rewrite the log if it is truncated, so that new entries aren't
+	// appended after the partial one. It can't be compacted without
+	// writing, like in a read-only database, but then nothing is appended.
+	if !compact {
+		return nil
+	}
+	if truncated || entries > len(db.expiry) {
+		return rewriteExpiry(db)
+	}
+	return nil
+}
+
+// rewriteExpiry replaces the expiry log with the current deadlines.
+func rewriteExpiry(db *DB) error {
+	if len(db.expiry) == 0 {
+		err := db.fs.Remove(expiryPath(db))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("remove: %w", err)
+		}
+		return nil
+	}
+
+	var data []byte
+	for key, deadline := range db.expiry {
+		data = appendExpiryEntry(data, db.keys.seal([]byte(key)), deadline)
+	}
+	writer := newAtomicWriter(db.fs, db.syncWrites)
+	return writer.WriteFile(expiryPath(db), data, false)
+}
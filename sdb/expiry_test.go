@@ -0,0 +1,339 @@
+package sdb
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setNow replaces the current time used by the database, restoring it at the
+// end of the test.
+func setNow(t *testing.T, tm time.Time) {
+	t.Helper()
+	old := now
+	now = func() time.Time { return tm }
+	t.Cleanup(func() { now = old })
+}
+
+func TestDB_PutWithTTL(t *testing.T) {
+	start := time.Now()
+
+	t.Run("Expires", func(t *testing.T) {
+		// Arrange
+		setNow(t, start)
+		db := StartDatabase(t, OpenTestDB, map[string]string{"key-1": "value-1"})
+		defer db.Close()
+
+		// Act
+		if err := db.PutWithTTL([]byte("key-2"), []byte("value-2"), time.Minute); err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+
+		// Assert
+		checkDatabase(t, db, map[string]string{"key-1": "value-1", "key-2": "value-2"})
+
+		setNow(t, start.Add(time.Minute))
+		checkDatabase(t, db, map[string]string{"key-1": "value-1"})
+		if ok, _ := db.Has([]byte("key-2")); ok {
+			t.Errorf("Expected key-2 to be expired")
+		}
+		AssertItems(t, db, nil, Asc, []string{"key-1"})
+		AssertItems(t, db, nil, Desc, []string{"key-1"})
+	})
+
+	t.Run("Put clears the TTL", func(t *testing.T) {
+		setNow(t, start)
+		db := StartDatabase(t, OpenTestDB, nil)
+		defer db.Close()
+
+		_ = db.PutWithTTL([]byte("key"), []byte("value"), time.Minute)
+		if err := db.Put([]byte("key"), []byte("new")); err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+
+		setNow(t, start.Add(time.Hour))
+		checkDatabase(t, db, map[string]string{"key": "new"})
+	})
+
+	t.Run("ReplaceBatchIf keeps the TTL", func(t *testing.T) {
+		// Arrange
+		setNow(t, start)
+		db := StartDatabase(t, OpenTestDB, nil)
+		defer db.Close()
+		_ = db.PutWithTTL([]byte("key-1"), []byte("value-1"), time.Minute)
+		_ = db.PutWithTTL([]byte("key-2"), []byte("value-2"), time.Minute)
+
+		// Act
+		ok, err := db.ReplaceBatchIf(
+			[][]byte{[]byte("key-1")}, [][]byte{[]byte("value-1")},
+			[][]byte{[]byte("key-1"), []byte("key-2"), []byte("key-3")},
+			[][]byte{[]byte("new-1"), nil, []byte("new-3")},
+		)
+
+		// Assert
+		if !ok || err != nil {
+			t.Fatalf("Expected the batch to be written, but got %v, %v", ok, err)
+		}
+		checkDatabase(t, db, map[string]string{"key-1": "new-1", "key-3": "new-3"})
+		if _, ok = db.expiry["key-2"]; ok {
+			t.Errorf("Expected the deadline of the deleted key to be removed")
+		}
+
+		setNow(t, start.Add(time.Hour))
+		checkDatabase(t, db, map[string]string{"key-3": "new-3"})
+	})
+
+	t.Run("Non-positive TTL", func(t *testing.T) {
+		setNow(t, start)
+		db := StartDatabase(t, OpenTestDB, nil)
+		defer db.Close()
+
+		if err := db.PutWithTTL([]byte("key"), []byte("value"), 0); err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		if len(db.expiry) != 0 {
+			t.Errorf("Expected no deadlines, but got %v", db.expiry)
+		}
+	})
+
+	t.Run("Persistence", func(t *testing.T) {
+		// Arrange
+		setNow(t, start)
+		db := StartDatabase(t, OpenTestDB, nil)
+		_ = db.PutWithTTL([]byte("key-1"), []byte("value-1"), time.Minute)
+		_ = db.PutWithTTL([]byte("key-2"), []byte("value-2"), time.Hour)
+		_ = db.PutWithTTL([]byte("key-3"), []byte("value-3"), time.Minute)
+		_ = db.Put([]byte("key-3"), []byte("new-3"))
+		_ = db.Delete([]byte("key-2"))
+		db.Close()
+
+		// Act
+		db, err := ReopenTestDB()
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		defer db.Close()
+
+		// Assert
+		if len(db.expiry) != 1 {
+			t.Errorf("Expected 1 deadline, but got %v", db.expiry)
+		}
+		setNow(t, start.Add(time.Minute))
+		checkDatabase(t, db, map[string]string{"key-3": "new-3"})
+	})
+
+	t.Run("Context - Cancelled", func(t *testing.T) {
+		setNow(t, start)
+		db := StartDatabase(t, OpenTestDB, nil)
+		defer db.Close()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err := db.PutWithTTLContext(ctx, []byte("key"), []byte("value"), time.Minute)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, but got %v", err)
+		}
+		checkDatabase(t, db, map[string]string{})
+		if len(db.expiry) != 0 {
+			t.Errorf("Expected no deadlines, but got %v", db.expiry)
+		}
+	})
+
+	t.Run("Key too large", func(t *testing.T) {
+		db := StartDatabase(t, OpenTestDB, nil)
+		defer db.Close()
+
+		key := []byte(strings.Repeat("k", MaxKeyLength+1))
+		err := db.PutWithTTL(key, []byte("value"), time.Minute)
+		if !errors.Is(err, ErrKeyTooLarge) {
+			t.Errorf("Expected ErrKeyTooLarge, but got %v", err)
+		}
+	})
+
+	t.Run("Closed database", func(t *testing.T) {
+		db := getClosedDB(t, nil)
+
+		err := db.PutWithTTL([]byte("key"), []byte("value"), time.Minute)
+		if !errors.Is(err, ErrDatabaseClosed) {
+			t.Errorf("Expected ErrDatabaseClosed, but got %v", err)
+		}
+	})
+}
+
+func TestDB_DeleteExpired(t *testing.T) {
+	start := time.Now()
+
+	t.Run("Deletes the expired records", func(t *testing.T) {
+		// Arrange
+		setNow(t, start)
+		db := StartDatabase(t, OpenTestDB, map[string]string{"key-1": "value-1"})
+		defer db.Close()
+		_ = db.PutWithTTL([]byte("key-2"), []byte("value-2"), time.Minute)
+		_ = db.PutWithTTL([]byte("key-3"), []byte("value-3"), time.Hour)
+		setNow(t, start.Add(time.Minute))
+
+		// Act
+		n, err := db.DeleteExpired()
+
+		// Assert
+		if err != nil {
+			t.Errorf("Expected no error, but got %v", err)
+		}
+		if n != 1 {
+			t.Errorf("Expected 1 deleted record, but got %d", n)
+		}
+		path, _ := keyPath(db, []byte("key-2"))
+		if _, err = os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected the record file to be removed, but got %v", err)
+		}
+		if db.metadata.TotalEntries != 2 {
+			t.Errorf("Expected 2 entries, but got %d", db.metadata.TotalEntries)
+		}
+		if len(db.expiry) != 1 {
+			t.Errorf("Expected 1 deadline, but got %v", db.expiry)
+		}
+	})
+
+	t.Run("Context - Cancelled", func(t *testing.T) {
+		// Arrange
+		setNow(t, start)
+		db := StartDatabase(t, OpenTestDB, nil)
+		defer db.Close()
+		_ = db.PutWithTTL([]byte("key"), []byte("value"), time.Minute)
+		setNow(t, start.Add(time.Minute))
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		// Act
+		n, err := db.DeleteExpiredContext(ctx)
+
+		// Assert
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, but got %v", err)
+		}
+		if n != 0 || len(db.expiry) != 1 {
+			t.Errorf("Expected nothing deleted, but got %d, %v", n, db.expiry)
+		}
+	})
+
+	t.Run("Removes the log", func(t *testing.T) {
+		setNow(t, start)
+		db := StartDatabase(t, OpenTestDB, nil)
+		defer db.Close()
+		_ = db.PutWithTTL([]byte("key"), []byte("value"), time.Minute)
+		setNow(t, start.Add(time.Minute))
+
+		if _, err := db.DeleteExpired(); err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		if _, err := os.Stat(expiryPath(db)); !os.IsNotExist(err) {
+			t.Errorf("Expected the log to be removed, but got %v", err)
+		}
+	})
+
+	t.Run("Nothing expired", func(t *testing.T) {
+		setNow(t, start)
+		db := StartDatabase(t, OpenTestDB, nil)
+		defer db.Close()
+		_ = db.PutWithTTL([]byte("key"), []byte("value"), time.Minute)
+
+		n, err := db.DeleteExpired()
+		if err != nil || n != 0 {
+			t.Errorf("Expected 0 and no error, but got %d, %v", n, err)
+		}
+	})
+
+	t.Run("Background deletion", func(t *testing.T) {
+		open := NewOpenFunc(true, WithExpiryInterval(time.Millisecond))
+		db := StartDatabase(t, open, nil)
+		defer db.Close()
+		_ = db.PutWithTTL([]byte("key"), []byte("value"), time.Millisecond)
+
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			db.mu.RLock()
+			n := len(db.expiry)
+			db.mu.RUnlock()
+			if n == 0 {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected the expired record to be deleted")
+			}
+			time.Sleep(time.Millisecond)
+		}
+		if db.metadata.TotalEntries != 0 {
+			t.Errorf("Expected 0 entries, but got %d", db.metadata.TotalEntries)
+		}
+	})
+}
+
+func TestDB_LoadExpiry(t *testing.T) {
+	start := time.Now()
+
+	t.Run("Truncated log", func(t *testing.T) {
+		// Arrange
+		setNow(t, start)
+		db := StartDatabase(t, OpenTestDB, nil)
+		_ = db.PutWithTTL([]byte("key-1"), []byte("value-1"), time.Minute)
+		db.Close()
+
+		f, err := os.OpenFile(expiryPath(db), os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		_, _ = f.Write([]byte{10, 'k', 'e'})
+		f.Close()
+
+		// Act
+		db, err = ReopenTestDB()
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		defer db.Close()
+		_ = db.PutWithTTL([]byte("key-2"), []byte("value-2"), time.Minute)
+		db.Close()
+		db, err = ReopenTestDB()
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		defer db.Close()
+
+		// Assert
+		if len(db.expiry) != 2 {
+			t.Errorf("Expected 2 deadlines, but got %v", db.expiry)
+		}
+	})
+
+	t.Run("Read error", func(t *testing.T) {
+		db := StartDatabase(t, OpenTestDB, nil)
+		db.Close()
+
+		// A directory in the place of the log can't be read.
+		if err := os.Mkdir(filepath.Join(TestDirectory, metadataDirectory, expiryFilename), 0700); err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		if _, err := ReopenTestDB(); err == nil {
+			t.Errorf("Expected error, but got nil")
+		}
+	})
+}
+
+func TestDB_Close_BackgroundLoops(t *testing.T) {
+	// The background loops must stop even if they are waiting for the lock
+	// while the database is closed.
+	for range 20 {
+		open := NewOpenFunc(
+			true, withSyncInterval(time.Microsecond), WithExpiryInterval(time.Microsecond),
+		)
+		db := StartDatabase(t, open, nil)
+		_ = db.PutWithTTL([]byte("key"), []byte("value"), time.Nanosecond)
+		if err := db.Close(); err != nil {
+			t.Errorf("Expected no error, but got %v", err)
+		}
+	}
+}
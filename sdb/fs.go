@@ -36,6 +36,8 @@ type fileSystem interface {
 	Remove(name string) error
 	Rename(oldpath, newpath string) error
 	MkdirAll(path string, perm fs.FileMode) error
+	RemoveAll(path string) error
+	Link(oldname, newname string) error
 }
 
 // OS Filesystem
@@ -75,6 +77,14 @@ func (*osFS) MkdirAll(path string, perm fs.FileMode) error {
 	return os.MkdirAll(path, perm)
 }
 
+func (*osFS) RemoveAll(path string) error {
+	return os.RemoveAll(path)
+}
+
+func (*osFS) Link(oldname, newname string) error {
+	return os.Link(oldname, newname)
+}
+
 // Atomic Writer
 
 // The main object of atomicWrite is to protect against incomplete writes.
@@ -173,7 +183,18 @@ func mkdirs(fs fileSystem, paths []string, perm os.FileMode) error {
 	return nil
 }
 
-func streamDir(fs fileSystem, dir, start string, order int, fn func(filename string) (bool, error)) (bool, error) {
+// streamDir calls fn for each filename in dir, in the given order, starting
+// from start (inclusive). The iteration stops at the first filename for which
+// stop returns true, which is not passed to fn. An empty start value starts
+// from the first filename. It returns false if the iteration was stopped,
+// either by fn or by the stop function.
+func streamDir(
+	fs fileSystem,
+	dir, start string,
+	order int,
+	stop func(filename string) bool,
+	fn func(filename string) (bool, error),
+) (bool, error) {
 	asc := order > Desc
 	needFilter := start != ""
 
@@ -192,6 +213,9 @@ func streamDir(fs fileSystem, dir, start string, order int, fn func(filename str
 			}
 			needFilter = false // boundary crossed -- stop filtering
 		}
+		if stop(name) {
+			return false, nil
+		}
 
 		keep, err := fn(name)
 		if err != nil {
@@ -187,6 +187,8 @@ func TestAtomicWriter_WriteFile_DirSyncError(t *testing.T) {
 }
 
 func TestStreamDir_MockFileSystemError(t *testing.T) {
+	neverStop := func(string) bool { return false }
+
 	t.Run("Cannot open dir", func(t *testing.T) {
 		fsys := &mockFS{
 			openFunc: func(_ string) (fs.File, error) {
@@ -194,7 +196,7 @@ func TestStreamDir_MockFileSystemError(t *testing.T) {
 			},
 		}
 
-		_, err := streamDir(fsys, "test", "", Asc, func(filename string) (bool, error) {
+		_, err := streamDir(fsys, "test", "", Asc, neverStop, func(filename string) (bool, error) {
 			return true, nil
 		})
 
@@ -215,7 +217,7 @@ func TestStreamDir_MockFileSystemError(t *testing.T) {
 			},
 		}
 
-		_, err := streamDir(fsys, "test", "", Asc, func(filename string) (bool, error) {
+		_, err := streamDir(fsys, "test", "", Asc, neverStop, func(filename string) (bool, error) {
 			return true, nil
 		})
 
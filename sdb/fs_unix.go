@@ -2,7 +2,11 @@
 
 package sdb
 
-import "os"
+import (
+	"io/fs"
+	"os"
+	"syscall"
+)
 
 // renameFile atomically replaces the destination file or directory with the
 // source. It is guaranteed to either replace the target file entirely, or not
@@ -10,3 +14,10 @@ import "os"
 func renameFile(oldpath, newpath string) error {
 	return os.Rename(oldpath, newpath)
 }
+
+// isLinked reports whether the file has other hard links, such as the ones
+// created by DB.Snapshot.
+func isLinked(fi fs.FileInfo) bool {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	return ok && st.Nlink > 1
+}
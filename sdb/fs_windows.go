@@ -1,6 +1,7 @@
 package sdb
 
 import (
+	"io/fs"
 	"os"
 	"syscall"
 	"unsafe"
@@ -34,6 +35,12 @@ func renameFile(source, destination string) error {
 	return nil
 }
 
+// isLinked reports whether the file has other hard links. The files are never
+// written in place on Windows, so the links don't need to be detected.
+func isLinked(fs.FileInfo) bool {
+	return false
+}
+
 var (
 	modkernel32     = syscall.NewLazyDLL("kernel32.dll")
 	procMoveFileExW = modkernel32.NewProc("MoveFileExW")
@@ -17,17 +17,26 @@ func initializeDatabase(db *DB) error {
 	}
 
 	if os.IsNotExist(err) {
+		if db.readOnly {
+			return fmt.Errorf("open read-only: %w", err)
+		}
 		return createDatabaseStorage(db)
 	}
 
-	// Check permissions (validate DB folder)
+	// Check permissions (validate DB folder). A read-only database only
+	// needs to be read.
 	if !fi.IsDir() {
 		return fmt.Errorf("path is not a directory")
-	} else if fi.Mode().Perm()&0700 != 0700 {
+	} else if !db.readOnly && fi.Mode().Perm()&0700 != 0700 {
 		return fmt.Errorf("path permissions are not 0700")
 	}
 
-	return loadDatabase(db)
+	if err = db.lockDirectory(); err != nil {
+		return fmt.Errorf("lock: %w", err)
+	}
+	return db.initLocked(func() error {
+		return loadDatabase(db)
+	})
 }
 
 func createDatabaseStorage(db *DB) error {
@@ -41,9 +50,17 @@ func createDatabaseStorage(db *DB) error {
 	if err := mkdirs(db.fs, paths, defaultDirPermissions); err != nil {
 		return fmt.Errorf("create directories: %w", err)
 	}
+	if err := db.lockDirectory(); err != nil {
+		return fmt.Errorf("lock: %w", err)
+	}
+
+	// Record the key encryption scheme
+	db.keys.record(&db.metadata)
 
 	// Sync the database
-	err := db.Sync()
+	err := db.initLocked(func() error {
+		return syncInternal(db)
+	})
 	if err != nil {
 		return fmt.Errorf("sync: %w", err)
 	}
@@ -58,24 +75,41 @@ func loadDatabase(db *DB) error {
 	}
 	db.metadata = meta
 
+	// Check the key encryption scheme before reading any key
+	if err = db.keys.validate(meta); err != nil {
+		return err
+	}
+
 	// Load the shards
 	if err = db.loadShards(); err != nil {
 		return fmt.Errorf("load shards: %w", err)
 	}
 
+	// Load the deadlines of the records with a TTL
+	if err = loadExpiry(db, !db.readOnly); err != nil {
+		return fmt.Errorf("load expiry: %w", err)
+	}
+
 	// Check the DB consistency and possibly recover from a corrupted
 	// state
 	return sanityCheck(db)
 }
 
 // Check version, totalBuckets and the generations. Recover from a corrupted
-// database if the generation checkpoint doesn't match the current generation.
+// database if the generation checkpoint doesn't match the current generation
+// or if an interrupted batch was completed.
 func sanityCheck(db *DB) error {
 	if err := db.metadata.Validate(); err != nil {
 		return err
 	}
+	// Complete a batch interrupted by a crash. Its mutations might not be
+	// accounted for in the metadata, so a recovery is also required.
+	recovered, err := recoverBatch(db)
+	if err != nil {
+		return fmt.Errorf("recover batch: %w", err)
+	}
 	// Check generations
-	if db.metadata.Generation != db.metadata.Checkpoint {
+	if recovered || db.metadata.Generation != db.metadata.Checkpoint {
 		return recoverDatabase(db)
 	}
 	return nil
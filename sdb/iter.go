@@ -0,0 +1,70 @@
+package sdb
+
+import "iter"
+
+// All returns an iterator over all key-value pairs in the database, in
+// ascending lexical order, and a function that returns the error that
+// stopped the iteration. It is the range-over-func counterpart of [DB.Items]
+// and can be used in a for-range loop:
+//
+//	items, errf := db.All()
+//	for k, v := range items {
+//	    ...
+//	}
+//	if err := errf(); err != nil {
+//	    ...
+//	}
+//
+// The error function returns the error that stopped the last loop over the
+// iterator, and nil if the loop completed or was stopped by its body. Each
+// call returns a new iterator with its own error, so concurrent iterations
+// don't affect each other, but a single iterator must not be used by
+// multiple goroutines at once.
+//
+// The read lock is held while the loop body runs, so the body must not
+// modify the database, as this would cause a deadlock. The yielded slices
+// must not be retained after each loop iteration.
+func (db *DB) All() (iter.Seq2[[]byte, []byte], func() error) {
+	return seq(func(fn Yield) error {
+		return db.Items(nil, Asc, fn)
+	})
+}
+
+// Backward returns an iterator over all key-value pairs in the database, in
+// descending lexical order. The details of the iteration are the same as for
+// [DB.All].
+func (db *DB) Backward() (iter.Seq2[[]byte, []byte], func() error) {
+	return seq(func(fn Yield) error {
+		return db.Items(nil, Desc, fn)
+	})
+}
+
+// From returns an iterator over the key-value pairs in the database, in
+// ascending lexical order, starting from the start key (inclusive). The
+// details of the iteration are the same as for [DB.All].
+func (db *DB) From(start []byte) (iter.Seq2[[]byte, []byte], func() error) {
+	return seq(func(fn Yield) error {
+		return db.Items(start, Asc, fn)
+	})
+}
+
+// Prefix returns an iterator over the key-value pairs in the database whose
+// keys start with the given prefix, in ascending lexical order. The details
+// of the iteration are the same as for [DB.All].
+func (db *DB) Prefix(prefix []byte) (iter.Seq2[[]byte, []byte], func() error) {
+	return seq(func(fn Yield) error {
+		return db.ItemsPrefix(prefix, Asc, fn)
+	})
+}
+
+// seq returns an iterator over the items yielded by iterate, and a function
+// that returns the error of its last run.
+func seq(iterate func(fn Yield) error) (iter.Seq2[[]byte, []byte], func() error) {
+	var err error
+	items := func(yield func(k, v []byte) bool) {
+		err = iterate(func(k, v []byte) (bool, error) {
+			return yield(k, v), nil
+		})
+	}
+	return items, func() error { return err }
+}
@@ -0,0 +1,122 @@
+package sdb
+
+import (
+	"errors"
+	"io/fs"
+	"iter"
+	"maps"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestDB_All(t *testing.T) {
+	seed := map[string]string{
+		"key-1": "value-1", "key-2": "value-2", "key-3": "value-3",
+		"key-4": "value-4", "key-5": "value-5", "key-6": "value-6",
+	}
+	keysAsc := slices.Sorted(maps.Keys(seed))
+	keysDesc := slices.Clone(keysAsc)
+	slices.Reverse(keysDesc)
+
+	db := StartDatabase(t, OpenTestDB, seed)
+	defer db.Close()
+
+	collect := func(seq iter.Seq2[[]byte, []byte], errf func() error) []string {
+		var keys []string
+		for k, v := range seq {
+			if string(v) != seed[string(k)] {
+				t.Errorf("Expected value %s, but got %s", seed[string(k)], v)
+			}
+			keys = append(keys, string(k))
+		}
+		if err := errf(); err != nil {
+			t.Errorf("Expected no error, but got %v", err)
+		}
+		return keys
+	}
+
+	t.Run("All", func(t *testing.T) {
+		got := collect(db.All())
+		if !reflect.DeepEqual(got, keysAsc) {
+			t.Errorf("Expected %v, but got %v", keysAsc, got)
+		}
+	})
+
+	t.Run("Backward", func(t *testing.T) {
+		got := collect(db.Backward())
+		if !reflect.DeepEqual(got, keysDesc) {
+			t.Errorf("Expected %v, but got %v", keysDesc, got)
+		}
+	})
+
+	t.Run("From", func(t *testing.T) {
+		got := collect(db.From([]byte("key-4")))
+		if !reflect.DeepEqual(got, keysAsc[3:]) {
+			t.Errorf("Expected %v, but got %v", keysAsc[3:], got)
+		}
+	})
+
+	t.Run("Break", func(t *testing.T) {
+		var got []string
+		items, errf := db.All()
+		for k := range items {
+			got = append(got, string(k))
+			if len(got) == 3 {
+				break
+			}
+		}
+		if !reflect.DeepEqual(got, keysAsc[:3]) {
+			t.Errorf("Expected %v, but got %v", keysAsc[:3], got)
+		}
+		if err := errf(); err != nil {
+			t.Errorf("Expected no error, but got %v", err)
+		}
+	})
+}
+
+func TestDB_All_Error(t *testing.T) {
+	t.Run("Closed database", func(t *testing.T) {
+		db := getClosedDB(t, map[string]string{"key-1": "value-1"})
+
+		items, errf := db.All()
+		for range items {
+			t.Errorf("Expected no items")
+		}
+
+		if err := errf(); !errors.Is(err, ErrDatabaseClosed) {
+			t.Errorf("Expected %v, but got %v", ErrDatabaseClosed, err)
+		}
+	})
+
+	t.Run("File system error", func(t *testing.T) {
+		fsys := &mockFS{}
+		open := NewOpenFunc(true, withFileSystem(fsys))
+		db := StartDatabase(t, open, map[string]string{"key-1": "value-1"})
+		defer db.Close()
+
+		fsys.openFunc = func(string) (fs.File, error) {
+			return nil, fs.ErrPermission
+		}
+		items, errf := db.Backward()
+		for range items {
+			t.Errorf("Expected no items")
+		}
+
+		if err := errf(); !errors.Is(err, fs.ErrPermission) {
+			t.Errorf("Expected %v, but got %v", fs.ErrPermission, err)
+		}
+
+		// The error is kept by the iterator, even after another one succeeds
+		fsys.openFunc = nil
+		items2, errf2 := db.All()
+		for range items2 {
+		}
+		if err := errf2(); err != nil {
+			t.Errorf("Expected no error, but got %v", err)
+		}
+		if err := errf(); !errors.Is(err, fs.ErrPermission) {
+			t.Errorf("Expected %v, but got %v", fs.ErrPermission, err)
+		}
+	})
+}
@@ -0,0 +1,370 @@
+package sdb
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"hash"
+	"sort"
+)
+
+// KeyEncryption is a scheme used to encrypt the keys of the records, set with
+// [WithKeyEncryption].
+type KeyEncryption int
+
+const (
+	// OrderedKeys encrypts the keys with a deterministic, order-preserving
+	// scheme: the filenames of the records sort like their keys, so the
+	// iteration is as fast as with plain keys. The keys are limited to
+	// [MaxOrderedKeyLength] bytes.
+	//
+	// It only obscures the keys, which remain approximately readable: each
+	// byte is replaced by a code that grows with it, by about 8 per value on
+	// average, so the filenames reveal the value of every byte of the keys
+	// to within a few units. The first byte of all the keys is encoded with
+	// the same table. The filenames also reveal the order of the keys, which
+	// keys share a common prefix and its length. Use HashedKeys if the keys
+	// must be kept confidential.
+	OrderedKeys KeyEncryption = iota + 1
+
+	// HashedKeys encrypts the keys with a deterministic scheme, using a
+	// keyed hash of the key as the initialization vector, so the filenames
+	// reveal only the length of the keys. Since the filenames don't sort
+	// like the keys, the iteration methods read and sort the keys of all
+	// the records in the iterated range before yielding the first one.
+	HashedKeys
+)
+
+// MaxOrderedKeyLength is the maximum size of a key when the keys are
+// encrypted with [OrderedKeys].
+const MaxOrderedKeyLength = 86
+
+const (
+	// minKeySecretSize is the minimum size of the secret given to
+	// WithKeyEncryption.
+	minKeySecretSize = 16
+
+	// hashedTagSize is the size of the keyed hash that starts the encrypted
+	// keys of HashedKeys.
+	hashedTagSize = 10
+
+	// orderedCodeBits is the size of the code of each byte of the keys
+	// encrypted with OrderedKeys.
+	orderedCodeBits = 12
+
+	// orderedMaxGap is the maximum distance between the codes of
+	// consecutive byte values, minus one. The codes of the 256 values must
+	// fit in orderedCodeBits.
+	orderedMaxGap = 15
+)
+
+// ErrKeyMismatch is returned by [Open] when the key encryption scheme or
+// secret doesn't match the ones the database was created with.
+var ErrKeyMismatch = errors.New("key encryption doesn't match the database")
+
+var errInvalidFilename = errors.New("invalid record filename")
+
+var (
+	plainKeyEncoding     = base32.HexEncoding
+	encryptedKeyEncoding = base32.HexEncoding.WithPadding(base32.NoPadding)
+)
+
+// String returns the name of the scheme, as recorded in the metadata.
+func (e KeyEncryption) String() string {
+	switch e {
+	case 0:
+		return "none"
+	case OrderedKeys:
+		return "ordered"
+	case HashedKeys:
+		return "hashed"
+	default:
+		return fmt.Sprintf("KeyEncryption(%d)", int(e))
+	}
+}
+
+// keyScheme converts the keys to the filenames of the records, and to the
+// form in which they are stored in the expiry log and the batch journal.
+//
+// Without encryption, the filename is the base32hex encoding of the key. With
+// encryption, it is the unpadded base32hex encoding of the encrypted key,
+// which preserves the order of the encrypted keys (the padding character
+// sorts after the digits).
+type keyScheme struct {
+	mode   KeyEncryption
+	secret []byte
+
+	// Derived from the secret by init.
+	block cipher.Block
+	mac   []byte
+	check []byte
+}
+
+// init validates the scheme and derives the keys from the secret.
+func (s *keyScheme) init() error {
+	if s.mode == 0 {
+		return nil
+	}
+	if s.mode != OrderedKeys && s.mode != HashedKeys {
+		return fmt.Errorf("invalid scheme %v", s.mode)
+	}
+	if len(s.secret) < minKeySecretSize {
+		return fmt.Errorf("secret must have at least %d bytes", minKeySecretSize)
+	}
+
+	block, err := aes.NewCipher(deriveKey(s.secret, "sdb key encryption"))
+	if err != nil {
+		return err
+	}
+	s.block = block
+	s.mac = deriveKey(s.secret, "sdb key authentication")
+	s.check = deriveKey(s.secret, "sdb key check")
+	s.secret = nil
+	return nil
+}
+
+func deriveKey(secret []byte, label string) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(label))
+	return h.Sum(nil)
+}
+
+// validate checks that the scheme matches the one recorded in the metadata.
+func (s *keyScheme) validate(m metadata) error {
+	recorded := m.KeyEncryption
+	if recorded == "" {
+		recorded = KeyEncryption(0).String()
+	}
+	if recorded != s.mode.String() {
+		return fmt.Errorf("%w: the database keys are %q, not %q",
+			ErrKeyMismatch, recorded, s.mode.String())
+	}
+	if !hmac.Equal(m.KeyCheck, s.check) {
+		return fmt.Errorf("%w: wrong secret", ErrKeyMismatch)
+	}
+	return nil
+}
+
+// record stores the scheme in the metadata.
+func (s *keyScheme) record(m *metadata) {
+	m.KeyEncryption = s.mode.String()
+	m.KeyCheck = s.check
+}
+
+// ordered reports whether the filenames sort like the keys.
+func (s *keyScheme) ordered() bool {
+	return s.mode != HashedKeys
+}
+
+func (s *keyScheme) maxKeyLength() int {
+	if s.mode == OrderedKeys {
+		return MaxOrderedKeyLength
+	}
+	return MaxKeyLength
+}
+
+// encode returns the filename of the key.
+func (s *keyScheme) encode(key []byte) string {
+	if s.mode == 0 {
+		return plainKeyEncoding.EncodeToString(key)
+	}
+	return encryptedKeyEncoding.EncodeToString(s.seal(key))
+}
+
+// decode returns the key of the filename. It is the inverse of encode.
+func (s *keyScheme) decode(name string) ([]byte, error) {
+	if s.mode == 0 {
+		return plainKeyEncoding.DecodeString(name)
+	}
+	data, err := encryptedKeyEncoding.DecodeString(name)
+	if err != nil {
+		return nil, err
+	}
+	return s.open(data)
+}
+
+// seal returns the key encrypted, or the key itself without encryption.
+func (s *keyScheme) seal(key []byte) []byte {
+	switch s.mode {
+	case OrderedKeys:
+		return s.sealOrdered(key)
+	case HashedKeys:
+		return s.sealHashed(key)
+	default:
+		return key
+	}
+}
+
+// open decrypts a key encrypted by seal.
+func (s *keyScheme) open(data []byte) ([]byte, error) {
+	switch s.mode {
+	case OrderedKeys:
+		return s.openOrdered(data)
+	case HashedKeys:
+		return s.openHashed(data)
+	default:
+		return data, nil
+	}
+}
+
+// Hashed Keys
+//
+// The encrypted key is the keyed hash of the key, truncated, followed by the
+// key encrypted with AES-CTR, using the hash as the initialization vector.
+
+func (s *keyScheme) sealHashed(key []byte) []byte {
+	out := make([]byte, hashedTagSize+len(key))
+	copy(out, s.tag(key))
+	s.xorHashed(out[hashedTagSize:], key, out[:hashedTagSize])
+	return out
+}
+
+func (s *keyScheme) openHashed(data []byte) ([]byte, error) {
+	if len(data) < hashedTagSize {
+		return nil, errInvalidFilename
+	}
+	tag := data[:hashedTagSize]
+	key := make([]byte, len(data)-hashedTagSize)
+	s.xorHashed(key, data[hashedTagSize:], tag)
+	if !hmac.Equal(tag, s.tag(key)) {
+		return nil, errInvalidFilename
+	}
+	return key, nil
+}
+
+func (s *keyScheme) tag(key []byte) []byte {
+	h := hmac.New(sha256.New, s.mac)
+	h.Write(key)
+	return h.Sum(nil)[:hashedTagSize]
+}
+
+func (s *keyScheme) xorHashed(dst, src, tag []byte) {
+	iv := make([]byte, aes.BlockSize)
+	copy(iv, tag)
+	cipher.NewCTR(s.block, iv).XORKeyStream(dst, src)
+}
+
+// Ordered Keys
+//
+// Each byte of the key is replaced by a 12-bit code, taken from a strictly
+// increasing table of codes for the 256 byte values. The table is derived
+// from a keyed hash of the bytes that precede it, so equal bytes are encoded
+// differently after different prefixes. Since the keys are compared at their
+// first different byte, which follows the same prefix in both keys, the
+// encrypted keys sort like the keys.
+//
+// The gaps between the codes average 8, so a code reveals the approximate
+// value of its byte. This is inherent to the order-preserving encoding of
+// single bytes, and it is documented on OrderedKeys.
+
+type orderedTable [256]uint16
+
+func (s *keyScheme) sealOrdered(key []byte) []byte {
+	out := make([]byte, (len(key)*orderedCodeBits+7)/8)
+	h := hmac.New(sha256.New, s.mac)
+	state := h.Sum(nil)
+
+	var table orderedTable
+	for i, b := range key {
+		s.fillTable(&table, state)
+		putCode(out, i, table[b])
+		state = nextState(h, state, b)
+	}
+	return out
+}
+
+func (s *keyScheme) openOrdered(data []byte) ([]byte, error) {
+	n := len(data) * 8 / orderedCodeBits
+	if len(data) != (n*orderedCodeBits+7)/8 || (n%2 == 1 && data[len(data)-1]&0x0f != 0) {
+		return nil, errInvalidFilename
+	}
+
+	key := make([]byte, n)
+	h := hmac.New(sha256.New, s.mac)
+	state := h.Sum(nil)
+
+	var table orderedTable
+	for i := range key {
+		s.fillTable(&table, state)
+		code := getCode(data, i)
+		b := sort.Search(len(table), func(j int) bool { return table[j] >= code })
+		if b == len(table) || table[b] != code {
+			return nil, errInvalidFilename
+		}
+		key[i] = byte(b)
+		state = nextState(h, state, key[i])
+	}
+	return key, nil
+}
+
+// fillTable fills the table with the codes of the byte values that follow the
+// prefix whose keyed hash is the given state.
+func (s *keyScheme) fillTable(table *orderedTable, state []byte) {
+	var gaps [256]byte
+	cipher.NewCTR(s.block, state[:aes.BlockSize]).XORKeyStream(gaps[:], gaps[:])
+
+	var code uint16
+	for i, g := range gaps {
+		code += uint16(g%orderedMaxGap) + 1
+		table[i] = code - 1
+	}
+}
+
+// nextState returns the state that follows the byte b.
+func nextState(h hash.Hash, state []byte, b byte) []byte {
+	h.Reset()
+	h.Write(state)
+	h.Write([]byte{b})
+	return h.Sum(state[:0])
+}
+
+// putCode writes the i-th 12-bit code, which starts either at a byte boundary
+// or at the middle of a byte.
+func putCode(data []byte, i int, code uint16) {
+	j := i * orderedCodeBits / 8
+	if i%2 == 0 {
+		data[j] = byte(code >> 4)
+		data[j+1] |= byte(code << 4)
+	} else {
+		data[j] |= byte(code >> 8)
+		data[j+1] = byte(code)
+	}
+}
+
+func getCode(data []byte, i int) uint16 {
+	j := i * orderedCodeBits / 8
+	if i%2 == 0 {
+		return uint16(data[j])<<4 | uint16(data[j+1]>>4)
+	}
+	return uint16(data[j]&0x0f)<<8 | uint16(data[j+1])
+}
+
+// keyInRange reports whether the key is within the bounds of an iteration in
+// the given order: from start (inclusive) to end (exclusive). Empty bounds
+// are unbounded.
+func keyInRange(key, start, end []byte, order int) bool {
+	if order == Asc {
+		return (len(start) == 0 || bytes.Compare(key, start) >= 0) &&
+			(len(end) == 0 || bytes.Compare(key, end) < 0)
+	}
+	return (len(start) == 0 || bytes.Compare(key, start) <= 0) &&
+		(len(end) == 0 || bytes.Compare(key, end) > 0)
+}
+
+// prefixEnd returns the smallest key that is greater than all the keys
+// starting with prefix, or nil if there is no such key.
+func prefixEnd(prefix []byte) []byte {
+	end := bytes.Clone(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,365 @@
+package sdb
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestKeyScheme(t *testing.T, mode KeyEncryption) *keyScheme {
+	t.Helper()
+	s := &keyScheme{mode: mode, secret: TestSecret}
+	if err := s.init(); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	return s
+}
+
+func randomKeys(n, maxLen int) [][]byte {
+	r := rand.New(rand.NewSource(1))
+	keys := [][]byte{{}, {0x00}, {0xff}, {0xff, 0xff}, {'a'}, {'a', 0x00}, {'a', 'b'}}
+	for range n {
+		key := make([]byte, r.Intn(maxLen+1))
+		for i := range key {
+			// Few distinct bytes, to have many common prefixes.
+			key[i] = []byte{0x00, 'a', 'b', 0x7f, 0xff}[r.Intn(5)]
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func TestKeyScheme_Encode(t *testing.T) {
+	for _, mode := range []KeyEncryption{0, OrderedKeys, HashedKeys} {
+		t.Run(mode.String(), func(t *testing.T) {
+			s := newTestKeyScheme(t, mode)
+			for _, key := range randomKeys(500, s.maxKeyLength()) {
+				name := s.encode(key)
+				got, err := s.decode(name)
+				if err != nil {
+					t.Fatalf("Expected no error, but got %v", err)
+				}
+				if !bytes.Equal(got, key) {
+					t.Errorf("Expected %x, but got %x", key, got)
+				}
+				if len(name)+32 > 255 {
+					t.Errorf("Expected a shorter filename, but got %d bytes", len(name))
+				}
+			}
+		})
+	}
+
+	t.Run("Deterministic", func(t *testing.T) {
+		for _, mode := range []KeyEncryption{OrderedKeys, HashedKeys} {
+			a := newTestKeyScheme(t, mode).encode([]byte("key"))
+			b := newTestKeyScheme(t, mode).encode([]byte("key"))
+			if a != b {
+				t.Errorf("Expected %s, but got %s", a, b)
+			}
+			other := &keyScheme{mode: mode, secret: bytes.Repeat([]byte{1}, 32)}
+			_ = other.init()
+			if c := other.encode([]byte("key")); c == a {
+				t.Errorf("Expected a different filename with another secret")
+			}
+		}
+	})
+}
+
+func TestKeyScheme_Order(t *testing.T) {
+	s := newTestKeyScheme(t, OrderedKeys)
+	keys := randomKeys(2000, 8)
+	slices.SortFunc(keys, bytes.Compare)
+
+	for i := 1; i < len(keys); i++ {
+		a, b := s.encode(keys[i-1]), s.encode(keys[i])
+		cmp := bytes.Compare(keys[i-1], keys[i])
+		if strings.Compare(a, b) != cmp {
+			t.Fatalf("Expected the filenames of %x and %x to compare as %d", keys[i-1], keys[i], cmp)
+		}
+	}
+}
+
+func TestKeyScheme_Decode(t *testing.T) {
+	tests := []struct {
+		name string
+		mode KeyEncryption
+		data func(s *keyScheme) []byte
+	}{
+		{
+			name: "Ordered, tampered",
+			mode: OrderedKeys,
+			data: func(s *keyScheme) []byte {
+				data := s.seal([]byte("key-1"))
+				data[1] ^= 0xff
+				return data
+			},
+		},
+		{
+			name: "Ordered, invalid length",
+			mode: OrderedKeys,
+			data: func(s *keyScheme) []byte {
+				return append(s.seal([]byte("key-1")), 0)
+			},
+		},
+		{
+			name: "Ordered, invalid padding",
+			mode: OrderedKeys,
+			data: func(s *keyScheme) []byte {
+				data := s.seal([]byte("key-1"))
+				data[len(data)-1] |= 1
+				return data
+			},
+		},
+		{
+			name: "Hashed, tampered",
+			mode: HashedKeys,
+			data: func(s *keyScheme) []byte {
+				data := s.seal([]byte("key-1"))
+				data[len(data)-1] ^= 1
+				return data
+			},
+		},
+		{
+			name: "Hashed, too short",
+			mode: HashedKeys,
+			data: func(*keyScheme) []byte { return []byte("short") },
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newTestKeyScheme(t, tc.mode)
+
+			_, err := s.decode(encryptedKeyEncoding.EncodeToString(tc.data(s)))
+
+			if !errors.Is(err, errInvalidFilename) {
+				t.Errorf("Expected errInvalidFilename, but got %v", err)
+			}
+		})
+	}
+
+	t.Run("Invalid encoding", func(t *testing.T) {
+		s := newTestKeyScheme(t, HashedKeys)
+		if _, err := s.decode("invalid!"); err == nil {
+			t.Errorf("Expected an error, but got nil")
+		}
+	})
+}
+
+func TestOpen_KeyEncryption(t *testing.T) {
+	otherSecret := bytes.Repeat([]byte{1}, 32)
+
+	tests := []struct {
+		name     string
+		create   []Option
+		reopen   []Option
+		expected error
+	}{
+		{
+			name:     "Same secret",
+			create:   []Option{WithKeyEncryption(OrderedKeys, TestSecret)},
+			reopen:   []Option{WithKeyEncryption(OrderedKeys, TestSecret)},
+			expected: nil,
+		},
+		{
+			name:     "Wrong secret",
+			create:   []Option{WithKeyEncryption(OrderedKeys, TestSecret)},
+			reopen:   []Option{WithKeyEncryption(OrderedKeys, otherSecret)},
+			expected: ErrKeyMismatch,
+		},
+		{
+			name:     "Wrong scheme",
+			create:   []Option{WithKeyEncryption(OrderedKeys, TestSecret)},
+			reopen:   []Option{WithKeyEncryption(HashedKeys, TestSecret)},
+			expected: ErrKeyMismatch,
+		},
+		{
+			name:     "Without encryption",
+			create:   []Option{WithKeyEncryption(HashedKeys, TestSecret)},
+			reopen:   nil,
+			expected: ErrKeyMismatch,
+		},
+		{
+			name:     "Plain database",
+			create:   nil,
+			reopen:   []Option{WithKeyEncryption(HashedKeys, TestSecret)},
+			expected: ErrKeyMismatch,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			db := StartDatabase(t, NewOpenFunc(true, tc.create...), map[string]string{"key": "value"})
+			_ = db.Close()
+
+			// Act
+			db, err := NewOpenFunc(false, tc.reopen...)()
+
+			// Assert
+			if !errors.Is(err, tc.expected) {
+				t.Fatalf("Expected %v, but got %v", tc.expected, err)
+			}
+			if err == nil {
+				defer db.Close()
+				if v, _ := db.Get([]byte("key")); string(v) != "value" {
+					t.Errorf("Expected value, but got %s", v)
+				}
+			}
+		})
+	}
+
+	t.Run("Invalid options", func(t *testing.T) {
+		for _, option := range []Option{
+			WithKeyEncryption(OrderedKeys, []byte("short")),
+			WithKeyEncryption(KeyEncryption(3), TestSecret),
+		} {
+			_, err := NewOpenFunc(true, option)()
+			if err == nil {
+				t.Errorf("Expected an error, but got nil")
+			}
+		}
+	})
+}
+
+func TestDB_KeyEncryption(t *testing.T) {
+	secretKey := []byte("alice@example.com")
+
+	for _, mode := range []KeyEncryption{OrderedKeys, HashedKeys} {
+		t.Run(mode.String(), func(t *testing.T) {
+			t.Run("Keys are not stored in plain form", func(t *testing.T) {
+				// Arrange
+				db, err := NewOpenFunc(true, WithKeyEncryption(mode, TestSecret))()
+				if err != nil {
+					t.Fatalf("Expected no error, but got %v", err)
+				}
+				defer db.Close()
+
+				// Act
+				_ = db.PutWithTTL(secretKey, []byte("value"), time.Hour)
+				err = saveJournal(db, []batchEntry{{Key: secretKey, Value: []byte("value")}})
+				if err != nil {
+					t.Fatalf("Expected no error, but got %v", err)
+				}
+
+				// Assert
+				err = filepath.WalkDir(TestDirectory, func(path string, d fs.DirEntry, err error) error {
+					if err != nil || d.IsDir() {
+						return err
+					}
+					data, err := os.ReadFile(path)
+					if bytes.Contains(data, secretKey) {
+						t.Errorf("Expected the key to be encrypted in %s", path)
+					}
+					return err
+				})
+				if err != nil {
+					t.Errorf("Expected no error, but got %v", err)
+				}
+
+				entries, err := loadJournal(db)
+				if err != nil || !bytes.Equal(entries[0].Key, secretKey) {
+					t.Errorf("Expected the key to be decrypted, but got %v, %v", entries, err)
+				}
+			})
+
+			t.Run("Expiry log", func(t *testing.T) {
+				// Arrange
+				option := WithKeyEncryption(mode, TestSecret)
+				db, _ := NewOpenFunc(true, option)()
+				_ = db.PutWithTTL(secretKey, []byte("value"), time.Hour)
+				_ = db.Close()
+
+				// Act
+				db, err := NewOpenFunc(false, option)()
+				if err != nil {
+					t.Fatalf("Expected no error, but got %v", err)
+				}
+				defer db.Close()
+
+				// Assert
+				if _, ok := db.expiry[string(secretKey)]; !ok {
+					t.Errorf("Expected the deadline of the key to be loaded")
+				}
+			})
+
+			t.Run("Prefix", func(t *testing.T) {
+				// Arrange
+				seed := map[string]string{
+					"a": "1", "\xfe": "2", "\xff": "3", "\xff\x00": "4", "\xff\xff": "5",
+				}
+				option := WithKeyEncryption(mode, TestSecret)
+				db := StartDatabase(t, NewOpenFunc(true, option, TestFilesPerShardOption), seed)
+				defer db.Close()
+
+				for _, order := range []int{Asc, Desc} {
+					// Act
+					var got []string
+					err := db.ItemsPrefix([]byte("\xff"), order, func(k, _ []byte) (bool, error) {
+						got = append(got, string(k))
+						return true, nil
+					})
+
+					// Assert
+					if err != nil {
+						t.Errorf("Expected no error, but got %v", err)
+					}
+					expected := []string{"\xff", "\xff\x00", "\xff\xff"}
+					if order == Desc {
+						slices.Reverse(expected)
+					}
+					if !reflect.DeepEqual(got, expected) {
+						t.Errorf("Expected %q, but got %q", expected, got)
+					}
+				}
+
+				var got []string
+				_ = db.ItemsPrefix([]byte("\xfe"), Desc, func(k, _ []byte) (bool, error) {
+					got = append(got, string(k))
+					return true, nil
+				})
+				if !reflect.DeepEqual(got, []string{"\xfe"}) {
+					t.Errorf("Expected [\\xfe], but got %q", got)
+				}
+			})
+		})
+	}
+
+	t.Run("Key too large", func(t *testing.T) {
+		db, _ := NewOpenFunc(true, WithKeyEncryption(OrderedKeys, TestSecret))()
+		defer db.Close()
+
+		if err := db.Put(bytes.Repeat([]byte{0xff}, MaxOrderedKeyLength), nil); err != nil {
+			t.Errorf("Expected no error, but got %v", err)
+		}
+		err := db.Put(bytes.Repeat([]byte{0xff}, MaxOrderedKeyLength+1), nil)
+		if !errors.Is(err, ErrKeyTooLarge) {
+			t.Errorf("Expected ErrKeyTooLarge, but got %v", err)
+		}
+	})
+
+	t.Run("Invalid filename", func(t *testing.T) {
+		// Arrange
+		db, _ := NewOpenFunc(true, WithKeyEncryption(HashedKeys, TestSecret))()
+		defer db.Close()
+		_ = db.Put([]byte("key"), []byte("value"))
+		err := os.WriteFile(filepath.Join(db.shardPath(0), "INVALID"), nil, 0600)
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+
+		// Act
+		err = db.Items(nil, Asc, func(_, _ []byte) (bool, error) { return true, nil })
+
+		// Assert
+		if !errors.Is(err, errInvalidFilename) {
+			t.Errorf("Expected errInvalidFilename, but got %v", err)
+		}
+	})
+}
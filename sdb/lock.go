@@ -0,0 +1,227 @@
+package sdb
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sync"
+
+	"github.com/lucmq/go-shelve/sdb/internal"
+)
+
+// ErrLocked is returned by Open when the database is locked by another
+// process, in a mode that doesn't allow it to be opened.
+var ErrLocked = errors.New("database is locked by another process")
+
+// fder is implemented by the files that can be locked, like *os.File.
+type fder interface {
+	Fd() uintptr
+}
+
+// lockDirectory locks the database directory for as long as the database is
+// open. The lock is exclusive, unless the database is read-only or shared by
+// many processes. The locks are advisory, and they are only supported on the
+// platforms with flock. The caller must release the lock with unlock.
+func (db *DB) lockDirectory() error {
+	dir, err := db.fs.Open(db.path)
+	if err != nil {
+		return fmt.Errorf("open directory: %w", err)
+	}
+	exclusive := !db.readOnly && !db.multiProcess
+	if err = lockFile(dir, exclusive, false); err != nil {
+		_ = dir.Close()
+		return err
+	}
+	db.dirLock = dir
+
+	if !db.multiProcess {
+		return nil
+	}
+	// The metadata directory is locked during each operation, like the
+	// database directory, which is always locked in shared mode.
+	meta, err := db.fs.Open(filepath.Join(db.path, metadataDirectory))
+	if err != nil {
+		return fmt.Errorf("open metadata directory: %w", err)
+	}
+	db.procLock = &processLock{file: meta}
+	return nil
+}
+
+// unlock releases the locks of the database. The operations of the other
+// goroutines must be done, or they must be waiting for the database lock.
+func (db *DB) unlock() error {
+	var errs []error
+	if db.procLock != nil {
+		errs = append(errs, db.procLock.close())
+	}
+	if db.dirLock != nil {
+		errs = append(errs, unlockFile(db.dirLock), db.dirLock.Close())
+		db.dirLock = nil
+	}
+	return errors.Join(errs...)
+}
+
+// lockProcess starts an operation in a database shared by many processes,
+// locking it for reading or writing and loading the changes made by the other
+// processes. It does nothing if the database isn't shared. Each call must be
+// paired with a call to unlockProcess.
+func (db *DB) lockProcess(write bool) error {
+	if db.procLock == nil {
+		return nil
+	}
+	write = write && !db.readOnly
+	if err := db.procLock.lock(write); err != nil {
+		return err
+	}
+	if err := db.refresh(write); err != nil {
+		db.procLock.unlock(write)
+		return fmt.Errorf("refresh: %w", err)
+	}
+	return nil
+}
+
+// unlockProcess ends an operation started with lockProcess. The metadata is
+// saved after each write, so the other processes can detect the changes.
+// An error is ignored, since the metadata was marked as changed before the
+// write, so the other processes will recover the database.
+func (db *DB) unlockProcess(write bool) {
+	if db.procLock == nil {
+		return
+	}
+	write = write && !db.readOnly
+	if write {
+		db.mu.Lock()
+		if !db.closed {
+			_ = syncInternal(db)
+		}
+		db.mu.Unlock()
+	}
+	db.procLock.unlock(write)
+}
+
+// refresh reloads the shards, the cache and the deadlines if the metadata on
+// disk was changed by another process, as detected by its generation. The
+// caller must hold the process lock.
+func (db *DB) refresh(write bool) error {
+	meta, err := db.metadataStore.Load()
+	if err != nil {
+		return fmt.Errorf("load metadata: %w", err)
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.closed {
+		return ErrDatabaseClosed
+	}
+	if meta.Generation == db.metadata.Generation &&
+		meta.Checkpoint == db.metadata.Checkpoint &&
+		meta.TotalEntries == db.metadata.TotalEntries {
+		return nil
+	}
+
+	db.metadata = meta
+	if err = db.loadShards(); err != nil {
+		return fmt.Errorf("load shards: %w", err)
+	}
+	db.cache = internal.NewCache[cacheEntry](db.cacheSize)
+	db.expiry = make(map[string]int64)
+	if err = loadExpiry(db, write); err != nil {
+		return fmt.Errorf("load expiry: %w", err)
+	}
+
+	// If a process crashed while writing, the database is recovered by
+	// the next writer, while the readers only count the records.
+	if write {
+		return sanityCheck(db)
+	}
+	if meta.Generation != meta.Checkpoint {
+		return recountItems(db)
+	}
+	return nil
+}
+
+// initLocked runs fn, which initializes the database, while holding the
+// process lock, if the database is shared by many processes.
+func (db *DB) initLocked(fn func() error) error {
+	if db.procLock == nil {
+		return fn()
+	}
+	write := !db.readOnly
+	if err := db.procLock.lock(write); err != nil {
+		return err
+	}
+	defer db.procLock.unlock(write)
+	return fn()
+}
+
+// processLock is a readers-writer lock shared by the goroutines of a process
+// and by the processes that opened the database with WithMultiProcess. The
+// file locks are owned by the process, so the file is locked by the first
+// reader and unlocked by the last one.
+type processLock struct {
+	rw      sync.RWMutex
+	mu      sync.Mutex // Guards readers
+	readers int
+	file    fs.File // Nil once closed
+}
+
+func (l *processLock) lock(write bool) error {
+	if write {
+		l.rw.Lock()
+		if l.file == nil {
+			l.rw.Unlock()
+			return ErrDatabaseClosed
+		}
+		if err := lockFile(l.file, true, true); err != nil {
+			l.rw.Unlock()
+			return err
+		}
+		return nil
+	}
+
+	l.rw.RLock()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		l.rw.RUnlock()
+		return ErrDatabaseClosed
+	}
+	if l.readers == 0 {
+		if err := lockFile(l.file, false, true); err != nil {
+			l.rw.RUnlock()
+			return err
+		}
+	}
+	l.readers++
+	return nil
+}
+
+func (l *processLock) unlock(write bool) {
+	if write {
+		_ = unlockFile(l.file)
+		l.rw.Unlock()
+		return
+	}
+
+	l.mu.Lock()
+	l.readers--
+	if l.readers == 0 {
+		_ = unlockFile(l.file)
+	}
+	l.mu.Unlock()
+	l.rw.RUnlock()
+}
+
+// close waits for the operations holding the lock and closes the file.
+func (l *processLock) close() error {
+	l.rw.Lock()
+	defer l.rw.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
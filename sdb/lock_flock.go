@@ -0,0 +1,48 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package sdb
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"syscall"
+)
+
+// lockFile locks the file with flock, in exclusive or shared mode. If wait is
+// false and the file is locked by another process, it returns ErrLocked.
+// Files that aren't backed by the operating system aren't locked.
+func lockFile(f fs.File, exclusive, wait bool) error {
+	fd, ok := f.(fder)
+	if !ok {
+		return nil
+	}
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	if !wait {
+		how |= syscall.LOCK_NB
+	}
+	for {
+		err := syscall.Flock(int(fd.Fd()), how)
+		switch {
+		case err == nil:
+			return nil
+		case errors.Is(err, syscall.EINTR):
+			continue
+		case errors.Is(err, syscall.EWOULDBLOCK):
+			return ErrLocked
+		}
+		return fmt.Errorf("flock: %w", err)
+	}
+}
+
+// unlockFile releases the lock taken with lockFile.
+func unlockFile(f fs.File) error {
+	fd, ok := f.(fder)
+	if !ok {
+		return nil
+	}
+	return syscall.Flock(int(fd.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,16 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package sdb
+
+import "io/fs"
+
+// lockFile does nothing, since flock isn't available on this platform, so
+// the databases aren't locked.
+func lockFile(fs.File, bool, bool) error {
+	return nil
+}
+
+// unlockFile does nothing, like lockFile.
+func unlockFile(fs.File) error {
+	return nil
+}
@@ -0,0 +1,226 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package sdb
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestDB_Lock(t *testing.T) {
+	seed := map[string]string{
+		"key-1": "value-1",
+		"key-2": "value-2",
+	}
+	openReadOnly := NewOpenFunc(false, WithReadOnly(true))
+
+	t.Run("Exclusive writer", func(t *testing.T) {
+		// Arrange
+		db := StartDatabase(t, OpenTestDB, seed)
+		defer db.Close()
+
+		// Act
+		_, err := ReopenTestDB()
+		_, roErr := openReadOnly()
+
+		// Assert
+		if !errors.Is(err, ErrLocked) {
+			t.Errorf("Expected ErrLocked, but got %v", err)
+		}
+		if !errors.Is(roErr, ErrLocked) {
+			t.Errorf("Expected ErrLocked, but got %v", roErr)
+		}
+		checkDatabase(t, db, seed)
+	})
+
+	t.Run("Released on close", func(t *testing.T) {
+		// Arrange
+		db := StartDatabase(t, OpenTestDB, seed)
+		_ = db.Close()
+
+		// Act
+		db, err := ReopenTestDB()
+
+		// Assert
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		defer db.Close()
+		checkDatabase(t, db, seed)
+	})
+
+	t.Run("Shared readers", func(t *testing.T) {
+		// Arrange
+		db := StartDatabase(t, OpenTestDB, seed)
+		_ = db.Close()
+
+		// Act
+		ro1, err1 := openReadOnly()
+		ro2, err2 := openReadOnly()
+		_, err := ReopenTestDB()
+
+		// Assert
+		if err1 != nil || err2 != nil {
+			t.Fatalf("Expected no error, but got %v, %v", err1, err2)
+		}
+		if !errors.Is(err, ErrLocked) {
+			t.Errorf("Expected ErrLocked, but got %v", err)
+		}
+		checkDatabase(t, ro1, seed)
+		checkDatabase(t, ro2, seed)
+		_ = ro1.Close()
+		_ = ro2.Close()
+		if db, err = ReopenTestDB(); err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		_ = db.Close()
+	})
+
+	t.Run("Multi-process exclusive", func(t *testing.T) {
+		// Arrange
+		db := StartDatabase(t, OpenTestDB, seed)
+		defer db.Close()
+
+		// Act
+		_, err := NewOpenFunc(false, WithMultiProcess(true))()
+
+		// Assert
+		if !errors.Is(err, ErrLocked) {
+			t.Errorf("Expected ErrLocked, but got %v", err)
+		}
+	})
+}
+
+func TestDB_MultiProcess(t *testing.T) {
+	seed := map[string]string{
+		"key-1": "value-1",
+		"key-2": "value-2",
+	}
+	openShared := NewOpenFunc(false, WithMultiProcess(true))
+
+	// Each DB has its own file descriptors, so two instances in the same
+	// process lock the database like two processes.
+	startShared := func(t *testing.T) (*DB, *DB) {
+		t.Helper()
+		db1 := StartDatabase(t, NewOpenFunc(true, WithMultiProcess(true)), seed)
+		db2, err := openShared()
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		return db1, db2
+	}
+
+	t.Run("Writes are visible", func(t *testing.T) {
+		// Arrange
+		db1, db2 := startShared(t)
+		defer db1.Close()
+		defer db2.Close()
+		expected := make(map[string]string)
+		for k, v := range seed {
+			expected[k] = v
+		}
+
+		// Act
+		for i := 3; i < 20; i++ {
+			k, v := fmt.Sprintf("key-%d", i), fmt.Sprintf("value-%d", i)
+			expected[k] = v
+			if err := db1.Put([]byte(k), []byte(v)); err != nil {
+				t.Fatalf("Expected no error, but got %v", err)
+			}
+		}
+		_ = db2.Delete([]byte("key-1"))
+		delete(expected, "key-1")
+
+		// Assert
+		checkDatabase(t, db1, expected)
+		checkDatabase(t, db2, expected)
+		var n int
+		_ = db2.Items(nil, Asc, func(_, _ []byte) (bool, error) {
+			n++
+			return true, nil
+		})
+		if n != len(expected) {
+			t.Errorf("Expected %d items, but got %d", len(expected), n)
+		}
+	})
+
+	t.Run("Cache is invalidated", func(t *testing.T) {
+		// Arrange
+		db1, db2 := startShared(t)
+		defer db1.Close()
+		defer db2.Close()
+		_, _ = db2.Get([]byte("key-1")) // Cached
+
+		// Act
+		_ = db1.Put([]byte("key-1"), []byte("changed"))
+		value, err := db2.Get([]byte("key-1"))
+
+		// Assert
+		if err != nil || string(value) != "changed" {
+			t.Errorf("Expected changed, but got %q, %v", value, err)
+		}
+	})
+
+	t.Run("Deadlines are reloaded", func(t *testing.T) {
+		// Arrange
+		db1, db2 := startShared(t)
+		defer db1.Close()
+		defer db2.Close()
+
+		// Act
+		_ = db1.PutWithTTL([]byte("key-3"), []byte("value-3"), time.Millisecond)
+		time.Sleep(5 * time.Millisecond)
+		value, err := db2.Get([]byte("key-3"))
+
+		// Assert
+		if err != nil || value != nil {
+			t.Errorf("Expected the key to be expired, but got %q, %v", value, err)
+		}
+	})
+
+	t.Run("Read-only process", func(t *testing.T) {
+		// Arrange
+		db1, db2 := startShared(t)
+		defer db1.Close()
+		_ = db2.Close()
+		ro, err := NewOpenFunc(false, WithMultiProcess(true), WithReadOnly(true))()
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		defer ro.Close()
+
+		// Act
+		_ = db1.Put([]byte("key-3"), []byte("value-3"))
+
+		// Assert
+		value, err := ro.Get([]byte("key-3"))
+		if err != nil || string(value) != "value-3" {
+			t.Errorf("Expected value-3, but got %q, %v", value, err)
+		}
+		if err = ro.Put([]byte("key-4"), nil); !errors.Is(err, ErrReadOnly) {
+			t.Errorf("Expected ErrReadOnly, but got %v", err)
+		}
+	})
+
+	t.Run("Closed", func(t *testing.T) {
+		// Arrange
+		db1, db2 := startShared(t)
+		defer db1.Close()
+		_ = db2.Close()
+
+		// Act
+		_, err := db2.Get([]byte("key-1"))
+		putErr := db2.Put([]byte("key-1"), nil)
+
+		// Assert
+		if !errors.Is(err, ErrDatabaseClosed) {
+			t.Errorf("Expected ErrDatabaseClosed, but got %v", err)
+		}
+		if !errors.Is(putErr, ErrDatabaseClosed) {
+			t.Errorf("Expected ErrDatabaseClosed, but got %v", putErr)
+		}
+		checkDatabase(t, db1, seed)
+	})
+}
@@ -13,6 +13,11 @@ type metadata struct {
 	TotalEntries uint64
 	Generation   uint64
 	Checkpoint   uint64
+
+	// Scheme used to encrypt the keys, and a value derived from the
+	// secret, to check that the database is opened with the same one.
+	KeyEncryption string
+	KeyCheck      []byte
 }
 
 func makeMetadata() metadata {
@@ -9,13 +9,15 @@ import (
 // mockFS is a lightweight test double that lets unit-tests inject behaviour
 // for any subset of fileSystem operations.
 type mockFS struct {
-	openFunc     func(name string) (fs.File, error)
-	openFileFunc func(name string, flag int, perm fs.FileMode) (fs.File, error)
-	statFunc     func(name string) (fs.FileInfo, error)
-	readFileFunc func(name string) ([]byte, error)
-	removeFunc   func(name string) error
-	renameFunc   func(oldpath, newpath string) error
-	mkdirAllFunc func(path string, perm fs.FileMode) error
+	openFunc      func(name string) (fs.File, error)
+	openFileFunc  func(name string, flag int, perm fs.FileMode) (fs.File, error)
+	statFunc      func(name string) (fs.FileInfo, error)
+	readFileFunc  func(name string) ([]byte, error)
+	removeFunc    func(name string) error
+	renameFunc    func(oldpath, newpath string) error
+	mkdirAllFunc  func(path string, perm fs.FileMode) error
+	removeAllFunc func(path string) error
+	linkFunc      func(oldname, newname string) error
 }
 
 // Compile-time interface check.
@@ -70,6 +72,20 @@ func (fs *mockFS) MkdirAll(path string, perm fs.FileMode) error {
 	return (&osFS{}).MkdirAll(path, perm)
 }
 
+func (fs *mockFS) RemoveAll(path string) error {
+	if fs.removeAllFunc != nil {
+		return fs.removeAllFunc(path)
+	}
+	return (&osFS{}).RemoveAll(path)
+}
+
+func (fs *mockFS) Link(oldname, newname string) error {
+	if fs.linkFunc != nil {
+		return fs.linkFunc(oldname, newname)
+	}
+	return (&osFS{}).Link(oldname, newname)
+}
+
 // Mock File
 
 // mockFile is an in-memory test double that pretends to be an *os.File.
@@ -0,0 +1,161 @@
+package sdb
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// namespaceDirectory is the directory holding the databases of the
+// namespaces, each in a subdirectory named like the record files.
+const namespaceDirectory = "namespaces"
+
+// ErrInvalidNamespace is returned when a namespace name is empty or longer
+// than the maximum key length.
+var ErrInvalidNamespace = errors.New("invalid namespace name")
+
+// Namespace returns the database of the namespace with the given name,
+// creating it if it doesn't exist. A namespace is a separate database, stored
+// in a subdirectory of the database path, so its keys, its count of records
+// and its iterations are independent of the ones of the database and of the
+// other namespaces.
+//
+// The namespace is opened with the options of the database, and the same DB
+// is returned until it is closed. Closing the database closes its open
+// namespaces. The names follow the limits of the keys, and they are encrypted
+// like the keys with [WithKeyEncryption]. The namespaces of a read-only
+// database are read-only, and they must already exist.
+func (db *DB) Namespace(name string) (*DB, error) {
+	if name == "" || len(name) > db.keys.maxKeyLength() {
+		return nil, ErrInvalidNamespace
+	}
+
+	db.nsMu.Lock()
+	defer db.nsMu.Unlock()
+
+	db.mu.RLock()
+	ns, ok := db.namespaces[name]
+	closed := db.closed
+	db.mu.RUnlock()
+
+	if closed {
+		return nil, ErrDatabaseClosed
+	}
+	if ok && !ns.isClosed() {
+		return ns, nil
+	}
+
+	// Open the namespace without holding the lock of the database, since
+	// it might have to be recovered.
+	if !db.readOnly {
+		if err := db.fs.MkdirAll(db.namespacesPath(), defaultDirPermissions); err != nil {
+			return nil, fmt.Errorf("create namespaces directory: %w", err)
+		}
+	}
+	ns, err := Open(db.namespacePath(name), db.options...)
+	if err != nil {
+		return nil, fmt.Errorf("open namespace: %w", err)
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.closed {
+		_ = ns.Close()
+		return nil, ErrDatabaseClosed
+	}
+	db.namespaces[name] = ns
+	return ns, nil
+}
+
+// Namespaces returns the names of the namespaces of the database, sorted.
+func (db *DB) Namespaces() ([]string, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.closed {
+		return nil, ErrDatabaseClosed
+	}
+
+	names, err := readdirnames(db.fs, db.namespacesPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read namespaces dir: %w", err)
+	}
+	namespaces := make([]string, 0, len(names))
+	for _, name := range names {
+		ns, err := db.keys.decode(name)
+		if err != nil {
+			return nil, fmt.Errorf("decode namespace: %w", err)
+		}
+		namespaces = append(namespaces, string(ns))
+	}
+	sort.Strings(namespaces)
+	return namespaces, nil
+}
+
+// DropNamespace closes the namespace with the given name, if it is open, and
+// deletes it with all its records. Dropping a namespace that doesn't exist is
+// not an error.
+func (db *DB) DropNamespace(name string) error {
+	if name == "" || len(name) > db.keys.maxKeyLength() {
+		return ErrInvalidNamespace
+	}
+
+	db.nsMu.Lock()
+	defer db.nsMu.Unlock()
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.closed {
+		return ErrDatabaseClosed
+	}
+	if db.readOnly {
+		return ErrReadOnly
+	}
+	if ns, ok := db.namespaces[name]; ok {
+		if err := ns.Close(); err != nil {
+			return fmt.Errorf("close namespace: %w", err)
+		}
+		delete(db.namespaces, name)
+	}
+
+	err := db.fs.RemoveAll(db.namespacePath(name))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove namespace: %w", err)
+	}
+	return nil
+}
+
+// closeNamespaces closes the open namespaces. The caller must hold the write
+// lock.
+func (db *DB) closeNamespaces() error {
+	var errs []error
+	for name, ns := range db.namespaces {
+		if err := ns.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("close namespace %q: %w", name, err))
+		}
+		delete(db.namespaces, name)
+	}
+	return errors.Join(errs...)
+}
+
+func (db *DB) isClosed() bool {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.closed
+}
+
+func (db *DB) namespacesPath() string {
+	return filepath.Join(db.path, namespaceDirectory)
+}
+
+func (db *DB) namespacePath(name string) string {
+	return filepath.Join(db.namespacesPath(), db.keys.encode([]byte(name)))
+}
@@ -0,0 +1,207 @@
+package sdb
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestDB_Namespace(t *testing.T) {
+	t.Run("Isolated keyspaces", func(t *testing.T) {
+		// Arrange
+		db := StartDatabase(t, OpenTestDB, map[string]string{"key": "root"})
+		defer db.Close()
+
+		// Act
+		users, err := db.Namespace("users")
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		orders, _ := db.Namespace("orders")
+		_ = users.Put([]byte("key"), []byte("user"))
+		_ = users.Put([]byte("other"), []byte("user"))
+		_ = orders.Put([]byte("key"), []byte("order"))
+
+		// Assert
+		checkDatabase(t, db, map[string]string{"key": "root"})
+		checkDatabase(t, users, map[string]string{"key": "user", "other": "user"})
+		checkDatabase(t, orders, map[string]string{"key": "order"})
+		AssertItems(t, db, nil, Asc, []string{"key"})
+		AssertItems(t, orders, nil, Desc, []string{"key"})
+	})
+
+	t.Run("Same instance", func(t *testing.T) {
+		db := StartDatabase(t, OpenTestDB, nil)
+		defer db.Close()
+
+		a, _ := db.Namespace("users")
+		b, _ := db.Namespace("users")
+		if a != b {
+			t.Errorf("Expected the same namespace")
+		}
+
+		// A closed namespace is opened again.
+		_ = a.Put([]byte("key"), []byte("value"))
+		_ = a.Close()
+		c, err := db.Namespace("users")
+		if err != nil || c == a {
+			t.Fatalf("Expected a new namespace, but got %v", err)
+		}
+		checkDatabase(t, c, map[string]string{"key": "value"})
+	})
+
+	t.Run("Concurrent opens", func(t *testing.T) {
+		// Arrange
+		db := StartDatabase(t, OpenTestDB, nil)
+		defer db.Close()
+		namespaces := make([]*DB, 8)
+		errs := make([]error, 8)
+
+		// Act
+		var wg sync.WaitGroup
+		for i := range namespaces {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				namespaces[i], errs[i] = db.Namespace("users")
+				_ = db.Put([]byte("key"), []byte("value"))
+			}()
+		}
+		wg.Wait()
+
+		// Assert
+		for i, ns := range namespaces {
+			if errs[i] != nil || ns != namespaces[0] {
+				t.Errorf("%d: Expected the same namespace, but got %v", i, errs[i])
+			}
+		}
+		checkDatabase(t, db, map[string]string{"key": "value"})
+	})
+
+	t.Run("Persistence", func(t *testing.T) {
+		// Arrange
+		db := StartDatabase(t, OpenTestDB, nil)
+		users, _ := db.Namespace("users")
+		_ = users.Put([]byte("key"), []byte("value"))
+
+		// Act
+		_ = db.Close()
+		if err := users.Put([]byte("key"), []byte("new")); !errors.Is(err, ErrDatabaseClosed) {
+			t.Errorf("Expected ErrDatabaseClosed, but got %v", err)
+		}
+		db, err := ReopenTestDB()
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		defer db.Close()
+
+		// Assert
+		users, _ = db.Namespace("users")
+		checkDatabase(t, users, map[string]string{"key": "value"})
+		checkDatabase(t, db, map[string]string{})
+	})
+
+	t.Run("Encrypted names", func(t *testing.T) {
+		db := StartDatabase(t, NewOpenFunc(true, WithKeyEncryption(HashedKeys, TestSecret)), nil)
+		defer db.Close()
+
+		users, _ := db.Namespace("users")
+		_ = users.Put([]byte("key"), []byte("value"))
+
+		names, err := db.Namespaces()
+		if err != nil || !reflect.DeepEqual(names, []string{"users"}) {
+			t.Errorf("Expected [users], but got %v, %v", names, err)
+		}
+		if ok, _ := users.Has([]byte("key")); !ok {
+			t.Errorf("Expected the key to exist")
+		}
+	})
+
+	t.Run("Invalid name", func(t *testing.T) {
+		db := StartDatabase(t, OpenTestDB, nil)
+		defer db.Close()
+
+		for _, name := range []string{"", strings.Repeat("a", MaxKeyLength+1)} {
+			if _, err := db.Namespace(name); !errors.Is(err, ErrInvalidNamespace) {
+				t.Errorf("Expected ErrInvalidNamespace, but got %v", err)
+			}
+		}
+	})
+
+	t.Run("Closed", func(t *testing.T) {
+		db := StartDatabase(t, OpenTestDB, nil)
+		_ = db.Close()
+
+		if _, err := db.Namespace("users"); !errors.Is(err, ErrDatabaseClosed) {
+			t.Errorf("Expected ErrDatabaseClosed, but got %v", err)
+		}
+		if _, err := db.Namespaces(); !errors.Is(err, ErrDatabaseClosed) {
+			t.Errorf("Expected ErrDatabaseClosed, but got %v", err)
+		}
+		if err := db.DropNamespace("users"); !errors.Is(err, ErrDatabaseClosed) {
+			t.Errorf("Expected ErrDatabaseClosed, but got %v", err)
+		}
+	})
+}
+
+func TestDB_Namespaces(t *testing.T) {
+	// Arrange
+	db := StartDatabase(t, OpenTestDB, nil)
+	defer db.Close()
+	if names, err := db.Namespaces(); err != nil || len(names) != 0 {
+		t.Errorf("Expected no namespaces, but got %v, %v", names, err)
+	}
+
+	// Act
+	for _, name := range []string{"users", "orders", "a/b"} {
+		if _, err := db.Namespace(name); err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+	}
+	names, err := db.Namespaces()
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	expected := []string{"a/b", "orders", "users"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected %v, but got %v", expected, names)
+	}
+}
+
+func TestDB_DropNamespace(t *testing.T) {
+	// Arrange
+	db := StartDatabase(t, OpenTestDB, map[string]string{"key": "root"})
+	defer db.Close()
+	users, _ := db.Namespace("users")
+	_ = users.Put([]byte("key"), []byte("user"))
+	orders, _ := db.Namespace("orders")
+
+	// Act
+	err := db.DropNamespace("users")
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if _, err = users.Get([]byte("key")); !errors.Is(err, ErrDatabaseClosed) {
+		t.Errorf("Expected ErrDatabaseClosed, but got %v", err)
+	}
+	names, _ := db.Namespaces()
+	if !reflect.DeepEqual(names, []string{"orders"}) {
+		t.Errorf("Expected [orders], but got %v", names)
+	}
+	users, _ = db.Namespace("users")
+	checkDatabase(t, users, map[string]string{})
+	checkDatabase(t, db, map[string]string{"key": "root"})
+	if ok, _ := orders.Has([]byte("key")); ok {
+		t.Errorf("Expected no key in orders")
+	}
+
+	if err = db.DropNamespace("missing"); err != nil {
+		t.Errorf("Expected no error, but got %v", err)
+	}
+}
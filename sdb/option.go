@@ -1,6 +1,7 @@
 package sdb
 
 import (
+	"bytes"
 	"time"
 
 	"github.com/lucmq/go-shelve/sdb/internal"
@@ -14,7 +15,8 @@ type Option func(*DB)
 // default cache size is -1.
 func WithCacheSize(size int64) Option {
 	return func(db *DB) {
-		db.cache = internal.NewCache[cacheEntry](int(size))
+		db.cacheSize = int(size)
+		db.cache = internal.NewCache[cacheEntry](db.cacheSize)
 	}
 }
 
@@ -26,6 +28,67 @@ func WithSynchronousWrites(sync bool) Option {
 	}
 }
 
+// WithReadOnly opens the database in read-only mode. The database must exist,
+// and it is never written: the methods that modify it return [ErrReadOnly],
+// and no background loop is started. Only read permissions are required, so
+// the database can be opened from a read-only mount.
+//
+// If the database wasn't closed cleanly, its count of records is recomputed
+// in memory when it is opened. A batch interrupted by a crash can't be
+// completed, so Open returns an error wrapping [ErrReadOnly] until the
+// database is opened for writing.
+func WithReadOnly(readOnly bool) Option {
+	return func(db *DB) {
+		db.readOnly = readOnly
+	}
+}
+
+// WithMultiProcess allows the database to be opened by many processes at the
+// same time, as long as all of them use this option. Each operation locks the
+// database, in shared mode for the reads and in exclusive mode for the
+// writes, and reloads its state if another process changed it, as detected
+// by the generation stored in the metadata. The metadata is saved after each
+// write. This makes the operations slower, and the cache is only useful
+// between the writes of the other processes.
+//
+// By default, Open locks the database in exclusive mode, or in shared mode
+// with [WithReadOnly], and returns [ErrLocked] if the lock can't be taken.
+// The locks are advisory, with flock, and they aren't supported on Windows.
+func WithMultiProcess(multiProcess bool) Option {
+	return func(db *DB) {
+		db.multiProcess = multiProcess
+	}
+}
+
+// WithExpiryInterval sets the interval at which the records written with
+// DB.PutWithTTL are checked and deleted once they expire. A zero or negative
+// interval disables the background deletion, and the expired records are
+// only deleted by DB.DeleteExpired. The default interval is one minute.
+//
+// Expired records are never returned by the database, regardless of this
+// interval.
+func WithExpiryInterval(d time.Duration) Option {
+	return func(db *DB) {
+		db.expiryInterval = d
+	}
+}
+
+// WithKeyEncryption encrypts the keys of the records with the given scheme,
+// so they can't be read from the filenames, the expiry log or the batch
+// journal. With [OrderedKeys], the keys are only obscured, and they remain
+// approximately readable. The secret must have at least 16 bytes, and the
+// keys used for the encryption are derived from it.
+//
+// The scheme is recorded in the metadata when the database is created, and
+// Open returns [ErrKeyMismatch] if the database is later opened with another
+// scheme or secret, or without encryption. The values of the records are not
+// encrypted.
+func WithKeyEncryption(scheme KeyEncryption, secret []byte) Option {
+	return func(db *DB) {
+		db.keys = keyScheme{mode: scheme, secret: bytes.Clone(secret)}
+	}
+}
+
 // withMaxFilesPerShard returns an Option that limits how many regular data
 // files may reside in a single shard directory before SDB triggers a split.
 //
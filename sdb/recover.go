@@ -9,7 +9,8 @@ import (
 func recoverDatabase(db *DB) error {
 	// Note: We have the following:
 	// - The DB design is simple, and all operations require at most one file
-	// mutation.
+	// mutation. Batches, which mutate many files, are completed from their
+	// journal before the recovery (see recoverBatch).
 	// - The metadata is stored in a single file, and all operations require
 	// at most one file mutation.
 	// - Currently, the only thing that can get corrupted is the metadata, in
@@ -17,6 +18,19 @@ func recoverDatabase(db *DB) error {
 	//
 	// Thus, the recovery process can be limited to counting the number of
 	// files in the data folder and updating the metadata.
+	if err := recountItems(db); err != nil {
+		return err
+	}
+	if db.readOnly {
+		// Keep the recovered count in memory only.
+		return nil
+	}
+	return db.metadataStore.Save(db.metadata)
+}
+
+// recountItems counts the records in the data folder and marks the metadata
+// as consistent, in memory.
+func recountItems(db *DB) error {
 	dataRoot := filepath.Join(db.path, dataDirectory)
 
 	totalItems, err := countItems(db.fs, dataRoot)
@@ -26,8 +40,7 @@ func recoverDatabase(db *DB) error {
 
 	db.metadata.TotalEntries = totalItems
 	db.metadata.Checkpoint = db.metadata.Generation
-
-	return db.metadataStore.Save(db.metadata)
+	return nil
 }
 
 func countItems(fsys fileSystem, path string) (uint64, error) {
@@ -0,0 +1,121 @@
+package shelve
+
+import (
+	"context"
+	"reflect"
+)
+
+// CompareAndSwap replaces the value of a key with new, but only if the key
+// exists and currently holds the old value. It reports whether the value was
+// swapped.
+//
+// The current value is decoded and compared with old using
+// [reflect.DeepEqual], so it works with codecs that encode equal values to
+// different bytes, like [EncryptedCodec]. Note that the values must be equal
+// as decoded by the Codec: for instance, JSONCodec decodes an empty slice
+// stored from a nil one as an empty slice. The comparison and the swap are
+// done atomically, with a transaction (see [Shelf.Transact]).
+func (s *Shelf[K, V]) CompareAndSwap(key K, old, new V) (bool, error) {
+	return s.compareAndSwap(context.Background(), key, old, new)
+}
+
+func (s *Shelf[K, V]) compareAndSwap(ctx context.Context, key K, old, new V) (bool, error) {
+	var swapped bool
+	err := s.transact(ctx, func(tx *Tx[K, V]) error {
+		current, ok, err := tx.Get(key)
+		if err != nil {
+			return err
+		}
+		swapped = ok && reflect.DeepEqual(current, old)
+		if !swapped {
+			return nil
+		}
+		return tx.Put(key, new)
+	})
+	if err != nil {
+		return false, err
+	}
+	return swapped, nil
+}
+
+// PutIfAbsent adds a key-value pair to the Shelf, but only if the key doesn't
+// exist yet. It reports whether the value was stored. The check and the write
+// are done atomically, with a transaction (see [Shelf.Transact]).
+func (s *Shelf[K, V]) PutIfAbsent(key K, value V) (bool, error) {
+	return s.putIfAbsent(context.Background(), key, value)
+}
+
+func (s *Shelf[K, V]) putIfAbsent(ctx context.Context, key K, value V) (bool, error) {
+	var stored bool
+	err := s.transact(ctx, func(tx *Tx[K, V]) error {
+		ok, err := tx.Has(key)
+		if err != nil {
+			return err
+		}
+		stored = !ok
+		if !stored {
+			return nil
+		}
+		return tx.Put(key, value)
+	})
+	if err != nil {
+		return false, err
+	}
+	return stored, nil
+}
+
+// GetAndDelete removes a key-value pair from the Shelf and returns the removed
+// value. If the key is not found, it returns false. The read and the removal
+// are done atomically, with a transaction (see [Shelf.Transact]).
+func (s *Shelf[K, V]) GetAndDelete(key K) (value V, ok bool, err error) {
+	return s.getAndDelete(context.Background(), key)
+}
+
+func (s *Shelf[K, V]) getAndDelete(ctx context.Context, key K) (value V, ok bool, err error) {
+	err = s.transact(ctx, func(tx *Tx[K, V]) error {
+		value, ok, err = tx.Get(key)
+		if err != nil || !ok {
+			return err
+		}
+		return tx.Delete(key)
+	})
+	if err != nil {
+		return *new(V), false, err
+	}
+	return value, ok, nil
+}
+
+// Update replaces the value of a key with the value returned by fn, which
+// is called with the current value and whether the key exists. If fn returns
+// an error, the Shelf is left unchanged and the error is returned. Otherwise,
+// Update returns the new value.
+//
+// The read and the write are done atomically, with a transaction (see
+// [Shelf.Transact]), so fn might be called more than once and shouldn't have
+// side effects.
+func (s *Shelf[K, V]) Update(key K, fn func(old V, exists bool) (V, error)) (V, error) {
+	return s.update(context.Background(), key, fn)
+}
+
+func (s *Shelf[K, V]) update(
+	ctx context.Context,
+	key K,
+	fn func(old V, exists bool) (V, error),
+) (V, error) {
+	var value V
+	err := s.transact(ctx, func(tx *Tx[K, V]) error {
+		old, ok, err := tx.Get(key)
+		if err != nil {
+			return err
+		}
+		value, err = fn(old, ok)
+		if err != nil {
+			return err
+		}
+		return tx.Put(key, value)
+	})
+	if err != nil {
+		return *new(V), err
+	}
+	return value, nil
+}
@@ -0,0 +1,304 @@
+package shelve
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestShelf_CompareAndSwap(t *testing.T) {
+	tests := []struct {
+		name     string
+		key      string
+		old      string
+		swapped  bool
+		expected map[string]string
+	}{
+		{
+			name:     "Matching value",
+			key:      "key-1",
+			old:      "value-1",
+			swapped:  true,
+			expected: map[string]string{"key-1": "new", "key-2": "value-2"},
+		},
+		{
+			name:     "Different value",
+			key:      "key-1",
+			old:      "other",
+			expected: map[string]string{"key-1": "value-1", "key-2": "value-2"},
+		},
+		{
+			name: "Missing key",
+			key:  "key-3",
+			old:  "",
+			expected: map[string]string{
+				"key-1": "value-1", "key-2": "value-2",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			shelf := OpenTestShelf[string, string](t)
+			defer shelf.Close()
+			_ = shelf.Put("key-1", "value-1")
+			_ = shelf.Put("key-2", "value-2")
+
+			// Act
+			swapped, err := shelf.CompareAndSwap(tt.key, tt.old, "new")
+
+			// Assert
+			if err != nil {
+				t.Errorf("Expected no error, but got %v", err)
+			}
+			if swapped != tt.swapped {
+				t.Errorf("Expected swapped to be %v, but got %v", tt.swapped, swapped)
+			}
+			checkShelf(t, shelf, tt.expected)
+		})
+	}
+
+	t.Run("Non-deterministic codec", func(t *testing.T) {
+		// Arrange
+		codec := newTestEncryptedCodec(t, testEncryptionKey(1))
+		shelf := OpenTestShelfWith[string, map[string]int](t, WithCodec(codec))
+		defer shelf.Close()
+		_ = shelf.Put("key", map[string]int{"a": 1, "b": 2, "c": 3})
+
+		// Act
+		swapped, err := shelf.CompareAndSwap("key",
+			map[string]int{"c": 3, "b": 2, "a": 1}, map[string]int{"a": 4})
+
+		// Assert
+		if err != nil || !swapped {
+			t.Errorf("Expected the value to be swapped, but got %v, %v", swapped, err)
+		}
+		value, _, _ := shelf.Get("key")
+		if len(value) != 1 || value["a"] != 4 {
+			t.Errorf("Expected map[a:4], but got %v", value)
+		}
+	})
+
+	t.Run("Encode error", func(t *testing.T) {
+		var codec MockCodec
+		codec.EncodeFunc = func(any) ([]byte, error) { return nil, TestError }
+		shelf := NewTestShelf(t, WithKeyCodec(&codec))
+
+		_, err := shelf.CompareAndSwap("key", "old", "new")
+		if !errors.Is(err, TestError) {
+			t.Errorf("Expected error %v, but got %v", TestError, err)
+		}
+	})
+
+	t.Run("Decode error", func(t *testing.T) {
+		var db MockTxDB
+		db.GetFunc = func(key []byte) ([]byte, error) { return []byte("value"), nil }
+		var codec MockCodec
+		codec.DecodeFunc = func([]byte, any) error { return TestError }
+		shelf := NewTestShelf(t, WithDatabase(&db), WithCodec(&codec))
+
+		_, err := shelf.CompareAndSwap("key", "old", "new")
+		if !errors.Is(err, TestError) {
+			t.Errorf("Expected error %v, but got %v", TestError, err)
+		}
+	})
+
+	t.Run("Get error", func(t *testing.T) {
+		var db MockTxDB
+		db.GetFunc = func(key []byte) ([]byte, error) { return nil, TestError }
+		shelf := NewTestShelf(t, WithDatabase(&db))
+
+		_, err := shelf.CompareAndSwap("key", "old", "new")
+		if !errors.Is(err, TestError) {
+			t.Errorf("Expected error %v, but got %v", TestError, err)
+		}
+	})
+}
+
+func TestShelf_PutIfAbsent(t *testing.T) {
+	t.Run("Concurrent claims", func(t *testing.T) {
+		shelf := OpenTestShelf[string, string](t)
+		defer shelf.Close()
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		var claims []string
+		for _, worker := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ok, err := shelf.PutIfAbsent("job", worker)
+				if err != nil {
+					t.Errorf("Expected no error, but got %v", err)
+				}
+				if ok {
+					mu.Lock()
+					claims = append(claims, worker)
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+
+		if len(claims) != 1 {
+			t.Fatalf("Expected 1 claim, but got %v", claims)
+		}
+		checkShelf(t, shelf, map[string]string{"job": claims[0]})
+	})
+
+	t.Run("Has error", func(t *testing.T) {
+		var db MockTxDB
+		db.GetFunc = func(key []byte) ([]byte, error) { return nil, TestError }
+		shelf := NewTestShelf(t, WithDatabase(&db))
+
+		_, err := shelf.PutIfAbsent("key", "value")
+		if !errors.Is(err, TestError) {
+			t.Errorf("Expected error %v, but got %v", TestError, err)
+		}
+	})
+}
+
+func TestShelf_GetAndDelete(t *testing.T) {
+	t.Run("Existing key", func(t *testing.T) {
+		shelf := OpenTestShelf[string, string](t)
+		defer shelf.Close()
+		_ = shelf.Put("key-1", "value-1")
+		_ = shelf.Put("key-2", "value-2")
+
+		value, ok, err := shelf.GetAndDelete("key-1")
+		if err != nil || !ok || value != "value-1" {
+			t.Errorf("Expected value-1, but got %v, %v, %v", value, ok, err)
+		}
+		checkShelf(t, shelf, map[string]string{"key-2": "value-2"})
+	})
+
+	t.Run("Missing key", func(t *testing.T) {
+		shelf := OpenTestShelf[string, string](t)
+		defer shelf.Close()
+
+		value, ok, err := shelf.GetAndDelete("key")
+		if err != nil || ok || value != "" {
+			t.Errorf("Expected no value, but got %v, %v, %v", value, ok, err)
+		}
+	})
+
+	t.Run("Get error", func(t *testing.T) {
+		var db MockTxDB
+		db.GetFunc = func(key []byte) ([]byte, error) { return nil, TestError }
+		shelf := NewTestShelf(t, WithDatabase(&db))
+
+		_, _, err := shelf.GetAndDelete("key")
+		if !errors.Is(err, TestError) {
+			t.Errorf("Expected error %v, but got %v", TestError, err)
+		}
+	})
+}
+
+func TestShelf_Update(t *testing.T) {
+	t.Run("Concurrent counter", func(t *testing.T) {
+		shelf := OpenTestShelf[string, int](t)
+		defer shelf.Close()
+
+		const workers, increments = 4, 10
+
+		var wg sync.WaitGroup
+		for range workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for range increments {
+					for {
+						_, err := shelf.Update("counter", func(old int, _ bool) (int, error) {
+							return old + 1, nil
+						})
+						if errors.Is(err, ErrConflict) {
+							continue
+						}
+						if err != nil {
+							t.Errorf("Expected no error, but got %v", err)
+						}
+						break
+					}
+				}
+			}()
+		}
+		wg.Wait()
+
+		n, _, err := shelf.Get("counter")
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		if n != workers*increments {
+			t.Errorf("Expected %d, but got %d", workers*increments, n)
+		}
+	})
+
+	t.Run("Exists", func(t *testing.T) {
+		shelf := OpenTestShelf[string, string](t)
+		defer shelf.Close()
+
+		var calls []bool
+		fn := func(old string, exists bool) (string, error) {
+			calls = append(calls, exists)
+			return old + "x", nil
+		}
+		for range 2 {
+			if _, err := shelf.Update("key", fn); err != nil {
+				t.Errorf("Expected no error, but got %v", err)
+			}
+		}
+
+		if len(calls) != 2 || calls[0] || !calls[1] {
+			t.Errorf("Expected [false true], but got %v", calls)
+		}
+		checkShelf(t, shelf, map[string]string{"key": "xx"})
+	})
+
+	t.Run("Function error", func(t *testing.T) {
+		shelf := OpenTestShelf[string, string](t)
+		defer shelf.Close()
+		_ = shelf.Put("key", "value")
+
+		_, err := shelf.Update("key", func(string, bool) (string, error) {
+			return "new", TestError
+		})
+		if !errors.Is(err, TestError) {
+			t.Errorf("Expected error %v, but got %v", TestError, err)
+		}
+		checkShelf(t, shelf, map[string]string{"key": "value"})
+	})
+
+	t.Run("Get error", func(t *testing.T) {
+		var db MockTxDB
+		db.GetFunc = func(key []byte) ([]byte, error) { return nil, TestError }
+		shelf := NewTestShelf(t, WithDatabase(&db))
+
+		_, err := shelf.Update("key", func(string, bool) (string, error) {
+			t.Errorf("Expected fn to not be called")
+			return "", nil
+		})
+		if !errors.Is(err, TestError) {
+			t.Errorf("Expected error %v, but got %v", TestError, err)
+		}
+	})
+}
+
+func checkShelf[V comparable](t *testing.T, shelf *Shelf[string, V], expected map[string]V) {
+	t.Helper()
+	items := make(map[string]V)
+	all, errf := shelf.All()
+	for k, v := range all {
+		items[k] = v
+	}
+	if err := errf(); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if len(items) != len(expected) {
+		t.Errorf("Expected %v, but got %v", expected, items)
+	}
+	for k, v := range expected {
+		if items[k] != v {
+			t.Errorf("Expected %v for %s, but got %v", v, k, items[k])
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package shelve
+
+import (
+	"context"
+	"fmt"
+)
+
+// Batch holds a set of puts and deletes that are written to a Shelf together,
+// with [Batch.Write]. It is created with [Shelf.NewBatch].
+//
+// If the underlying database implements [BatchDB], the batch is written
+// atomically: either all the mutations are applied or none are. Otherwise,
+// the mutations are applied one at a time and, if an error occurs, some of
+// them might have already been applied.
+//
+// The keys and values are encoded when they are added to the batch. If the
+// same key is added more than once, only the last mutation is kept.
+//
+// A Batch is not safe for concurrent use by multiple goroutines.
+type Batch[K comparable, V any] struct {
+	shelf  *Shelf[K, V]
+	keys   [][]byte
+	values [][]byte // nil for deletes
+	index  map[string]int
+}
+
+// NewBatch creates an empty Batch for the Shelf.
+func (s *Shelf[K, V]) NewBatch() *Batch[K, V] {
+	return &Batch[K, V]{
+		shelf: s,
+		index: make(map[string]int),
+	}
+}
+
+// Put adds a key-value pair to the batch. It only returns an error if the key
+// or value can't be encoded, or if the key is reserved ([ErrReservedKey]).
+func (b *Batch[K, V]) Put(key K, value V) error {
+	data, vData, err := b.shelf.encodeItem(key, value)
+	if err != nil {
+		return err
+	}
+	if b.shelf.isReserved(data) {
+		return ErrReservedKey
+	}
+	b.add(data, vData)
+	return nil
+}
+
+// Delete adds the removal of a key to the batch. It only returns an error if
+// the key can't be encoded, or if the key is reserved ([ErrReservedKey]).
+func (b *Batch[K, V]) Delete(key K) error {
+	data, err := b.shelf.keyCodec.Encode(key)
+	if err != nil {
+		return fmt.Errorf("encode: %w", err)
+	}
+	if b.shelf.isReserved(data) {
+		return ErrReservedKey
+	}
+	b.add(data, nil)
+	return nil
+}
+
+// Len returns the number of mutations in the batch.
+func (b *Batch[K, V]) Len() int {
+	return len(b.keys)
+}
+
+// Reset removes all the mutations from the batch, so that it can be reused.
+func (b *Batch[K, V]) Reset() {
+	b.keys = b.keys[:0]
+	b.values = b.values[:0]
+	clear(b.index)
+}
+
+// Write applies the mutations in the batch to the Shelf. The batch is not
+// reset afterward.
+func (b *Batch[K, V]) Write() error {
+	return b.apply(context.Background())
+}
+
+func (b *Batch[K, V]) apply(ctx context.Context) error {
+	if len(b.keys) == 0 {
+		return nil
+	}
+	if len(b.shelf.indexes) > 0 {
+		// Write the batch in a transaction, to update the indexes.
+		return b.shelf.transact(ctx, func(tx *Tx[K, V]) error {
+			for i, key := range b.keys {
+				if err := tx.set(key, b.values[i]); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	defer b.shelf.lockWrite()()
+	return b.write(ctx)
+}
+
+// write applies the mutations in the batch to the database as they are,
+// without updating the indexes. If the database doesn't implement BatchDB,
+// the context is also checked between the mutations.
+func (b *Batch[K, V]) write(ctx context.Context) error {
+	if err := b.shelf.checkWritable(); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if db, ok := b.shelf.db.(BatchDB); ok {
+		if err := db.WriteBatch(b.keys, b.values); err != nil {
+			return fmt.Errorf("write batch: %w", err)
+		}
+		b.shelf.notify(b.keys, b.values)
+		return nil
+	}
+
+	// Fallback: apply the mutations one at a time.
+	for i, key := range b.keys {
+		var err error
+		if b.values[i] == nil {
+			if err = b.shelf.dbDelete(ctx, key); err != nil {
+				err = fmt.Errorf("delete: %w", err)
+			}
+		} else if err = b.shelf.dbPut(ctx, key, b.values[i]); err != nil {
+			err = fmt.Errorf("put: %w", err)
+		}
+		if err != nil {
+			// Report the mutations that were applied.
+			b.shelf.notify(b.keys[:i], b.values[:i])
+			return err
+		}
+	}
+	b.shelf.notify(b.keys, b.values)
+	return nil
+}
+
+func (b *Batch[K, V]) add(key, value []byte) {
+	if i, ok := b.index[string(key)]; ok {
+		b.values[i] = value
+		return
+	}
+	b.index[string(key)] = len(b.keys)
+	b.keys = append(b.keys, key)
+	b.values = append(b.values, value)
+}
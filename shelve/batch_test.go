@@ -0,0 +1,260 @@
+package shelve
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/lucmq/go-shelve/sdb"
+)
+
+func TestBatch_Write(t *testing.T) {
+	fill := func(t *testing.T, b *Batch[string, string]) {
+		t.Helper()
+		for _, err := range []error{
+			b.Put("key-1", "value-1"),
+			b.Delete("key-2"),
+			b.Put("key-3", "value-3"),
+			b.Put("key-1", "value-1b"),
+			b.Delete("key-3"),
+		} {
+			if err != nil {
+				t.Fatalf("Expected no error, but got %v", err)
+			}
+		}
+	}
+
+	t.Run("BatchDB", func(t *testing.T) {
+		// Arrange
+		var db MockBatchDB
+		var keys, values [][]byte
+		db.WriteBatchFunc = func(k, v [][]byte) error {
+			keys, values = k, v
+			return nil
+		}
+		shelf := NewTestShelf(t, WithDatabase(&db))
+		b := shelf.NewBatch()
+		fill(t, b)
+
+		// Act
+		err := b.Write()
+
+		// Assert
+		if err != nil {
+			t.Errorf("Expected no error, but got %v", err)
+		}
+		expectedKeys := [][]byte{
+			[]byte("encoded_key-1"), []byte("encoded_key-2"), []byte("encoded_key-3"),
+		}
+		expectedValues := [][]byte{[]byte("encoded_value-1b"), nil, nil}
+		if !reflect.DeepEqual(keys, expectedKeys) {
+			t.Errorf("Expected keys %q, but got %q", expectedKeys, keys)
+		}
+		if !reflect.DeepEqual(values, expectedValues) {
+			t.Errorf("Expected values %q, but got %q", expectedValues, values)
+		}
+		if b.Len() != 3 {
+			t.Errorf("Expected len 3, but got %d", b.Len())
+		}
+	})
+
+	t.Run("BatchDB error", func(t *testing.T) {
+		var db MockBatchDB
+		db.WriteBatchFunc = func(_, _ [][]byte) error { return TestError }
+		shelf := NewTestShelf(t, WithDatabase(&db))
+		b := shelf.NewBatch()
+		fill(t, b)
+
+		err := b.Write()
+		if !errors.Is(err, TestError) {
+			t.Errorf("Expected error %v, but got %v", TestError, err)
+		}
+	})
+
+	t.Run("Fallback", func(t *testing.T) {
+		// Arrange
+		var db MockDB
+		var ops []string
+		db.PutFunc = func(k, v []byte) error {
+			ops = append(ops, "put "+string(k)+" "+string(v))
+			return nil
+		}
+		db.DeleteFunc = func(k []byte) error {
+			ops = append(ops, "delete "+string(k))
+			return nil
+		}
+		shelf := NewTestShelf(t, WithDatabase(&db))
+		b := shelf.NewBatch()
+		fill(t, b)
+
+		// Act
+		err := b.Write()
+
+		// Assert
+		if err != nil {
+			t.Errorf("Expected no error, but got %v", err)
+		}
+		expected := []string{
+			"put encoded_key-1 encoded_value-1b",
+			"delete encoded_key-2",
+			"delete encoded_key-3",
+		}
+		if !reflect.DeepEqual(ops, expected) {
+			t.Errorf("Expected %v, but got %v", expected, ops)
+		}
+	})
+
+	t.Run("Fallback put error", func(t *testing.T) {
+		var db MockDB
+		db.PutFunc = func(_, _ []byte) error { return TestError }
+		shelf := NewTestShelf(t, WithDatabase(&db))
+		b := shelf.NewBatch()
+		fill(t, b)
+
+		err := b.Write()
+		if !errors.Is(err, TestError) {
+			t.Errorf("Expected error %v, but got %v", TestError, err)
+		}
+	})
+
+	t.Run("Fallback delete error", func(t *testing.T) {
+		var db MockDB
+		db.DeleteFunc = func(_ []byte) error { return TestError }
+		shelf := NewTestShelf(t, WithDatabase(&db))
+		b := shelf.NewBatch()
+		fill(t, b)
+
+		err := b.Write()
+		if !errors.Is(err, TestError) {
+			t.Errorf("Expected error %v, but got %v", TestError, err)
+		}
+	})
+
+	t.Run("Empty batch", func(t *testing.T) {
+		var db MockBatchDB
+		db.WriteBatchFunc = func(_, _ [][]byte) error {
+			t.Errorf("Expected WriteBatch to not be called")
+			return nil
+		}
+		shelf := NewTestShelf(t, WithDatabase(&db))
+
+		if err := shelf.NewBatch().Write(); err != nil {
+			t.Errorf("Expected no error, but got %v", err)
+		}
+	})
+
+	t.Run("Reset", func(t *testing.T) {
+		shelf := NewTestShelf(t)
+		b := shelf.NewBatch()
+		fill(t, b)
+
+		b.Reset()
+		if b.Len() != 0 {
+			t.Errorf("Expected len 0, but got %d", b.Len())
+		}
+		if err := b.Put("key-1", "value-1"); err != nil {
+			t.Errorf("Expected no error, but got %v", err)
+		}
+		if b.Len() != 1 {
+			t.Errorf("Expected len 1, but got %d", b.Len())
+		}
+	})
+}
+
+func TestBatch_EncodeError(t *testing.T) {
+	var codec MockCodec
+	codec.EncodeFunc = func(any) ([]byte, error) { return nil, TestError }
+
+	t.Run("Put key", func(t *testing.T) {
+		shelf := NewTestShelf(t, WithKeyCodec(&codec))
+		err := shelf.NewBatch().Put("key", "value")
+		if !errors.Is(err, TestError) {
+			t.Errorf("Expected error %v, but got %v", TestError, err)
+		}
+	})
+
+	t.Run("Put value", func(t *testing.T) {
+		shelf := NewTestShelf(t, WithCodec(&codec))
+		err := shelf.NewBatch().Put("key", "value")
+		if !errors.Is(err, TestError) {
+			t.Errorf("Expected error %v, but got %v", TestError, err)
+		}
+	})
+
+	t.Run("Delete key", func(t *testing.T) {
+		shelf := NewTestShelf(t, WithKeyCodec(&codec))
+		err := shelf.NewBatch().Delete("key")
+		if !errors.Is(err, TestError) {
+			t.Errorf("Expected error %v, but got %v", TestError, err)
+		}
+	})
+
+	t.Run("Nil value is not a delete", func(t *testing.T) {
+		var nilCodec MockCodec
+		nilCodec.EncodeFunc = func(any) ([]byte, error) { return nil, nil }
+		shelf := NewTestShelf(t, WithCodec(&nilCodec))
+		b := shelf.NewBatch()
+		if err := b.Put("key", "value"); err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		if b.values[0] == nil {
+			t.Errorf("Expected a non-nil value")
+		}
+	})
+}
+
+func TestBatch_ReservedKey(t *testing.T) {
+	// Arrange
+	shelf := OpenTestShelfWith[string, string](t,
+		WithSchema(1, map[uint32]Migration[string]{}))
+	defer shelf.Close()
+	b := shelf.NewBatch()
+
+	// Act
+	putErr := b.Put(schemaKey, "value")
+	deleteErr := b.Delete(schemaKey)
+
+	// Assert
+	if !errors.Is(putErr, ErrReservedKey) {
+		t.Errorf("Expected ErrReservedKey, but got %v", putErr)
+	}
+	if !errors.Is(deleteErr, ErrReservedKey) {
+		t.Errorf("Expected ErrReservedKey, but got %v", deleteErr)
+	}
+	if b.Len() != 0 {
+		t.Errorf("Expected len 0, but got %d", b.Len())
+	}
+}
+
+func TestBatch_SDB(t *testing.T) {
+	shelf := OpenTestShelf[string, int](t)
+	defer shelf.Close()
+
+	if _, ok := shelf.db.(*sdb.DB); !ok {
+		t.Fatalf("Expected the shelf to use sdb")
+	}
+	if err := shelf.Put("a", 1); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	b := shelf.NewBatch()
+	_ = b.Put("b", 2)
+	_ = b.Put("c", 3)
+	_ = b.Delete("a")
+	if err := b.Write(); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	items := make(map[string]int)
+	all, _ := shelf.All()
+	for k, v := range all {
+		items[k] = v
+	}
+	expected := map[string]int{"b": 2, "c": 3}
+	if !reflect.DeepEqual(items, expected) {
+		t.Errorf("Expected %v, but got %v", expected, items)
+	}
+	if shelf.Len() != 2 {
+		t.Errorf("Expected len 2, but got %d", shelf.Len())
+	}
+}
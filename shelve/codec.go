@@ -3,21 +3,38 @@ package shelve
 import (
 	"bytes"
 	"encoding"
+	"encoding/binary"
 	"encoding/gob"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"reflect"
 	"strconv"
+	"sync"
 )
 
 // Codec is the interface for encoding and decoding data stored by Shelf.
 //
 // The go-shelve module natively supports the following codecs:
 //   - [GobCodec]: Returns a Codec for the [gob] format.
+//   - [GobDictionaryCodec]: Returns a Codec for the [gob] format that stores
+//     the type descriptions once, in the database of the Shelf.
 //   - [JSONCodec]: Returns a Codec for the [json] format.
 //   - [TextCodec]: Returns a Codec for values that can be represented as
 //     plain text.
+//   - [RawCodec]: Returns a Codec that stores byte slices and strings as
+//     they are.
+//   - [OrderedCodec]: Returns a Codec for keys that must sort in their
+//     natural order.
+//   - [EncryptedCodec]: Returns a Codec that encrypts the data of another
+//     Codec.
+//   - [CompressedCodec]: Returns a Codec that compresses the data of
+//     another Codec.
+//   - [EnvelopeCodec]: Returns a Codec that stores the ID of the codec
+//     with each value, so the codec of a Shelf can be changed.
 //
 // Additional codecs are provided by the packages in [driver/encoding].
 //
@@ -31,6 +48,47 @@ type Codec interface {
 	Decode(data []byte, v any) error
 }
 
+// keyedCodec is implemented by the codecs that bind the encoded values to
+// the encoded keys of their records, like EncryptedCodec, and by the codecs
+// that wrap other codecs, to pass the key along. The Shelf calls these
+// methods instead of Encode and Decode, with the key as stored in the
+// database.
+type keyedCodec interface {
+	encodeKeyed(key []byte, v any) ([]byte, error)
+	decodeKeyed(key, data []byte, v any) error
+
+	// unwrapKeyed returns the data as encoded by the innermost codec, after
+	// the decryption and decompression done by the wrapping codecs.
+	unwrapKeyed(key, data []byte) ([]byte, error)
+}
+
+// encodeKeyed encodes v with the codec, passing the key along if the codec
+// implements keyedCodec.
+func encodeKeyed(c Codec, key []byte, v any) ([]byte, error) {
+	if kc, ok := c.(keyedCodec); ok {
+		return kc.encodeKeyed(key, v)
+	}
+	return c.Encode(v)
+}
+
+// decodeKeyed decodes the data with the codec, passing the key along if the
+// codec implements keyedCodec.
+func decodeKeyed(c Codec, key, data []byte, v any) error {
+	if kc, ok := c.(keyedCodec); ok {
+		return kc.decodeKeyed(key, data, v)
+	}
+	return c.Decode(data, v)
+}
+
+// unwrapKeyed returns the data as encoded by the innermost codec wrapped by
+// c, or the data itself if c doesn't implement keyedCodec.
+func unwrapKeyed(c Codec, key, data []byte) ([]byte, error) {
+	if kc, ok := c.(keyedCodec); ok {
+		return kc.unwrapKeyed(key, data)
+	}
+	return data, nil
+}
+
 // GobCodec Returns a Codec for the [gob] format, a self-describing
 // serialization format native to Go.
 //
@@ -38,12 +96,107 @@ type Codec interface {
 // JSON.
 func GobCodec() Codec { return gobCodec{} }
 
-// JSONCodec Returns a Codec for the JSON format.
-func JSONCodec() Codec { return jsonCodec{} }
+// JSONCodec Returns a Codec for the JSON format. By default, the values are
+// indented with two spaces and the HTML characters are escaped, like with
+// [json.MarshalIndent], which can be changed with the [JSONOption] functions.
+// The values are decoded regardless of the options used to encode them.
+func JSONCodec(opts ...JSONOption) Codec {
+	o := jsonOptions{
+		EscapeHTML: true,
+	}
+	for _, option := range opts {
+		option(&o)
+	}
+	return jsonCodec{
+		jsonOptions: o,
+		encoders:    &sync.Pool{},
+		decoders:    &sync.Pool{},
+	}
+}
+
+// JSONOption is passed to [JSONCodec] to customize it.
+type JSONOption func(*jsonOptions)
+
+type jsonOptions struct {
+	Compact               bool
+	UseNumber             bool
+	DisallowUnknownFields bool
+	EscapeHTML            bool
+}
+
+// WithCompactJSON makes the JSONCodec encode the values without indentation,
+// which makes them smaller.
+func WithCompactJSON() JSONOption {
+	return func(o *jsonOptions) {
+		o.Compact = true
+	}
+}
+
+// WithJSONUseNumber makes the JSONCodec decode the numbers held by interface
+// values as a [json.Number], instead of a float64, which keeps the precision
+// of large integers, for instance in a map[string]any.
+func WithJSONUseNumber() JSONOption {
+	return func(o *jsonOptions) {
+		o.UseNumber = true
+	}
+}
+
+// WithJSONDisallowUnknownFields makes the JSONCodec return an error when a
+// value has an object key that doesn't match an exported field of the
+// struct it is decoded into, instead of ignoring it.
+func WithJSONDisallowUnknownFields() JSONOption {
+	return func(o *jsonOptions) {
+		o.DisallowUnknownFields = true
+	}
+}
+
+// WithJSONEscapeHTML sets whether the JSONCodec escapes the characters <, >
+// and & in strings, as \u003c, \u003e and \u0026. The default is true.
+func WithJSONEscapeHTML(on bool) JSONOption {
+	return func(o *jsonOptions) {
+		o.EscapeHTML = on
+	}
+}
 
 // TextCodec Returns a Codec for values that can be represented as plain text.
+//
+// The strings are stored as they are, so a string that is not valid UTF-8
+// and starts with a byte from 0xf5 to 0xff can be mistaken for a value
+// tagged by [EnvelopeCodec], [CompressedCodec] or [WithSchema]. Use
+// [RawCodec] for binary data.
 func TextCodec() Codec { return textCodec{} }
 
+// RawCodec Returns a Codec that stores the values verbatim, without any
+// serialization. It supports []byte, string and [json.RawMessage] values, as
+// well as the other types whose underlying type is []byte or string, and the
+// types that implement [encoding.BinaryMarshaler] and
+// [encoding.BinaryUnmarshaler], like [time.Time]. The representation is
+// chosen from the type of each value.
+//
+// The values starting with a byte from 0xf5 to 0xff, which start the values
+// tagged by [EnvelopeCodec], [CompressedCodec], [GobDictionaryCodec] and
+// [WithSchema], are stored with an extra leading byte, so that they aren't
+// mistaken for tagged values. The decoded byte slices don't share memory
+// with the database, so they can be modified freely.
+func RawCodec() Codec { return rawCodec{} }
+
+// OrderedCodec Returns a Codec for keys whose encoded form sorts in the same
+// order as the values themselves.
+//
+// Integers are encoded as fixed-width big-endian numbers, with the sign bit
+// flipped for signed types, and floats are encoded from their IEEE 754 bits
+// so that negative numbers sort before positive ones. Strings and [N]byte
+// arrays are stored as their raw bytes, and booleans as a single byte. Named
+// types, like "type UserID int64", are encoded like their underlying type.
+//
+// This makes the iteration methods of a Shelf, like [Shelf.Items], return
+// numeric keys in numeric order, which is not the case with [TextCodec]
+// (e.g., "10" sorts before "2"). It is meant to be used with [WithKeyCodec].
+//
+// The encoded keys are binary, so databases that use the keys directly as
+// file names, like the diskv driver, are not suitable for it.
+func OrderedCodec() Codec { return orderedCodec{} }
+
 // Gob Codec
 
 type gobCodec struct{}
@@ -69,14 +222,130 @@ func (gobCodec) Decode(data []byte, value any) error {
 
 // Json Codec
 
-type jsonCodec struct{}
+// maxPooledJSONBuffer is the capacity above which the buffers of the JSON
+// encoders and decoders aren't kept for reuse, to not hold on to the memory
+// of a few large values.
+const maxPooledJSONBuffer = 64 << 10
+
+type jsonCodec struct {
+	jsonOptions
+
+	// Pools of *jsonEncoder and *jsonDecoder, nil in the zero value.
+	encoders *sync.Pool
+	decoders *sync.Pool
+}
+
+type jsonEncoder struct {
+	buf bytes.Buffer
+	enc *json.Encoder
+}
+
+// jsonDecoder is a json.Decoder reading from a bytes.Reader, which is reset
+// with the data of each value. The Decoder can't be reset, but once it has
+// read a value and the end of its input, it only keeps the offset of the
+// input, so it can decode the next value.
+type jsonDecoder struct {
+	r    bytes.Reader
+	dec  *json.Decoder
+	size int // bytes read, to bound the buffer of the Decoder
+}
+
+func (c jsonCodec) Encode(value any) ([]byte, error) {
+	e := c.encoder()
+	defer c.release(e)
+
+	if err := e.enc.Encode(value); err != nil {
+		return nil, err
+	}
+	// Remove the newline added by the Encoder, and copy the data, since
+	// the buffer is reused.
+	data := bytes.TrimSuffix(e.buf.Bytes(), []byte{'\n'})
+	return bytes.Clone(data), nil
+}
+
+func (c jsonCodec) Decode(data []byte, value any) error {
+	if !c.UseNumber && !c.DisallowUnknownFields {
+		return json.Unmarshal(data, value)
+	}
+
+	d, reused := c.decoder(data)
+	if err := d.decode(value); err != nil {
+		if reused {
+			// A reused Decoder counts the offsets of the syntax errors
+			// from its first value, so a new one reports the error.
+			return c.newDecoder(data).decode(value)
+		}
+		// The Decoder may hold the rest of the data, so it is dropped.
+		return err
+	}
+	c.releaseDecoder(d)
+	return nil
+}
+
+func (d *jsonDecoder) decode(value any) error {
+	if err := d.dec.Decode(value); err != nil {
+		return err
+	}
+	if _, err := d.dec.Token(); err != io.EOF {
+		return errors.New("invalid data after top-level JSON value")
+	}
+	return nil
+}
+
+// decoder returns a decoder reading the data, and reports whether it was
+// reused from the pool.
+func (c jsonCodec) decoder(data []byte) (*jsonDecoder, bool) {
+	if c.decoders != nil {
+		if d, ok := c.decoders.Get().(*jsonDecoder); ok {
+			d.r.Reset(data)
+			d.size = max(d.size, len(data))
+			return d, true
+		}
+	}
+	return c.newDecoder(data), false
+}
+
+func (c jsonCodec) newDecoder(data []byte) *jsonDecoder {
+	d := &jsonDecoder{size: len(data)}
+	d.r.Reset(data)
+	d.dec = json.NewDecoder(&d.r)
+	if c.UseNumber {
+		d.dec.UseNumber()
+	}
+	if c.DisallowUnknownFields {
+		d.dec.DisallowUnknownFields()
+	}
+	return d
+}
 
-func (jsonCodec) Encode(value any) ([]byte, error) {
-	return json.MarshalIndent(value, "", "  ")
+func (c jsonCodec) releaseDecoder(d *jsonDecoder) {
+	if c.decoders != nil && d.size <= maxPooledJSONBuffer {
+		// Don't keep the data of the value alive.
+		d.r.Reset(nil)
+		c.decoders.Put(d)
+	}
+}
+
+func (c jsonCodec) encoder() *jsonEncoder {
+	if c.encoders != nil {
+		if e, ok := c.encoders.Get().(*jsonEncoder); ok {
+			e.buf.Reset()
+			return e
+		}
+	}
+	e := &jsonEncoder{}
+	e.enc = json.NewEncoder(&e.buf)
+	e.enc.SetEscapeHTML(c.EscapeHTML)
+	if !c.Compact {
+		e.enc.SetIndent("", "  ")
+	}
+	return e
 }
 
-func (jsonCodec) Decode(data []byte, value any) error {
-	return json.Unmarshal(data, value)
+func (c jsonCodec) release(e *jsonEncoder) {
+	if c.encoders != nil && e.buf.Cap() <= maxPooledJSONBuffer {
+		c.encoders.Put(e)
+	}
 }
 
 // Text Codec
@@ -197,6 +466,263 @@ func (textCodec) Decode(data []byte, value any) error {
 	}
 }
 
+// Raw Codec
+//
+// The tags of the values written by the wrapping codecs and by WithSchema
+// are in the range from minTag to 0xff, which can't start JSON, valid UTF-8
+// text or a gob message, but can start a raw value. These raw values are
+// escaped with rawEscapeTag, which is in the same range.
+
+const (
+	// minTag is the smallest of the tags that start the values written by
+	// the wrapping codecs and by WithSchema.
+	minTag = 0xf5
+
+	// rawEscapeTag is the first byte of the values encoded by RawCodec that
+	// would otherwise start with a tag.
+	rawEscapeTag = 0xf8
+)
+
+type rawCodec struct{}
+
+func (rawCodec) Encode(value any) ([]byte, error) {
+	data, err := encodeRaw(value)
+	if err != nil || len(data) == 0 || data[0] < minTag {
+		return data, err
+	}
+	return append([]byte{rawEscapeTag}, data...), nil
+}
+
+func encodeRaw(value any) ([]byte, error) {
+	switch v := value.(type) {
+	case []byte:
+		return bytes.Clone(v), nil
+	case string:
+		return []byte(v), nil
+	case json.RawMessage:
+		return bytes.Clone(v), nil
+	case encoding.BinaryMarshaler:
+		return v.MarshalBinary()
+	}
+
+	rv := reflect.ValueOf(value)
+	switch {
+	case rv.Kind() == reflect.String:
+		return []byte(rv.String()), nil
+	case rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8:
+		return bytes.Clone(rv.Bytes()), nil
+	}
+	return nil, fmt.Errorf("rawCodec: unsupported type %T", value)
+}
+
+func (rawCodec) Decode(data []byte, value any) error {
+	if len(data) > 0 && data[0] == rawEscapeTag {
+		data = data[1:]
+	}
+	switch v := value.(type) {
+	case *[]byte:
+		*v = bytes.Clone(data)
+		return nil
+	case *string:
+		*v = string(data)
+		return nil
+	case *json.RawMessage:
+		*v = bytes.Clone(data)
+		return nil
+	case encoding.BinaryUnmarshaler:
+		return v.UnmarshalBinary(data)
+	}
+
+	rv := reflect.ValueOf(value)
+	if rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		elem := rv.Elem()
+		switch {
+		case elem.Kind() == reflect.String:
+			elem.SetString(string(data))
+			return nil
+		case elem.Kind() == reflect.Slice && elem.Type().Elem().Kind() == reflect.Uint8:
+			elem.SetBytes(bytes.Clone(data))
+			return nil
+		}
+	}
+	return fmt.Errorf("rawCodec: unsupported decode target %T", value)
+}
+
+// Ordered Codec
+
+type orderedCodec struct{}
+
+func (orderedCodec) Encode(value any) ([]byte, error) {
+	// Switch on the kind, so that named types like "type UserID int64" are
+	// encoded like their underlying type.
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.String:
+		return []byte(v.String()), nil
+	case reflect.Bool:
+		if v.Bool() {
+			return []byte{1}, nil
+		}
+		return []byte{0}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return encodeInt(v.Int(), orderedSize(v.Kind())), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return encodeUint(v.Uint(), orderedSize(v.Kind())), nil
+	case reflect.Float32:
+		return encodeUint(uint64(orderedFloat32(float32(v.Float()))), 4), nil
+	case reflect.Float64:
+		return encodeUint(orderedFloat64(v.Float()), 8), nil
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			buf := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(buf), v)
+			return buf, nil
+		}
+	}
+	return nil, fmt.Errorf("orderedCodec: unsupported type %T", value)
+}
+
+func (orderedCodec) Decode(data []byte, value any) error {
+	ptr := reflect.ValueOf(value)
+	if ptr.Kind() != reflect.Pointer || ptr.IsNil() {
+		return fmt.Errorf("orderedCodec: unsupported decode target %T", value)
+	}
+	v := ptr.Elem()
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(string(data))
+		return nil
+	case reflect.Bool:
+		u, err := decodeUint(data, 1)
+		if err != nil {
+			return err
+		}
+		v.SetBool(u != 0)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := decodeInt(data, orderedSize(v.Kind()))
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := decodeUint(data, orderedSize(v.Kind()))
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+		return nil
+	case reflect.Float32:
+		u, err := decodeUint(data, 4)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(float64(float32FromOrdered(uint32(u))))
+		return nil
+	case reflect.Float64:
+		u, err := decodeUint(data, 8)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(float64FromOrdered(u))
+		return nil
+	case reflect.Array:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			break
+		}
+		if len(data) != v.Len() {
+			return fmt.Errorf("orderedCodec: invalid length: got %d bytes, want %d",
+				len(data), v.Len())
+		}
+		reflect.Copy(v, reflect.ValueOf(data))
+		return nil
+	}
+	return fmt.Errorf("orderedCodec: unsupported decode target %T", value)
+}
+
+// orderedSize returns the size in bytes of the encoded integers of the given
+// kind. The int and uint types always use 8 bytes, so the encoded keys don't
+// depend on the platform.
+func orderedSize(kind reflect.Kind) int {
+	switch kind {
+	case reflect.Int8, reflect.Uint8:
+		return 1
+	case reflect.Int16, reflect.Uint16:
+		return 2
+	case reflect.Int32, reflect.Uint32:
+		return 4
+	}
+	return 8
+}
+
+// encodeUint encodes u as a big-endian number with the given size in bytes.
+func encodeUint(u uint64, size int) []byte {
+	buf := binary.BigEndian.AppendUint64(nil, u)
+	return buf[8-size:]
+}
+
+// encodeInt encodes i as a big-endian number with the given size in bytes,
+// flipping the sign bit so that negative numbers sort first.
+func encodeInt(i int64, size int) []byte {
+	signBit := uint64(1) << (size*8 - 1)
+	return encodeUint(uint64(i)^signBit, size)
+}
+
+func decodeUint(data []byte, size int) (uint64, error) {
+	if len(data) != size {
+		return 0, fmt.Errorf("orderedCodec: invalid length: got %d bytes, want %d",
+			len(data), size)
+	}
+	var buf [8]byte
+	copy(buf[8-size:], data)
+	return binary.BigEndian.Uint64(buf[:]), nil
+}
+
+func decodeInt(data []byte, size int) (int64, error) {
+	u, err := decodeUint(data, size)
+	if err != nil {
+		return 0, err
+	}
+	bits := uint(size * 8)
+	u ^= uint64(1) << (bits - 1)
+	// Sign-extend the value to 64 bits.
+	return int64(u<<(64-bits)) >> (64 - bits), nil
+}
+
+// orderedFloat64 maps f to an uint64 that sorts in the same order as f: the
+// sign bit is flipped for positive numbers and all bits are flipped for
+// negative ones.
+func orderedFloat64(f float64) uint64 {
+	u := math.Float64bits(f)
+	if u&(1<<63) != 0 {
+		return ^u
+	}
+	return u | 1<<63
+}
+
+func float64FromOrdered(u uint64) float64 {
+	if u&(1<<63) != 0 {
+		return math.Float64frombits(u &^ (1 << 63))
+	}
+	return math.Float64frombits(^u)
+}
+
+func orderedFloat32(f float32) uint32 {
+	u := math.Float32bits(f)
+	if u&(1<<31) != 0 {
+		return ^u
+	}
+	return u | 1<<31
+}
+
+func float32FromOrdered(u uint32) float32 {
+	if u&(1<<31) != 0 {
+		return math.Float32frombits(u &^ (1 << 31))
+	}
+	return math.Float32frombits(^u)
+}
+
 func encodeFixedByteArray(v any) ([]byte, bool) {
 	val := reflect.ValueOf(v)
 	if val.Kind() == reflect.Array && val.Type().Elem().Kind() == reflect.Uint8 {
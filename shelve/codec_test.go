@@ -1,7 +1,11 @@
 package shelve
 
 import (
+	"bytes"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math"
 	"reflect"
 	"strings"
 	"testing"
@@ -106,6 +110,128 @@ func TestJsonCodec_Decode(t *testing.T) {
 	})
 }
 
+func TestJsonCodec_Options(t *testing.T) {
+	value := map[string]any{"name": "<b>", "id": uint64(math.MaxUint64)}
+
+	t.Run("Encode", func(t *testing.T) {
+		indented, _ := json.MarshalIndent(value, "", "  ")
+
+		tests := []struct {
+			name     string
+			codec    Codec
+			expected string
+		}{
+			{
+				name:     "Default",
+				codec:    JSONCodec(),
+				expected: string(indented),
+			},
+			{
+				name:     "Compact",
+				codec:    JSONCodec(WithCompactJSON()),
+				expected: `{"id":18446744073709551615,"name":"\u003cb\u003e"}`,
+			},
+			{
+				name:     "Without HTML escaping",
+				codec:    JSONCodec(WithCompactJSON(), WithJSONEscapeHTML(false)),
+				expected: `{"id":18446744073709551615,"name":"<b>"}`,
+			},
+		}
+		for _, tc := range tests {
+			t.Run(tc.name, func(t *testing.T) {
+				data, err := tc.codec.Encode(value)
+				if err != nil {
+					t.Fatalf("Expected no error, but got %v", err)
+				}
+				if string(data) != tc.expected {
+					t.Errorf("Expected %s, but got %s", tc.expected, data)
+				}
+			})
+		}
+	})
+
+	t.Run("Reuses the encoders", func(t *testing.T) {
+		codec := JSONCodec(WithCompactJSON())
+		a, _ := codec.Encode("a")
+		b, _ := codec.Encode("b")
+		if string(a) != `"a"` || string(b) != `"b"` {
+			t.Errorf("Expected \"a\" and \"b\", but got %s and %s", a, b)
+		}
+	})
+
+	t.Run("Reads indented values", func(t *testing.T) {
+		data, _ := JSONCodec().Encode(value)
+
+		var got map[string]any
+		err := JSONCodec(WithCompactJSON(), WithJSONUseNumber()).Decode(data, &got)
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		if got["name"] != "<b>" {
+			t.Errorf("Expected <b>, but got %v", got["name"])
+		}
+	})
+
+	t.Run("UseNumber", func(t *testing.T) {
+		data, _ := JSONCodec().Encode(value)
+
+		var got map[string]any
+		if err := JSONCodec(WithJSONUseNumber()).Decode(data, &got); err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		n, ok := got["id"].(json.Number)
+		if !ok || n.String() != "18446744073709551615" {
+			t.Errorf("Expected 18446744073709551615, but got %v", got["id"])
+		}
+	})
+
+	t.Run("Reuses the decoders", func(t *testing.T) {
+		codec := JSONCodec(WithJSONUseNumber(), WithJSONDisallowUnknownFields())
+		fresh := JSONCodec(WithJSONUseNumber())
+
+		for i, data := range []string{`{"Name": "a"}`, ` "b" `, `{"Name": "c"}`} {
+			var got any
+			if err := codec.Decode([]byte(data), &got); err != nil {
+				t.Fatalf("%d: Expected no error, but got %v", i, err)
+			}
+		}
+		var v struct{ Name string }
+		if err := codec.Decode([]byte(`{"Name": "d"}`), &v); err != nil || v.Name != "d" {
+			t.Errorf("Expected d, but got %q, %v", v.Name, err)
+		}
+
+		// The syntax errors have the offsets of a new decoder.
+		var got, want *json.SyntaxError
+		errors.As(codec.Decode([]byte(`{"Name": x}`), &v), &got)
+		errors.As(fresh.Decode([]byte(`{"Name": x}`), &v), &want)
+		if got == nil || want == nil || got.Offset != want.Offset {
+			t.Errorf("Expected a syntax error at %v, but got %v", want, got)
+		}
+		if err := codec.Decode([]byte(`"e" "f"`), new(string)); err == nil {
+			t.Errorf("Expected an error, but got nil")
+		}
+		var n json.Number
+		if err := codec.Decode([]byte(`42`), &n); err != nil || n != "42" {
+			t.Errorf("Expected 42, but got %q, %v", n, err)
+		}
+	})
+
+	t.Run("DisallowUnknownFields", func(t *testing.T) {
+		codec := JSONCodec(WithJSONDisallowUnknownFields())
+
+		var v struct{ Name string }
+		if err := codec.Decode([]byte(`{"Name": "a"}`), &v); err != nil || v.Name != "a" {
+			t.Errorf("Expected a, but got %q, %v", v.Name, err)
+		}
+		if err := codec.Decode([]byte(`{"Name": "a", "Age": 1}`), &v); err == nil {
+			t.Errorf("Expected an error, but got nil")
+		}
+		if err := codec.Decode([]byte(`{"Name": "a"} {}`), &v); err == nil {
+			t.Errorf("Expected an error, but got nil")
+		}
+	})
+}
+
 // String
 
 func TestTextCodec_Encode(t *testing.T) {
@@ -235,6 +361,360 @@ func TestTextCodec_Decode(t *testing.T) {
 	}
 }
 
+// Raw
+
+type rawName string
+
+type rawBytes []byte
+
+func TestRawCodec_Encode(t *testing.T) {
+	now := time.Date(2023, 10, 20, 15, 0, 0, 0, time.UTC)
+	binary, _ := now.MarshalBinary()
+
+	tests := []struct {
+		name  string
+		input any
+		want  []byte
+	}{
+		{name: "Encode Bytes", input: []byte{0x00, 0xff}, want: []byte{0x00, 0xff}},
+		{name: "Encode String", input: "hello", want: []byte("hello")},
+		{name: "Encode RawMessage", input: json.RawMessage(`{"a":1}`), want: []byte(`{"a":1}`)},
+		{name: "Encode Named String", input: rawName("hello"), want: []byte("hello")},
+		{name: "Encode Named Bytes", input: rawBytes("hello"), want: []byte("hello")},
+
+		// encoding.BinaryMarshaler
+		{name: "Encode time.Time", input: now, want: binary},
+
+		// Escaped tags
+		{name: "Encode Tagged", input: []byte{0xf6, 0x01}, want: []byte{0xf8, 0xf6, 0x01}},
+		{name: "Encode Escape Tag", input: "\xf8", want: []byte{0xf8, 0xf8}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var codec rawCodec
+			data, err := codec.Encode(tc.input)
+
+			if err != nil {
+				t.Errorf("Encode() failed: %v", err)
+			}
+			if !reflect.DeepEqual(data, tc.want) {
+				t.Errorf("Encode() output = %q, want %q", data, tc.want)
+			}
+		})
+	}
+
+	t.Run("Copies the bytes", func(t *testing.T) {
+		value := []byte("hello")
+		data, _ := RawCodec().Encode(value)
+		value[0] = 'j'
+		if string(data) != "hello" {
+			t.Errorf("Expected hello, but got %q", data)
+		}
+	})
+
+	t.Run("Encode Error", func(t *testing.T) {
+		for _, value := range []any{1, struct{}{}, []int{1}} {
+			if _, err := RawCodec().Encode(value); err == nil {
+				t.Errorf("%T: Expected an error, but got nil", value)
+			}
+		}
+	})
+}
+
+func TestRawCodec_Decode(t *testing.T) {
+	now := time.Date(2023, 10, 20, 15, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		input     any
+		wantError bool
+	}{
+		{name: "Bytes", input: []byte{0x00, 0xff}},
+		{name: "Empty Bytes", input: []byte{}},
+		{name: "String", input: "hello"},
+		{name: "RawMessage", input: json.RawMessage(`{"a":1}`)},
+		{name: "Named String", input: rawName("hello")},
+		{name: "Named Bytes", input: rawBytes("hello")},
+
+		// encoding.BinaryMarshaler / BinaryUnmarshaler
+		{name: "Time", input: now},
+
+		// Escaped tags
+		{name: "Tagged", input: []byte{0xf5, 0x01, 0x02}},
+		{name: "Escape Tag", input: "\xf8"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var codec rawCodec
+
+			data, err := codec.Encode(tc.input)
+			if err != nil {
+				t.Fatalf("Encode failed: %v", err)
+			}
+
+			ptr := reflect.New(reflect.TypeOf(tc.input)).Interface()
+			err = codec.Decode(data, ptr)
+			if err != nil {
+				t.Errorf("unexpected decode error: %v", err)
+				return
+			}
+
+			got := reflect.ValueOf(ptr).Elem().Interface()
+			if !reflect.DeepEqual(got, tc.input) {
+				t.Errorf("Decode() = %v (%T), want %v (%T)", got, got, tc.input, tc.input)
+			}
+		})
+	}
+
+	t.Run("Copies the bytes", func(t *testing.T) {
+		data := []byte("hello")
+		var value []byte
+		_ = RawCodec().Decode(data, &value)
+		data[0] = 'j'
+		if string(value) != "hello" {
+			t.Errorf("Expected hello, but got %q", value)
+		}
+	})
+
+	t.Run("Decode Error", func(t *testing.T) {
+		var i int
+		var v struct{}
+		var s []byte
+		for _, target := range []any{&i, &v, s, nil} {
+			if err := RawCodec().Decode([]byte("1"), target); err == nil {
+				t.Errorf("%T: Expected an error, but got nil", target)
+			}
+		}
+	})
+}
+
+func TestShelf_RawCodec(t *testing.T) {
+	seed := map[string]string{
+		"a": "\x00\xff",
+		"b": "",
+		"c": "text",
+		"d": "\xf6\x00\x00\x00\x01data", // Like a schema header
+		"e": "\xf5\x01\x01data",         // Like an envelope
+	}
+
+	t.Run("Put and Get", func(t *testing.T) {
+		// Arrange
+		shelf := OpenTestShelfWith[string, []byte](t, WithCodec(RawCodec()))
+		defer shelf.Close()
+
+		// Act
+		for k, v := range seed {
+			if err := shelf.Put(k, []byte(v)); err != nil {
+				t.Fatalf("Expected no error, but got %v", err)
+			}
+		}
+
+		// Assert
+		for k, v := range seed {
+			value, ok, err := shelf.Get(k)
+			if err != nil || !ok {
+				t.Fatalf("Expected no error, but got %v, %v", ok, err)
+			}
+			if string(value) != v {
+				t.Errorf("Expected %q, but got %q", v, value)
+			}
+		}
+	})
+
+	t.Run("Stored before the wrappers", func(t *testing.T) {
+		// Arrange
+		shelf := OpenTestShelfWith[string, []byte](t, WithCodec(RawCodec()))
+		defer shelf.Close()
+		for k, v := range seed {
+			_ = shelf.Put(k, []byte(v))
+		}
+		envelope, err := EnvelopeCodec(GobCodecID, WithLegacyCodec(RawCodec()))
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+
+		for _, opt := range []Option{
+			WithSchema(1, map[uint32]Migration[[]byte]{
+				0: func(data []byte) (value []byte, err error) {
+					err = RawCodec().Decode(data, &value)
+					return value, err
+				},
+			}),
+			WithCodec(envelope),
+		} {
+			// Act
+			shelf2, err := Open[string, []byte](TestDirectory,
+				WithDatabase(shelf.db), WithCodec(RawCodec()), opt)
+			if err != nil {
+				t.Fatalf("Expected no error, but got %v", err)
+			}
+
+			// Assert
+			for k, v := range seed {
+				value, ok, err := shelf2.Get(k)
+				if err != nil || !ok || string(value) != v {
+					t.Errorf("Expected %q, but got %q, %v, %v", v, value, ok, err)
+				}
+			}
+		}
+	})
+}
+
+// Ordered
+
+type (
+	orderedUserID int64
+	orderedName   string
+	orderedScore  float64
+	orderedHash   [2]byte
+)
+
+func TestOrderedCodec_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		input any
+	}{
+		{name: "String", input: "hello"},
+		{name: "Bool True", input: true},
+		{name: "Bool False", input: false},
+
+		{name: "Int", input: int(-42)},
+		{name: "Int8", input: int8(math.MinInt8)},
+		{name: "Int16", input: int16(-42)},
+		{name: "Int32", input: int32(math.MaxInt32)},
+		{name: "Int64", input: int64(math.MinInt64)},
+
+		{name: "Uint", input: uint(42)},
+		{name: "Uint8", input: uint8(math.MaxUint8)},
+		{name: "Uint16", input: uint16(42)},
+		{name: "Uint32", input: uint32(42)},
+		{name: "Uint64", input: uint64(math.MaxUint64)},
+
+		{name: "Float32", input: float32(-3.14)},
+		{name: "Float64", input: float64(3.14)},
+		{name: "Float64 Negative Zero", input: math.Copysign(0, -1)},
+		{name: "Float64 Inf", input: math.Inf(-1)},
+
+		{name: "Byte Array", input: [4]byte{1, 2, 3, 4}},
+
+		{name: "Named Int", input: orderedUserID(-42)},
+		{name: "Named String", input: orderedName("ada")},
+		{name: "Named Float", input: orderedScore(2.5)},
+		{name: "Named Array", input: orderedHash{1, 2}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var codec orderedCodec
+
+			data, err := codec.Encode(tc.input)
+			if err != nil {
+				t.Fatalf("Encode failed: %v", err)
+			}
+
+			ptr := reflect.New(reflect.TypeOf(tc.input)).Interface()
+			if err = codec.Decode(data, ptr); err != nil {
+				t.Fatalf("Decode failed: %v", err)
+			}
+
+			got := reflect.ValueOf(ptr).Elem().Interface()
+			if !reflect.DeepEqual(got, tc.input) {
+				t.Errorf("Decode() = %v (%T), want %v (%T)", got, got, tc.input, tc.input)
+			}
+		})
+	}
+}
+
+func TestOrderedCodec_Order(t *testing.T) {
+	t.Run("Int64", func(t *testing.T) {
+		OrderTest(t, []int64{math.MinInt64, -1000, -2, -1, 0, 1, 2, 10, 100, math.MaxInt64})
+	})
+	t.Run("Int8", func(t *testing.T) {
+		OrderTest(t, []int8{math.MinInt8, -10, -1, 0, 1, 10, math.MaxInt8})
+	})
+	t.Run("Uint32", func(t *testing.T) {
+		OrderTest(t, []uint32{0, 1, 2, 10, 255, 256, 1000, math.MaxUint32})
+	})
+	t.Run("Float64", func(t *testing.T) {
+		OrderTest(t, []float64{
+			math.Inf(-1), -math.MaxFloat64, -100.5, -1, -math.SmallestNonzeroFloat64,
+			0, math.SmallestNonzeroFloat64, 0.5, 1, 2, 10, math.MaxFloat64, math.Inf(1),
+		})
+	})
+	t.Run("Float32", func(t *testing.T) {
+		OrderTest(t, []float32{-100.5, -1, -0.25, 0, 0.25, 1, 2, 10})
+	})
+	t.Run("Named Int", func(t *testing.T) {
+		OrderTest(t, []orderedUserID{math.MinInt64, -1, 0, 1, 10, math.MaxInt64})
+	})
+}
+
+// OrderTest checks that the encoded values sort in the same order as the
+// given (sorted) values.
+func OrderTest[T any](t *testing.T, values []T) {
+	t.Helper()
+	var codec orderedCodec
+
+	var prev []byte
+	for i, v := range values {
+		data, err := codec.Encode(v)
+		if err != nil {
+			t.Fatalf("Encode failed: %v", err)
+		}
+		if i > 0 && bytes.Compare(prev, data) >= 0 {
+			t.Errorf("Expected %v to sort after %v, but got %x <= %x",
+				v, values[i-1], data, prev)
+		}
+		prev = data
+	}
+}
+
+func TestOrderedCodec_Errors(t *testing.T) {
+	var codec orderedCodec
+
+	t.Run("Encode Unsupported", func(t *testing.T) {
+		_, err := codec.Encode(struct{}{})
+		if err == nil {
+			t.Errorf("Expected error, but got nil")
+		}
+	})
+
+	t.Run("Decode Unsupported", func(t *testing.T) {
+		var s struct{}
+		err := codec.Decode([]byte{0}, &s)
+		if err == nil {
+			t.Errorf("Expected error, but got nil")
+		}
+	})
+
+	t.Run("Decode Non-pointer", func(t *testing.T) {
+		var i *int64
+		for _, target := range []any{int64(0), i, [4]int{}} {
+			if err := codec.Decode([]byte{0}, target); err == nil {
+				t.Errorf("Expected error for %T, but got nil", target)
+			}
+		}
+	})
+
+	t.Run("Decode Invalid Length", func(t *testing.T) {
+		var i int32
+		err := codec.Decode([]byte{0, 1}, &i)
+		if err == nil || !strings.Contains(err.Error(), "invalid length") {
+			t.Errorf("Expected invalid length error, but got %v", err)
+		}
+	})
+
+	t.Run("Decode Invalid Array Length", func(t *testing.T) {
+		var arr [4]byte
+		err := codec.Decode([]byte{0, 1}, &arr)
+		if err == nil || !strings.Contains(err.Error(), "invalid length") {
+			t.Errorf("Expected invalid length error, but got %v", err)
+		}
+	})
+}
+
 // Other
 
 func TestDecodeFixedByteArray_EdgeCases(t *testing.T) {
@@ -0,0 +1,408 @@
+package shelve
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// compressionTag is the first byte of the values tagged by
+	// compressedCodec. It is followed by the ID of the compressor and the
+	// size of the original data, as an uvarint, or by rawCompressorID if the
+	// value isn't compressed.
+	compressionTag = 0xff
+
+	// rawCompressorID tags the values that aren't compressed, but would be
+	// mistaken for tagged values.
+	rawCompressorID = 0
+
+	// maxCompressorID is the largest valid compressor ID. Keeping the IDs
+	// below 0x80 makes the tagged values distinct from the data of the
+	// native codecs: 0xff is not valid in JSON and text, and a gob message
+	// starting with 0xff is followed by a byte of 0x80 or more.
+	maxCompressorID = 0x7f
+
+	// defaultCompressThreshold is the default minimum size of the values
+	// compressed by compressedCodec.
+	defaultCompressThreshold = 512
+
+	// defaultMaxDecompressedSize is the default maximum size of the values
+	// decompressed by compressedCodec.
+	defaultMaxDecompressedSize = 64 << 20
+
+	flateCompressorID = 1
+	gzipCompressorID  = 2
+)
+
+// ErrUnknownCompressor is returned when decoding a value compressed with a
+// compressor that isn't known by the [compressedCodec].
+var ErrUnknownCompressor = errors.New("unknown compressor")
+
+// Compressor compresses the data encoded by a [compressedCodec].
+type Compressor interface {
+	// ID identifies the compressor in the header of the compressed values.
+	// It must be between 1 and 127, and it must not change once values are
+	// stored. The IDs 1 and 2 are used by FlateCompressor and
+	// GzipCompressor.
+	ID() byte
+
+	// Compress returns the compressed data.
+	Compress(data []byte) ([]byte, error)
+
+	// Decompress returns the data decompressed. It is the inverse of
+	// Compress. The size of the original data is given, and no more than
+	// size bytes must be decompressed, so that a corrupted or crafted value
+	// can't exhaust the memory.
+	Decompress(data []byte, size int) ([]byte, error)
+}
+
+// FlateCompressor returns a Compressor for the [flate] format, with the given
+// compression level, such as [flate.DefaultCompression].
+func FlateCompressor(level int) Compressor { return &flateCompressor{level: level} }
+
+// GzipCompressor returns a Compressor for the [gzip] format, with the given
+// compression level, such as [gzip.DefaultCompression].
+func GzipCompressor(level int) Compressor { return &gzipCompressor{level: level} }
+
+// CompressOption is passed to [CompressedCodec] to customize it.
+type CompressOption func(*compressOptions)
+
+type compressOptions struct {
+	Compressor Compressor
+	Threshold  int
+	MaxSize    int
+}
+
+// WithCompressor sets the Compressor used to compress the values. The
+// default is FlateCompressor with the default compression level.
+func WithCompressor(c Compressor) CompressOption {
+	return func(o *compressOptions) {
+		o.Compressor = c
+	}
+}
+
+// WithCompressThreshold sets the minimum size, in bytes, of the encoded
+// values that are compressed. Smaller values are stored as they are. The
+// default is 512 bytes.
+func WithCompressThreshold(n int) CompressOption {
+	return func(o *compressOptions) {
+		o.Threshold = max(n, 0)
+	}
+}
+
+// WithMaxDecompressedSize sets the maximum size, in bytes, of the values
+// decompressed when decoding. Larger encoded values are stored uncompressed,
+// and the stored values that claim a larger size are rejected without being
+// decompressed. The default is 64 MiB.
+func WithMaxDecompressedSize(n int) CompressOption {
+	return func(o *compressOptions) {
+		o.MaxSize = max(n, 0)
+	}
+}
+
+// CompressionStats holds the sizes of the values encoded by a Codec created
+// with [CompressedCodec]. They are read with [CompressionStatsOf].
+type CompressionStats struct {
+	// Values is the number of values encoded, and Compressed the number
+	// of them that were stored compressed.
+	Values     int64
+	Compressed int64
+
+	// EncodedBytes is the size of the values encoded by the wrapped codec,
+	// and StoredBytes the size of the values returned by the compressing
+	// codec.
+	EncodedBytes int64
+	StoredBytes  int64
+}
+
+// Ratio returns the compression ratio achieved, that is, EncodedBytes
+// divided by StoredBytes. It returns 1 if no values were encoded.
+func (s CompressionStats) Ratio() float64 {
+	if s.StoredBytes == 0 {
+		return 1
+	}
+	return float64(s.EncodedBytes) / float64(s.StoredBytes)
+}
+
+// compressedCodec is a Codec that compresses the data encoded by another
+// Codec. It is created with CompressedCodec.
+type compressedCodec struct {
+	codec       Codec
+	compressor  Compressor
+	threshold   int
+	maxSize     int
+	compressors map[byte]Compressor
+
+	values       atomic.Int64
+	compressed   atomic.Int64
+	encodedBytes atomic.Int64
+	storedBytes  atomic.Int64
+}
+
+// CompressedCodec returns a Codec that compresses the data encoded by codec,
+// if it is larger than a threshold. It returns an error if the ID of the
+// Compressor is invalid. The statistics of the returned Codec can be read
+// with [CompressionStatsOf].
+//
+// The compressed values are tagged with a header, which identifies the
+// Compressor and holds the size of the original data. The values below the
+// threshold, or that don't shrink when compressed, are stored as they are,
+// so values stored before the codec was used are still decoded. Values
+// written by the FlateCompressor and GzipCompressor are always decoded, even
+// if the codec was created with another Compressor.
+//
+// When combined with [EncryptedCodec], the compression must be applied
+// first, since encrypted data doesn't compress.
+func CompressedCodec(codec Codec, opts ...CompressOption) (Codec, error) {
+	o := compressOptions{
+		Compressor: FlateCompressor(flate.DefaultCompression),
+		Threshold:  defaultCompressThreshold,
+		MaxSize:    defaultMaxDecompressedSize,
+	}
+	for _, option := range opts {
+		option(&o)
+	}
+
+	id := o.Compressor.ID()
+	if id == rawCompressorID || id > maxCompressorID {
+		return nil, fmt.Errorf("invalid compressor ID %d", id)
+	}
+
+	c := &compressedCodec{
+		codec:      codec,
+		compressor: o.Compressor,
+		threshold:  o.Threshold,
+		maxSize:    o.MaxSize,
+		compressors: map[byte]Compressor{
+			flateCompressorID: FlateCompressor(flate.DefaultCompression),
+			gzipCompressorID:  GzipCompressor(gzip.DefaultCompression),
+		},
+	}
+	c.compressors[id] = o.Compressor
+	return c, nil
+}
+
+// Encode returns the encoding of v by the wrapped codec, compressed if it
+// is larger than the threshold.
+func (c *compressedCodec) Encode(v any) ([]byte, error) {
+	return c.encodeKeyed(nil, v)
+}
+
+// Decode decompresses the data, if it is compressed, and decodes it with the
+// wrapped codec.
+func (c *compressedCodec) Decode(data []byte, v any) error {
+	return c.decodeKeyed(nil, data, v)
+}
+
+func (c *compressedCodec) encodeKeyed(key []byte, v any) ([]byte, error) {
+	data, err := encodeKeyed(c.codec, key, v)
+	if err != nil {
+		return nil, err
+	}
+	stored, compressed, err := c.compress(data)
+	if err != nil {
+		return nil, err
+	}
+
+	c.values.Add(1)
+	if compressed {
+		c.compressed.Add(1)
+	}
+	c.encodedBytes.Add(int64(len(data)))
+	c.storedBytes.Add(int64(len(stored)))
+	return stored, nil
+}
+
+func (c *compressedCodec) decodeKeyed(key, data []byte, v any) error {
+	data, err := c.decompress(data)
+	if err != nil {
+		return err
+	}
+	return decodeKeyed(c.codec, key, data, v)
+}
+
+func (c *compressedCodec) unwrapKeyed(key, data []byte) ([]byte, error) {
+	data, err := c.decompress(data)
+	if err != nil {
+		return nil, err
+	}
+	return unwrapKeyed(c.codec, key, data)
+}
+
+// CompressionStatsOf returns the sizes of the values encoded so far by a
+// Codec created with [CompressedCodec]. It returns false if the Codec wasn't
+// created with it.
+func CompressionStatsOf(c Codec) (CompressionStats, bool) {
+	cc, ok := c.(*compressedCodec)
+	if !ok {
+		return CompressionStats{}, false
+	}
+	return cc.stats(), true
+}
+
+// stats returns the sizes of the values encoded so far.
+func (c *compressedCodec) stats() CompressionStats {
+	return CompressionStats{
+		Values:       c.values.Load(),
+		Compressed:   c.compressed.Load(),
+		EncodedBytes: c.encodedBytes.Load(),
+		StoredBytes:  c.storedBytes.Load(),
+	}
+}
+
+func (c *compressedCodec) compress(data []byte) ([]byte, bool, error) {
+	if len(data) >= c.threshold && len(data) <= c.maxSize {
+		compressed, err := c.compressor.Compress(data)
+		if err != nil {
+			return nil, false, fmt.Errorf("compress: %w", err)
+		}
+		header := binary.AppendUvarint([]byte{compressionTag, c.compressor.ID()},
+			uint64(len(data)))
+		if len(header)+len(compressed) < len(data) {
+			return append(header, compressed...), true, nil
+		}
+	}
+	if isTagged(data) {
+		return tagValue(rawCompressorID, data), false, nil
+	}
+	return data, false, nil
+}
+
+func (c *compressedCodec) decompress(data []byte) ([]byte, error) {
+	if !isTagged(data) {
+		return data, nil
+	}
+	id := data[1]
+	if id == rawCompressorID {
+		return data[2:], nil
+	}
+	compressor, ok := c.compressors[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownCompressor, id)
+	}
+	size, n := binary.Uvarint(data[2:])
+	if n <= 0 {
+		return nil, errors.New("decompress: invalid size")
+	}
+	if size > uint64(c.maxSize) {
+		return nil, fmt.Errorf("decompress: size of %d bytes exceeds the limit of %d",
+			size, c.maxSize)
+	}
+	data, err := compressor.Decompress(data[2+n:], int(size))
+	if err != nil {
+		return nil, fmt.Errorf("decompress: %w", err)
+	}
+	if len(data) != int(size) {
+		return nil, fmt.Errorf("decompress: got %d bytes, want %d", len(data), size)
+	}
+	return data, nil
+}
+
+// isTagged reports whether the data starts with the header of the values
+// tagged by compressedCodec.
+func isTagged(data []byte) bool {
+	return len(data) >= 2 && data[0] == compressionTag && data[1] <= maxCompressorID
+}
+
+func tagValue(id byte, data []byte) []byte {
+	tagged := make([]byte, 0, 2+len(data))
+	tagged = append(tagged, compressionTag, id)
+	return append(tagged, data...)
+}
+
+// Flate Compressor
+
+type flateCompressor struct {
+	level   int
+	writers sync.Pool
+}
+
+func (c *flateCompressor) ID() byte { return flateCompressorID }
+
+func (c *flateCompressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, ok := c.writers.Get().(*flate.Writer)
+	if ok {
+		w.Reset(&buf)
+	} else {
+		var err error
+		if w, err = flate.NewWriter(&buf, c.level); err != nil {
+			return nil, err
+		}
+	}
+	defer c.writers.Put(w)
+
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c *flateCompressor) Decompress(data []byte, size int) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(data))
+	defer r.Close()
+	return readLimited(r, size)
+}
+
+// Gzip Compressor
+
+type gzipCompressor struct {
+	level   int
+	writers sync.Pool
+}
+
+func (c *gzipCompressor) ID() byte { return gzipCompressorID }
+
+func (c *gzipCompressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, ok := c.writers.Get().(*gzip.Writer)
+	if ok {
+		w.Reset(&buf)
+	} else {
+		var err error
+		if w, err = gzip.NewWriterLevel(&buf, c.level); err != nil {
+			return nil, err
+		}
+	}
+	defer c.writers.Put(w)
+
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c *gzipCompressor) Decompress(data []byte, size int) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return readLimited(r, size)
+}
+
+// readLimited reads the data decompressed by r, returning an error if it is
+// larger than size.
+func readLimited(r io.Reader, size int) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, int64(size)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > size {
+		return nil, fmt.Errorf("data larger than %d bytes", size)
+	}
+	return data, nil
+}
@@ -0,0 +1,287 @@
+package shelve
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func newTestCompressedCodec(t *testing.T, opts ...CompressOption) *compressedCodec {
+	t.Helper()
+	codec, err := CompressedCodec(JSONCodec(), opts...)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	return codec.(*compressedCodec)
+}
+
+func TestCompressedCodec_Encode(t *testing.T) {
+	codec := newTestCompressedCodec(t, WithCompressThreshold(0))
+
+	// Run the tests in the suite
+	EncodeTest(t, codec)
+
+	t.Run("Encode Error", func(t *testing.T) {
+		_, err := codec.Encode(func() {})
+		if err == nil {
+			t.Errorf("Expected an error, but got nil")
+		}
+	})
+
+	t.Run("Compress Error", func(t *testing.T) {
+		var compressor MockCompressor
+		compressor.CompressFunc = func([]byte) ([]byte, error) { return nil, TestError }
+		codec := newTestCompressedCodec(t, WithCompressor(&compressor), WithCompressThreshold(0))
+
+		_, err := codec.Encode("value")
+		if !errors.Is(err, TestError) {
+			t.Errorf("Expected TestError, but got %v", err)
+		}
+	})
+}
+
+func TestCompressedCodec_Decode(t *testing.T) {
+	// Run the tests in the suite
+	DecodeTest(t, newTestCompressedCodec(t, WithCompressThreshold(0)))
+	DecodeTest(t, newTestCompressedCodec(t,
+		WithCompressor(GzipCompressor(gzip.BestSpeed)), WithCompressThreshold(0)))
+
+	t.Run("Legacy values", func(t *testing.T) {
+		codec := newTestCompressedCodec(t)
+		for _, data := range [][]byte{[]byte(`"value"`), {}, {0xff}, {0xff, 0x80, 0x01}} {
+			got, err := codec.decompress(data)
+			if err != nil {
+				t.Errorf("Expected no error, but got %v", err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("Expected %q, but got %q", data, got)
+			}
+		}
+	})
+
+	t.Run("Other compressor", func(t *testing.T) {
+		gz := newTestCompressedCodec(t, WithCompressor(GzipCompressor(gzip.BestSpeed)))
+		data, _ := gz.Encode(strings.Repeat("value", 200))
+
+		var s string
+		err := newTestCompressedCodec(t).Decode(data, &s)
+		if err != nil {
+			t.Errorf("Expected no error, but got %v", err)
+		}
+		if s != strings.Repeat("value", 200) {
+			t.Errorf("Expected the value to be decoded, but got %q", s)
+		}
+	})
+
+	t.Run("Unknown compressor", func(t *testing.T) {
+		var s string
+		err := newTestCompressedCodec(t).Decode([]byte{0xff, 0x10, 0x00}, &s)
+		if !errors.Is(err, ErrUnknownCompressor) {
+			t.Errorf("Expected ErrUnknownCompressor, but got %v", err)
+		}
+	})
+
+	t.Run("Size limit", func(t *testing.T) {
+		// Arrange
+		long := strings.Repeat("value", 1000)
+		data, _ := newTestCompressedCodec(t).Encode(long)
+		codec := newTestCompressedCodec(t, WithMaxDecompressedSize(1000))
+
+		// Act
+		var s string
+		err := codec.Decode(data, &s)
+
+		// Assert
+		if err == nil || !strings.Contains(err.Error(), "exceeds the limit") {
+			t.Errorf("Expected a size error, but got %v", err)
+		}
+	})
+
+	t.Run("Larger than its size", func(t *testing.T) {
+		// Arrange
+		codec := newTestCompressedCodec(t)
+		data, _ := codec.Encode(strings.Repeat("value", 1000))
+		data[2]-- // The size, as an uvarint, becomes 5001 bytes
+
+		// Act
+		var s string
+		err := codec.Decode(data, &s)
+
+		// Assert
+		if err == nil {
+			t.Errorf("Expected an error, but got nil")
+		}
+	})
+
+	t.Run("Size mismatch", func(t *testing.T) {
+		var compressor MockCompressor
+		compressor.DecompressFunc = func([]byte, int) ([]byte, error) { return nil, nil }
+		codec := newTestCompressedCodec(t, WithCompressor(&compressor), WithCompressThreshold(0))
+
+		var s string
+		err := codec.Decode([]byte{0xff, 16, 0x07}, &s)
+		if err == nil || !strings.Contains(err.Error(), "want 7") {
+			t.Errorf("Expected a size error, but got %v", err)
+		}
+	})
+
+	t.Run("Corrupted", func(t *testing.T) {
+		var s string
+		for _, id := range []byte{flateCompressorID, gzipCompressorID} {
+			err := newTestCompressedCodec(t).Decode([]byte{0xff, id, 0x07}, &s)
+			if err == nil {
+				t.Errorf("%d: Expected an error, but got nil", id)
+			}
+		}
+	})
+}
+
+func TestCompressedCodec_Threshold(t *testing.T) {
+	long := strings.Repeat("value", 200)
+
+	tests := []struct {
+		name       string
+		value      any
+		compressed bool
+	}{
+		{
+			name:       "Below threshold",
+			value:      "value",
+			compressed: false,
+		},
+		{
+			name:       "Above threshold",
+			value:      long,
+			compressed: true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			codec := newTestCompressedCodec(t)
+
+			data, err := codec.Encode(tc.value)
+			if err != nil {
+				t.Fatalf("Expected no error, but got %v", err)
+			}
+			if got := isTagged(data); got != tc.compressed {
+				t.Errorf("Expected compressed %v, but got %v", tc.compressed, got)
+			}
+		})
+	}
+
+	t.Run("Above size limit", func(t *testing.T) {
+		codec := newTestCompressedCodec(t, WithMaxDecompressedSize(100))
+
+		data, _ := codec.Encode(long)
+		if isTagged(data) {
+			t.Errorf("Expected the value to be stored as is")
+		}
+	})
+
+	t.Run("No gain", func(t *testing.T) {
+		var compressor MockCompressor
+		compressor.CompressFunc = func(data []byte) ([]byte, error) { return data, nil }
+		codec := newTestCompressedCodec(t, WithCompressor(&compressor), WithCompressThreshold(0))
+
+		data, _ := codec.Encode("value")
+		if string(data) != `"value"` {
+			t.Errorf("Expected the value to be stored as is, but got %q", data)
+		}
+	})
+
+	t.Run("Escapes tagged values", func(t *testing.T) {
+		codec, _ := CompressedCodec(TextCodec(), WithCompressThreshold(100))
+
+		data, _ := codec.Encode("\xff\x01")
+		if !bytes.Equal(data, []byte{0xff, 0x00, 0xff, 0x01}) {
+			t.Errorf("Expected the value to be escaped, but got %x", data)
+		}
+		var s string
+		if err := codec.Decode(data, &s); err != nil || s != "\xff\x01" {
+			t.Errorf("Expected the value to be decoded, but got %q, %v", s, err)
+		}
+	})
+}
+
+func TestCompressedCodec_Options(t *testing.T) {
+	t.Run("Invalid compressor ID", func(t *testing.T) {
+		for _, id := range []byte{0, 0x80} {
+			var compressor MockCompressor
+			compressor.IDFunc = func() byte { return id }
+
+			codec, err := CompressedCodec(JSONCodec(), WithCompressor(&compressor))
+			if err == nil || codec != nil {
+				t.Errorf("%d: Expected an error, but got %v", id, err)
+			}
+		}
+	})
+
+	t.Run("Invalid level", func(t *testing.T) {
+		for _, c := range []Compressor{FlateCompressor(100), GzipCompressor(100)} {
+			codec := newTestCompressedCodec(t, WithCompressor(c), WithCompressThreshold(-1))
+
+			_, err := codec.Encode("value")
+			if err == nil {
+				t.Errorf("%d: Expected an error, but got nil", c.ID())
+			}
+		}
+	})
+}
+
+func TestCompressedCodec_Stats(t *testing.T) {
+	// Arrange
+	codec := newTestCompressedCodec(t, WithCompressor(FlateCompressor(flate.BestCompression)))
+	if r := codec.stats().Ratio(); r != 1 {
+		t.Errorf("Expected ratio 1, but got %v", r)
+	}
+
+	// Act
+	_, _ = codec.Encode("value")
+	_, _ = codec.Encode(strings.Repeat("value", 1000))
+
+	// Assert
+	stats, ok := CompressionStatsOf(codec)
+	if !ok {
+		t.Fatalf("Expected the stats of a compressed codec")
+	}
+	if stats.Values != 2 || stats.Compressed != 1 {
+		t.Errorf("Expected 2 values and 1 compressed, but got %+v", stats)
+	}
+	if stats.EncodedBytes != 7+5002 {
+		t.Errorf("Expected 5009 encoded bytes, but got %d", stats.EncodedBytes)
+	}
+	if r := stats.Ratio(); r < 10 {
+		t.Errorf("Expected a ratio of at least 10, but got %v", r)
+	}
+}
+
+func TestCompressionStatsOf(t *testing.T) {
+	_, ok := CompressionStatsOf(JSONCodec())
+	if ok {
+		t.Errorf("Expected no stats for a codec without compression")
+	}
+}
+
+func TestShelf_CompressedCodec(t *testing.T) {
+	// Arrange
+	seed := map[string]string{
+		"a": "value",
+		"b": strings.Repeat("value", 1000),
+	}
+	codec := newTestCompressedCodec(t)
+	shelf := OpenTestShelfWith[string, string](t, WithCodec(codec))
+	defer shelf.Close()
+
+	// Act
+	for k, v := range seed {
+		if err := shelf.Put(k, v); err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+	}
+
+	// Assert
+	checkShelf(t, shelf, seed)
+}
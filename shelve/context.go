@@ -0,0 +1,345 @@
+package shelve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// The methods in this file work like their counterparts without the Context
+// suffix, but take a context.Context. The context is checked before each
+// read or write done in the database and, in the iterations, before each item
+// read from the database, so a long iteration stops with the context error
+// once the context is done. If the database implements [ContextDB], the
+// context is also passed to it, and it can be checked while the database
+// waits for a lock or seeks over records.
+
+// errLen is returned by Shelf.LenContext when the database can't count its
+// items.
+var errLen = errors.New("count items")
+
+// LenContext works like [Shelf.Len], but returns the context error if ctx is
+// done. Unlike Len, it reports the error, together with a count of -1, if the
+// items can't be counted.
+func (s *Shelf[K, V]) LenContext(ctx context.Context) (int64, error) {
+	return s.len(ctx)
+}
+
+// HasContext works like [Shelf.Has], but returns the context error if ctx is
+// done.
+func (s *Shelf[K, V]) HasContext(ctx context.Context, key K) (bool, error) {
+	return s.has(ctx, key)
+}
+
+// GetContext works like [Shelf.Get], but returns the context error if ctx is
+// done.
+func (s *Shelf[K, V]) GetContext(ctx context.Context, key K) (value V, ok bool, err error) {
+	return s.get(ctx, key)
+}
+
+// PutContext works like [Shelf.Put], but returns the context error, without
+// writing the value, if ctx is done.
+func (s *Shelf[K, V]) PutContext(ctx context.Context, key K, value V) error {
+	return s.put(ctx, key, value)
+}
+
+// PutWithTTLContext works like [Shelf.PutWithTTL], but returns the context
+// error, without writing the value, if ctx is done.
+func (s *Shelf[K, V]) PutWithTTLContext(ctx context.Context, key K, value V, ttl time.Duration) error {
+	return s.putWithTTL(ctx, key, value, ttl)
+}
+
+// DeleteContext works like [Shelf.Delete], but returns the context error,
+// without removing the key, if ctx is done.
+func (s *Shelf[K, V]) DeleteContext(ctx context.Context, key K) error {
+	return s.delete(ctx, key)
+}
+
+// LenPrefixContext works like [Shelf.LenPrefix], but stops counting with the
+// context error once ctx is done.
+func (s *Shelf[K, V]) LenPrefixContext(ctx context.Context, prefix K) (int64, error) {
+	return s.lenPrefix(ctx, prefix)
+}
+
+// DeletePrefixContext works like [Shelf.DeletePrefix], but stops with the
+// context error once ctx is done. Like with an error of the database, the
+// items removed before that are not restored.
+func (s *Shelf[K, V]) DeletePrefixContext(ctx context.Context, prefix K) (int64, error) {
+	return s.deletePrefix(ctx, prefix)
+}
+
+// TransactContext works like [Shelf.Transact], but the context is checked
+// before each attempt to run the transaction and before its commit, and it is
+// passed to the reads of the transaction. If ctx is done, the transaction is
+// discarded and the context error is returned.
+func (s *Shelf[K, V]) TransactContext(ctx context.Context, fn func(tx *Tx[K, V]) error) error {
+	return s.transact(ctx, fn)
+}
+
+// UpdateContext works like [Shelf.Update], but the transaction is run with
+// the context, like in [Shelf.TransactContext].
+func (s *Shelf[K, V]) UpdateContext(
+	ctx context.Context,
+	key K,
+	fn func(old V, exists bool) (V, error),
+) (V, error) {
+	return s.update(ctx, key, fn)
+}
+
+// CompareAndSwapContext works like [Shelf.CompareAndSwap], but the
+// transaction is run with the context, like in [Shelf.TransactContext].
+func (s *Shelf[K, V]) CompareAndSwapContext(ctx context.Context, key K, old, new V) (bool, error) {
+	return s.compareAndSwap(ctx, key, old, new)
+}
+
+// PutIfAbsentContext works like [Shelf.PutIfAbsent], but the transaction is
+// run with the context, like in [Shelf.TransactContext].
+func (s *Shelf[K, V]) PutIfAbsentContext(ctx context.Context, key K, value V) (bool, error) {
+	return s.putIfAbsent(ctx, key, value)
+}
+
+// GetAndDeleteContext works like [Shelf.GetAndDelete], but the transaction is
+// run with the context, like in [Shelf.TransactContext].
+func (s *Shelf[K, V]) GetAndDeleteContext(ctx context.Context, key K) (value V, ok bool, err error) {
+	return s.getAndDelete(ctx, key)
+}
+
+// WriteContext works like [Batch.Write], but returns the context error,
+// without writing the batch, if ctx is done. If the database doesn't
+// implement [BatchDB], the mutations are applied one at a time and the
+// context is checked before each one.
+func (b *Batch[K, V]) WriteContext(ctx context.Context) error {
+	return b.apply(ctx)
+}
+
+// ItemsContext works like [Shelf.Items], but stops the iteration with the
+// context error once ctx is done.
+func (s *Shelf[K, V]) ItemsContext(ctx context.Context, start *K, n, step int, fn Yield[K, V]) error {
+	return s.ItemsRangeContext(ctx, start, nil, n, step, fn)
+}
+
+// ItemsRangeContext works like [Shelf.ItemsRange], but stops the iteration
+// with the context error once ctx is done.
+func (s *Shelf[K, V]) ItemsRangeContext(
+	ctx context.Context,
+	start, end *K,
+	n, step int,
+	fn Yield[K, V],
+) error {
+	return s.iterate(ctx, start, end, n, step, s.decodeItems(fn))
+}
+
+// ItemsPrefixContext works like [Shelf.ItemsPrefix], but stops the iteration
+// with the context error once ctx is done.
+func (s *Shelf[K, V]) ItemsPrefixContext(ctx context.Context, prefix K, n, step int, fn Yield[K, V]) error {
+	return s.iteratePrefix(ctx, prefix, n, step, s.decodeItems(fn))
+}
+
+// KeysContext works like [Shelf.Keys], but stops the iteration with the
+// context error once ctx is done.
+func (s *Shelf[K, V]) KeysContext(ctx context.Context, start *K, n, step int, fn Yield[K, V]) error {
+	return s.iterate(ctx, start, nil, n, step, s.decodeKeys(fn))
+}
+
+// KeysRangeContext works like [Shelf.KeysRange], but stops the iteration
+// with the context error once ctx is done.
+func (s *Shelf[K, V]) KeysRangeContext(
+	ctx context.Context,
+	start, end *K,
+	n, step int,
+	fn Yield[K, V],
+) error {
+	return s.iterate(ctx, start, end, n, step, s.decodeKeys(fn))
+}
+
+// KeysPrefixContext works like [Shelf.KeysPrefix], but stops the iteration
+// with the context error once ctx is done.
+func (s *Shelf[K, V]) KeysPrefixContext(ctx context.Context, prefix K, n, step int, fn Yield[K, V]) error {
+	return s.iteratePrefix(ctx, prefix, n, step, s.decodeKeys(fn))
+}
+
+// ValuesContext works like [Shelf.Values], but stops the iteration with the
+// context error once ctx is done.
+func (s *Shelf[K, V]) ValuesContext(ctx context.Context, start *K, n, step int, fn Yield[K, V]) error {
+	return s.iterate(ctx, start, nil, n, step, s.decodeValues(fn))
+}
+
+// ValuesRangeContext works like [Shelf.ValuesRange], but stops the iteration
+// with the context error once ctx is done.
+func (s *Shelf[K, V]) ValuesRangeContext(
+	ctx context.Context,
+	start, end *K,
+	n, step int,
+	fn Yield[K, V],
+) error {
+	return s.iterate(ctx, start, end, n, step, s.decodeValues(fn))
+}
+
+// ValuesPrefixContext works like [Shelf.ValuesPrefix], but stops the
+// iteration with the context error once ctx is done.
+func (s *Shelf[K, V]) ValuesPrefixContext(ctx context.Context, prefix K, n, step int, fn Yield[K, V]) error {
+	return s.iteratePrefix(ctx, prefix, n, step, s.decodeValues(fn))
+}
+
+// ItemsTupleRangeContext works like [Shelf.ItemsTupleRange], but stops the
+// iteration with the context error once ctx is done.
+func (s *Shelf[K, V]) ItemsTupleRangeContext(
+	ctx context.Context,
+	start, end Tuple,
+	n, step int,
+	fn Yield[K, V],
+) error {
+	return s.iterateTuple(ctx, start, end, n, step, s.decodeItems(fn))
+}
+
+// ItemsTuplePrefixContext works like [Shelf.ItemsTuplePrefix], but stops the
+// iteration with the context error once ctx is done.
+func (s *Shelf[K, V]) ItemsTuplePrefixContext(
+	ctx context.Context,
+	prefix Tuple,
+	n, step int,
+	fn Yield[K, V],
+) error {
+	return s.iterateTuplePrefix(ctx, prefix, n, step, s.decodeItems(fn))
+}
+
+// ItemsByIndexContext works like [Shelf.ItemsByIndex], but stops with the
+// context error once ctx is done, while the index entries are collected or
+// before each item is read.
+func (s *Shelf[K, V]) ItemsByIndexContext(
+	ctx context.Context,
+	name string,
+	key IndexKey,
+	n, step int,
+	fn Yield[K, V],
+) error {
+	return s.itemsByIndex(ctx, name, key, n, step, fn)
+}
+
+// ItemsIndexRangeContext works like [Shelf.ItemsIndexRange], but stops with
+// the context error once ctx is done, like [Shelf.ItemsByIndexContext].
+func (s *Shelf[K, V]) ItemsIndexRangeContext(
+	ctx context.Context,
+	name string,
+	start, end IndexKey,
+	n, step int,
+	fn Yield[K, V],
+) error {
+	return s.itemsIndexRange(ctx, name, start, end, n, step, fn)
+}
+
+// GetByIndexContext works like [Shelf.GetByIndex], but returns the context
+// error if ctx is done.
+func (s *Shelf[K, V]) GetByIndexContext(
+	ctx context.Context,
+	name string,
+	key IndexKey,
+) (k K, v V, ok bool, err error) {
+	return s.getByIndex(ctx, name, key)
+}
+
+// RebuildIndexesContext works like [Shelf.RebuildIndexes], but stops with the
+// context error once ctx is done. The indexes are only changed if the rebuild
+// completes, unless the database doesn't implement [BatchDB] and ctx is done
+// while the new entries are written.
+func (s *Shelf[K, V]) RebuildIndexesContext(ctx context.Context) error {
+	return s.rebuildIndexes(ctx)
+}
+
+// MigrateContext works like [Shelf.Migrate], but stops with the context
+// error once ctx is done. The context is checked before each transaction, so
+// the values rewritten until then are kept, and their number is returned
+// with the error. Like after any error, Migrate can be called again to
+// resume.
+func (s *Shelf[K, V]) MigrateContext(ctx context.Context) (int64, error) {
+	return s.migrate(ctx)
+}
+
+// ReencodeContext works like [Shelf.Reencode], but stops with the context
+// error once ctx is done, like [Shelf.MigrateContext].
+func (s *Shelf[K, V]) ReencodeContext(ctx context.Context) (int64, error) {
+	return s.reencode(ctx)
+}
+
+// ReencryptContext works like [Shelf.Reencrypt], but stops with the context
+// error once ctx is done, like [Shelf.MigrateContext].
+func (s *Shelf[K, V]) ReencryptContext(ctx context.Context) (int64, error) {
+	return s.reencrypt(ctx)
+}
+
+// contextDB returns the database as a ContextDB, if it implements it and ctx
+// can be cancelled. Otherwise, the context-free methods of the database are
+// used, which avoids the cost of checking a context that is never done.
+func (s *Shelf[K, V]) contextDB(ctx context.Context) (ContextDB, bool) {
+	if ctx.Done() == nil {
+		return nil, false
+	}
+	db, ok := s.db.(ContextDB)
+	return db, ok
+}
+
+// dbHas checks ctx and reports whether the encoded key exists in the
+// database, passing ctx to it if it implements ContextDB.
+func (s *Shelf[K, V]) dbHas(ctx context.Context, key []byte) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	if db, ok := s.contextDB(ctx); ok {
+		return db.HasContext(ctx, key)
+	}
+	return s.db.Has(key)
+}
+
+// dbGet checks ctx and reads the encoded key from the database, passing ctx
+// to it if it implements ContextDB.
+func (s *Shelf[K, V]) dbGet(ctx context.Context, key []byte) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if db, ok := s.contextDB(ctx); ok {
+		return db.GetContext(ctx, key)
+	}
+	return s.db.Get(key)
+}
+
+// dbPut checks ctx and writes the encoded key-value pair to the database,
+// passing ctx to it if it implements ContextDB.
+func (s *Shelf[K, V]) dbPut(ctx context.Context, key, value []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if db, ok := s.contextDB(ctx); ok {
+		return db.PutContext(ctx, key, value)
+	}
+	return s.db.Put(key, value)
+}
+
+// dbDelete checks ctx and removes the encoded key from the database, passing
+// ctx to it if it implements ContextDB.
+func (s *Shelf[K, V]) dbDelete(ctx context.Context, key []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if db, ok := s.contextDB(ctx); ok {
+		return db.DeleteContext(ctx, key)
+	}
+	return s.db.Delete(key)
+}
+
+// checkContext wraps fn so that the iteration stops with the context error
+// once ctx is done. It returns fn unchanged if ctx can't be cancelled.
+func checkContext(
+	ctx context.Context,
+	fn func(k, v []byte) (bool, error),
+) func(k, v []byte) (bool, error) {
+	if ctx.Done() == nil {
+		return fn
+	}
+	return func(k, v []byte) (bool, error) {
+		if err := ctx.Err(); err != nil {
+			return false, fmt.Errorf("iterate: %w", err)
+		}
+		return fn(k, v)
+	}
+}
@@ -0,0 +1,389 @@
+package shelve
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestShelf_Context_Done(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var db MockDB
+	db.HasFunc = func([]byte) (bool, error) { t.Errorf("Unexpected call"); return false, nil }
+	db.GetFunc = func([]byte) ([]byte, error) { t.Errorf("Unexpected call"); return nil, nil }
+	db.PutFunc = func(_, _ []byte) error { t.Errorf("Unexpected call"); return nil }
+	db.DeleteFunc = func([]byte) error { t.Errorf("Unexpected call"); return nil }
+	db.LenFunc = func() int64 { t.Errorf("Unexpected call"); return 0 }
+	db.ItemsFunc = NewMockItemsFunc(MakeItems(map[string]string{"key": "value"}))
+	shelf := NewTestShelf(t, WithDatabase(&db))
+	ttlShelf := NewTestShelf(t, WithDatabase(&db), WithExpiryHeader())
+	batch := shelf.NewBatch()
+	_ = batch.Put("key", "value")
+	unexpected := func(string, string) (bool, error) {
+		t.Errorf("Unexpected call")
+		return true, nil
+	}
+
+	_, err1 := shelf.HasContext(ctx, "key")
+	_, _, err2 := shelf.GetContext(ctx, "key")
+	err3 := shelf.PutContext(ctx, "key", "value")
+	err4 := shelf.DeleteContext(ctx, "key")
+	err5 := shelf.TransactContext(ctx, func(tx *Tx[string, string]) error {
+		t.Errorf("Unexpected call")
+		return nil
+	})
+	err6 := shelf.ItemsContext(ctx, nil, All, Asc, unexpected)
+	_, err7 := shelf.LenContext(ctx)
+	err8 := ttlShelf.PutWithTTLContext(ctx, "key", "value", time.Hour)
+	_, err9 := shelf.LenPrefixContext(ctx, "k")
+	_, err10 := shelf.DeletePrefixContext(ctx, "k")
+	_, err11 := shelf.UpdateContext(ctx, "key", func(string, bool) (string, error) {
+		t.Errorf("Unexpected call")
+		return "", nil
+	})
+	_, err12 := shelf.CompareAndSwapContext(ctx, "key", "value", "new")
+	_, err13 := shelf.PutIfAbsentContext(ctx, "key", "value")
+	_, _, err14 := shelf.GetAndDeleteContext(ctx, "key")
+	err15 := batch.WriteContext(ctx)
+	err16 := shelf.KeysRangeContext(ctx, nil, nil, All, Asc, unexpected)
+	err17 := shelf.KeysPrefixContext(ctx, "k", All, Asc, unexpected)
+	err18 := shelf.ValuesRangeContext(ctx, nil, nil, All, Asc, unexpected)
+	err19 := shelf.ValuesPrefixContext(ctx, "k", All, Asc, unexpected)
+
+	for i, err := range []error{
+		err1, err2, err3, err4, err5, err6, err7, err8, err9, err10,
+		err11, err12, err13, err14, err15, err16, err17, err18, err19,
+	} {
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("%d: Expected context.Canceled, but got %v", i+1, err)
+		}
+	}
+}
+
+func TestShelf_Context_Done_Extensions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	byValue := func(v string) []IndexKey { return []IndexKey{{v}} }
+	unexpected := func(string, string) (bool, error) {
+		t.Errorf("Unexpected call")
+		return true, nil
+	}
+	tests := []struct {
+		name string
+		opts []Option
+		call func(shelf *Shelf[string, string]) error
+	}{
+		{"ItemsTupleRange", []Option{WithKeyCodec(TupleCodec())}, func(s *Shelf[string, string]) error {
+			return s.ItemsTupleRangeContext(ctx, nil, nil, All, Asc, unexpected)
+		}},
+		{"ItemsTuplePrefix", []Option{WithKeyCodec(TupleCodec())}, func(s *Shelf[string, string]) error {
+			return s.ItemsTuplePrefixContext(ctx, Tuple{"key"}, All, Asc, unexpected)
+		}},
+		{"ItemsByIndex", []Option{WithIndex("value", byValue)}, func(s *Shelf[string, string]) error {
+			return s.ItemsByIndexContext(ctx, "value", IndexKey{"value"}, All, Asc, unexpected)
+		}},
+		{"ItemsIndexRange", []Option{WithIndex("value", byValue)}, func(s *Shelf[string, string]) error {
+			return s.ItemsIndexRangeContext(ctx, "value", nil, nil, All, Asc, unexpected)
+		}},
+		{"GetByIndex", []Option{WithIndex("value", byValue)}, func(s *Shelf[string, string]) error {
+			_, _, _, err := s.GetByIndexContext(ctx, "value", IndexKey{"value"})
+			return err
+		}},
+		{"RebuildIndexes", []Option{WithIndex("value", byValue)}, func(s *Shelf[string, string]) error {
+			return s.RebuildIndexesContext(ctx)
+		}},
+		{"Migrate", []Option{WithSchema(1, map[uint32]Migration[string]{})}, func(s *Shelf[string, string]) error {
+			_, err := s.MigrateContext(ctx)
+			return err
+		}},
+		{"Reencode", []Option{WithCodec(newTestEnvelopeCodec(t, JSONCodecID))}, func(s *Shelf[string, string]) error {
+			_, err := s.ReencodeContext(ctx)
+			return err
+		}},
+		{"Reencrypt", []Option{WithCodec(newTestEncryptedCodec(t, testEncryptionKey(1)))}, func(s *Shelf[string, string]) error {
+			_, err := s.ReencryptContext(ctx)
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			shelf := OpenTestShelfWith[string, string](t, tt.opts...)
+			defer shelf.Close()
+			if err := shelf.Put("key", "value"); err != nil {
+				t.Fatalf("Expected no error, but got %v", err)
+			}
+
+			// Act
+			err := tt.call(shelf)
+
+			// Assert
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("Expected context.Canceled, but got %v", err)
+			}
+		})
+	}
+}
+
+func TestShelf_Context_Rewrite(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	v0 := OpenTestShelf[string, string](t)
+	for i := 0; i < rewriteBatchSize+10; i++ {
+		_ = v0.Put(fmt.Sprintf("key-%03d", i), "value")
+	}
+
+	var calls int
+	shelf, err := Open[string, string](TestDirectory,
+		WithDatabase(v0.db), WithCodec(JSONCodec()),
+		WithSchema(1, map[uint32]Migration[string]{
+			0: func(data []byte) (string, error) {
+				// Cancel in the second chunk of the rewrite.
+				if calls++; calls == rewriteBatchSize+1 {
+					cancel()
+				}
+				var v string
+				err := json.Unmarshal(data, &v)
+				return v + "-v1", err
+			},
+		}))
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	defer shelf.Close()
+
+	// Act
+	n, err := shelf.MigrateContext(ctx)
+
+	// Assert
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, but got %v", err)
+	}
+	if n != rewriteBatchSize {
+		t.Errorf("Expected %d rewritten values, but got %d", rewriteBatchSize, n)
+	}
+	n, err = shelf.Migrate()
+	if err != nil || n != 10 {
+		t.Errorf("Expected 10 rewritten values, but got %d, %v", n, err)
+	}
+}
+
+func TestShelf_Context(t *testing.T) {
+	seed := map[string]string{"a-1": "1", "a-2": "2", "b-1": "3", "b-2": "4"}
+
+	t.Run("Succeeds", func(t *testing.T) {
+		// Arrange
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		shelf := OpenTestShelf[string, string](t)
+		defer shelf.Close()
+		for k, v := range seed {
+			if err := shelf.PutContext(ctx, k, v); err != nil {
+				t.Fatalf("Expected no error, but got %v", err)
+			}
+		}
+
+		// Act & Assert
+		var keys []string
+		collect := func(k, _ string) (bool, error) {
+			keys = append(keys, k)
+			return true, nil
+		}
+
+		_ = shelf.ItemsPrefixContext(ctx, "b-", All, Desc, collect)
+		checkKeys(t, []string{"b-2", "b-1"}, keys)
+
+		keys = nil
+		_ = shelf.ItemsPrefixContext(ctx, "a-", All, Asc, collect)
+		checkKeys(t, []string{"a-1", "a-2"}, keys)
+
+		keys = nil
+		start, end := "a-2", "b-2"
+		_ = shelf.ItemsRangeContext(ctx, &start, &end, All, Asc, collect)
+		checkKeys(t, []string{"a-2", "b-1"}, keys)
+
+		keys = nil
+		_ = shelf.KeysContext(ctx, &start, 2, Asc, collect)
+		checkKeys(t, []string{"a-2", "b-1"}, keys)
+
+		var values []string
+		_ = shelf.ValuesContext(ctx, nil, All, Desc, func(_, v string) (bool, error) {
+			values = append(values, v)
+			return true, nil
+		})
+		checkKeys(t, []string{"4", "3", "2", "1"}, values)
+
+		err := shelf.TransactContext(ctx, func(tx *Tx[string, string]) error {
+			return tx.Delete("a-1")
+		})
+		if err != nil {
+			t.Errorf("Expected no error, but got %v", err)
+		}
+		if ok, _ := shelf.HasContext(ctx, "a-1"); ok {
+			t.Errorf("Expected a-1 to be deleted")
+		}
+
+		keys = nil
+		_ = shelf.KeysRangeContext(ctx, &start, &end, All, Desc, collect)
+		checkKeys(t, nil, keys)
+
+		_ = shelf.KeysPrefixContext(ctx, "b-", All, Asc, collect)
+		checkKeys(t, []string{"b-1", "b-2"}, keys)
+
+		values = nil
+		_ = shelf.ValuesRangeContext(ctx, &start, &end, All, Asc, func(_, v string) (bool, error) {
+			values = append(values, v)
+			return true, nil
+		})
+		_ = shelf.ValuesPrefixContext(ctx, "b-", All, Desc, func(_, v string) (bool, error) {
+			values = append(values, v)
+			return true, nil
+		})
+		checkKeys(t, []string{"2", "3", "4", "3"}, values)
+
+		if n, err := shelf.LenContext(ctx); n != 3 || err != nil {
+			t.Errorf("Expected 3 items, but got %d, %v", n, err)
+		}
+		if n, err := shelf.LenPrefixContext(ctx, "b-"); n != 2 || err != nil {
+			t.Errorf("Expected 2 items, but got %d, %v", n, err)
+		}
+	})
+
+	t.Run("Atomic helpers", func(t *testing.T) {
+		// Arrange
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		shelf := OpenTestShelf[string, string](t)
+		defer shelf.Close()
+
+		// Act
+		stored, err1 := shelf.PutIfAbsentContext(ctx, "key", "1")
+		swapped, err2 := shelf.CompareAndSwapContext(ctx, "key", "1", "2")
+		updated, err3 := shelf.UpdateContext(ctx, "key", func(old string, _ bool) (string, error) {
+			return old + "3", nil
+		})
+		removed, ok, err4 := shelf.GetAndDeleteContext(ctx, "key")
+		err5 := shelf.PutWithTTLContext(ctx, "ttl", "value", time.Hour)
+		batch := shelf.NewBatch()
+		_ = batch.Put("batch", "value")
+		err6 := batch.WriteContext(ctx)
+		n, err7 := shelf.DeletePrefixContext(ctx, "ttl")
+
+		// Assert
+		for i, err := range []error{err1, err2, err3, err4, err5, err6, err7} {
+			if err != nil {
+				t.Errorf("%d: Expected no error, but got %v", i+1, err)
+			}
+		}
+		if !stored || !swapped || updated != "23" || !ok || removed != "23" {
+			t.Errorf("Expected true, true, 23, true, 23, but got %v, %v, %v, %v, %v",
+				stored, swapped, updated, ok, removed)
+		}
+		if n != 1 {
+			t.Errorf("Expected 1 removed item, but got %d", n)
+		}
+		checkShelf(t, shelf, map[string]string{"batch": "value"})
+	})
+
+	t.Run("Passed to the database", func(t *testing.T) {
+		// Arrange
+		var db MockContextDB
+		db.ItemsFunc = NewMockItemsFunc(MakeItems(seed))
+		shelf := NewTestShelf(t, WithDatabase(&db))
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// Act
+		_, _ = shelf.Has("a-1")
+		_ = shelf.Put("a-1", "1")
+		_, _ = shelf.HasContext(ctx, "a-1")
+		_, _, _ = shelf.GetContext(ctx, "a-1")
+		_ = shelf.PutContext(ctx, "a-1", "1")
+		_ = shelf.DeleteContext(ctx, "a-1")
+		_ = shelf.ItemsRangeContext(ctx, nil, nil, All, Asc, func(string, string) (bool, error) {
+			return false, nil
+		})
+		_ = shelf.ItemsPrefixContext(ctx, "a-", All, Asc, func(string, string) (bool, error) {
+			return false, nil
+		})
+		_ = shelf.TransactContext(ctx, func(tx *Tx[string, string]) error {
+			_, _, err := tx.Get("a-1")
+			if err != nil {
+				return err
+			}
+			return tx.Put("a-1", "1")
+		})
+
+		// Assert
+		// The transaction reads the key again at the commit, before writing.
+		if len(db.Contexts) != 9 {
+			t.Fatalf("Expected 9 calls with the context, but got %d", len(db.Contexts))
+		}
+		for i, c := range db.Contexts {
+			if c != ctx {
+				t.Errorf("%d: Expected the context of the Shelf method", i)
+			}
+		}
+	})
+
+	t.Run("Cancelled before the commit", func(t *testing.T) {
+		// Arrange
+		ctx, cancel := context.WithCancel(context.Background())
+		shelf := OpenTestShelf[string, string](t)
+		defer shelf.Close()
+
+		// Act
+		err := shelf.TransactContext(ctx, func(tx *Tx[string, string]) error {
+			cancel()
+			return tx.Put("key", "value")
+		})
+
+		// Assert
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, but got %v", err)
+		}
+		checkShelf(t, shelf, map[string]string{})
+	})
+
+	t.Run("Cancelled during the iteration", func(t *testing.T) {
+		// The sdb database implements ContextDB, while MockDB doesn't.
+		var db MockDB
+		db.ItemsFunc = NewMockItemsFunc(MakeItems(seed))
+		shelves := []*Shelf[string, string]{
+			OpenTestShelf[string, string](t),
+			NewTestShelf(t, WithDatabase(&db)),
+		}
+		for k, v := range seed {
+			_ = shelves[0].Put(k, v)
+		}
+
+		for _, shelf := range shelves {
+			// Arrange
+			ctx, cancel := context.WithCancel(context.Background())
+
+			// Act
+			var count int
+			err := shelf.ItemsContext(ctx, nil, All, Asc, func(string, string) (bool, error) {
+				count++
+				cancel()
+				return true, nil
+			})
+
+			// Assert
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("Expected context.Canceled, but got %v", err)
+			}
+			if count != 1 {
+				t.Errorf("Expected 1 item, but got %d", count)
+			}
+			_ = shelf.Close()
+		}
+	})
+}
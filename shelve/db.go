@@ -1,5 +1,10 @@
 package shelve
 
+import (
+	"context"
+	"time"
+)
+
 // DB is an interface that defines the methods for a database that can be used
 // with Shelf. It takes the encoded binary representation of keys and values.
 type DB interface {
@@ -48,3 +53,148 @@ type DB interface {
 		fn func(key, value []byte) (bool, error),
 	) error
 }
+
+// RangeDB is an optional interface that can be implemented by a DB to support
+// bounded iteration natively. When the underlying DB of a Shelf implements
+// it, range queries are pushed down to the database, which can then stop the
+// iteration as soon as the end bound is reached. Otherwise, the Shelf falls
+// back to [DB.Items] and compares the encoded keys with the end bound.
+type RangeDB interface {
+	DB
+
+	// ItemsRange works like [DB.Items], but the iteration stops before the
+	// first key that is beyond the end bound. The end parameter is
+	// exclusive: in ascending order, only keys k < end are yielded, and in
+	// descending order, only keys k > end are yielded. If end is nil or
+	// empty, the iteration is unbounded, as in [DB.Items].
+	ItemsRange(
+		start, end []byte,
+		order int,
+		fn func(key, value []byte) (bool, error),
+	) error
+}
+
+// PrefixDB is an optional interface that can be implemented by a DB to
+// support prefix scans natively. When the underlying DB of a Shelf implements
+// it, prefix queries are pushed down to the database. Otherwise, the Shelf
+// falls back to a bounded iteration over the range of keys with the prefix.
+type PrefixDB interface {
+	DB
+
+	// ItemsPrefix works like [DB.Items], but only yields the keys that start
+	// with the given prefix, in the order specified by the order parameter.
+	// If prefix is nil or empty, all keys are yielded.
+	ItemsPrefix(
+		prefix []byte,
+		order int,
+		fn func(key, value []byte) (bool, error),
+	) error
+}
+
+// BatchDB is an optional interface that can be implemented by a DB to support
+// atomic batches of mutations. When the underlying DB of a Shelf implements
+// it, a [Batch] is applied all-or-nothing. Otherwise, the Shelf falls back to
+// applying the mutations one at a time, with [DB.Put] and [DB.Delete].
+type BatchDB interface {
+	DB
+
+	// WriteBatch atomically writes the given key-value pairs to the
+	// database: either all of them are applied or none are. A nil value
+	// deletes the key, while an empty, non-nil value is stored as is. If a
+	// key appears more than once, the last value wins. The keys and values
+	// slices must have the same length.
+	WriteBatch(keys, values [][]byte) error
+}
+
+// TxDB is an optional interface that can be implemented by a DB to support
+// conditional atomic batches, which are used to commit the transactions
+// created with [Shelf.Transact]. When the underlying DB of a Shelf doesn't
+// implement it, the transactions are only isolated from the other
+// transactions and writes of the same Shelf.
+type TxDB interface {
+	BatchDB
+
+	// WriteBatchIf works like [BatchDB.WriteBatch], but the batch is only
+	// written if each of the checked keys holds the expected value, with a
+	// nil value meaning that the key must not exist. The check and the write
+	// are done atomically, and no other mutation of the database can happen
+	// between them. It reports whether the batch was written. The
+	// checkKeys and checkValues slices must have the same length.
+	WriteBatchIf(checkKeys, checkValues, keys, values [][]byte) (bool, error)
+}
+
+// TTLDB is an optional interface that can be implemented by a DB to support
+// keys that expire. When the underlying DB of a Shelf implements it,
+// [Shelf.PutWithTTL] delegates the expiration to the database. Otherwise,
+// the Shelf must be opened with [WithExpiryHeader] to support it.
+type TTLDB interface {
+	DB
+
+	// PutWithTTL works like [DB.Put], but the key expires after the given
+	// time-to-live. Once expired, the key must no longer be returned by
+	// [DB.Get], [DB.Has] and [DB.Items], nor counted by [DB.Len]. If ttl is
+	// zero or negative, the key doesn't expire.
+	PutWithTTL(key, value []byte, ttl time.Duration) error
+}
+
+// ExpiryDB is an optional interface that can be implemented by a database
+// that supports both expiring keys and conditional batches, to rewrite
+// values without changing their expiration. It is used by [Shelf.Migrate],
+// [Shelf.Reencode] and [Shelf.Reencrypt]. With the other databases
+// implementing [TTLDB], the keys stored with [Shelf.PutWithTTL] lose their
+// expiration when rewritten.
+type ExpiryDB interface {
+	TTLDB
+	TxDB
+
+	// ReplaceBatchIf works like [TxDB.WriteBatchIf], but the keys written
+	// keep their expiration, if they have one. The deleted keys lose it.
+	ReplaceBatchIf(checkKeys, checkValues, keys, values [][]byte) (bool, error)
+}
+
+// ContextDB is an optional interface that can be implemented by a DB to
+// support cancelling operations with a context. When the underlying DB of a
+// Shelf implements it, the context-aware methods of the Shelf, like
+// [Shelf.GetContext] and [Shelf.ItemsContext], pass the context to the
+// database. Otherwise, the Shelf only checks the context before each
+// operation and between the items yielded by the database.
+type ContextDB interface {
+	DB
+
+	// HasContext works like [DB.Has], but returns the context error if ctx
+	// is done before the key is looked up.
+	HasContext(ctx context.Context, key []byte) (bool, error)
+
+	// GetContext works like [DB.Get], but returns the context error if ctx
+	// is done before the value is read.
+	GetContext(ctx context.Context, key []byte) ([]byte, error)
+
+	// PutContext works like [DB.Put], but returns the context error, without
+	// writing the value, if ctx is done before the write starts, for
+	// instance while waiting for a lock.
+	PutContext(ctx context.Context, key, value []byte) error
+
+	// DeleteContext works like [DB.Delete], but returns the context error,
+	// without removing the key, if ctx is done before the removal starts.
+	DeleteContext(ctx context.Context, key []byte) error
+
+	// ItemsRangeContext works like [RangeDB.ItemsRange], with a nil or
+	// empty end making the iteration unbounded, but the iteration stops
+	// with an error wrapping the context error once ctx is done.
+	ItemsRangeContext(
+		ctx context.Context,
+		start, end []byte,
+		order int,
+		fn func(key, value []byte) (bool, error),
+	) error
+
+	// ItemsPrefixContext works like [PrefixDB.ItemsPrefix], but the
+	// iteration stops with an error wrapping the context error once ctx is
+	// done.
+	ItemsPrefixContext(
+		ctx context.Context,
+		prefix []byte,
+		order int,
+		fn func(key, value []byte) (bool, error),
+	) error
+}
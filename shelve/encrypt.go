@@ -0,0 +1,228 @@
+package shelve
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+const (
+	// encryptionVersion is the first byte of the values encrypted by
+	// EncryptedCodec, identifying the layout of the header.
+	encryptionVersion = 1
+
+	// encryptionHeaderSize is the size of the version and key ID, which are
+	// authenticated together with the ciphertext and the key of the record.
+	encryptionHeaderSize = 1 + 4
+)
+
+var (
+	// ErrUnknownKeyID is returned when decoding a value encrypted with a key
+	// that wasn't given to [EncryptedCodec].
+	ErrUnknownKeyID = errors.New("unknown encryption key ID")
+
+	// ErrNotEncrypted is returned by [Shelf.Reencrypt] when the value codec
+	// of the Shelf wasn't created with [EncryptedCodec].
+	ErrNotEncrypted = errors.New("value codec is not encrypted")
+
+	errInvalidCiphertext = errors.New("invalid ciphertext")
+)
+
+// EncryptionKey is a key used by [EncryptedCodec]. The ID is stored with
+// each encrypted value, to select the key that decrypts it, and the Key must
+// have 16, 24 or 32 bytes, to select AES-128, AES-192 or AES-256.
+type EncryptionKey struct {
+	ID  uint32
+	Key []byte
+}
+
+// EncryptedCodec returns a Codec that encrypts the data encoded by codec
+// with AES-GCM, which also authenticates it. The values are encrypted with
+// the current key, and decrypted with the current or the previous keys,
+// selected by the key ID stored in the header of each value.
+//
+// To rotate the keys, open the Shelf with a new current key and the old keys
+// as previous keys, and call [Shelf.Reencrypt] to rewrite the values with the
+// new key. The old keys can be dropped once that is done.
+//
+// Only the values are encrypted, so it is meant to be used with [WithCodec].
+// The keys of the Shelf are stored in plain form, unless [WithKeyEncryption]
+// is used. When used by a Shelf, each value is bound to the encoded key of
+// its record, so a value copied to another key fails to decrypt. Values
+// encoded by calling Encode directly are bound to no key.
+func EncryptedCodec(codec Codec, current EncryptionKey, previous ...EncryptionKey) (Codec, error) {
+	c := &encryptedCodec{
+		codec:   codec,
+		current: current.ID,
+		keys:    make(map[uint32]cipher.AEAD),
+	}
+	for _, k := range append([]EncryptionKey{current}, previous...) {
+		if _, ok := c.keys[k.ID]; ok {
+			return nil, fmt.Errorf("duplicate encryption key ID %d", k.ID)
+		}
+		block, err := aes.NewCipher(k.Key)
+		if err != nil {
+			return nil, fmt.Errorf("key %d: %w", k.ID, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("key %d: %w", k.ID, err)
+		}
+		c.keys[k.ID] = aead
+	}
+	return c, nil
+}
+
+// Encrypted Codec
+//
+// Each value has the following layout:
+//
+//	version (1 byte) | key ID (4 bytes) | nonce | ciphertext and tag
+//
+// The version and key ID are authenticated as additional data, followed by
+// the encoded key of the record, when used by a Shelf.
+
+type encryptedCodec struct {
+	codec   Codec
+	current uint32
+	keys    map[uint32]cipher.AEAD
+}
+
+func (c *encryptedCodec) Encode(value any) ([]byte, error) {
+	return c.encodeKeyed(nil, value)
+}
+
+func (c *encryptedCodec) Decode(data []byte, value any) error {
+	return c.decodeKeyed(nil, data, value)
+}
+
+func (c *encryptedCodec) encodeKeyed(key []byte, value any) ([]byte, error) {
+	data, err := encodeKeyed(c.codec, key, value)
+	if err != nil {
+		return nil, err
+	}
+	return c.encrypt(key, data)
+}
+
+func (c *encryptedCodec) decodeKeyed(key, data []byte, value any) error {
+	plaintext, err := c.decrypt(key, data)
+	if err != nil {
+		return err
+	}
+	return decodeKeyed(c.codec, key, plaintext, value)
+}
+
+func (c *encryptedCodec) unwrapKeyed(key, data []byte) ([]byte, error) {
+	plaintext, err := c.decrypt(key, data)
+	if err != nil {
+		return nil, err
+	}
+	return unwrapKeyed(c.codec, key, plaintext)
+}
+
+func (c *encryptedCodec) encrypt(key, plaintext []byte) ([]byte, error) {
+	aead := c.keys[c.current]
+	size := encryptionHeaderSize + aead.NonceSize()
+	data := make([]byte, size, size+len(plaintext)+aead.Overhead())
+	data[0] = encryptionVersion
+	binary.BigEndian.PutUint32(data[1:], c.current)
+
+	nonce := data[encryptionHeaderSize:]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("nonce: %w", err)
+	}
+	return aead.Seal(data, nonce, plaintext, additionalData(data, key)), nil
+}
+
+func (c *encryptedCodec) decrypt(key, data []byte) ([]byte, error) {
+	id, err := c.keyID(data)
+	if err != nil {
+		return nil, err
+	}
+	aead, ok := c.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownKeyID, id)
+	}
+	size := encryptionHeaderSize + aead.NonceSize()
+	if len(data) < size+aead.Overhead() {
+		return nil, errInvalidCiphertext
+	}
+	nonce := data[encryptionHeaderSize:size]
+	plaintext, err := aead.Open(nil, nonce, data[size:], additionalData(data, key))
+	if err != nil {
+		return nil, fmt.Errorf("decrypt: %w", err)
+	}
+	return plaintext, nil
+}
+
+// additionalData returns the data authenticated with the ciphertext: the
+// header of the encrypted data, followed by the key of the record.
+func additionalData(data, key []byte) []byte {
+	ad := make([]byte, 0, encryptionHeaderSize+len(key))
+	ad = append(ad, data[:encryptionHeaderSize]...)
+	return append(ad, key...)
+}
+
+// keyID returns the ID of the key that encrypted the data.
+func (c *encryptedCodec) keyID(data []byte) (uint32, error) {
+	if len(data) < encryptionHeaderSize || data[0] != encryptionVersion {
+		return 0, errInvalidCiphertext
+	}
+	return binary.BigEndian.Uint32(data[1:]), nil
+}
+
+// reencrypt encrypts the data of the record with the given key again with
+// the current encryption key, if it was encrypted with another one, and
+// reports whether it did.
+func (c *encryptedCodec) reencrypt(key, data []byte) ([]byte, bool, error) {
+	id, err := c.keyID(data)
+	if err != nil || id == c.current {
+		return nil, false, err
+	}
+	plaintext, err := c.decrypt(key, data)
+	if err != nil {
+		return nil, false, err
+	}
+	data, err = c.encrypt(key, plaintext)
+	return data, err == nil, err
+}
+
+// Reencrypt rewrites the values of the Shelf that weren't encrypted with the
+// current key of its codec, which must be created with [EncryptedCodec], and
+// returns the number of values rewritten. The values are decrypted and
+// encrypted again without being decoded, and the expiration deadlines stored
+// with [WithExpiryHeader] are kept.
+//
+// The values are rewritten in transactions of a few hundred keys each, so it
+// is safe to call Reencrypt while the Shelf is in use, and it can be called
+// again to resume after an error. The rewrites are reported to the watchers
+// as puts. Keys stored with [Shelf.PutWithTTL] in a database implementing
+// [TTLDB] keep their expiration only if it implements [ExpiryDB].
+func (s *Shelf[K, V]) Reencrypt() (int64, error) {
+	return s.reencrypt(context.Background())
+}
+
+func (s *Shelf[K, V]) reencrypt(ctx context.Context) (int64, error) {
+	c, ok := s.codec.(*encryptedCodec)
+	if !ok {
+		return 0, ErrNotEncrypted
+	}
+
+	return s.rewriteValues(ctx, func(_, payload []byte) (bool, error) {
+		_, _, data := splitSchema(payload)
+		id, err := c.keyID(data)
+		return id != c.current, err
+	}, func(key, payload []byte) ([]byte, bool, error) {
+		header, _, data := splitSchema(payload)
+		data, ok, err := c.reencrypt(key, data)
+		if err != nil || !ok {
+			return nil, ok, err
+		}
+		return append(slices.Clone(header), data...), true, nil
+	})
+}
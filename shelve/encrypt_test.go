@@ -0,0 +1,310 @@
+package shelve
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func testEncryptionKey(id uint32) EncryptionKey {
+	return EncryptionKey{ID: id, Key: bytes.Repeat([]byte{byte(id)}, 32)}
+}
+
+func newTestEncryptedCodec(t *testing.T, current EncryptionKey, previous ...EncryptionKey) Codec {
+	t.Helper()
+	codec, err := EncryptedCodec(JSONCodec(), current, previous...)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	return codec
+}
+
+func TestEncryptedCodec_Encode(t *testing.T) {
+	codec := newTestEncryptedCodec(t, testEncryptionKey(1))
+
+	// Run the tests in the suite
+	EncodeTest(t, codec)
+
+	t.Run("Encode Error", func(t *testing.T) {
+		_, err := codec.Encode(func() {})
+		if err == nil {
+			t.Errorf("Expected an error, but got nil")
+		}
+	})
+
+	t.Run("Random nonce", func(t *testing.T) {
+		a, _ := codec.Encode("value")
+		b, _ := codec.Encode("value")
+		if bytes.Equal(a, b) {
+			t.Errorf("Expected different ciphertexts, but got %x", a)
+		}
+		if bytes.Contains(a, []byte("value")) {
+			t.Errorf("Expected the value to be encrypted, but got %q", a)
+		}
+	})
+}
+
+func TestEncryptedCodec_Decode(t *testing.T) {
+	codec := newTestEncryptedCodec(t, testEncryptionKey(1))
+
+	// Run the tests in the suite
+	DecodeTest(t, codec)
+
+	t.Run("Previous key", func(t *testing.T) {
+		data, _ := codec.Encode("value")
+		rotated := newTestEncryptedCodec(t, testEncryptionKey(2), testEncryptionKey(1))
+
+		var s string
+		if err := rotated.Decode(data, &s); err != nil {
+			t.Errorf("Expected no error, but got %v", err)
+		}
+		if s != "value" {
+			t.Errorf("Expected value, but got %s", s)
+		}
+	})
+
+	t.Run("Unknown key", func(t *testing.T) {
+		data, _ := codec.Encode("value")
+		other := newTestEncryptedCodec(t, testEncryptionKey(2))
+
+		var s string
+		err := other.Decode(data, &s)
+		if !errors.Is(err, ErrUnknownKeyID) {
+			t.Errorf("Expected ErrUnknownKeyID, but got %v", err)
+		}
+	})
+
+	t.Run("Wrong key", func(t *testing.T) {
+		data, _ := codec.Encode("value")
+		other := newTestEncryptedCodec(t, EncryptionKey{ID: 1, Key: make([]byte, 16)})
+
+		var s string
+		if err := other.Decode(data, &s); err == nil {
+			t.Errorf("Expected an error, but got nil")
+		}
+	})
+
+	t.Run("Tampered", func(t *testing.T) {
+		for _, i := range []int{0, 4, encryptionHeaderSize, 30} {
+			data, _ := codec.Encode("value")
+			data[i] ^= 1
+
+			var s string
+			if err := codec.Decode(data, &s); err == nil {
+				t.Errorf("%d: Expected an error, but got nil", i)
+			}
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		data, _ := codec.Encode("value")
+		for _, d := range [][]byte{nil, data[:3], data[:encryptionHeaderSize+8]} {
+			var s string
+			err := codec.Decode(d, &s)
+			if !errors.Is(err, errInvalidCiphertext) {
+				t.Errorf("Expected errInvalidCiphertext, but got %v", err)
+			}
+		}
+	})
+}
+
+func TestEncryptedCodec_Keys(t *testing.T) {
+	tests := []struct {
+		name     string
+		current  EncryptionKey
+		previous []EncryptionKey
+	}{
+		{
+			name:    "Invalid key size",
+			current: EncryptionKey{ID: 1, Key: []byte("short")},
+		},
+		{
+			name:     "Invalid previous key",
+			current:  testEncryptionKey(1),
+			previous: []EncryptionKey{{ID: 2}},
+		},
+		{
+			name:     "Duplicate ID",
+			current:  testEncryptionKey(1),
+			previous: []EncryptionKey{testEncryptionKey(1)},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			codec, err := EncryptedCodec(JSONCodec(), tc.current, tc.previous...)
+			if err == nil {
+				t.Errorf("Expected an error, but got nil")
+			}
+			if codec != nil {
+				t.Errorf("Expected a nil codec, but got %v", codec)
+			}
+		})
+	}
+}
+
+func TestEncryptedCodec_BoundToKey(t *testing.T) {
+	encrypted := newTestEncryptedCodec(t, testEncryptionKey(1))
+	compressed, err := CompressedCodec(encrypted, WithCompressThreshold(0))
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		codec Codec
+	}{
+		{"Encrypted", encrypted},
+		{"Wrapped", compressed},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			shelf := OpenTestShelfWith[string, string](t, WithCodec(tc.codec))
+			defer shelf.Close()
+			_ = shelf.Put("a", "1")
+			_ = shelf.Put("b", "2")
+
+			keyA, _ := shelf.keyCodec.Encode("a")
+			keyB, _ := shelf.keyCodec.Encode("b")
+			data, _ := shelf.db.Get(keyB)
+
+			// Act
+			_ = shelf.db.Put(keyA, data)
+			_, _, err := shelf.Get("a")
+
+			// Assert
+			if err == nil {
+				t.Errorf("Expected an error, but got nil")
+			}
+			value, ok, err := shelf.Get("b")
+			if !ok || value != "2" || err != nil {
+				t.Errorf("Expected 2, but got %s, %v, %v", value, ok, err)
+			}
+		})
+	}
+}
+
+func TestShelf_Reencrypt(t *testing.T) {
+	seed := map[string]string{"a": "1", "b": "2", "c": "3"}
+
+	t.Run("Rotates the keys", func(t *testing.T) {
+		// Arrange
+		old := newTestEncryptedCodec(t, testEncryptionKey(1))
+		shelf := OpenTestShelfWith[string, string](t, WithCodec(old), WithExpiryHeader())
+		defer shelf.Close()
+		for k, v := range seed {
+			_ = shelf.Put(k, v)
+		}
+		_ = shelf.PutWithTTL("d", "4", time.Hour)
+
+		rotated := newTestEncryptedCodec(t, testEncryptionKey(2), testEncryptionKey(1))
+		shelf2, _ := Open[string, string](TestDirectory,
+			WithDatabase(shelf.db), WithCodec(rotated), WithExpiryHeader())
+		_ = shelf2.Put("e", "5")
+
+		// Act
+		n, err := shelf2.Reencrypt()
+
+		// Assert
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		if n != 4 {
+			t.Errorf("Expected 4 values, but got %d", n)
+		}
+		if n, _ = shelf2.Reencrypt(); n != 0 {
+			t.Errorf("Expected 0 values, but got %d", n)
+		}
+
+		// The old key is no longer needed.
+		current := newTestEncryptedCodec(t, testEncryptionKey(2))
+		shelf3, _ := Open[string, string](TestDirectory,
+			WithDatabase(shelf.db), WithCodec(current), WithExpiryHeader())
+		seed["d"], seed["e"] = "4", "5"
+		checkShelf(t, shelf3, seed)
+
+		setNow(t, time.Now().Add(2*time.Hour))
+		if ok, _ := shelf3.Has("d"); ok {
+			t.Errorf("Expected the key to expire")
+		}
+	})
+
+	t.Run("With indexes", func(t *testing.T) {
+		// Arrange
+		old := newTestEncryptedCodec(t, testEncryptionKey(1))
+		byValue := WithIndex("value", func(v string) []IndexKey {
+			return []IndexKey{{v}}
+		})
+		shelf := OpenTestShelfWith[string, string](t, WithCodec(old), byValue)
+		defer shelf.Close()
+		_ = shelf.Put("a", "1")
+
+		rotated := newTestEncryptedCodec(t, testEncryptionKey(2), testEncryptionKey(1))
+		shelf2, _ := Open[string, string](TestDirectory,
+			WithDatabase(shelf.db), WithCodec(rotated), byValue)
+
+		// Act
+		n, err := shelf2.Reencrypt()
+
+		// Assert
+		if err != nil || n != 1 {
+			t.Errorf("Expected 1 value and no error, but got %d, %v", n, err)
+		}
+		k, _, ok, err := shelf2.GetByIndex("value", IndexKey{"1"})
+		if !ok || k != "a" || err != nil {
+			t.Errorf("Expected a, but got %s, %v, %v", k, ok, err)
+		}
+	})
+
+	t.Run("Not encrypted", func(t *testing.T) {
+		shelf := NewTestShelf(t)
+
+		_, err := shelf.Reencrypt()
+		if !errors.Is(err, ErrNotEncrypted) {
+			t.Errorf("Expected ErrNotEncrypted, but got %v", err)
+		}
+	})
+
+	t.Run("Invalid value", func(t *testing.T) {
+		// Arrange
+		var db MockDB
+		db.ItemsFunc = NewMockItemsFunc(MakeItems(map[string]string{"key": "value"}))
+		codec := newTestEncryptedCodec(t, testEncryptionKey(1))
+		shelf := NewTestShelf(t, WithDatabase(&db), WithCodec(codec))
+
+		// Act
+		_, err := shelf.Reencrypt()
+
+		// Assert
+		if !errors.Is(err, errInvalidCiphertext) {
+			t.Errorf("Expected errInvalidCiphertext, but got %v", err)
+		}
+	})
+
+	t.Run("Get error", func(t *testing.T) {
+		// Arrange
+		old := newTestEncryptedCodec(t, testEncryptionKey(1))
+		data, _ := old.Encode("value")
+
+		var db MockDB
+		db.ItemsFunc = func(start []byte, order int, fn YieldData) error {
+			_, err := fn([]byte("key"), data)
+			return err
+		}
+		db.GetFunc = func([]byte) ([]byte, error) { return nil, TestError }
+		codec := newTestEncryptedCodec(t, testEncryptionKey(2), testEncryptionKey(1))
+		shelf := NewTestShelf(t, WithDatabase(&db), WithCodec(codec))
+
+		// Act
+		n, err := shelf.Reencrypt()
+
+		// Assert
+		if !errors.Is(err, TestError) {
+			t.Errorf("Expected TestError, but got %v", err)
+		}
+		if n != 0 {
+			t.Errorf("Expected 0 values, but got %d", n)
+		}
+	})
+}
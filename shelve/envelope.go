@@ -0,0 +1,248 @@
+package shelve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+const (
+	// envelopeTag is the first byte of the values written by EnvelopeCodec.
+	// It can't start JSON or valid UTF-8 text, nor a gob message, since it
+	// would announce a length of more than 8 bytes. RawCodec escapes the
+	// values that start with it, so the values without an envelope are
+	// recognized.
+	envelopeTag = 0xf5
+
+	// envelopeVersion identifies the layout of the envelope.
+	envelopeVersion = 1
+
+	// envelopeHeaderSize is the size of the tag, version and codec ID.
+	envelopeHeaderSize = 3
+)
+
+// CodecID identifies a Codec in the envelope of the values written by
+// [EnvelopeCodec]. The IDs are stored with the values, so they must not be
+// reassigned once values are stored.
+type CodecID byte
+
+// The IDs of the codecs of this package, which are always registered. The
+// IDs from 1 to 15 are reserved for the codecs of go-shelve and its drivers,
+// which register their codecs when imported:
+//
+//   - 5: the Codec of driver/encoding/msgpack, as msgpack.CodecID.
+const (
+	GobCodecID  CodecID = 1
+	JSONCodecID CodecID = 2
+	TextCodecID CodecID = 3
+	RawCodecID  CodecID = 4
+)
+
+var (
+	// ErrUnknownCodec is returned when a Codec ID isn't registered with
+	// [RegisterCodec].
+	ErrUnknownCodec = errors.New("unknown codec ID")
+
+	// ErrNotEnveloped is returned by [Shelf.Reencode] when the value codec
+	// of the Shelf wasn't created with [EnvelopeCodec].
+	ErrNotEnveloped = errors.New("value codec is not an envelope")
+)
+
+var codecs = struct {
+	sync.RWMutex
+	m map[CodecID]Codec
+}{
+	m: map[CodecID]Codec{
+		GobCodecID:  GobCodec(),
+		JSONCodecID: JSONCodec(),
+		TextCodecID: TextCodec(),
+		RawCodecID:  RawCodec(),
+	},
+}
+
+// RegisterCodec makes a Codec available to [EnvelopeCodec] under the given
+// ID, usually from an init function. Registering a codec lets the Shelves
+// decode the values it encoded, even after they switch to another codec.
+//
+// It panics if id is zero or if a Codec is already registered with it.
+func RegisterCodec(id CodecID, c Codec) {
+	codecs.Lock()
+	defer codecs.Unlock()
+
+	if id == 0 {
+		panic("shelve: RegisterCodec with zero ID")
+	}
+	if c == nil {
+		panic("shelve: RegisterCodec with nil Codec")
+	}
+	if _, dup := codecs.m[id]; dup {
+		panic(fmt.Sprintf("shelve: RegisterCodec called twice for ID %d", id))
+	}
+	codecs.m[id] = c
+}
+
+// LookupCodec returns the Codec registered with the given ID.
+func LookupCodec(id CodecID) (Codec, bool) {
+	codecs.RLock()
+	defer codecs.RUnlock()
+	c, ok := codecs.m[id]
+	return c, ok
+}
+
+// EnvelopeOption is passed to [EnvelopeCodec] to customize it.
+type EnvelopeOption func(*envelopeOptions)
+
+type envelopeOptions struct {
+	Legacy Codec
+}
+
+// WithLegacyCodec sets the Codec that decodes the values stored without an
+// envelope, before the Shelf used [EnvelopeCodec]. The default is JSONCodec,
+// the default value codec of the Shelf.
+func WithLegacyCodec(c Codec) EnvelopeOption {
+	return func(o *envelopeOptions) {
+		o.Legacy = c
+	}
+}
+
+// EnvelopeCodec returns a Codec that prefixes the encoded values with a
+// small envelope holding the ID of the codec that encoded them. The values
+// are encoded with the Codec registered with the given ID, and decoded with
+// the Codec identified by their envelope, so a Shelf can switch codecs
+// without making its existing values undecodable. It returns ErrUnknownCodec
+// if the ID isn't registered.
+//
+// The values without an envelope are decoded with the legacy codec, set
+// with [WithLegacyCodec]. After switching codecs, [Shelf.Reencode] rewrites
+// the values that are not encoded with the current one.
+//
+// To combine it with [EncryptedCodec] or [CompressedCodec], register the
+// wrapped codec with its own ID, so that the envelope stays readable.
+func EnvelopeCodec(id CodecID, opts ...EnvelopeOption) (Codec, error) {
+	o := envelopeOptions{
+		Legacy: JSONCodec(),
+	}
+	for _, option := range opts {
+		option(&o)
+	}
+
+	codec, ok := LookupCodec(id)
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownCodec, id)
+	}
+	return &envelopeCodec{
+		id:     id,
+		codec:  codec,
+		legacy: o.Legacy,
+	}, nil
+}
+
+type envelopeCodec struct {
+	id     CodecID
+	codec  Codec
+	legacy Codec
+}
+
+func (c *envelopeCodec) Encode(value any) ([]byte, error) {
+	return c.encodeKeyed(nil, value)
+}
+
+func (c *envelopeCodec) Decode(data []byte, value any) error {
+	return c.decodeKeyed(nil, data, value)
+}
+
+func (c *envelopeCodec) encodeKeyed(key []byte, value any) ([]byte, error) {
+	data, err := encodeKeyed(c.codec, key, value)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, envelopeHeaderSize+len(data))
+	out = append(out, envelopeTag, envelopeVersion, byte(c.id))
+	return append(out, data...), nil
+}
+
+func (c *envelopeCodec) decodeKeyed(key, data []byte, value any) error {
+	codec, payload, err := c.open(data)
+	if err != nil {
+		return err
+	}
+	return decodeKeyed(codec, key, payload, value)
+}
+
+func (c *envelopeCodec) unwrapKeyed(key, data []byte) ([]byte, error) {
+	codec, payload, err := c.open(data)
+	if err != nil {
+		return nil, err
+	}
+	return unwrapKeyed(codec, key, payload)
+}
+
+// open returns the codec that encoded the data and the encoded value,
+// without the envelope.
+func (c *envelopeCodec) open(data []byte) (Codec, []byte, error) {
+	id, ok := envelopeID(data)
+	if !ok {
+		return c.legacy, data, nil
+	}
+	if id == c.id {
+		return c.codec, data[envelopeHeaderSize:], nil
+	}
+	codec, ok := LookupCodec(id)
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %d", ErrUnknownCodec, id)
+	}
+	return codec, data[envelopeHeaderSize:], nil
+}
+
+// stale reports whether the data wasn't encoded with the current codec.
+func (c *envelopeCodec) stale(data []byte) bool {
+	id, ok := envelopeID(data)
+	return !ok || id != c.id
+}
+
+// envelopeID returns the codec ID in the envelope of the data, and false if
+// the data has no envelope.
+func envelopeID(data []byte) (CodecID, bool) {
+	if len(data) < envelopeHeaderSize || data[0] != envelopeTag || data[1] != envelopeVersion {
+		return 0, false
+	}
+	return CodecID(data[2]), true
+}
+
+// Reencode rewrites the values of the Shelf that weren't encoded with the
+// current codec of its envelope, which must be created with [EnvelopeCodec],
+// and returns the number of values rewritten. This includes the values
+// stored without an envelope. The values are decoded and encoded again,
+// applying the migrations of [WithSchema], and the expiration deadlines
+// stored with [WithExpiryHeader] are kept.
+//
+// The values are rewritten in transactions of a few hundred keys each, so it
+// is safe to call Reencode while the Shelf is in use, for instance from a
+// background goroutine, and it can be called again to resume after an error.
+// The rewrites are reported to the watchers as puts. Keys stored with
+// [Shelf.PutWithTTL] in a database implementing [TTLDB] keep their
+// expiration only if it implements [ExpiryDB].
+func (s *Shelf[K, V]) Reencode() (int64, error) {
+	return s.reencode(context.Background())
+}
+
+func (s *Shelf[K, V]) reencode(ctx context.Context) (int64, error) {
+	c, ok := s.codec.(*envelopeCodec)
+	if !ok {
+		return 0, ErrNotEnveloped
+	}
+
+	stale := func(payload []byte) bool {
+		_, _, data := splitSchema(payload)
+		return c.stale(data)
+	}
+	return s.rewriteValues(ctx, func(_, payload []byte) (bool, error) {
+		return stale(payload), nil
+	}, func(key, payload []byte) ([]byte, bool, error) {
+		if !stale(payload) {
+			return nil, false, nil
+		}
+		return s.recode(key, payload)
+	})
+}
@@ -0,0 +1,232 @@
+package shelve
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// testCodecID is the ID of a codec registered only by the tests.
+const testCodecID CodecID = 200
+
+func init() {
+	codec, err := CompressedCodec(GobCodec(), WithCompressThreshold(0))
+	if err != nil {
+		panic(err)
+	}
+	RegisterCodec(testCodecID, codec)
+}
+
+func newTestEnvelopeCodec(t *testing.T, id CodecID, opts ...EnvelopeOption) Codec {
+	t.Helper()
+	codec, err := EnvelopeCodec(id, opts...)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	return codec
+}
+
+func TestEnvelopeCodec_Encode(t *testing.T) {
+	for _, id := range []CodecID{GobCodecID, JSONCodecID, testCodecID} {
+		codec := newTestEnvelopeCodec(t, id)
+
+		// Run the tests in the suite
+		EncodeTest(t, codec)
+
+		t.Run("Envelope", func(t *testing.T) {
+			data, _ := codec.Encode("value")
+			if got, ok := envelopeID(data); !ok || got != id {
+				t.Errorf("Expected codec ID %d, but got %d, %v", id, got, ok)
+			}
+		})
+	}
+
+	t.Run("Encode Error", func(t *testing.T) {
+		codec := newTestEnvelopeCodec(t, JSONCodecID)
+		_, err := codec.Encode(func() {})
+		if err == nil {
+			t.Errorf("Expected an error, but got nil")
+		}
+	})
+}
+
+func TestEnvelopeCodec_Decode(t *testing.T) {
+	// Run the tests in the suite
+	DecodeTest(t, newTestEnvelopeCodec(t, GobCodecID))
+	DecodeTest(t, newTestEnvelopeCodec(t, JSONCodecID))
+
+	t.Run("Other codec", func(t *testing.T) {
+		other := newTestEnvelopeCodec(t, testCodecID)
+		data, _ := other.Encode("value")
+
+		var s string
+		err := newTestEnvelopeCodec(t, JSONCodecID).Decode(data, &s)
+		if err != nil {
+			t.Errorf("Expected no error, but got %v", err)
+		}
+		if s != "value" {
+			t.Errorf("Expected value, but got %q", s)
+		}
+	})
+
+	t.Run("Legacy values", func(t *testing.T) {
+		tests := []struct {
+			name  string
+			codec Codec
+			data  []byte
+		}{
+			{
+				name:  "Default",
+				codec: newTestEnvelopeCodec(t, GobCodecID),
+				data:  []byte(`"value"`),
+			},
+			{
+				name:  "Text",
+				codec: newTestEnvelopeCodec(t, GobCodecID, WithLegacyCodec(TextCodec())),
+				data:  []byte("value"),
+			},
+		}
+		for _, tc := range tests {
+			t.Run(tc.name, func(t *testing.T) {
+				var s string
+				err := tc.codec.Decode(tc.data, &s)
+				if err != nil {
+					t.Errorf("Expected no error, but got %v", err)
+				}
+				if s != "value" {
+					t.Errorf("Expected value, but got %q", s)
+				}
+			})
+		}
+	})
+
+	t.Run("Unknown codec", func(t *testing.T) {
+		var s string
+		data := []byte{envelopeTag, envelopeVersion, 199, '"', '"'}
+		err := newTestEnvelopeCodec(t, JSONCodecID).Decode(data, &s)
+		if !errors.Is(err, ErrUnknownCodec) {
+			t.Errorf("Expected ErrUnknownCodec, but got %v", err)
+		}
+	})
+}
+
+func TestEnvelopeCodec_Registry(t *testing.T) {
+	t.Run("Unknown ID", func(t *testing.T) {
+		codec, err := EnvelopeCodec(199)
+		if !errors.Is(err, ErrUnknownCodec) {
+			t.Errorf("Expected ErrUnknownCodec, but got %v", err)
+		}
+		if codec != nil {
+			t.Errorf("Expected a nil codec, but got %v", codec)
+		}
+	})
+
+	t.Run("Lookup", func(t *testing.T) {
+		if c, ok := LookupCodec(TextCodecID); !ok || c != TextCodec() {
+			t.Errorf("Expected TextCodec, but got %v, %v", c, ok)
+		}
+		if c, ok := LookupCodec(RawCodecID); !ok || c != RawCodec() {
+			t.Errorf("Expected RawCodec, but got %v, %v", c, ok)
+		}
+		if _, ok := LookupCodec(199); ok {
+			t.Errorf("Expected no codec")
+		}
+	})
+
+	tests := []struct {
+		name  string
+		id    CodecID
+		codec Codec
+	}{
+		{name: "Zero ID", id: 0, codec: JSONCodec()},
+		{name: "Nil codec", id: 199, codec: nil},
+		{name: "Duplicate", id: JSONCodecID, codec: JSONCodec()},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expected a panic")
+				}
+			}()
+			RegisterCodec(tc.id, tc.codec)
+		})
+	}
+}
+
+func TestShelf_Reencode(t *testing.T) {
+	seed := map[string]string{"a": "1", "b": "2", "c": "3"}
+
+	t.Run("Switches codecs", func(t *testing.T) {
+		// Arrange
+		shelf := OpenTestShelfWith[string, string](t, WithExpiryHeader())
+		defer shelf.Close()
+		for k, v := range seed {
+			_ = shelf.Put(k, v)
+		}
+		_ = shelf.PutWithTTL("d", "4", time.Hour)
+
+		gob := newTestEnvelopeCodec(t, GobCodecID)
+		shelf2, _ := Open[string, string](TestDirectory,
+			WithDatabase(shelf.db), WithCodec(gob), WithExpiryHeader())
+		_ = shelf2.Put("e", "5")
+		seed["d"], seed["e"] = "4", "5"
+		checkShelf(t, shelf2, seed)
+
+		// Act
+		n, err := shelf2.Reencode()
+
+		// Assert
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		if n != 4 {
+			t.Errorf("Expected 4 values, but got %d", n)
+		}
+		if n, _ = shelf2.Reencode(); n != 0 {
+			t.Errorf("Expected 0 values, but got %d", n)
+		}
+
+		// The legacy codec is no longer needed.
+		strict := newTestEnvelopeCodec(t, GobCodecID, WithLegacyCodec(&MockCodec{
+			DecodeFunc: func([]byte, any) error { return TestError },
+		}))
+		shelf3, _ := Open[string, string](TestDirectory,
+			WithDatabase(shelf.db), WithCodec(strict), WithExpiryHeader())
+		checkShelf(t, shelf3, seed)
+
+		setNow(t, time.Now().Add(2*time.Hour))
+		if ok, _ := shelf3.Has("d"); ok {
+			t.Errorf("Expected the key to expire")
+		}
+	})
+
+	t.Run("Not enveloped", func(t *testing.T) {
+		shelf := NewTestShelf(t)
+
+		_, err := shelf.Reencode()
+		if !errors.Is(err, ErrNotEnveloped) {
+			t.Errorf("Expected ErrNotEnveloped, but got %v", err)
+		}
+	})
+
+	t.Run("Decode error", func(t *testing.T) {
+		// Arrange
+		var db MockDB
+		db.ItemsFunc = NewMockItemsFunc(MakeItems(map[string]string{"key": "value"}))
+		db.GetFunc = func([]byte) ([]byte, error) { return []byte("value"), nil }
+		codec := newTestEnvelopeCodec(t, GobCodecID)
+		shelf := NewTestShelf(t, WithDatabase(&db), WithCodec(codec))
+
+		// Act
+		n, err := shelf.Reencode()
+
+		// Assert
+		if err == nil {
+			t.Errorf("Expected an error, but got nil")
+		}
+		if n != 0 {
+			t.Errorf("Expected 0 values, but got %d", n)
+		}
+	})
+}
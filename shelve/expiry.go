@@ -0,0 +1,161 @@
+package shelve
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// expiryHeaderSize is the size of the header added to the values of a Shelf
+// opened with WithExpiryHeader. It holds the expiration deadline of the value
+// in Unix nanoseconds, with 0 meaning that the value doesn't expire.
+const expiryHeaderSize = 8
+
+var (
+	// ErrTTLNotSupported is returned by [Shelf.PutWithTTL] when the Shelf
+	// wasn't opened with [WithExpiryHeader] and the underlying database
+	// doesn't implement [TTLDB], or the Shelf has indexes.
+	ErrTTLNotSupported = errors.New("database doesn't support TTL")
+
+	errInvalidExpiryHeader = errors.New("invalid expiry header")
+)
+
+// now returns the current time. It can be replaced in tests.
+var now = time.Now
+
+// PutWithTTL works like [Shelf.Put], but the key expires after the given
+// time-to-live. Once expired, the key is no longer returned by [Shelf.Get],
+// [Shelf.Has] and the iteration methods, and it isn't counted by
+// [Shelf.Len]. If ttl is zero or negative, the key doesn't expire.
+//
+// If the Shelf was opened with [WithExpiryHeader], the deadline is stored
+// together with the value. Otherwise, the underlying database must
+// implement [TTLDB], or [ErrTTLNotSupported] is returned. A Shelf with
+// indexes must use WithExpiryHeader, since the expiration set by the
+// database can't be written atomically with the index entries.
+func (s *Shelf[K, V]) PutWithTTL(key K, value V, ttl time.Duration) error {
+	return s.putWithTTL(context.Background(), key, value, ttl)
+}
+
+func (s *Shelf[K, V]) putWithTTL(ctx context.Context, key K, value V, ttl time.Duration) error {
+	if err := s.checkWritable(); err != nil {
+		return err
+	}
+	data, err := s.keyCodec.Encode(key)
+	if err != nil {
+		return fmt.Errorf("encode key: %w", err)
+	}
+
+	if s.expiryHeader {
+		var deadline int64
+		if ttl > 0 {
+			deadline = now().Add(ttl).UnixNano()
+		}
+		vData, err := s.encodeValue(data, value, deadline)
+		if err != nil {
+			return fmt.Errorf("encode value: %w", err)
+		}
+		if err = s.putEncoded(ctx, data, vData); err != nil {
+			return fmt.Errorf("put: %w", err)
+		}
+		return nil
+	}
+
+	db, ok := s.db.(TTLDB)
+	if !ok {
+		return ErrTTLNotSupported
+	}
+	if len(s.indexes) > 0 {
+		return fmt.Errorf("%w: a Shelf with indexes requires WithExpiryHeader",
+			ErrTTLNotSupported)
+	}
+	vData, err := s.encodeValue(data, value, 0)
+	if err != nil {
+		return fmt.Errorf("encode value: %w", err)
+	}
+	if vData == nil {
+		vData = []byte{}
+	}
+	if s.isReserved(data) {
+		return ErrReservedKey
+	}
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+	defer s.lockWrite()()
+	if err = db.PutWithTTL(data, vData, ttl); err != nil {
+		return fmt.Errorf("put with ttl: %w", err)
+	}
+	s.notify([][]byte{data}, [][]byte{vData})
+	return nil
+}
+
+// encodeValue encodes the value of a key, adding the expiry header with the
+// given deadline if the Shelf was opened with WithExpiryHeader.
+func (s *Shelf[K, V]) encodeValue(key []byte, value V, deadline int64) ([]byte, error) {
+	vData, err := s.encodeVersioned(key, value)
+	if err != nil || !s.expiryHeader {
+		return vData, err
+	}
+	data := make([]byte, 0, expiryHeaderSize+len(vData))
+	data = binary.BigEndian.AppendUint64(data, uint64(deadline))
+	return append(data, vData...), nil
+}
+
+// unwrapValue removes the expiry header from an encoded value, if the Shelf
+// was opened with WithExpiryHeader. It returns nil if the value is missing or
+// expired.
+func (s *Shelf[K, V]) unwrapValue(data []byte) ([]byte, error) {
+	if !s.expiryHeader || data == nil {
+		return data, nil
+	}
+	if len(data) < expiryHeaderSize {
+		return nil, errInvalidExpiryHeader
+	}
+	deadline := int64(binary.BigEndian.Uint64(data))
+	if deadline != 0 && now().UnixNano() >= deadline {
+		return nil, nil
+	}
+	return data[expiryHeaderSize:], nil
+}
+
+// decodeStored decodes the value of a key read from the database, ignoring
+// its expiration. It returns false if the value is missing.
+func (s *Shelf[K, V]) decodeStored(key, data []byte) (value V, ok bool, err error) {
+	if data == nil {
+		return value, false, nil
+	}
+	if s.expiryHeader {
+		if len(data) < expiryHeaderSize {
+			return value, false, errInvalidExpiryHeader
+		}
+		data = data[expiryHeaderSize:]
+	}
+	if err = s.decodeValue(key, data, &value); err != nil {
+		return value, false, fmt.Errorf("decode value: %w", err)
+	}
+	return value, true, nil
+}
+
+// skipExpired wraps fn so that the expired items are skipped and the expiry
+// header is removed from the values, if the Shelf was opened with
+// WithExpiryHeader.
+func (s *Shelf[K, V]) skipExpired(
+	fn func(k, v []byte) (bool, error),
+) func(k, v []byte) (bool, error) {
+	if !s.expiryHeader {
+		return fn
+	}
+	return func(k, v []byte) (bool, error) {
+		v, err := s.unwrapValue(v)
+		if err != nil {
+			return false, fmt.Errorf("unwrap value: %w", err)
+		}
+		if v == nil {
+			return true, nil
+		}
+		return fn(k, v)
+	}
+}
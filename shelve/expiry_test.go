@@ -0,0 +1,197 @@
+package shelve
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// setNow replaces the current time used by the Shelf, restoring it at the end
+// of the test.
+func setNow(t *testing.T, tm time.Time) {
+	t.Helper()
+	old := now
+	now = func() time.Time { return tm }
+	t.Cleanup(func() { now = old })
+}
+
+func TestShelf_PutWithTTL(t *testing.T) {
+	t.Run("Database TTL", func(t *testing.T) {
+		// Arrange
+		shelf := OpenTestShelf[string, string](t)
+		defer shelf.Close()
+		_ = shelf.Put("key-1", "value-1")
+
+		// Act
+		err1 := shelf.PutWithTTL("key-2", "value-2", time.Hour)
+		err2 := shelf.PutWithTTL("key-3", "value-3", time.Millisecond)
+
+		// Assert
+		if err1 != nil || err2 != nil {
+			t.Fatalf("Expected no error, but got %v, %v", err1, err2)
+		}
+		time.Sleep(10 * time.Millisecond)
+		checkShelf(t, shelf, map[string]string{"key-1": "value-1", "key-2": "value-2"})
+		if n := shelf.Len(); n != 2 {
+			t.Errorf("Expected 2 items, but got %d", n)
+		}
+	})
+
+	t.Run("Not supported", func(t *testing.T) {
+		shelf := NewTestShelf(t)
+
+		err := shelf.PutWithTTL("key", "value", time.Hour)
+		if !errors.Is(err, ErrTTLNotSupported) {
+			t.Errorf("Expected ErrTTLNotSupported, but got %v", err)
+		}
+	})
+
+	t.Run("Indexes without expiry header", func(t *testing.T) {
+		// Arrange
+		var db MockTTLDB
+		var puts int
+		db.PutWithTTLFunc = func(_, _ []byte, _ time.Duration) error {
+			puts++
+			return nil
+		}
+		shelf := NewTestShelf(t, WithDatabase(&db),
+			WithIndex("len", func(v string) []IndexKey { return []IndexKey{{len(v)}} }))
+
+		// Act
+		err := shelf.PutWithTTL("key", "value", time.Hour)
+
+		// Assert
+		if !errors.Is(err, ErrTTLNotSupported) {
+			t.Errorf("Expected ErrTTLNotSupported, but got %v", err)
+		}
+		if puts != 0 {
+			t.Errorf("Expected no writes, but got %d", puts)
+		}
+	})
+
+	t.Run("Encode error", func(t *testing.T) {
+		var codec MockCodec
+		codec.EncodeFunc = func(any) ([]byte, error) { return nil, TestError }
+
+		for _, opt := range []Option{WithCodec(&codec), WithKeyCodec(&codec)} {
+			for _, header := range []bool{false, true} {
+				var db MockTTLDB
+				opts := []Option{WithDatabase(&db), opt}
+				if header {
+					opts = append(opts, WithExpiryHeader())
+				}
+				shelf := NewTestShelf(t, opts...)
+
+				err := shelf.PutWithTTL("key", "value", time.Hour)
+				if !errors.Is(err, TestError) {
+					t.Errorf("Expected error %v, but got %v", TestError, err)
+				}
+			}
+		}
+	})
+
+	t.Run("Put error", func(t *testing.T) {
+		var db MockTTLDB
+		db.PutFunc = func(_, _ []byte) error { return TestError }
+		db.PutWithTTLFunc = func(_, _ []byte, _ time.Duration) error { return TestError }
+
+		for _, opts := range [][]Option{nil, {WithExpiryHeader()}} {
+			opts = append(opts, WithDatabase(&db))
+			shelf := NewTestShelf(t, opts...)
+
+			err := shelf.PutWithTTL("key", "value", time.Hour)
+			if !errors.Is(err, TestError) {
+				t.Errorf("Expected error %v, but got %v", TestError, err)
+			}
+		}
+	})
+}
+
+func TestShelf_ExpiryHeader(t *testing.T) {
+	start := time.Now()
+
+	t.Run("Hides expired items", func(t *testing.T) {
+		// Arrange
+		setNow(t, start)
+		shelf := OpenTestShelfWith[string, string](t, WithExpiryHeader())
+		defer shelf.Close()
+		_ = shelf.Put("key-1", "value-1")
+		_ = shelf.PutWithTTL("key-2", "value-2", time.Minute)
+		_ = shelf.PutWithTTL("key-3", "value-3", time.Hour)
+		_ = shelf.PutWithTTL("key-4", "value-4", 0)
+
+		// Act
+		setNow(t, start.Add(time.Minute))
+
+		// Assert
+		checkShelf(t, shelf, map[string]string{
+			"key-1": "value-1", "key-3": "value-3", "key-4": "value-4",
+		})
+		if n := shelf.Len(); n != 3 {
+			t.Errorf("Expected 3 items, but got %d", n)
+		}
+		if ok, _ := shelf.Has("key-2"); ok {
+			t.Errorf("Expected key-2 to be expired")
+		}
+		if ok, _ := shelf.Has("key-3"); !ok {
+			t.Errorf("Expected key-3 to exist")
+		}
+		if _, ok, _ := shelf.Get("key-2"); ok {
+			t.Errorf("Expected key-2 to be expired")
+		}
+		n, err := shelf.LenPrefix("key")
+		if err != nil || n != 3 {
+			t.Errorf("Expected 3 items and no error, but got %d, %v", n, err)
+		}
+	})
+
+	t.Run("Transactions", func(t *testing.T) {
+		// Arrange
+		setNow(t, start)
+		shelf := OpenTestShelfWith[string, int](t, WithExpiryHeader())
+		defer shelf.Close()
+		_ = shelf.PutWithTTL("counter", 10, time.Minute)
+		setNow(t, start.Add(time.Minute))
+
+		// Act
+		value, err := shelf.Update("counter", func(old int, exists bool) (int, error) {
+			if exists {
+				return old + 1, nil
+			}
+			return 1, nil
+		})
+
+		// Assert
+		if err != nil {
+			t.Errorf("Expected no error, but got %v", err)
+		}
+		if value != 1 {
+			t.Errorf("Expected 1, but got %d", value)
+		}
+		swapped, err := shelf.CompareAndSwap("counter", 1, 2)
+		if err != nil || !swapped {
+			t.Errorf("Expected the value to be swapped, but got %v, %v", swapped, err)
+		}
+		checkShelf(t, shelf, map[string]int{"counter": 2})
+	})
+
+	t.Run("Invalid header", func(t *testing.T) {
+		var db MockDB
+		db.GetFunc = func(_ []byte) ([]byte, error) { return []byte("short"), nil }
+		db.ItemsFunc = func(_ []byte, _ int, fn YieldData) error {
+			_, err := fn([]byte("key"), []byte("short"))
+			return err
+		}
+		shelf := NewTestShelf(t, WithDatabase(&db), WithExpiryHeader())
+
+		if _, _, err := shelf.Get("key"); !errors.Is(err, errInvalidExpiryHeader) {
+			t.Errorf("Expected error %v, but got %v", errInvalidExpiryHeader, err)
+		}
+		if _, err := shelf.Has("key"); !errors.Is(err, errInvalidExpiryHeader) {
+			t.Errorf("Expected error %v, but got %v", errInvalidExpiryHeader, err)
+		}
+		if n := shelf.Len(); n != -1 {
+			t.Errorf("Expected -1, but got %d", n)
+		}
+	})
+}
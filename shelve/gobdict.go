@@ -0,0 +1,372 @@
+package shelve
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+const (
+	// gobDictTag is the first byte of the values written by
+	// GobDictionaryCodec. A gob message can't start with it, since it would
+	// announce a length of more than 8 bytes, so the values written by
+	// GobCodec are recognized.
+	gobDictTag = 0xf7
+
+	// gobDictHeaderSize is the size of the tag and the dictionary ID.
+	gobDictHeaderSize = 1 + 4
+
+	// gobDictPrefix starts the keys of the type dictionaries stored by
+	// GobDictionaryCodec. It is followed by the dictionary ID.
+	gobDictPrefix = "\xff\xffgob\x00"
+
+	// The first byte of a dictionary tells how its values are encoded:
+	// with a single gob stream, or with a stream for each value, for the
+	// types that can hold interface values. Since gob sends the definitions
+	// of the types held by interfaces inside the value messages, the values
+	// of a single stream would depend on the values encoded before them.
+	gobDictStream     = 0
+	gobDictStandalone = 1
+)
+
+var (
+	// ErrUnknownDictionary is returned when decoding a value whose type
+	// dictionary isn't in the database.
+	ErrUnknownDictionary = errors.New("unknown gob type dictionary")
+
+	errCodecNotAttached = errors.New("codec is not attached to a database")
+	errInvalidGobStream = errors.New("invalid gob stream")
+)
+
+// attachedCodec is implemented by the codecs that store data in the database
+// of the Shelf, under reserved keys. They are attached to the database by
+// [Open].
+type attachedCodec interface {
+	attach(db DB) error
+	reservedPrefix() []byte
+}
+
+// GobDictionaryCodec returns a Codec for the [gob] format that stores the
+// type descriptions once, in a dictionary kept in the database of the Shelf,
+// instead of with every value. The values hold only the gob value stream
+// and the ID of their dictionary, which makes them much smaller, and faster
+// to decode, than the ones of [GobCodec], mostly for small structs.
+//
+// The dictionaries are stored under keys reserved by the Shelf, which are
+// hidden from its methods. The codec must be given directly to [WithCodec],
+// and can only be used by the Shelves of a single database. The values
+// written by GobCodec are still decoded, so it can replace GobCodec in an
+// existing Shelf.
+//
+// Since gob assigns the type IDs of a stream in the order in which the types
+// are first encoded by the process, the dictionaries are identified by a
+// hash of their content, and a new one is stored whenever the type
+// descriptions differ from the stored ones.
+func GobDictionaryCodec() Codec {
+	return &gobDictCodec{
+		dicts:    make(map[uint32][]byte),
+		encoders: make(map[reflect.Type]*gobDictEncoder),
+		decoders: make(map[uint32]*gobDictDecoder),
+	}
+}
+
+type gobDictCodec struct {
+	mu       sync.Mutex
+	db       DB
+	dicts    map[uint32][]byte
+	encoders map[reflect.Type]*gobDictEncoder
+	decoders map[uint32]*gobDictDecoder
+}
+
+// gobDictEncoder encodes the values of a type with a single gob stream, or
+// with a new stream for each value if the type is standalone. The dictionary
+// holds the type definitions sent in the stream so far.
+type gobDictEncoder struct {
+	mu         sync.Mutex
+	buf        bytes.Buffer
+	enc        *gob.Encoder
+	dict       []byte
+	id         uint32
+	stored     bool
+	standalone bool
+}
+
+// gobDictDecoder decodes the values of a dictionary with a gob stream that
+// starts with the type definitions of the dictionary. The stream is started
+// again for each value if the dictionary is standalone.
+type gobDictDecoder struct {
+	mu   sync.Mutex
+	buf  bytes.Buffer
+	dec  *gob.Decoder
+	dict []byte
+}
+
+func (c *gobDictCodec) attach(db DB) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.db != nil {
+		if c.db != db {
+			return errors.New("gob dictionary codec already attached to another database")
+		}
+		return nil
+	}
+
+	// Load the dictionaries now, since the database might not allow reads
+	// while it is iterated by the Shelf.
+	prefix := []byte(gobDictPrefix)
+	err := db.Items(prefix, Asc, func(k, v []byte) (bool, error) {
+		if !bytes.HasPrefix(k, prefix) {
+			return false, nil
+		}
+		if len(k) == len(prefix)+4 {
+			c.dicts[binary.BigEndian.Uint32(k[len(prefix):])] = bytes.Clone(v)
+		}
+		return true, nil
+	})
+	if err != nil {
+		return fmt.Errorf("load gob dictionaries: %w", err)
+	}
+	c.db = db
+	return nil
+}
+
+func (c *gobDictCodec) reservedPrefix() []byte {
+	return []byte(gobDictPrefix)
+}
+
+func (c *gobDictCodec) Encode(value any) ([]byte, error) {
+	e, err := c.encoder(reflect.TypeOf(value))
+	if err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.standalone {
+		e.reset()
+	}
+	e.buf.Reset()
+	if err = e.enc.Encode(value); err != nil {
+		// The stream might have recorded types it didn't send.
+		e.reset()
+		return nil, fmt.Errorf("encode gob: %w", err)
+	}
+	defs, msg, err := splitGobMessages(e.buf.Bytes())
+	if err != nil {
+		e.reset()
+		return nil, err
+	}
+	if len(defs) > 0 || !e.stored {
+		dict := append(e.dict[:len(e.dict):len(e.dict)], defs...)
+		id, err := c.store(dict)
+		if err != nil {
+			e.reset()
+			return nil, err
+		}
+		e.dict, e.id, e.stored = dict, id, true
+	}
+
+	out := make([]byte, 0, gobDictHeaderSize+len(msg))
+	out = append(out, gobDictTag)
+	out = binary.BigEndian.AppendUint32(out, e.id)
+	return append(out, msg...), nil
+}
+
+func (c *gobDictCodec) Decode(data []byte, value any) error {
+	if len(data) == 0 || data[0] != gobDictTag {
+		return GobCodec().Decode(data, value)
+	}
+	if len(data) < gobDictHeaderSize {
+		return errInvalidGobStream
+	}
+	d, err := c.decoder(binary.BigEndian.Uint32(data[1:]))
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.dict[0] == gobDictStandalone {
+		d.reset()
+	}
+	d.buf.Write(data[gobDictHeaderSize:])
+	if err = d.dec.Decode(value); err != nil {
+		// Start the stream again, since it might be left in the middle of
+		// a message.
+		d.reset()
+		return fmt.Errorf("decode gob: %w", err)
+	}
+	return nil
+}
+
+// encoder returns the encoder of the values of type t.
+func (c *gobDictCodec) encoder(t reflect.Type) (*gobDictEncoder, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.db == nil {
+		return nil, errCodecNotAttached
+	}
+	e, ok := c.encoders[t]
+	if !ok {
+		e = &gobDictEncoder{standalone: hasInterface(t, make(map[reflect.Type]bool))}
+		e.reset()
+		c.encoders[t] = e
+	}
+	return e, nil
+}
+
+// reset starts a new gob stream, which will send the type definitions again.
+func (e *gobDictEncoder) reset() {
+	e.buf.Reset()
+	e.enc = gob.NewEncoder(&e.buf)
+	e.dict = []byte{gobDictStream}
+	if e.standalone {
+		e.dict[0] = gobDictStandalone
+	}
+	e.stored = false
+}
+
+// hasInterface reports whether the values of type t can hold interface
+// values.
+func hasInterface(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if t == nil || seen[t] {
+		return false
+	}
+	seen[t] = true
+	switch t.Kind() {
+	case reflect.Interface:
+		return true
+	case reflect.Pointer, reflect.Slice, reflect.Array:
+		return hasInterface(t.Elem(), seen)
+	case reflect.Map:
+		return hasInterface(t.Key(), seen) || hasInterface(t.Elem(), seen)
+	case reflect.Struct:
+		for i := range t.NumField() {
+			if hasInterface(t.Field(i).Type, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// store stores the dictionary in the database, if it isn't stored yet, and
+// returns its ID.
+func (c *gobDictCodec) store(dict []byte) (uint32, error) {
+	sum := sha256.Sum256(dict)
+	id := binary.BigEndian.Uint32(sum[:])
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if stored, ok := c.dicts[id]; ok {
+		if !bytes.Equal(stored, dict) {
+			return 0, fmt.Errorf("gob dictionary ID collision: %d", id)
+		}
+		return id, nil
+	}
+	stored, err := c.db.Get(gobDictKey(id))
+	if err != nil {
+		return 0, fmt.Errorf("get gob dictionary: %w", err)
+	}
+	if stored == nil {
+		if err = c.db.Put(gobDictKey(id), dict); err != nil {
+			return 0, fmt.Errorf("put gob dictionary: %w", err)
+		}
+	} else if !bytes.Equal(stored, dict) {
+		return 0, fmt.Errorf("gob dictionary ID collision: %d", id)
+	}
+	c.dicts[id] = bytes.Clone(dict)
+	return id, nil
+}
+
+// decoder returns the decoder of the values of the dictionary with the
+// given ID, loading the dictionary from the database if needed.
+func (c *gobDictCodec) decoder(id uint32) (*gobDictDecoder, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if d, ok := c.decoders[id]; ok {
+		return d, nil
+	}
+	dict, ok := c.dicts[id]
+	if !ok {
+		if c.db == nil {
+			return nil, errCodecNotAttached
+		}
+		var err error
+		if dict, err = c.db.Get(gobDictKey(id)); err != nil {
+			return nil, fmt.Errorf("get gob dictionary: %w", err)
+		}
+		if len(dict) == 0 {
+			return nil, fmt.Errorf("%w: %d", ErrUnknownDictionary, id)
+		}
+		dict = bytes.Clone(dict)
+		c.dicts[id] = dict
+	}
+
+	d := &gobDictDecoder{dict: dict}
+	d.reset()
+	c.decoders[id] = d
+	return d, nil
+}
+
+// reset starts a new gob stream with the type definitions of the dictionary.
+func (d *gobDictDecoder) reset() {
+	d.buf.Reset()
+	d.buf.Write(d.dict[1:])
+	d.dec = gob.NewDecoder(&d.buf)
+}
+
+func gobDictKey(id uint32) []byte {
+	return binary.BigEndian.AppendUint32([]byte(gobDictPrefix), id)
+}
+
+// splitGobMessages splits the output of an Encode call of a gob stream into
+// the type definitions, whose type IDs are negative, and the value message.
+func splitGobMessages(data []byte) (defs, value []byte, err error) {
+	for len(data) > 0 {
+		n, size, ok := gobUint(data)
+		if !ok || n > uint64(len(data)-size) {
+			return nil, nil, errInvalidGobStream
+		}
+		msg := data[:size+int(n)]
+		id, _, ok := gobUint(data[size:])
+		if !ok {
+			return nil, nil, errInvalidGobStream
+		}
+		if id&1 == 1 {
+			// A negative type ID: a type definition.
+			defs = append(defs, msg...)
+		} else {
+			value = append(value, msg...)
+		}
+		data = data[len(msg):]
+	}
+	return defs, value, nil
+}
+
+// gobUint decodes an unsigned integer as encoded by gob: a value below 128
+// is stored in a byte, and larger values are stored big-endian, preceded by
+// their negated byte count.
+func gobUint(data []byte) (v uint64, size int, ok bool) {
+	if len(data) == 0 {
+		return 0, 0, false
+	}
+	if data[0] < 0x80 {
+		return uint64(data[0]), 1, true
+	}
+	n := -int(int8(data[0]))
+	if n > 8 || len(data) < 1+n {
+		return 0, 0, false
+	}
+	for _, b := range data[1 : 1+n] {
+		v = v<<8 | uint64(b)
+	}
+	return v, 1 + n, true
+}
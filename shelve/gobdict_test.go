@@ -0,0 +1,246 @@
+package shelve
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"maps"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+type gobTestValue struct {
+	Name string
+	Age  int
+}
+
+// newMapDB returns a MockDB that stores the items in a map.
+func newMapDB() *MockDB {
+	items := make(map[string][]byte)
+	return &MockDB{
+		GetFunc: func(key []byte) ([]byte, error) {
+			return items[string(key)], nil
+		},
+		PutFunc: func(key, value []byte) error {
+			items[string(key)] = bytes.Clone(value)
+			return nil
+		},
+		ItemsFunc: func(start []byte, order int, fn YieldData) error {
+			for _, k := range slices.Sorted(maps.Keys(items)) {
+				if k < string(start) {
+					continue
+				}
+				if ok, err := fn([]byte(k), items[k]); !ok || err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func newTestGobDictCodec(t *testing.T, db DB) Codec {
+	t.Helper()
+	codec := GobDictionaryCodec()
+	if err := codec.(attachedCodec).attach(db); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	return codec
+}
+
+func TestGobDictionaryCodec_Encode(t *testing.T) {
+	codec := newTestGobDictCodec(t, newMapDB())
+
+	// Run the tests in the suite
+	EncodeTest(t, codec)
+
+	t.Run("Encode Error", func(t *testing.T) {
+		_, err := codec.Encode(func() {})
+		if err == nil {
+			t.Errorf("Expected an error, but got nil")
+		}
+	})
+
+	t.Run("Smaller than gob", func(t *testing.T) {
+		value := gobTestValue{Name: "value", Age: 1}
+		for range 2 {
+			data, _ := codec.Encode(value)
+			plain, _ := GobCodec().Encode(value)
+			if len(data)*2 > len(plain) {
+				t.Errorf("Expected less than %d bytes, but got %d", len(plain)/2, len(data))
+			}
+		}
+	})
+
+	t.Run("Not attached", func(t *testing.T) {
+		_, err := GobDictionaryCodec().Encode("value")
+		if !errors.Is(err, errCodecNotAttached) {
+			t.Errorf("Expected errCodecNotAttached, but got %v", err)
+		}
+	})
+
+	t.Run("Put error", func(t *testing.T) {
+		db := newMapDB()
+		db.PutFunc = func([]byte, []byte) error { return TestError }
+		codec := newTestGobDictCodec(t, db)
+
+		_, err := codec.Encode(gobTestValue{})
+		if !errors.Is(err, TestError) {
+			t.Errorf("Expected TestError, but got %v", err)
+		}
+	})
+}
+
+func TestGobDictionaryCodec_Decode(t *testing.T) {
+	db := newMapDB()
+
+	// Run the tests in the suite
+	DecodeTest(t, newTestGobDictCodec(t, db))
+
+	t.Run("Other instance", func(t *testing.T) {
+		data, _ := newTestGobDictCodec(t, db).Encode(gobTestValue{Name: "value"})
+
+		var v gobTestValue
+		err := newTestGobDictCodec(t, db).Decode(data, &v)
+		if err != nil {
+			t.Errorf("Expected no error, but got %v", err)
+		}
+		if v.Name != "value" {
+			t.Errorf("Expected value, but got %q", v.Name)
+		}
+	})
+
+	t.Run("New types in the stream", func(t *testing.T) {
+		gob.Register(gobTestValue{})
+		codec := newTestGobDictCodec(t, db)
+		values := [][]any{{1}, {gobTestValue{Name: "value"}}, {1, gobTestValue{}}}
+		var encoded [][]byte
+		for _, v := range values {
+			data, err := codec.Encode(v)
+			if err != nil {
+				t.Fatalf("Expected no error, but got %v", err)
+			}
+			encoded = append(encoded, data)
+		}
+
+		for i, data := range encoded {
+			var v []any
+			err := newTestGobDictCodec(t, db).Decode(data, &v)
+			if err != nil {
+				t.Errorf("Expected no error, but got %v", err)
+			}
+			if !reflect.DeepEqual(v, values[i]) {
+				t.Errorf("Expected %v, but got %v", values[i], v)
+			}
+		}
+	})
+
+	t.Run("Gob values", func(t *testing.T) {
+		data, _ := GobCodec().Encode(gobTestValue{Name: "value"})
+
+		var v gobTestValue
+		err := newTestGobDictCodec(t, db).Decode(data, &v)
+		if err != nil || v.Name != "value" {
+			t.Errorf("Expected value, but got %q, %v", v.Name, err)
+		}
+	})
+
+	t.Run("Recovers from errors", func(t *testing.T) {
+		codec := newTestGobDictCodec(t, db)
+		data, _ := codec.Encode(gobTestValue{Name: "value"})
+
+		var s string
+		if err := codec.Decode(data[:len(data)-1], &s); err == nil {
+			t.Errorf("Expected an error, but got nil")
+		}
+		var v gobTestValue
+		if err := codec.Decode(data, &v); err != nil || v.Name != "value" {
+			t.Errorf("Expected value, but got %q, %v", v.Name, err)
+		}
+	})
+
+	t.Run("Unknown dictionary", func(t *testing.T) {
+		var v gobTestValue
+		data := []byte{gobDictTag, 0, 0, 0, 1, 0x03, 0x04, 0x00, 0x00}
+		err := newTestGobDictCodec(t, db).Decode(data, &v)
+		if !errors.Is(err, ErrUnknownDictionary) {
+			t.Errorf("Expected ErrUnknownDictionary, but got %v", err)
+		}
+	})
+
+	t.Run("Invalid header", func(t *testing.T) {
+		var v gobTestValue
+		err := newTestGobDictCodec(t, db).Decode([]byte{gobDictTag, 0}, &v)
+		if !errors.Is(err, errInvalidGobStream) {
+			t.Errorf("Expected errInvalidGobStream, but got %v", err)
+		}
+	})
+}
+
+func TestGobDictionaryCodec_Attach(t *testing.T) {
+	t.Run("Other database", func(t *testing.T) {
+		codec := newTestGobDictCodec(t, newMapDB())
+
+		err := codec.(attachedCodec).attach(newMapDB())
+		if err == nil {
+			t.Errorf("Expected an error, but got nil")
+		}
+	})
+
+	t.Run("Items error", func(t *testing.T) {
+		var db MockDB
+		db.ItemsFunc = func([]byte, int, YieldData) error { return TestError }
+
+		_, err := Open[string, string](TestDirectory,
+			WithDatabase(&db), WithCodec(GobDictionaryCodec()))
+		if !errors.Is(err, TestError) {
+			t.Errorf("Expected TestError, but got %v", err)
+		}
+	})
+}
+
+func TestShelf_GobDictionaryCodec(t *testing.T) {
+	// Arrange
+	seed := map[string]gobTestValue{
+		"a": {Name: "a", Age: 1},
+		"b": {Name: "b", Age: 2},
+	}
+	shelf := OpenTestShelfWith[string, gobTestValue](t, WithCodec(GobDictionaryCodec()))
+	defer shelf.Close()
+
+	// Act
+	for k, v := range seed {
+		if err := shelf.Put(k, v); err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+	}
+
+	// Assert
+	reopened, err := Open[string, gobTestValue](TestDirectory,
+		WithDatabase(shelf.db), WithCodec(GobDictionaryCodec()))
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	checkShelf(t, reopened, seed)
+	if n := reopened.Len(); n != 2 {
+		t.Errorf("Expected 2 items, but got %d", n)
+	}
+	err = reopened.Put(gobDictPrefix+"key", gobTestValue{})
+	if !errors.Is(err, ErrReservedKey) {
+		t.Errorf("Expected ErrReservedKey, but got %v", err)
+	}
+}
+
+func TestSplitGobMessages(t *testing.T) {
+	for _, data := range [][]byte{
+		{0x05, 0x01},
+		{0xf7, 0x01},
+		{0x01},
+	} {
+		_, _, err := splitGobMessages(data)
+		if !errors.Is(err, errInvalidGobStream) {
+			t.Errorf("%x: Expected errInvalidGobStream, but got %v", data, err)
+		}
+	}
+}
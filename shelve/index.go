@@ -0,0 +1,524 @@
+package shelve
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// indexPrefix starts the keys of the index entries in the database. The
+// entries of an index are stored under the prefix, followed by the index name,
+// a 0x00 byte and the index key encoded with TupleCodec. The keys of the
+// entries of non-unique indexes end with the key of the indexed item, which
+// is also stored as the value of the entries of both kinds of indexes.
+const indexPrefix = "\xff\xffidx\x00"
+
+var (
+	// ErrDuplicateIndexKey is returned when a write would add an index key
+	// that is already used by another item to a unique index.
+	ErrDuplicateIndexKey = errors.New("duplicate index key")
+
+	// ErrIndexNotFound is returned when the Shelf has no index with the
+	// given name.
+	ErrIndexNotFound = errors.New("index not found")
+
+	// ErrReservedKey is returned when a Shelf is given a key whose encoded
+	// form is reserved for the index entries, the schema version, or the
+	// data of the value codec.
+	ErrReservedKey = errors.New("key in the reserved namespace")
+)
+
+// IndexKey holds the components of a key in a secondary index of a Shelf,
+// returned by the extractor functions given to [WithIndex] and
+// [WithUniqueIndex]. The components are encoded like a [Tuple], so they must
+// have one of the types supported by [TupleCodec], and the index keys sort
+// in the order of their components.
+//
+// For example, an index of users by name could use the key
+// IndexKey{u.LastName, u.FirstName}, and be searched by the last name alone
+// with IndexKey{lastName}.
+type IndexKey []any
+
+type indexOption struct {
+	name   string
+	fn     any
+	unique bool
+}
+
+// index is a secondary index of a Shelf.
+type index[V any] struct {
+	name   string
+	fn     func(V) []IndexKey
+	unique bool
+	prefix []byte
+}
+
+// WithIndex declares a secondary index of the Shelf values, with the given
+// name. The fn function extracts the index keys of a value, and its
+// parameter must have the value type of the Shelf, or [Open] fails. A value
+// can have any number of index keys.
+//
+// The index entries are stored in the same database as the items, under a
+// reserved key namespace that is hidden from the Shelf methods. They are
+// updated together with the items by [Shelf.Put], [Shelf.Delete] and the
+// other methods that write to the Shelf, using a transaction (see
+// [Shelf.Transact]). The indexes must be declared every time the Shelf is
+// opened, and [Shelf.RebuildIndexes] must be used to index the existing
+// items when a new index is declared.
+//
+// The items can be looked up with [Shelf.ItemsByIndex],
+// [Shelf.ItemsIndexRange] and [Shelf.GetByIndex].
+func WithIndex[V any](name string, fn func(V) []IndexKey) Option {
+	return func(v any) {
+		opt := v.(*options)
+		opt.Indexes = append(opt.Indexes, indexOption{name: name, fn: fn})
+	}
+}
+
+// WithUniqueIndex works like [WithIndex], but declares an index whose keys
+// can be used by a single item. Writes that would add a key already used by
+// another item fail with [ErrDuplicateIndexKey].
+func WithUniqueIndex[V any](name string, fn func(V) []IndexKey) Option {
+	return func(v any) {
+		opt := v.(*options)
+		opt.Indexes = append(
+			opt.Indexes,
+			indexOption{name: name, fn: fn, unique: true},
+		)
+	}
+}
+
+// newIndexes creates the indexes declared with the options.
+func newIndexes[V any](opts []indexOption) ([]*index[V], error) {
+	var indexes []*index[V]
+	for _, o := range opts {
+		if o.name == "" || bytes.IndexByte([]byte(o.name), 0) >= 0 {
+			return nil, fmt.Errorf("invalid index name %q", o.name)
+		}
+		fn, ok := o.fn.(func(V) []IndexKey)
+		if !ok {
+			return nil, fmt.Errorf(
+				"index %q: extractor is %T, expected %T", o.name, o.fn, fn,
+			)
+		}
+		for _, idx := range indexes {
+			if idx.name == o.name {
+				return nil, fmt.Errorf("index %q declared twice", o.name)
+			}
+		}
+		indexes = append(indexes, &index[V]{
+			name:   o.name,
+			fn:     fn,
+			unique: o.unique,
+			prefix: []byte(indexPrefix + o.name + "\x00"),
+		})
+	}
+	return indexes, nil
+}
+
+// ItemsByIndex iterates over the items whose index keys, in the index with
+// the given name, start with the components of key. With all the
+// components, it iterates over the items with an exact match. The items are
+// yielded in the order of their index keys, and the n and step parameters
+// are the same as for [Shelf.Items].
+//
+// The matching index entries are collected before the items are read, so fn
+// can modify the Shelf. Index entries whose items no longer have the index
+// key are skipped.
+func (s *Shelf[K, V]) ItemsByIndex(name string, key IndexKey, n, step int, fn Yield[K, V]) error {
+	return s.itemsByIndex(context.Background(), name, key, n, step, fn)
+}
+
+func (s *Shelf[K, V]) itemsByIndex(
+	ctx context.Context,
+	name string,
+	key IndexKey,
+	n, step int,
+	fn Yield[K, V],
+) error {
+	idx, err := s.index(name)
+	if err != nil {
+		return err
+	}
+	prefix, err := idx.encodeKey(key)
+	if err != nil {
+		return fmt.Errorf("encode index key: %w", err)
+	}
+	return s.iterateIndex(ctx, idx, prefix, prefixEnd(prefix), n, step, fn)
+}
+
+// ItemsIndexRange works like [Shelf.ItemsByIndex], but iterates over the
+// items whose index keys are in the range between start (inclusive) and end
+// (exclusive). Either bound can be nil, leaving that side of the range open.
+// The bounds compare like with [Shelf.ItemsTupleRange] in ascending order,
+// regardless of the iteration order.
+//
+// An item with many index keys in the range is yielded once for each key.
+func (s *Shelf[K, V]) ItemsIndexRange(
+	name string,
+	start, end IndexKey,
+	n, step int,
+	fn Yield[K, V],
+) error {
+	return s.itemsIndexRange(context.Background(), name, start, end, n, step, fn)
+}
+
+func (s *Shelf[K, V]) itemsIndexRange(
+	ctx context.Context,
+	name string,
+	start, end IndexKey,
+	n, step int,
+	fn Yield[K, V],
+) error {
+	idx, err := s.index(name)
+	if err != nil {
+		return err
+	}
+	from, err := idx.encodeKey(start)
+	if err != nil {
+		return fmt.Errorf("encode start: %w", err)
+	}
+	to := prefixEnd(idx.prefix)
+	if end != nil {
+		to, err = idx.encodeKey(end)
+		if err != nil {
+			return fmt.Errorf("encode end: %w", err)
+		}
+	}
+	return s.iterateIndex(ctx, idx, from, to, n, step, fn)
+}
+
+// GetByIndex returns the first item whose index key, in the index with the
+// given name, starts with the components of key. If no item is found, it
+// returns false. It is most useful with unique indexes.
+func (s *Shelf[K, V]) GetByIndex(name string, key IndexKey) (k K, v V, ok bool, err error) {
+	return s.getByIndex(context.Background(), name, key)
+}
+
+func (s *Shelf[K, V]) getByIndex(
+	ctx context.Context,
+	name string,
+	key IndexKey,
+) (k K, v V, ok bool, err error) {
+	err = s.itemsByIndex(ctx, name, key, 1, Asc, func(key K, value V) (bool, error) {
+		k, v, ok = key, value, true
+		return false, nil
+	})
+	if err != nil {
+		return *new(K), *new(V), false, err
+	}
+	return k, v, ok, nil
+}
+
+// RebuildIndexes removes all the index entries from the database and indexes
+// the items again. It must be used to index the existing items when an index
+// is declared, and can be used to repair the indexes, like after items were
+// written to the database without the Shelf.
+//
+// The new entries are collected in memory and written with a [Batch], so the
+// rebuild is atomic if the database implements [BatchDB]. The Shelf must not
+// be modified during the rebuild. If the items have duplicate keys for a
+// unique index, the indexes are left unchanged and [ErrDuplicateIndexKey] is
+// returned.
+func (s *Shelf[K, V]) RebuildIndexes() error {
+	return s.rebuildIndexes(context.Background())
+}
+
+func (s *Shelf[K, V]) rebuildIndexes(ctx context.Context) error {
+	b := s.NewBatch()
+
+	err := s.itemsPrefix(ctx, []byte(indexPrefix), Asc, checkContext(ctx, func(k, _ []byte) (bool, error) {
+		// Copy, since some databases reuse the key buffer.
+		b.add(bytes.Clone(k), nil)
+		return true, nil
+	}))
+	if err != nil {
+		return fmt.Errorf("remove entries: %w", err)
+	}
+
+	owners := make(map[string][]byte)
+	err = s.iterateEncoded(ctx, nil, nil, All, Asc, func(k, v []byte) (bool, error) {
+		var value V
+		if err := s.decodeValue(k, v, &value); err != nil {
+			return false, fmt.Errorf("decode value: %w", err)
+		}
+		for _, idx := range s.indexes {
+			entries, err := idx.entries(k, value)
+			if err != nil {
+				return false, err
+			}
+			for _, e := range entries {
+				if owner, ok := owners[string(e)]; ok && !bytes.Equal(owner, k) {
+					return false, fmt.Errorf("%w: index %q", ErrDuplicateIndexKey, idx.name)
+				}
+				key := bytes.Clone(k)
+				if idx.unique {
+					owners[string(e)] = key
+				}
+				b.add(e, key)
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		return fmt.Errorf("index items: %w", err)
+	}
+	defer s.lockWrite()()
+	return b.write(ctx)
+}
+
+// index returns the index with the given name.
+func (s *Shelf[K, V]) index(name string) (*index[V], error) {
+	for _, idx := range s.indexes {
+		if idx.name == name {
+			return idx, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %q", ErrIndexNotFound, name)
+}
+
+// iterateIndex calls fn for the items of the index entries in the range
+// [from, to).
+func (s *Shelf[K, V]) iterateIndex(
+	ctx context.Context,
+	idx *index[V],
+	from, to []byte,
+	n, step int,
+	fn Yield[K, V],
+) error {
+	var value V
+	order, yield := paginate(n, step, func(k, _ []byte) (bool, error) {
+		var key K
+		if err := s.keyCodec.Decode(k, &key); err != nil {
+			return false, fmt.Errorf("decode key: %w", err)
+		}
+		return fn(key, value)
+	})
+	if order == 0 {
+		return nil
+	}
+
+	// Collect the entries first, since some databases don't allow reading
+	// other keys during an iteration.
+	var entries, keys [][]byte
+	err := s.itemsRange(ctx, from, to, Asc, checkContext(ctx, func(k, v []byte) (bool, error) {
+		// Copy, since some databases reuse the buffers.
+		entries = append(entries, bytes.Clone(k))
+		keys = append(keys, bytes.Clone(v))
+		return true, nil
+	}))
+	if err != nil {
+		return fmt.Errorf("iterate index: %w", err)
+	}
+	if order == Desc {
+		slices.Reverse(entries)
+		slices.Reverse(keys)
+	}
+
+	for i, k := range keys {
+		vData, err := s.getEncoded(ctx, k)
+		if err != nil {
+			return fmt.Errorf("get: %w", err)
+		}
+		if vData == nil {
+			continue
+		}
+		value = *new(V)
+		if err = s.decodeValue(k, vData, &value); err != nil {
+			return fmt.Errorf("decode value: %w", err)
+		}
+		current, err := idx.entries(k, value)
+		if err != nil {
+			return err
+		}
+		if !slices.ContainsFunc(current, func(e []byte) bool {
+			return bytes.Equal(e, entries[i])
+		}) {
+			// Stale entry.
+			continue
+		}
+		ok, err := yield(k, nil)
+		if err != nil || !ok {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeKey returns the prefix of the entries of the index with the given
+// key.
+func (idx *index[V]) encodeKey(key IndexKey) ([]byte, error) {
+	data, err := TupleCodec().Encode(Tuple(key))
+	if err != nil {
+		return nil, err
+	}
+	return append(bytes.Clone(idx.prefix), data...), nil
+}
+
+// entries returns the keys of the index entries of an item.
+func (idx *index[V]) entries(key []byte, value V) ([][]byte, error) {
+	var entries [][]byte
+	for _, k := range idx.fn(value) {
+		e, err := idx.encodeKey(k)
+		if err != nil {
+			return nil, fmt.Errorf("index %q: encode key: %w", idx.name, err)
+		}
+		if !idx.unique {
+			e = append(e, key...)
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// isReserved reports whether the encoded key is in the namespace of the index
+// entries, which is only reserved if the Shelf has indexes, is the key of the
+// schema version of a Shelf opened with WithSchema, or is reserved by the
+// value codec.
+func (s *Shelf[K, V]) isReserved(key []byte) bool {
+	return (len(s.indexes) > 0 && bytes.HasPrefix(key, []byte(indexPrefix))) ||
+		(s.schema != nil && string(key) == schemaKey) ||
+		(s.codecPrefix != nil && bytes.HasPrefix(key, s.codecPrefix))
+}
+
+// hasReserved reports whether the Shelf stores entries under reserved keys.
+func (s *Shelf[K, V]) hasReserved() bool {
+	return len(s.indexes) > 0 || s.schema != nil || s.codecPrefix != nil
+}
+
+// countReserved returns the number of entries stored under reserved keys:
+// the index entries, the schema version and the data of the value codec.
+func (s *Shelf[K, V]) countReserved(ctx context.Context) (int64, error) {
+	var count int64
+	countFn := checkContext(ctx, func(_, _ []byte) (bool, error) {
+		count++
+		return true, nil
+	})
+	if len(s.indexes) > 0 {
+		if err := s.itemsPrefix(ctx, []byte(indexPrefix), Asc, countFn); err != nil {
+			return 0, fmt.Errorf("count index entries: %w", err)
+		}
+	}
+	if s.codecPrefix != nil {
+		if err := s.itemsPrefix(ctx, s.codecPrefix, Asc, countFn); err != nil {
+			return 0, fmt.Errorf("count codec entries: %w", err)
+		}
+	}
+	if s.schema != nil {
+		ok, err := s.dbHas(ctx, []byte(schemaKey))
+		if err != nil {
+			return 0, fmt.Errorf("has schema version: %w", err)
+		}
+		if ok {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// skipReserved wraps fn so that the index entries, the schema version and the
+// keys reserved by the value codec are skipped.
+func (s *Shelf[K, V]) skipReserved(
+	fn func(k, v []byte) (bool, error),
+) func(k, v []byte) (bool, error) {
+	if !s.hasReserved() {
+		return fn
+	}
+	return func(k, v []byte) (bool, error) {
+		if s.isReserved(k) {
+			return true, nil
+		}
+		return fn(k, v)
+	}
+}
+
+// updateIndexes adds the changes to the index entries of the key to the
+// transaction, for the write of the encoded value, or its removal if value is
+// nil.
+func (tx *Tx[K, V]) updateIndexes(key, value []byte) error {
+	s := tx.shelf
+	old, err := tx.loadRaw(key)
+	if err != nil {
+		return fmt.Errorf("get: %w", err)
+	}
+	oldValue, oldOK, err := s.decodeStored(key, old)
+	if err != nil {
+		return err
+	}
+	newValue, newOK, err := s.decodeStored(key, value)
+	if err != nil {
+		return err
+	}
+
+	for _, idx := range s.indexes {
+		var oldEntries, newEntries [][]byte
+		if oldOK {
+			if oldEntries, err = idx.entries(key, oldValue); err != nil {
+				return err
+			}
+		}
+		if newOK {
+			if newEntries, err = idx.entries(key, newValue); err != nil {
+				return err
+			}
+		}
+		for _, e := range oldEntries {
+			if idx.unique {
+				// Keep the entries that belong to other items.
+				owner, err := tx.loadRaw(e)
+				if err != nil {
+					return fmt.Errorf("get: %w", err)
+				}
+				if !bytes.Equal(owner, key) {
+					continue
+				}
+			}
+			tx.writes.add(e, nil)
+		}
+		for _, e := range newEntries {
+			if idx.unique {
+				if err = tx.checkUnique(idx, e, key); err != nil {
+					return err
+				}
+			}
+			tx.writes.add(e, append([]byte{}, key...))
+		}
+	}
+	return nil
+}
+
+// checkUnique returns ErrDuplicateIndexKey if the entry of the unique index
+// belongs to an item other than key. Entries whose items no longer have the
+// index key are overwritten.
+func (tx *Tx[K, V]) checkUnique(idx *index[V], entry, key []byte) error {
+	owner, err := tx.loadRaw(entry)
+	if err != nil {
+		return fmt.Errorf("get: %w", err)
+	}
+	if owner == nil || bytes.Equal(owner, key) {
+		return nil
+	}
+	vData, err := tx.load(owner)
+	if err != nil {
+		return fmt.Errorf("get: %w", err)
+	}
+	if vData == nil {
+		return nil
+	}
+	var value V
+	if err = tx.shelf.decodeValue(owner, vData, &value); err != nil {
+		return fmt.Errorf("decode value: %w", err)
+	}
+	entries, err := idx.entries(owner, value)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if bytes.Equal(e, entry) {
+			return fmt.Errorf("%w: index %q", ErrDuplicateIndexKey, idx.name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,348 @@
+package shelve
+
+import (
+	"errors"
+	"testing"
+)
+
+type indexedUser struct {
+	Email string
+	Age   int
+	Tags  []string
+}
+
+func emailKeys(u indexedUser) []IndexKey { return []IndexKey{{u.Email}} }
+
+func tagKeys(u indexedUser) []IndexKey {
+	var keys []IndexKey
+	for _, tag := range u.Tags {
+		keys = append(keys, IndexKey{tag, u.Age})
+	}
+	return keys
+}
+
+func OpenIndexedShelf(t *testing.T) *Shelf[string, indexedUser] {
+	t.Helper()
+	return OpenTestShelfWith[string, indexedUser](
+		t,
+		WithUniqueIndex("email", emailKeys),
+		WithIndex("tag", tagKeys),
+	)
+}
+
+// collectIndex returns the keys of the items yielded by ItemsByIndex.
+func collectIndex(
+	t *testing.T,
+	shelf *Shelf[string, indexedUser],
+	name string,
+	key IndexKey,
+	n, step int,
+) []string {
+	t.Helper()
+	var keys []string
+	err := shelf.ItemsByIndex(name, key, n, step, func(k string, _ indexedUser) (bool, error) {
+		keys = append(keys, k)
+		return true, nil
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	return keys
+}
+
+func checkKeys(t *testing.T, expected, got []string) {
+	t.Helper()
+	if len(expected) != len(got) {
+		t.Fatalf("Expected %v, but got %v", expected, got)
+	}
+	for i := range expected {
+		if expected[i] != got[i] {
+			t.Fatalf("Expected %v, but got %v", expected, got)
+		}
+	}
+}
+
+func TestShelf_Open_Indexes(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{
+			name: "Wrong value type",
+			opts: []Option{WithIndex("email", func(u *indexedUser) []IndexKey { return nil })},
+		},
+		{
+			name: "Empty name",
+			opts: []Option{WithIndex("", emailKeys)},
+		},
+		{
+			name: "Invalid name",
+			opts: []Option{WithIndex("a\x00b", emailKeys)},
+		},
+		{
+			name: "Duplicate name",
+			opts: []Option{WithIndex("email", emailKeys), WithUniqueIndex("email", emailKeys)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append([]Option{WithDatabase(&MockDB{})}, tt.opts...)
+			_, err := Open[string, indexedUser](TestDirectory, opts...)
+			if err == nil {
+				t.Errorf("Expected error, but got nil")
+			}
+		})
+	}
+}
+
+func TestShelf_Indexes(t *testing.T) {
+	t.Run("Put and Delete update the indexes", func(t *testing.T) {
+		// Arrange
+		shelf := OpenIndexedShelf(t)
+		defer shelf.Close()
+
+		// Act
+		_ = shelf.Put("ann", indexedUser{Email: "ann@a.com", Age: 30, Tags: []string{"admin"}})
+		_ = shelf.Put("bob", indexedUser{Email: "bob@a.com", Age: 20, Tags: []string{"admin", "dev"}})
+		_ = shelf.Put("cid", indexedUser{Email: "cid@a.com", Age: 40, Tags: []string{"dev"}})
+		_ = shelf.Put("ann", indexedUser{Email: "ann@b.com", Age: 30, Tags: []string{"dev"}})
+		_ = shelf.Delete("cid")
+
+		// Assert
+		checkKeys(t, nil, collectIndex(t, shelf, "email", IndexKey{"ann@a.com"}, All, Asc))
+		checkKeys(t, []string{"ann"}, collectIndex(t, shelf, "email", IndexKey{"ann@b.com"}, All, Asc))
+		checkKeys(t, nil, collectIndex(t, shelf, "email", IndexKey{"cid@a.com"}, All, Asc))
+		checkKeys(t, []string{"bob"}, collectIndex(t, shelf, "tag", IndexKey{"admin"}, All, Asc))
+		checkKeys(t, []string{"bob", "ann"}, collectIndex(t, shelf, "tag", IndexKey{"dev"}, All, Asc))
+		checkKeys(t, []string{"ann", "bob"}, collectIndex(t, shelf, "tag", IndexKey{"dev"}, All, Desc))
+		checkKeys(t, []string{"bob"}, collectIndex(t, shelf, "tag", IndexKey{"dev"}, 1, Asc))
+		checkKeys(t, []string{"ann"}, collectIndex(t, shelf, "tag", IndexKey{"dev", 30}, All, Asc))
+
+		// The index entries are hidden from the Shelf.
+		if n := shelf.Len(); n != 2 {
+			t.Errorf("Expected 2 items, but got %d", n)
+		}
+		var keys []string
+		items, _ := shelf.Backward()
+		for k := range items {
+			keys = append(keys, k)
+		}
+		checkKeys(t, []string{"bob", "ann"}, keys)
+	})
+
+	t.Run("Range", func(t *testing.T) {
+		// Arrange
+		shelf := OpenIndexedShelf(t)
+		defer shelf.Close()
+		for k, age := range map[string]int{"a": 10, "b": 20, "c": 30, "d": 40} {
+			_ = shelf.Put(k, indexedUser{Email: k, Age: age, Tags: []string{"x"}})
+		}
+
+		// Act
+		var keys []string
+		err := shelf.ItemsIndexRange(
+			"tag", IndexKey{"x", 20}, IndexKey{"x", 40}, All, Desc,
+			func(k string, _ indexedUser) (bool, error) {
+				keys = append(keys, k)
+				return true, nil
+			},
+		)
+
+		// Assert
+		if err != nil {
+			t.Errorf("Expected no error, but got %v", err)
+		}
+		checkKeys(t, []string{"c", "b"}, keys)
+	})
+
+	t.Run("Unique index", func(t *testing.T) {
+		// Arrange
+		shelf := OpenIndexedShelf(t)
+		defer shelf.Close()
+		_ = shelf.Put("ann", indexedUser{Email: "ann@a.com"})
+
+		// Act
+		err := shelf.Put("bob", indexedUser{Email: "ann@a.com"})
+
+		// Assert
+		if !errors.Is(err, ErrDuplicateIndexKey) {
+			t.Errorf("Expected ErrDuplicateIndexKey, but got %v", err)
+		}
+		if ok, _ := shelf.Has("bob"); ok {
+			t.Errorf("Expected bob to not be stored")
+		}
+		if err = shelf.Put("ann", indexedUser{Email: "ann@a.com", Age: 1}); err != nil {
+			t.Errorf("Expected no error, but got %v", err)
+		}
+		_ = shelf.Delete("ann")
+		if err = shelf.Put("bob", indexedUser{Email: "ann@a.com"}); err != nil {
+			t.Errorf("Expected no error, but got %v", err)
+		}
+		k, _, ok, err := shelf.GetByIndex("email", IndexKey{"ann@a.com"})
+		if err != nil || !ok || k != "bob" {
+			t.Errorf("Expected bob, but got %v, %v, %v", k, ok, err)
+		}
+	})
+
+	t.Run("Batch and transactions", func(t *testing.T) {
+		// Arrange
+		shelf := OpenIndexedShelf(t)
+		defer shelf.Close()
+
+		// Act
+		b := shelf.NewBatch()
+		_ = b.Put("ann", indexedUser{Email: "ann@a.com"})
+		_ = b.Put("bob", indexedUser{Email: "bob@a.com"})
+		err1 := b.Write()
+		err2 := shelf.Transact(func(tx *Tx[string, indexedUser]) error {
+			if err := tx.Delete("ann"); err != nil {
+				return err
+			}
+			return tx.Put("cid", indexedUser{Email: "ann@a.com"})
+		})
+		b.Reset()
+		_ = b.Put("dan", indexedUser{Email: "bob@a.com"})
+		err3 := b.Write()
+
+		// Assert
+		if err1 != nil || err2 != nil {
+			t.Fatalf("Expected no error, but got %v, %v", err1, err2)
+		}
+		if !errors.Is(err3, ErrDuplicateIndexKey) {
+			t.Errorf("Expected ErrDuplicateIndexKey, but got %v", err3)
+		}
+		checkKeys(t, []string{"cid"}, collectIndex(t, shelf, "email", IndexKey{"ann@a.com"}, All, Asc))
+		checkKeys(t, []string{"bob"}, collectIndex(t, shelf, "email", IndexKey{"bob@a.com"}, All, Asc))
+	})
+
+	t.Run("DeletePrefix", func(t *testing.T) {
+		shelf := OpenIndexedShelf(t)
+		defer shelf.Close()
+		_ = shelf.Put("user-1", indexedUser{Email: "1", Tags: []string{"x"}})
+		_ = shelf.Put("user-2", indexedUser{Email: "2", Tags: []string{"x"}})
+
+		n, err := shelf.DeletePrefix("user-")
+		if err != nil || n != 2 {
+			t.Errorf("Expected 2 deleted items, but got %d, %v", n, err)
+		}
+		if n := shelf.db.Len(); n != 0 {
+			t.Errorf("Expected an empty database, but got %d keys", n)
+		}
+	})
+
+	t.Run("Stale entries", func(t *testing.T) {
+		// Arrange
+		shelf := OpenIndexedShelf(t)
+		defer shelf.Close()
+		_ = shelf.Put("ann", indexedUser{Email: "ann@a.com", Tags: []string{"x"}})
+
+		// Act: Modify the item without the Shelf.
+		_ = shelf.db.Put([]byte("ann"), []byte(`{"Email":"ann@b.com"}`))
+
+		// Assert
+		checkKeys(t, nil, collectIndex(t, shelf, "tag", IndexKey{"x"}, All, Asc))
+		checkKeys(t, nil, collectIndex(t, shelf, "email", IndexKey{"ann@a.com"}, All, Asc))
+		if err := shelf.Put("bob", indexedUser{Email: "ann@a.com"}); err != nil {
+			t.Errorf("Expected no error, but got %v", err)
+		}
+	})
+
+	t.Run("Reserved key", func(t *testing.T) {
+		shelf := OpenIndexedShelf(t)
+		defer shelf.Close()
+
+		err := shelf.Put(indexPrefix+"key", indexedUser{})
+		if !errors.Is(err, ErrReservedKey) {
+			t.Errorf("Expected ErrReservedKey, but got %v", err)
+		}
+	})
+
+	t.Run("Index not found", func(t *testing.T) {
+		shelf := OpenIndexedShelf(t)
+		defer shelf.Close()
+
+		_, _, _, err := shelf.GetByIndex("name", IndexKey{"ann"})
+		if !errors.Is(err, ErrIndexNotFound) {
+			t.Errorf("Expected ErrIndexNotFound, but got %v", err)
+		}
+		err = shelf.ItemsIndexRange("name", nil, nil, All, Asc, nil)
+		if !errors.Is(err, ErrIndexNotFound) {
+			t.Errorf("Expected ErrIndexNotFound, but got %v", err)
+		}
+	})
+
+	t.Run("Invalid index key", func(t *testing.T) {
+		shelf := OpenIndexedShelf(t)
+		defer shelf.Close()
+
+		err := shelf.ItemsByIndex("email", IndexKey{nil}, All, Asc, nil)
+		if err == nil {
+			t.Errorf("Expected error, but got nil")
+		}
+	})
+
+	t.Run("Get error", func(t *testing.T) {
+		var db MockTxDB
+		db.GetFunc = func(key []byte) ([]byte, error) { return nil, TestError }
+		shelf, err := Open[string, indexedUser](
+			TestDirectory,
+			WithDatabase(&db),
+			WithIndex("email", emailKeys),
+		)
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+
+		err = shelf.Put("ann", indexedUser{Email: "ann@a.com"})
+		if !errors.Is(err, TestError) {
+			t.Errorf("Expected error %v, but got %v", TestError, err)
+		}
+	})
+}
+
+func TestShelf_RebuildIndexes(t *testing.T) {
+	t.Run("Indexes the existing items", func(t *testing.T) {
+		// Arrange
+		shelf := OpenIndexedShelf(t)
+		defer shelf.Close()
+		_ = shelf.Put("ann", indexedUser{Email: "ann@a.com", Tags: []string{"x"}})
+
+		// Items written without the Shelf, and a stale entry.
+		_ = shelf.db.Put([]byte("bob"), []byte(`{"Email":"bob@a.com","Tags":["x"]}`))
+		_ = shelf.db.Put([]byte("ann"), []byte(`{"Email":"ann@b.com"}`))
+
+		// Act
+		err := shelf.RebuildIndexes()
+
+		// Assert
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		checkKeys(t, []string{"bob"}, collectIndex(t, shelf, "tag", IndexKey{"x"}, All, Asc))
+		checkKeys(t, []string{"ann"}, collectIndex(t, shelf, "email", IndexKey{"ann@b.com"}, All, Asc))
+		checkKeys(t, []string{"bob"}, collectIndex(t, shelf, "email", IndexKey{"bob@a.com"}, All, Asc))
+
+		// Two entries for bob, one for the email and one for the tag, and
+		// one for ann.
+		if n := shelf.db.Len(); n != 5 {
+			t.Errorf("Expected 5 keys, but got %d", n)
+		}
+	})
+
+	t.Run("Duplicate keys", func(t *testing.T) {
+		// Arrange
+		shelf := OpenIndexedShelf(t)
+		defer shelf.Close()
+		_ = shelf.Put("ann", indexedUser{Email: "ann@a.com"})
+		_ = shelf.db.Put([]byte("bob"), []byte(`{"Email":"ann@a.com"}`))
+
+		// Act
+		err := shelf.RebuildIndexes()
+
+		// Assert
+		if !errors.Is(err, ErrDuplicateIndexKey) {
+			t.Errorf("Expected ErrDuplicateIndexKey, but got %v", err)
+		}
+		checkKeys(t, []string{"ann"}, collectIndex(t, shelf, "email", IndexKey{"ann@a.com"}, All, Asc))
+	})
+}
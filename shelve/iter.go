@@ -0,0 +1,97 @@
+package shelve
+
+import "iter"
+
+// All returns an iterator over all key-value pairs in the Shelf, in ascending
+// order, and a function that returns the error that stopped the iteration.
+// It is the range-over-func counterpart of [Shelf.Items] and can be used in a
+// for-range loop or with the helpers from the standard library, like
+// [maps.Collect]:
+//
+//	items, errf := shelf.All()
+//	for k, v := range items {
+//	    ...
+//	}
+//	if err := errf(); err != nil {
+//	    ...
+//	}
+//
+// The error function returns the error that stopped the last loop over the
+// iterator (e.g., a decoding error), and nil if the loop completed or was
+// stopped by its body. Each call returns a new iterator with its own error,
+// so concurrent iterations don't affect each other, but a single iterator
+// must not be used by multiple goroutines at once.
+//
+// The details of the iteration order are the same as for [Shelf.Items].
+// With the default database, the loop body must not modify the Shelf, as
+// this would cause a deadlock.
+func (s *Shelf[K, V]) All() (iter.Seq2[K, V], func() error) {
+	return seq2(func(fn Yield[K, V]) error {
+		return s.Items(nil, All, Asc, fn)
+	})
+}
+
+// Backward returns an iterator over all key-value pairs in the Shelf, in
+// descending order. The details of the iteration are the same as for
+// [Shelf.All].
+func (s *Shelf[K, V]) Backward() (iter.Seq2[K, V], func() error) {
+	return seq2(func(fn Yield[K, V]) error {
+		return s.Items(nil, All, Desc, fn)
+	})
+}
+
+// From returns an iterator over the key-value pairs in the Shelf, in
+// ascending order, starting from the start key (inclusive). The details of
+// the iteration are the same as for [Shelf.All].
+func (s *Shelf[K, V]) From(start K) (iter.Seq2[K, V], func() error) {
+	return seq2(func(fn Yield[K, V]) error {
+		return s.Items(&start, All, Asc, fn)
+	})
+}
+
+// Prefix returns an iterator over the key-value pairs in the Shelf whose keys
+// start with the given prefix, in ascending order. The details of the prefix
+// matching are the same as for [Shelf.ItemsPrefix], and the details of the
+// iteration are the same as for [Shelf.All].
+func (s *Shelf[K, V]) Prefix(prefix K) (iter.Seq2[K, V], func() error) {
+	return seq2(func(fn Yield[K, V]) error {
+		return s.ItemsPrefix(prefix, All, Asc, fn)
+	})
+}
+
+// AllKeys returns an iterator over all keys in the Shelf, in ascending order.
+// The details of the iteration are the same as for [Shelf.All].
+func (s *Shelf[K, V]) AllKeys() (iter.Seq[K], func() error) {
+	var err error
+	keys := func(yield func(K) bool) {
+		err = s.Keys(nil, All, Asc, func(key K, _ V) (bool, error) {
+			return yield(key), nil
+		})
+	}
+	return keys, func() error { return err }
+}
+
+// AllValues returns an iterator over all values in the Shelf, in ascending
+// order of their keys. The details of the iteration are the same as for
+// [Shelf.All].
+func (s *Shelf[K, V]) AllValues() (iter.Seq[V], func() error) {
+	var err error
+	values := func(yield func(V) bool) {
+		err = s.Values(nil, All, Asc, func(_ K, value V) (bool, error) {
+			return yield(value), nil
+		})
+	}
+	return values, func() error { return err }
+}
+
+// seq2 returns an iterator over the items yielded by iterate, and a function
+// that returns the error of its last run.
+func seq2[K, V any](iterate func(fn Yield[K, V]) error) (iter.Seq2[K, V], func() error) {
+	var err error
+	items := func(yield func(K, V) bool) {
+		err = iterate(func(key K, value V) (bool, error) {
+			return yield(key, value), nil
+		})
+	}
+	return items, func() error { return err }
+}
@@ -0,0 +1,164 @@
+package shelve
+
+import (
+	"errors"
+	"maps"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestShelf_All(t *testing.T) {
+	seed := map[string]string{
+		"key-1": "value-1", "key-2": "value-2",
+		"key-3": "value-3", "key-4": "value-4",
+	}
+
+	t.Run("All", func(t *testing.T) {
+		var db MockDB
+		db.ItemsFunc = NewMockItemsFunc(MakeItems(seed))
+		shelf := NewTestShelf(t, WithDatabase(&db))
+
+		items, errf := shelf.All()
+		got := maps.Collect(items)
+
+		if !reflect.DeepEqual(got, seed) {
+			t.Errorf("Expected %v, but got %v", seed, got)
+		}
+		if err := errf(); err != nil {
+			t.Errorf("Expected no error, but got %v", err)
+		}
+	})
+
+	t.Run("Backward", func(t *testing.T) {
+		var db MockDB
+		db.ItemsFunc = NewMockItemsFunc(MakeItems(seed))
+		shelf := NewTestShelf(t, WithDatabase(&db))
+
+		var got []string
+		items, _ := shelf.Backward()
+		for k := range items {
+			got = append(got, k)
+		}
+
+		expected := []string{"key-4", "key-3", "key-2", "key-1"}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %v, but got %v", expected, got)
+		}
+	})
+
+	t.Run("From", func(t *testing.T) {
+		var db MockDB
+		db.ItemsFunc = NewMockItemsFunc(MakeItems(seed))
+		shelf := NewTestShelf(t, WithDatabase(&db))
+
+		var got []string
+		items, _ := shelf.From("key-3")
+		for k, v := range items {
+			got = append(got, k, v)
+		}
+
+		expected := []string{"key-3", "value-3", "key-4", "value-4"}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %v, but got %v", expected, got)
+		}
+	})
+
+	t.Run("AllKeys", func(t *testing.T) {
+		var db MockDB
+		db.ItemsFunc = NewMockItemsFunc(MakeItems(seed))
+		shelf := NewTestShelf(t, WithDatabase(&db))
+
+		keys, _ := shelf.AllKeys()
+		got := slices.Collect(keys)
+
+		expected := slices.Sorted(maps.Keys(seed))
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %v, but got %v", expected, got)
+		}
+	})
+
+	t.Run("AllValues", func(t *testing.T) {
+		var db MockDB
+		db.ItemsFunc = NewMockItemsFunc(MakeItems(seed))
+		shelf := NewTestShelf(t, WithDatabase(&db))
+
+		values, _ := shelf.AllValues()
+		got := slices.Collect(values)
+
+		expected := slices.Sorted(maps.Values(seed))
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %v, but got %v", expected, got)
+		}
+	})
+
+	t.Run("Break", func(t *testing.T) {
+		var db MockDB
+		db.ItemsFunc = NewMockItemsFunc(MakeItems(seed))
+		shelf := NewTestShelf(t, WithDatabase(&db))
+
+		var got []string
+		items, errf := shelf.All()
+		for k := range items {
+			got = append(got, k)
+			if len(got) == 2 {
+				break
+			}
+		}
+
+		expected := []string{"key-1", "key-2"}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %v, but got %v", expected, got)
+		}
+		if err := errf(); err != nil {
+			t.Errorf("Expected no error, but got %v", err)
+		}
+	})
+}
+
+func TestShelf_All_Error(t *testing.T) {
+	t.Run("DB error", func(t *testing.T) {
+		var db MockDB
+		db.ItemsFunc = func(_ []byte, _ int, _ YieldData) error {
+			return TestError
+		}
+		shelf := NewTestShelf(t, WithDatabase(&db))
+
+		items, errf := shelf.All()
+		for range items {
+			t.Errorf("Expected no items")
+		}
+
+		if err := errf(); !errors.Is(err, TestError) {
+			t.Errorf("Expected error %v, but got %v", TestError, err)
+		}
+	})
+
+	t.Run("Decode error", func(t *testing.T) {
+		var db MockDB
+		var codec MockCodec
+		db.ItemsFunc = NewMockItemsFunc(MakeItems(map[string]string{
+			"key-1": "value-1",
+		}))
+		codec.DecodeFunc = func(_ []byte, _ any) error {
+			return TestError
+		}
+		shelf := NewTestShelf(t, WithDatabase(&db), WithCodec(&codec))
+
+		values, errf := shelf.AllValues()
+		for range values {
+			t.Errorf("Expected no items")
+		}
+
+		// The error is kept by the iterator, even after another one succeeds
+		keys, keysErrf := shelf.AllKeys()
+		for range keys {
+		}
+		if err := errf(); !errors.Is(err, TestError) {
+			t.Errorf("Expected error %v, but got %v", TestError, err)
+		}
+		if err := keysErrf(); err != nil {
+			t.Errorf("Expected no error, but got %v", err)
+		}
+	})
+}
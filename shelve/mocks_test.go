@@ -1,5 +1,10 @@
 package shelve
 
+import (
+	"context"
+	"time"
+)
+
 // MockDB is a mock implementation of the DB interface.
 type MockDB struct {
 	CloseFunc  func() error
@@ -88,6 +93,58 @@ func (m *MockDB) Items(
 	return nil
 }
 
+// MockRangeDB is a mock implementation of the RangeDB interface.
+type MockRangeDB struct {
+	MockDB
+
+	ItemsRangeFunc func(
+		start, end []byte,
+		order int,
+		fn func(key, value []byte) (bool, error),
+	) error
+}
+
+// Assert that MockRangeDB implements the RangeDB interface.
+var _ RangeDB = (*MockRangeDB)(nil)
+
+// ItemsRange mocks the ItemsRange method of the RangeDB interface.
+func (m *MockRangeDB) ItemsRange(
+	start, end []byte,
+	order int,
+	fn func(key, value []byte) (bool, error),
+) error {
+	if m.ItemsRangeFunc != nil {
+		return m.ItemsRangeFunc(start, end, order, fn)
+	}
+	return nil
+}
+
+// MockPrefixDB is a mock implementation of the PrefixDB interface.
+type MockPrefixDB struct {
+	MockDB
+
+	ItemsPrefixFunc func(
+		prefix []byte,
+		order int,
+		fn func(key, value []byte) (bool, error),
+	) error
+}
+
+// Assert that MockPrefixDB implements the PrefixDB interface.
+var _ PrefixDB = (*MockPrefixDB)(nil)
+
+// ItemsPrefix mocks the ItemsPrefix method of the PrefixDB interface.
+func (m *MockPrefixDB) ItemsPrefix(
+	prefix []byte,
+	order int,
+	fn func(key, value []byte) (bool, error),
+) error {
+	if m.ItemsPrefixFunc != nil {
+		return m.ItemsPrefixFunc(prefix, order, fn)
+	}
+	return nil
+}
+
 // MockCodec is a mock implementation of the Codec interface.
 type MockCodec struct {
 	EncodeFunc func(value any) ([]byte, error)
@@ -112,3 +169,153 @@ func (m *MockCodec) Decode(data []byte, value any) error {
 	}
 	return nil
 }
+
+// MockCompressor is a mock implementation of the Compressor interface. Its
+// ID is 16, unless IDFunc is set.
+type MockCompressor struct {
+	IDFunc         func() byte
+	CompressFunc   func(data []byte) ([]byte, error)
+	DecompressFunc func(data []byte, size int) ([]byte, error)
+}
+
+// Assert that MockCompressor implements the Compressor interface.
+var _ Compressor = (*MockCompressor)(nil)
+
+// ID mocks the ID method of the Compressor interface.
+func (m *MockCompressor) ID() byte {
+	if m.IDFunc != nil {
+		return m.IDFunc()
+	}
+	return 16
+}
+
+// Compress mocks the Compress method of the Compressor interface.
+func (m *MockCompressor) Compress(data []byte) ([]byte, error) {
+	if m.CompressFunc != nil {
+		return m.CompressFunc(data)
+	}
+	return data, nil
+}
+
+// Decompress mocks the Decompress method of the Compressor interface.
+func (m *MockCompressor) Decompress(data []byte, size int) ([]byte, error) {
+	if m.DecompressFunc != nil {
+		return m.DecompressFunc(data, size)
+	}
+	return data, nil
+}
+
+// MockBatchDB is a mock implementation of the BatchDB interface.
+type MockBatchDB struct {
+	MockDB
+
+	WriteBatchFunc func(keys, values [][]byte) error
+}
+
+// Assert that MockBatchDB implements the BatchDB interface.
+var _ BatchDB = (*MockBatchDB)(nil)
+
+// WriteBatch mocks the WriteBatch method of the BatchDB interface.
+func (m *MockBatchDB) WriteBatch(keys, values [][]byte) error {
+	if m.WriteBatchFunc != nil {
+		return m.WriteBatchFunc(keys, values)
+	}
+	return nil
+}
+
+// MockTxDB is a mock implementation of the TxDB interface.
+type MockTxDB struct {
+	MockBatchDB
+
+	WriteBatchIfFunc func(checkKeys, checkValues, keys, values [][]byte) (bool, error)
+}
+
+// Assert that MockTxDB implements the TxDB interface.
+var _ TxDB = (*MockTxDB)(nil)
+
+// WriteBatchIf mocks the WriteBatchIf method of the TxDB interface.
+func (m *MockTxDB) WriteBatchIf(checkKeys, checkValues, keys, values [][]byte) (bool, error) {
+	if m.WriteBatchIfFunc != nil {
+		return m.WriteBatchIfFunc(checkKeys, checkValues, keys, values)
+	}
+	return true, nil
+}
+
+// MockTTLDB is a mock implementation of the TTLDB interface.
+type MockTTLDB struct {
+	MockDB
+
+	PutWithTTLFunc func(key, value []byte, ttl time.Duration) error
+}
+
+// Assert that MockTTLDB implements the TTLDB interface.
+var _ TTLDB = (*MockTTLDB)(nil)
+
+// PutWithTTL mocks the PutWithTTL method of the TTLDB interface.
+func (m *MockTTLDB) PutWithTTL(key, value []byte, ttl time.Duration) error {
+	if m.PutWithTTLFunc != nil {
+		return m.PutWithTTLFunc(key, value, ttl)
+	}
+	return nil
+}
+
+// MockContextDB is a mock implementation of the ContextDB interface. Its
+// methods record the context they are given in Contexts, and then call the
+// methods of MockDB. The end bound of ItemsRangeContext is ignored, and the
+// prefix of ItemsPrefixContext is used as the start of the iteration.
+type MockContextDB struct {
+	MockDB
+
+	Contexts []context.Context
+}
+
+// Assert that MockContextDB implements the ContextDB interface.
+var _ ContextDB = (*MockContextDB)(nil)
+
+// HasContext mocks the HasContext method of the ContextDB interface.
+func (m *MockContextDB) HasContext(ctx context.Context, key []byte) (bool, error) {
+	m.Contexts = append(m.Contexts, ctx)
+	return m.Has(key)
+}
+
+// GetContext mocks the GetContext method of the ContextDB interface.
+func (m *MockContextDB) GetContext(ctx context.Context, key []byte) ([]byte, error) {
+	m.Contexts = append(m.Contexts, ctx)
+	return m.Get(key)
+}
+
+// PutContext mocks the PutContext method of the ContextDB interface.
+func (m *MockContextDB) PutContext(ctx context.Context, key, value []byte) error {
+	m.Contexts = append(m.Contexts, ctx)
+	return m.Put(key, value)
+}
+
+// DeleteContext mocks the DeleteContext method of the ContextDB interface.
+func (m *MockContextDB) DeleteContext(ctx context.Context, key []byte) error {
+	m.Contexts = append(m.Contexts, ctx)
+	return m.Delete(key)
+}
+
+// ItemsRangeContext mocks the ItemsRangeContext method of the ContextDB
+// interface.
+func (m *MockContextDB) ItemsRangeContext(
+	ctx context.Context,
+	start, _ []byte,
+	order int,
+	fn func(key, value []byte) (bool, error),
+) error {
+	m.Contexts = append(m.Contexts, ctx)
+	return m.Items(start, order, fn)
+}
+
+// ItemsPrefixContext mocks the ItemsPrefixContext method of the ContextDB
+// interface.
+func (m *MockContextDB) ItemsPrefixContext(
+	ctx context.Context,
+	prefix []byte,
+	order int,
+	fn func(key, value []byte) (bool, error),
+) error {
+	m.Contexts = append(m.Contexts, ctx)
+	return m.Items(prefix, order, fn)
+}
@@ -0,0 +1,100 @@
+package shelve
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/lucmq/go-shelve/sdb"
+)
+
+// ErrNamespacesUnsupported is returned when the database of a namespace
+// function doesn't support namespaces.
+var ErrNamespacesUnsupported = errors.New("database doesn't support namespaces")
+
+// NamespaceDB is an optional interface that can be implemented by a DB to
+// hold separate keyspaces, which are used by [OpenNamespace]. The default
+// database, [sdb.DB], supports namespaces natively.
+type NamespaceDB interface {
+	DB
+
+	// Namespace returns the database of the namespace with the given name,
+	// creating it if it doesn't exist. Its keys, its length and its
+	// iterations must be independent of the ones of the database and of the
+	// other namespaces. Closing the namespace must not close the database.
+	Namespace(name string) (DB, error)
+
+	// Namespaces returns the names of the namespaces, sorted.
+	Namespaces() ([]string, error)
+
+	// DropNamespace deletes the namespace with the given name and all its
+	// keys. Dropping a namespace that doesn't exist is not an error.
+	DropNamespace(name string) error
+}
+
+// OpenNamespace creates a Shelf for the namespace with the given name of the
+// database, creating the namespace if it doesn't exist. Many Shelves, with
+// different key and value types, can be opened on the namespaces of a single
+// database, each with its own keys, length and iterations.
+//
+// The database must implement [NamespaceDB], or be an [sdb.DB], or the
+// function returns [ErrNamespacesUnsupported]. Closing the Shelf closes the
+// namespace, but not the database, which must be closed once all the Shelves
+// are no longer used. The options are the same as for [Open], except that
+// [WithDatabase] is ignored.
+func OpenNamespace[K comparable, V any](db DB, name string, opts ...Option) (*Shelf[K, V], error) {
+	nsdb, err := namespaceDB(db)
+	if err != nil {
+		return nil, err
+	}
+	ns, err := nsdb.Namespace(name)
+	if err != nil {
+		return nil, fmt.Errorf("open namespace: %w", err)
+	}
+	opts = append(opts[:len(opts):len(opts)], WithDatabase(ns))
+	return Open[K, V]("", opts...)
+}
+
+// Namespaces returns the names of the namespaces of the database, sorted. The
+// database must support namespaces, like for [OpenNamespace].
+func Namespaces(db DB) ([]string, error) {
+	nsdb, err := namespaceDB(db)
+	if err != nil {
+		return nil, err
+	}
+	return nsdb.Namespaces()
+}
+
+// DropNamespace deletes the namespace with the given name of the database,
+// with all its items. The Shelves opened on the namespace can't be used
+// afterward. The database must support namespaces, like for [OpenNamespace].
+func DropNamespace(db DB, name string) error {
+	nsdb, err := namespaceDB(db)
+	if err != nil {
+		return err
+	}
+	return nsdb.DropNamespace(name)
+}
+
+func namespaceDB(db DB) (NamespaceDB, error) {
+	switch db := db.(type) {
+	case NamespaceDB:
+		return db, nil
+	case *sdb.DB:
+		return sdbNamespaces{db}, nil
+	}
+	return nil, fmt.Errorf("%w: %T", ErrNamespacesUnsupported, db)
+}
+
+// sdbNamespaces adapts the namespaces of sdb to NamespaceDB, since sdb
+// returns its own type.
+type sdbNamespaces struct {
+	*sdb.DB
+}
+
+func (db sdbNamespaces) Namespace(name string) (DB, error) {
+	ns, err := db.DB.Namespace(name)
+	if err != nil {
+		return nil, err
+	}
+	return ns, nil
+}
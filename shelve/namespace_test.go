@@ -0,0 +1,123 @@
+package shelve
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/lucmq/go-shelve/sdb"
+)
+
+func openTestNamespaceDB(t *testing.T) *sdb.DB {
+	t.Helper()
+	if err := os.RemoveAll(TestDirectory); err != nil && !os.IsNotExist(err) {
+		t.Fatalf("remove db: %s", err)
+	}
+	db, err := sdb.Open(TestDirectory)
+	if err != nil {
+		t.Fatalf("open db: %s", err)
+	}
+	return db
+}
+
+func TestOpenNamespace(t *testing.T) {
+	t.Run("Isolated keyspaces", func(t *testing.T) {
+		// Arrange
+		db := openTestNamespaceDB(t)
+		defer db.Close()
+
+		// Act
+		users, err := OpenNamespace[string, string](db, "users")
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		counts, err := OpenNamespace[string, int](db, "counts", WithCodec(TextCodec()))
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		_ = users.Put("a", "ada")
+		_ = users.Put("b", "bob")
+		_ = counts.Put("a", 1)
+
+		// Assert
+		checkShelf(t, users, map[string]string{"a": "ada", "b": "bob"})
+		checkShelf(t, counts, map[string]int{"a": 1})
+		if n := db.Len(); n != 0 {
+			t.Errorf("Expected 0 items, but got %d", n)
+		}
+		names, err := Namespaces(db)
+		if err != nil || !reflect.DeepEqual(names, []string{"counts", "users"}) {
+			t.Errorf("Expected [counts users], but got %v, %v", names, err)
+		}
+	})
+
+	t.Run("Close keeps the database", func(t *testing.T) {
+		// Arrange
+		db := openTestNamespaceDB(t)
+		defer db.Close()
+		users, _ := OpenNamespace[string, string](db, "users")
+		_ = users.Put("a", "ada")
+
+		// Act
+		err := users.Close()
+
+		// Assert
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		if err = db.Put([]byte("key"), []byte("value")); err != nil {
+			t.Errorf("Expected no error, but got %v", err)
+		}
+		users, _ = OpenNamespace[string, string](db, "users")
+		checkShelf(t, users, map[string]string{"a": "ada"})
+	})
+
+	t.Run("Drop", func(t *testing.T) {
+		// Arrange
+		db := openTestNamespaceDB(t)
+		defer db.Close()
+		users, _ := OpenNamespace[string, string](db, "users")
+		_ = users.Put("a", "ada")
+
+		// Act
+		err := DropNamespace(db, "users")
+
+		// Assert
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		if names, _ := Namespaces(db); len(names) != 0 {
+			t.Errorf("Expected no namespaces, but got %v", names)
+		}
+		users, _ = OpenNamespace[string, string](db, "users")
+		if n := users.Len(); n != 0 {
+			t.Errorf("Expected 0 items, but got %d", n)
+		}
+	})
+
+	t.Run("Unsupported", func(t *testing.T) {
+		var db MockDB
+
+		_, err := OpenNamespace[string, string](&db, "users")
+		if !errors.Is(err, ErrNamespacesUnsupported) {
+			t.Errorf("Expected ErrNamespacesUnsupported, but got %v", err)
+		}
+		if _, err = Namespaces(&db); !errors.Is(err, ErrNamespacesUnsupported) {
+			t.Errorf("Expected ErrNamespacesUnsupported, but got %v", err)
+		}
+		if err = DropNamespace(&db, "users"); !errors.Is(err, ErrNamespacesUnsupported) {
+			t.Errorf("Expected ErrNamespacesUnsupported, but got %v", err)
+		}
+	})
+
+	t.Run("Invalid name", func(t *testing.T) {
+		db := openTestNamespaceDB(t)
+		defer db.Close()
+
+		_, err := OpenNamespace[string, string](db, "")
+		if !errors.Is(err, sdb.ErrInvalidNamespace) {
+			t.Errorf("Expected sdb.ErrInvalidNamespace, but got %v", err)
+		}
+	})
+}
@@ -0,0 +1,43 @@
+package shelve
+
+import "github.com/lucmq/go-shelve/sdb"
+
+// ErrReadOnly is returned by the methods that modify a Shelf opened with
+// [WithReadOnly]. It is the same error returned by a read-only [sdb.DB].
+var ErrReadOnly = sdb.ErrReadOnly
+
+// WithReadOnly makes the Shelf read-only: its methods that modify the
+// database, including the transactions with writes, return [ErrReadOnly]
+// without calling the database.
+//
+// If the Shelf opens the default database, it is opened with
+// [sdb.WithReadOnly], so it is never written, it must already exist and only
+// read permissions are required. A database given with [WithDatabase] is used
+// as is, and the Shelf just doesn't write to it.
+//
+// A schema version set with [WithSchema] isn't stored, and the values with an
+// older version are migrated only when they are read.
+func WithReadOnly() Option {
+	return func(v any) {
+		opt := v.(*options)
+		opt.ReadOnly = true
+	}
+}
+
+// checkWritable returns ErrReadOnly if the Shelf is read-only.
+func (s *Shelf[K, V]) checkWritable() error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	return nil
+}
+
+// readOnlyDB is a DB that rejects the writes. It is given to the codecs that
+// store data in the database of a read-only Shelf.
+type readOnlyDB struct {
+	DB
+}
+
+func (readOnlyDB) Put(_, _ []byte) error { return ErrReadOnly }
+
+func (readOnlyDB) Delete([]byte) error { return ErrReadOnly }
@@ -0,0 +1,130 @@
+package shelve
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/lucmq/go-shelve/sdb"
+)
+
+func TestShelf_ReadOnly(t *testing.T) {
+	seed := func(t *testing.T, opts ...Option) {
+		t.Helper()
+		if err := os.RemoveAll(TestDirectory); err != nil && !os.IsNotExist(err) {
+			t.Fatalf("remove db: %s", err)
+		}
+		shelf, err := Open[string, string](TestDirectory, opts...)
+		if err != nil {
+			t.Fatalf("open: %s", err)
+		}
+		_ = shelf.Put("a", "1")
+		_ = shelf.Put("b", "2")
+		if err = shelf.Close(); err != nil {
+			t.Fatalf("close: %s", err)
+		}
+	}
+
+	t.Run("Default database", func(t *testing.T) {
+		// Arrange
+		seed(t)
+
+		// Act
+		shelf, err := Open[string, string](TestDirectory, WithReadOnly())
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		defer shelf.Close()
+
+		// Assert
+		checkShelf(t, shelf, map[string]string{"a": "1", "b": "2"})
+		errs := []error{
+			shelf.Put("c", "3"),
+			shelf.Delete("a"),
+			shelf.PutWithTTL("c", "3", time.Hour),
+			shelf.Transact(func(tx *Tx[string, string]) error { return tx.Put("c", "3") }),
+		}
+		b := shelf.NewBatch()
+		_ = b.Put("c", "3")
+		errs = append(errs, b.Write())
+		if _, err = shelf.DeletePrefix("a"); err != nil {
+			errs = append(errs, err)
+		}
+		for i, err := range errs {
+			if !errors.Is(err, ErrReadOnly) {
+				t.Errorf("%d: Expected ErrReadOnly, but got %v", i, err)
+			}
+		}
+		checkShelf(t, shelf, map[string]string{"a": "1", "b": "2"})
+	})
+
+	t.Run("Read transaction", func(t *testing.T) {
+		seed(t)
+		shelf, _ := Open[string, string](TestDirectory, WithReadOnly())
+		defer shelf.Close()
+
+		var value string
+		err := shelf.Transact(func(tx *Tx[string, string]) error {
+			value, _, _ = tx.Get("a")
+			return nil
+		})
+		if err != nil || value != "1" {
+			t.Errorf("Expected 1, but got %q, %v", value, err)
+		}
+	})
+
+	t.Run("Missing database", func(t *testing.T) {
+		_ = os.RemoveAll(TestDirectory)
+
+		_, err := Open[string, string](TestDirectory, WithReadOnly())
+		if !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("Expected fs.ErrNotExist, but got %v", err)
+		}
+	})
+
+	t.Run("Given database", func(t *testing.T) {
+		// Arrange
+		db := newMapDB()
+		shelf, _ := Open[string, string]("", WithDatabase(db), WithCodec(GobDictionaryCodec()))
+		_ = shelf.Put("a", "1")
+		put := db.PutFunc
+		var puts int
+		db.PutFunc = func(key, value []byte) error {
+			puts++
+			return put(key, value)
+		}
+
+		// Act
+		shelf, err := Open[string, string]("", WithDatabase(db), WithCodec(GobDictionaryCodec()),
+			WithReadOnly())
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+
+		// Assert
+		if err = shelf.Put("b", "2"); !errors.Is(err, ErrReadOnly) {
+			t.Errorf("Expected ErrReadOnly, but got %v", err)
+		}
+		b := shelf.NewBatch()
+		if err = b.Put("b", "2"); err == nil {
+			err = b.Write()
+		}
+		if !errors.Is(err, ErrReadOnly) {
+			t.Errorf("Expected ErrReadOnly, but got %v", err)
+		}
+		if v, ok, err := shelf.Get("a"); !ok || v != "1" {
+			t.Errorf("Expected 1, but got %q, %v", v, err)
+		}
+		if puts != 0 {
+			t.Errorf("Expected no writes, but got %d", puts)
+		}
+	})
+
+	t.Run("Same error as sdb", func(t *testing.T) {
+		if !errors.Is(ErrReadOnly, sdb.ErrReadOnly) {
+			t.Errorf("Expected ErrReadOnly to be sdb.ErrReadOnly")
+		}
+	})
+}
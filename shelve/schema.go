@@ -0,0 +1,228 @@
+package shelve
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	// schemaTag is the first byte of the schema header added to the values
+	// of a Shelf opened with WithSchema. It is in the range of tags that
+	// RawCodec escapes (see minTag), so the values stored before the option
+	// was used are recognized.
+	schemaTag = 0xf6
+
+	// schemaHeaderSize is the size of the tag and the schema version.
+	schemaHeaderSize = 1 + 4
+
+	// schemaKey is the key of the entry holding the newest schema version
+	// used to write the values of the Shelf. It is in the reserved
+	// namespace of the index entries.
+	schemaKey = "\xff\xffschema\x00"
+)
+
+var (
+	// ErrSchemaTooOld is returned when the Shelf holds values written with
+	// a schema version newer than the one given to [WithSchema].
+	ErrSchemaTooOld = errors.New("schema version older than the stored values")
+
+	// ErrMissingMigration is returned when decoding a value stored with an
+	// older schema version that has no [Migration].
+	ErrMissingMigration = errors.New("missing schema migration")
+
+	// ErrNoSchema is returned by [Shelf.Migrate] when the Shelf wasn't
+	// opened with [WithSchema].
+	ErrNoSchema = errors.New("shelf has no schema version")
+
+	errInvalidSchemaVersion = errors.New("invalid schema version")
+)
+
+// Migration converts a value stored with an older schema version to the
+// value type of the Shelf. It receives the value as encoded by the Codec of
+// the Shelf when it was written, after the decryption and decompression of
+// the codecs wrapping it, like [EncryptedCodec] and [CompressedCodec]. With
+// [EnvelopeCodec], it receives the value as encoded by the codec of the
+// envelope.
+type Migration[V any] func(data []byte) (V, error)
+
+type schemaOption struct {
+	version    uint32
+	migrations any
+}
+
+// schema holds the schema version of the values of a Shelf and the
+// migrations of the older versions.
+type schema[V any] struct {
+	version    uint32
+	migrations map[uint32]Migration[V]
+}
+
+// WithSchema sets the schema version of the values of the Shelf, which is
+// stored with each value, and the migrations that convert the values stored
+// with older versions, indexed by version. The type parameter must be the
+// value type of the Shelf, or [Open] fails. The versions start at 1: the
+// values stored before the option was used have version 0.
+//
+// The migrations are applied lazily, when the values are read, and
+// [Shelf.Migrate] rewrites the values stored with older versions. The Shelf
+// also records the newest version used to write its values, and Open fails
+// with [ErrSchemaTooOld] if it is newer than the given version, so that the
+// values aren't downgraded by older versions of the program. Since the
+// values are stored with the version, the option must be used every time the
+// Shelf is opened.
+func WithSchema[V any](version uint32, migrations map[uint32]Migration[V]) Option {
+	return func(v any) {
+		opt := v.(*options)
+		opt.Schema = &schemaOption{version: version, migrations: migrations}
+	}
+}
+
+// newSchema creates the schema declared with the options.
+func newSchema[V any](o *schemaOption) (*schema[V], error) {
+	if o == nil {
+		return nil, nil
+	}
+	if o.version == 0 {
+		return nil, fmt.Errorf("%w: %d", errInvalidSchemaVersion, o.version)
+	}
+	migrations, ok := o.migrations.(map[uint32]Migration[V])
+	if !ok {
+		return nil, fmt.Errorf(
+			"schema migrations are %T, expected %T", o.migrations, migrations,
+		)
+	}
+	for version := range migrations {
+		if version >= o.version {
+			return nil, fmt.Errorf("%w: migration of version %d", errInvalidSchemaVersion, version)
+		}
+	}
+	return &schema[V]{version: o.version, migrations: migrations}, nil
+}
+
+// checkSchema records the schema version in the database, failing if it
+// holds values written with a newer version.
+func (s *Shelf[K, V]) checkSchema() error {
+	data, err := s.db.Get([]byte(schemaKey))
+	if err != nil {
+		return fmt.Errorf("get schema version: %w", err)
+	}
+	if data != nil {
+		if len(data) != 4 {
+			return errInvalidSchemaVersion
+		}
+		stored := binary.BigEndian.Uint32(data)
+		if stored > s.schema.version {
+			return fmt.Errorf("%w: %d is older than %d", ErrSchemaTooOld, s.schema.version, stored)
+		}
+		if stored == s.schema.version {
+			return nil
+		}
+	}
+	if s.readOnly {
+		// The values are migrated when they are read.
+		return nil
+	}
+	data = binary.BigEndian.AppendUint32(nil, s.schema.version)
+	if err = s.db.Put([]byte(schemaKey), data); err != nil {
+		return fmt.Errorf("put schema version: %w", err)
+	}
+	return nil
+}
+
+// encodeVersioned encodes the value of a key with the Codec, adding the
+// schema header if the Shelf was opened with WithSchema.
+func (s *Shelf[K, V]) encodeVersioned(key []byte, value V) ([]byte, error) {
+	data, err := encodeKeyed(s.codec, key, value)
+	if err != nil || s.schema == nil {
+		return data, err
+	}
+	out := make([]byte, 0, schemaHeaderSize+len(data))
+	out = append(out, schemaTag)
+	out = binary.BigEndian.AppendUint32(out, s.schema.version)
+	return append(out, data...), nil
+}
+
+// decodeValue decodes the value of a key without the expiry header,
+// migrating it if it was stored with an older schema version.
+func (s *Shelf[K, V]) decodeValue(key, data []byte, value *V) error {
+	if s.schema == nil {
+		return decodeKeyed(s.codec, key, data, value)
+	}
+	_, version, data := splitSchema(data)
+	if version == s.schema.version {
+		return decodeKeyed(s.codec, key, data, value)
+	}
+	if version > s.schema.version {
+		return fmt.Errorf("%w: value has version %d", ErrSchemaTooOld, version)
+	}
+	migrate, ok := s.schema.migrations[version]
+	if !ok {
+		return fmt.Errorf("%w: from version %d", ErrMissingMigration, version)
+	}
+	data, err := unwrapKeyed(s.codec, key, data)
+	if err != nil {
+		return err
+	}
+	v, err := migrate(data)
+	if err != nil {
+		return fmt.Errorf("migrate from version %d: %w", version, err)
+	}
+	*value = v
+	return nil
+}
+
+// splitSchema splits a value without the expiry header into the schema
+// header, the schema version and the data encoded by the Codec. The values
+// without a header have version 0.
+func splitSchema(data []byte) (header []byte, version uint32, value []byte) {
+	if len(data) < schemaHeaderSize || data[0] != schemaTag {
+		return nil, 0, data
+	}
+	version = binary.BigEndian.Uint32(data[1:schemaHeaderSize])
+	return data[:schemaHeaderSize], version, data[schemaHeaderSize:]
+}
+
+// Migrate rewrites the values of the Shelf stored with an older schema
+// version than the one given to [WithSchema], applying their migrations, and
+// returns the number of values rewritten. The expiration deadlines stored
+// with [WithExpiryHeader] are kept.
+//
+// The values are rewritten in transactions of a few hundred keys each, so it
+// is safe to call Migrate while the Shelf is in use, and it can be called
+// again to resume after an error. The rewrites are reported to the watchers
+// as puts. Keys stored with [Shelf.PutWithTTL] in a database implementing
+// [TTLDB] keep their expiration only if it implements [ExpiryDB].
+func (s *Shelf[K, V]) Migrate() (int64, error) {
+	return s.migrate(context.Background())
+}
+
+func (s *Shelf[K, V]) migrate(ctx context.Context) (int64, error) {
+	if s.schema == nil {
+		return 0, ErrNoSchema
+	}
+	return s.rewriteValues(ctx, func(_, payload []byte) (bool, error) {
+		_, version, _ := splitSchema(payload)
+		return version < s.schema.version, nil
+	}, func(key, payload []byte) ([]byte, bool, error) {
+		if _, version, _ := splitSchema(payload); version >= s.schema.version {
+			return nil, false, nil
+		}
+		return s.recode(key, payload)
+	})
+}
+
+// recode decodes the value of a key, without the expiry header, and encodes
+// it again, with the current Codec and schema version.
+func (s *Shelf[K, V]) recode(key, payload []byte) ([]byte, bool, error) {
+	var value V
+	if err := s.decodeValue(key, payload, &value); err != nil {
+		return nil, false, fmt.Errorf("decode: %w", err)
+	}
+	data, err := s.encodeVersioned(key, value)
+	if err != nil {
+		return nil, false, fmt.Errorf("encode: %w", err)
+	}
+	return data, true, nil
+}
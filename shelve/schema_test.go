@@ -0,0 +1,294 @@
+package shelve
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type userV0 struct {
+	Name string
+}
+
+type userV1 struct {
+	First string
+	Last  string
+}
+
+type userV2 struct {
+	First string
+	Last  string
+	Admin bool
+}
+
+// userMigrations upgrades userV0 and userV1 values, encoded with JSON, to
+// userV2.
+var userMigrations = map[uint32]Migration[userV2]{
+	0: func(data []byte) (userV2, error) {
+		var old userV0
+		if err := json.Unmarshal(data, &old); err != nil {
+			return userV2{}, err
+		}
+		first, last, _ := strings.Cut(old.Name, " ")
+		return userV2{First: first, Last: last}, nil
+	},
+	1: func(data []byte) (userV2, error) {
+		var old userV1
+		if err := json.Unmarshal(data, &old); err != nil {
+			return userV2{}, err
+		}
+		return userV2{First: old.First, Last: old.Last}, nil
+	},
+}
+
+// openSchemaShelves returns a Shelf holding values of every schema version,
+// and a Shelf with the current version, opened on the same database.
+func openSchemaShelves(t *testing.T, opts ...Option) (*Shelf[string, userV0], *Shelf[string, userV2]) {
+	t.Helper()
+	v0 := OpenTestShelfWith[string, userV0](t, opts...)
+	_ = v0.Put("ada", userV0{Name: "Ada Lovelace"})
+
+	v1, err := Open[string, userV1](TestDirectory, append(opts,
+		WithDatabase(v0.db), WithSchema(1, map[uint32]Migration[userV1]{}))...)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	_ = v1.Put("alan", userV1{First: "Alan", Last: "Turing"})
+
+	v2, err := Open[string, userV2](TestDirectory, append(opts,
+		WithDatabase(v0.db), WithSchema(2, userMigrations))...)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	_ = v2.Put("grace", userV2{First: "Grace", Last: "Hopper", Admin: true})
+	return v0, v2
+}
+
+var migratedUsers = map[string]userV2{
+	"ada":   {First: "Ada", Last: "Lovelace"},
+	"alan":  {First: "Alan", Last: "Turing"},
+	"grace": {First: "Grace", Last: "Hopper", Admin: true},
+}
+
+func TestShelf_Schema(t *testing.T) {
+	t.Run("Migrates lazily", func(t *testing.T) {
+		// Arrange
+		v0, shelf := openSchemaShelves(t)
+		defer v0.Close()
+
+		// Act
+		value, ok, err := shelf.Get("ada")
+
+		// Assert
+		if err != nil || !ok {
+			t.Fatalf("Expected no error, but got %v, %v", ok, err)
+		}
+		if value != migratedUsers["ada"] {
+			t.Errorf("Expected %v, but got %v", migratedUsers["ada"], value)
+		}
+		checkShelf(t, shelf, migratedUsers)
+		if n := shelf.Len(); n != 3 {
+			t.Errorf("Expected 3 items, but got %d", n)
+		}
+	})
+
+	t.Run("Refuses an older schema", func(t *testing.T) {
+		// Arrange
+		v0, _ := openSchemaShelves(t)
+		defer v0.Close()
+
+		// Act
+		_, err := Open[string, userV1](TestDirectory,
+			WithDatabase(v0.db), WithSchema(1, map[uint32]Migration[userV1]{}))
+
+		// Assert
+		if !errors.Is(err, ErrSchemaTooOld) {
+			t.Errorf("Expected ErrSchemaTooOld, but got %v", err)
+		}
+	})
+
+	t.Run("Missing migration", func(t *testing.T) {
+		// Arrange
+		v0, _ := openSchemaShelves(t)
+		defer v0.Close()
+		shelf, _ := Open[string, userV2](TestDirectory,
+			WithDatabase(v0.db), WithSchema(2, map[uint32]Migration[userV2]{}))
+
+		// Act
+		_, _, err := shelf.Get("ada")
+
+		// Assert
+		if !errors.Is(err, ErrMissingMigration) {
+			t.Errorf("Expected ErrMissingMigration, but got %v", err)
+		}
+		if _, ok, err := shelf.Get("grace"); !ok || err != nil {
+			t.Errorf("Expected the current version to be decoded, but got %v, %v", ok, err)
+		}
+	})
+
+	t.Run("Migration error", func(t *testing.T) {
+		// Arrange
+		v0, _ := openSchemaShelves(t)
+		defer v0.Close()
+		shelf, _ := Open[string, userV2](TestDirectory,
+			WithDatabase(v0.db), WithSchema(2, map[uint32]Migration[userV2]{
+				0: func([]byte) (userV2, error) { return userV2{}, TestError },
+			}))
+
+		// Act
+		_, _, err := shelf.Get("ada")
+
+		// Assert
+		if !errors.Is(err, TestError) {
+			t.Errorf("Expected TestError, but got %v", err)
+		}
+	})
+
+	t.Run("Reserved key", func(t *testing.T) {
+		v0, shelf := openSchemaShelves(t)
+		defer v0.Close()
+
+		err := shelf.Put(schemaKey, userV2{})
+		if !errors.Is(err, ErrReservedKey) {
+			t.Errorf("Expected ErrReservedKey, but got %v", err)
+		}
+	})
+
+	t.Run("Invalid options", func(t *testing.T) {
+		for _, option := range []Option{
+			WithSchema(0, map[uint32]Migration[userV2]{}),
+			WithSchema(2, map[uint32]Migration[userV2]{2: userMigrations[1]}),
+			WithSchema(2, map[uint32]Migration[userV1]{}),
+		} {
+			shelf, err := Open[string, userV2](TestDirectory, WithDatabase(&MockDB{}), option)
+			if err == nil || shelf != nil {
+				t.Errorf("Expected an error, but got %v", err)
+			}
+		}
+	})
+
+	t.Run("Get error", func(t *testing.T) {
+		var db MockDB
+		db.GetFunc = func([]byte) ([]byte, error) { return nil, TestError }
+
+		_, err := Open[string, userV2](TestDirectory,
+			WithDatabase(&db), WithSchema(2, userMigrations))
+		if !errors.Is(err, TestError) {
+			t.Errorf("Expected TestError, but got %v", err)
+		}
+	})
+}
+
+func TestShelf_Migrate(t *testing.T) {
+	t.Run("Rewrites the old versions", func(t *testing.T) {
+		// Arrange
+		v0, shelf := openSchemaShelves(t, WithExpiryHeader())
+		defer v0.Close()
+		_ = shelf.PutWithTTL("linus", userV2{First: "Linus"}, time.Hour)
+
+		// Act
+		n, err := shelf.Migrate()
+
+		// Assert
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		if n != 2 {
+			t.Errorf("Expected 2 values, but got %d", n)
+		}
+		if n, _ = shelf.Migrate(); n != 0 {
+			t.Errorf("Expected 0 values, but got %d", n)
+		}
+
+		// The migrations are no longer needed.
+		current, _ := Open[string, userV2](TestDirectory, WithDatabase(v0.db),
+			WithSchema(2, map[uint32]Migration[userV2]{}), WithExpiryHeader())
+		expected := map[string]userV2{"linus": {First: "Linus"}}
+		for k, v := range migratedUsers {
+			expected[k] = v
+		}
+		checkShelf(t, current, expected)
+
+		setNow(t, time.Now().Add(2*time.Hour))
+		if ok, _ := current.Has("linus"); ok {
+			t.Errorf("Expected the key to expire")
+		}
+	})
+
+	t.Run("Keeps the database TTL", func(t *testing.T) {
+		// Arrange
+		v0 := OpenTestShelfWith[string, userV0](t)
+		defer v0.Close()
+		_ = v0.PutWithTTL("ada", userV0{Name: "Ada Lovelace"}, 200*time.Millisecond)
+		shelf, _ := Open[string, userV2](TestDirectory,
+			WithDatabase(v0.db), WithSchema(2, userMigrations))
+
+		// Act
+		n, err := shelf.Migrate()
+
+		// Assert
+		if err != nil || n != 1 {
+			t.Fatalf("Expected 1 value and no error, but got %d, %v", n, err)
+		}
+		value, ok, _ := shelf.Get("ada")
+		if !ok || value != migratedUsers["ada"] {
+			t.Errorf("Expected %v, but got %v, %v", migratedUsers["ada"], value, ok)
+		}
+		time.Sleep(250 * time.Millisecond)
+		if ok, _ = shelf.Has("ada"); ok {
+			t.Errorf("Expected the key to expire")
+		}
+	})
+
+	t.Run("With an envelope", func(t *testing.T) {
+		// Arrange
+		v0, _ := openSchemaShelves(t)
+		defer v0.Close()
+		codec := newTestEnvelopeCodec(t, GobCodecID)
+		shelf, _ := Open[string, userV2](TestDirectory,
+			WithDatabase(v0.db), WithCodec(codec), WithSchema(2, userMigrations))
+
+		// Act
+		n, err := shelf.Reencode()
+
+		// Assert
+		if err != nil || n != 3 {
+			t.Errorf("Expected 3 values and no error, but got %d, %v", n, err)
+		}
+		if n, _ = shelf.Migrate(); n != 0 {
+			t.Errorf("Expected 0 values, but got %d", n)
+		}
+		checkShelf(t, shelf, migratedUsers)
+	})
+
+	t.Run("With an encrypted codec", func(t *testing.T) {
+		// Arrange
+		codec := newTestEncryptedCodec(t, testEncryptionKey(1))
+		v0, shelf := openSchemaShelves(t, WithCodec(codec))
+		defer v0.Close()
+
+		// Act
+		value, ok, err := shelf.Get("ada")
+		n, err2 := shelf.Migrate()
+
+		// Assert
+		if err != nil || !ok || value != migratedUsers["ada"] {
+			t.Errorf("Expected %v, but got %v, %v, %v", migratedUsers["ada"], value, ok, err)
+		}
+		if err2 != nil || n != 2 {
+			t.Errorf("Expected 2 values and no error, but got %d, %v", n, err2)
+		}
+		checkShelf(t, shelf, migratedUsers)
+	})
+
+	t.Run("No schema", func(t *testing.T) {
+		shelf := NewTestShelf(t)
+
+		_, err := shelf.Migrate()
+		if !errors.Is(err, ErrNoSchema) {
+			t.Errorf("Expected ErrNoSchema, but got %v", err)
+		}
+	})
+}
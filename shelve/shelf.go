@@ -16,9 +16,13 @@
 package shelve
 
 import (
+	"bytes"
+	"context"
 	"encoding"
 	"fmt"
 	"reflect"
+	"slices"
+	"sync"
 
 	"github.com/lucmq/go-shelve/sdb"
 )
@@ -39,6 +43,10 @@ const (
 	All = -1
 )
 
+// rewriteBatchSize is the number of values rewritten in each transaction by
+// Shelf.rewriteValues.
+const rewriteBatchSize = 256
+
 // Yield is a function called when iterating over key-value pairs in the
 // Shelf. If Yield returns false or an error, the iteration stops.
 type Yield[K, V any] func(key K, value V) (bool, error)
@@ -57,18 +65,37 @@ type Yield[K, V any] func(key K, value V) (bool, error)
 // The underlying storage and codec Shelf uses can be configured with the
 // [Option] functions.
 type Shelf[K comparable, V any] struct {
-	db       DB
-	codec    Codec
-	keyCodec Codec
+	db           DB
+	codec        Codec
+	keyCodec     Codec
+	expiryHeader bool
+	readOnly     bool
+	indexes      []*index[V]
+	schema       *schema[V]
+
+	// Prefix of the keys reserved by the value codec, if it stores data in
+	// the database.
+	codecPrefix []byte
+
+	// Serializes the commits of transactions when the DB doesn't implement
+	// TxDB. The other writes hold it for reading, so they don't interleave
+	// with the commits.
+	txMu sync.RWMutex
+
+	watchers *watchHub[K, V]
 }
 
 // Option is passed to the Open function to create a customized Shelf.
 type Option func(any)
 
 type options struct {
-	DB       DB
-	Codec    Codec
-	KeyCodec Codec
+	DB           DB
+	Codec        Codec
+	KeyCodec     Codec
+	ExpiryHeader bool
+	ReadOnly     bool
+	Indexes      []indexOption
+	Schema       *schemaOption
 }
 
 // WithDatabase specifies the underlying database to use. By default, the
@@ -105,6 +132,10 @@ func WithCodec(c Codec) Option {
 // float, [N]byte arrays (e.g., [12]byte), or types that implement
 // [encoding.TextMarshaler] are encoded using [TextCodec].
 //
+// Note that keys encoded with [TextCodec] are iterated in the lexicographic
+// order of their text form. Use [OrderedCodec] to iterate numeric keys in
+// numeric order.
+//
 // Additional Codecs can be found in the packages in [driver/encoding].
 //
 // [driver/encoding]: https://pkg.go.dev/github.com/lucmq/go-shelve/driver/encoding
@@ -115,6 +146,21 @@ func WithKeyCodec(c Codec) Option {
 	}
 }
 
+// WithExpiryHeader makes the Shelf store the expiration deadline of each
+// value in a header, added before the encoded value. It allows
+// [Shelf.PutWithTTL] to be used with databases that don't implement [TTLDB].
+//
+// The expired values are hidden by the Shelf, but they are only removed from
+// the database when their keys are deleted or overwritten. Since the values
+// are stored with the header, the option must be used every time the Shelf
+// is opened, and [Shelf.Len] needs to iterate over the items to count them.
+func WithExpiryHeader() Option {
+	return func(v any) {
+		opt := v.(*options)
+		opt.ExpiryHeader = true
+	}
+}
+
 // Open creates a new Shelf.
 //
 // The path parameter specifies the filesystem path to the database files. It
@@ -126,10 +172,7 @@ func Open[K comparable, V any](path string, opts ...Option) (
 	error,
 ) {
 	var k K
-	keyCodec, err := defaultKeyCodec(k)
-	if err != nil {
-		return nil, err
-	}
+	keyCodec, keyErr := defaultKeyCodec(k)
 
 	o := options{
 		Codec:    JSONCodec(),
@@ -139,30 +182,118 @@ func Open[K comparable, V any](path string, opts ...Option) (
 		option(&o)
 	}
 
-	if o.DB == nil {
-		db, err := sdb.Open(path)
+	// Key types without a default codec are only an error if the key codec
+	// wasn't set explicitly.
+	if keyErr != nil && o.KeyCodec == nil {
+		return nil, keyErr
+	}
+
+	indexes, err := newIndexes[V](o.Indexes)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := newSchema[V](o.Schema)
+	if err != nil {
+		return nil, err
+	}
+
+	opened := o.DB == nil
+	if opened {
+		db, err := sdb.Open(path, sdb.WithReadOnly(o.ReadOnly))
 		if err != nil {
 			return nil, fmt.Errorf("open db: %w", err)
 		}
 		o.DB = db
 	}
 
-	return &Shelf[K, V]{
-		db:       o.DB,
-		codec:    o.Codec,
-		keyCodec: o.KeyCodec,
-	}, nil
+	s := &Shelf[K, V]{
+		db:           o.DB,
+		codec:        o.Codec,
+		keyCodec:     o.KeyCodec,
+		expiryHeader: o.ExpiryHeader,
+		readOnly:     o.ReadOnly,
+		indexes:      indexes,
+		schema:       schema,
+		watchers:     newWatchHub[K, V](),
+	}
+	if err = s.init(); err != nil {
+		if opened {
+			_ = o.DB.Close()
+		}
+		return nil, err
+	}
+	return s, nil
+}
+
+// init attaches the value codec to the database, if it stores data in it,
+// and checks the schema version.
+func (s *Shelf[K, V]) init() error {
+	if c, ok := s.codec.(attachedCodec); ok {
+		db := s.db
+		if s.readOnly {
+			db = readOnlyDB{db}
+		}
+		if err := c.attach(db); err != nil {
+			return fmt.Errorf("attach codec: %w", err)
+		}
+		s.codecPrefix = c.reservedPrefix()
+	}
+	if s.schema != nil {
+		return s.checkSchema()
+	}
+	return nil
 }
 
-// Close synchronizes and closes the Shelf.
+// Close synchronizes and closes the Shelf. The Watchers of the Shelf are
+// closed too.
 func (s *Shelf[K, V]) Close() error {
+	s.watchers.close()
 	return s.db.Close()
 }
 
 // Len returns the number of items in the Shelf. It returns the number
 // of items as an int64. If an error occurs, it returns -1.
+//
+// Len uses the count of the database, which is usually cheap, minus the
+// entries the Shelf stores under reserved keys. These are counted by
+// iterating over them, so with indexes, Len takes time proportional to the
+// number of index entries. With [WithExpiryHeader], the items are iterated
+// to skip the expired ones, and Len takes time proportional to the number of
+// items.
 func (s *Shelf[K, V]) Len() int64 {
-	return s.db.Len()
+	count, err := s.len(context.Background())
+	if err != nil {
+		return -1
+	}
+	return count
+}
+
+func (s *Shelf[K, V]) len(ctx context.Context) (int64, error) {
+	if s.expiryHeader {
+		// The database also counts the expired items.
+		var count int64
+		err := s.iterateEncoded(ctx, nil, nil, All, Asc, func(_, _ []byte) (bool, error) {
+			count++
+			return true, nil
+		})
+		if err != nil {
+			return -1, err
+		}
+		return count, nil
+	}
+
+	if err := ctx.Err(); err != nil {
+		return -1, err
+	}
+	count := s.db.Len()
+	if count < 0 {
+		return -1, errLen
+	}
+	reserved, err := s.countReserved(ctx)
+	if err != nil {
+		return -1, err
+	}
+	return count - reserved, nil
 }
 
 // Sync synchronizes the Shelf contents to persistent storage.
@@ -172,11 +303,23 @@ func (s *Shelf[K, V]) Sync() error {
 
 // Has reports whether a key exists in the Shelf.
 func (s *Shelf[K, V]) Has(key K) (bool, error) {
+	return s.has(context.Background(), key)
+}
+
+func (s *Shelf[K, V]) has(ctx context.Context, key K) (bool, error) {
 	data, err := s.keyCodec.Encode(key)
 	if err != nil {
 		return false, fmt.Errorf("encode: %w", err)
 	}
-	ok, err := s.db.Has(data)
+	if s.expiryHeader {
+		// The value is needed to check if it expired.
+		vData, err := s.getEncoded(ctx, data)
+		if err != nil {
+			return false, fmt.Errorf("has: %w", err)
+		}
+		return vData != nil, nil
+	}
+	ok, err := s.dbHas(ctx, data)
 	if err != nil {
 		return false, fmt.Errorf("has: %w", err)
 	}
@@ -186,11 +329,15 @@ func (s *Shelf[K, V]) Has(key K) (bool, error) {
 // Get retrieves the value associated with a key from the Shelf. If the key is
 // not found, it returns nil.
 func (s *Shelf[K, V]) Get(key K) (value V, ok bool, err error) {
+	return s.get(context.Background(), key)
+}
+
+func (s *Shelf[K, V]) get(ctx context.Context, key K) (value V, ok bool, err error) {
 	data, err := s.keyCodec.Encode(key)
 	if err != nil {
 		return *new(V), false, fmt.Errorf("encode: %w", err)
 	}
-	vData, err := s.db.Get(data)
+	vData, err := s.getEncoded(ctx, data)
 	if err != nil {
 		return *new(V), false, fmt.Errorf("get: %w", err)
 	}
@@ -198,22 +345,25 @@ func (s *Shelf[K, V]) Get(key K) (value V, ok bool, err error) {
 		return *new(V), false, nil
 	}
 	var v V
-	err = s.codec.Decode(vData, &v)
+	err = s.decodeValue(data, vData, &v)
 	return v, true, err
 }
 
 // Put adds a key-value pair to the Shelf. If the key already exists, it
 // overwrites the existing value.
 func (s *Shelf[K, V]) Put(key K, value V) error {
-	data, err := s.keyCodec.Encode(key)
-	if err != nil {
-		return fmt.Errorf("encode key: %w", err)
+	return s.put(context.Background(), key, value)
+}
+
+func (s *Shelf[K, V]) put(ctx context.Context, key K, value V) error {
+	if err := s.checkWritable(); err != nil {
+		return err
 	}
-	vData, err := s.codec.Encode(value)
+	data, vData, err := s.encodeItem(key, value)
 	if err != nil {
-		return fmt.Errorf("encode value: %w", err)
+		return err
 	}
-	err = s.db.Put(data, vData)
+	err = s.putEncoded(ctx, data, vData)
 	if err != nil {
 		return fmt.Errorf("put: %w", err)
 	}
@@ -222,11 +372,15 @@ func (s *Shelf[K, V]) Put(key K, value V) error {
 
 // Delete removes a key-value pair from the Shelf.
 func (s *Shelf[K, V]) Delete(key K) error {
+	return s.delete(context.Background(), key)
+}
+
+func (s *Shelf[K, V]) delete(ctx context.Context, key K) error {
 	data, err := s.keyCodec.Encode(key)
 	if err != nil {
 		return fmt.Errorf("encode: %w", err)
 	}
-	err = s.db.Delete(data)
+	err = s.deleteEncoded(ctx, data)
 	if err != nil {
 		return fmt.Errorf("delete: %w", err)
 	}
@@ -259,7 +413,269 @@ func (s *Shelf[K, V]) Delete(key K) error {
 //   - Desc: position at the last key < start. If all keys > start, the
 //     iterator is empty.
 func (s *Shelf[K, V]) Items(start *K, n, step int, fn Yield[K, V]) error {
-	dbFn := func(k, v []byte) (bool, error) {
+	return s.ItemsRange(start, nil, n, step, fn)
+}
+
+// ItemsRange works like [Shelf.Items], but only iterates over the keys in the
+// half-open range between start (inclusive) and end (exclusive). Either bound
+// can be nil, leaving that side of the range open:
+//   - Asc: yields keys start <= k < end.
+//   - Desc: yields keys end < k <= start.
+//
+// The bounds are compared using the encoded keys, so the range follows the
+// iteration order of the underlying database. If the database implements
+// [RangeDB], the end bound is handled by the database itself. Otherwise, the
+// iteration stops at the first encoded key beyond the end bound, which
+// assumes the database yields keys in sorted order.
+func (s *Shelf[K, V]) ItemsRange(start, end *K, n, step int, fn Yield[K, V]) error {
+	return s.iterate(context.Background(), start, end, n, step, s.decodeItems(fn))
+}
+
+// ItemsPrefix works like [Shelf.Items], but only iterates over the keys that
+// start with the given prefix. The prefix is compared with the keys using
+// their encoded representation, so a Shelf[string, V] with the default key
+// codec matches the keys with [strings.HasPrefix]. The n and step parameters
+// are the same as for [Shelf.Items].
+//
+// If the database implements [PrefixDB], the prefix is handled by the
+// database itself. Otherwise, the Shelf seeks to the prefix and stops the
+// iteration at the first encoded key that doesn't match it, which assumes
+// the database yields keys in sorted order.
+func (s *Shelf[K, V]) ItemsPrefix(prefix K, n, step int, fn Yield[K, V]) error {
+	return s.iteratePrefix(context.Background(), prefix, n, step, s.decodeItems(fn))
+}
+
+// ItemsTupleRange works like [Shelf.ItemsRange], but the range bounds are
+// given as [Tuple] values with the leading components of the keys, which must
+// be encoded with [TupleCodec]. A nil bound is unbounded.
+//
+// Since the keys sort after the tuples they start with, in ascending order
+// the start bound includes all the keys with its components, while the end
+// bound excludes them. For example, the range from Tuple{"acme", t1} to
+// Tuple{"acme", t2} iterates over the keys of the "acme" tenant with times in
+// [t1, t2). In descending order it is the opposite: the keys with the
+// components of start are excluded and the ones with the components of end
+// are included.
+func (s *Shelf[K, V]) ItemsTupleRange(start, end Tuple, n, step int, fn Yield[K, V]) error {
+	return s.iterateTuple(context.Background(), start, end, n, step, s.decodeItems(fn))
+}
+
+func (s *Shelf[K, V]) iterateTuple(
+	ctx context.Context,
+	start, end Tuple,
+	n, step int,
+	fn func(k, v []byte) (bool, error),
+) error {
+	var from, to []byte
+	var err error
+	if start != nil {
+		from, err = s.keyCodec.Encode(start)
+		if err != nil {
+			return fmt.Errorf("encode start: %w", err)
+		}
+	}
+	if end != nil {
+		to, err = s.keyCodec.Encode(end)
+		if err != nil {
+			return fmt.Errorf("encode end: %w", err)
+		}
+	}
+	return s.iterateEncoded(ctx, from, to, n, step, fn)
+}
+
+// ItemsTuplePrefix works like [Shelf.ItemsPrefix], but only iterates over the
+// keys whose leading components are equal to the ones in the given [Tuple].
+// The keys must be encoded with [TupleCodec].
+func (s *Shelf[K, V]) ItemsTuplePrefix(prefix Tuple, n, step int, fn Yield[K, V]) error {
+	return s.iterateTuplePrefix(context.Background(), prefix, n, step, s.decodeItems(fn))
+}
+
+func (s *Shelf[K, V]) iterateTuplePrefix(
+	ctx context.Context,
+	prefix Tuple,
+	n, step int,
+	fn func(k, v []byte) (bool, error),
+) error {
+	p, err := s.keyCodec.Encode(prefix)
+	if err != nil {
+		return fmt.Errorf("encode prefix: %w", err)
+	}
+	return s.iteratePrefixEncoded(ctx, p, n, step, fn)
+}
+
+// Keys iterates over all keys in the Shelf and calls the user-provided
+// function fn for each key. The details of the iteration are the same as
+// for [Shelf.Items].
+//
+// The value parameter for fn will always be the zero value for the type V.
+func (s *Shelf[K, V]) Keys(start *K, n, step int, fn Yield[K, V]) error {
+	return s.KeysRange(start, nil, n, step, fn)
+}
+
+// KeysRange works like [Shelf.Keys], but only iterates over the keys in the
+// range between start (inclusive) and end (exclusive). The details of the
+// range are the same as for [Shelf.ItemsRange].
+func (s *Shelf[K, V]) KeysRange(start, end *K, n, step int, fn Yield[K, V]) error {
+	return s.iterate(context.Background(), start, end, n, step, s.decodeKeys(fn))
+}
+
+// KeysPrefix works like [Shelf.Keys], but only iterates over the keys that
+// start with the given prefix. The details of the prefix matching are the
+// same as for [Shelf.ItemsPrefix].
+func (s *Shelf[K, V]) KeysPrefix(prefix K, n, step int, fn Yield[K, V]) error {
+	return s.iteratePrefix(context.Background(), prefix, n, step, s.decodeKeys(fn))
+}
+
+// Values iterates over all values in the Shelf and calls the user-provided
+// function fn for each value. The details of the iteration are the same as
+// for [Shelf.Items].
+//
+// The key parameter for fn will always be the zero value for the type K.
+func (s *Shelf[K, V]) Values(start *K, n, step int, fn Yield[K, V]) error {
+	return s.ValuesRange(start, nil, n, step, fn)
+}
+
+// ValuesRange works like [Shelf.Values], but only iterates over the values
+// whose keys are in the range between start (inclusive) and end (exclusive).
+// The details of the range are the same as for [Shelf.ItemsRange].
+func (s *Shelf[K, V]) ValuesRange(start, end *K, n, step int, fn Yield[K, V]) error {
+	return s.iterate(context.Background(), start, end, n, step, s.decodeValues(fn))
+}
+
+// ValuesPrefix works like [Shelf.Values], but only iterates over the values
+// whose keys start with the given prefix. The details of the prefix matching
+// are the same as for [Shelf.ItemsPrefix].
+func (s *Shelf[K, V]) ValuesPrefix(prefix K, n, step int, fn Yield[K, V]) error {
+	return s.iteratePrefix(context.Background(), prefix, n, step, s.decodeValues(fn))
+}
+
+// LenPrefix returns the number of items in the Shelf whose keys start with
+// the given prefix. The details of the prefix matching are the same as for
+// [Shelf.ItemsPrefix].
+//
+// Unlike [Shelf.Len], it iterates over the matching keys to count them.
+func (s *Shelf[K, V]) LenPrefix(prefix K) (int64, error) {
+	return s.lenPrefix(context.Background(), prefix)
+}
+
+func (s *Shelf[K, V]) lenPrefix(ctx context.Context, prefix K) (int64, error) {
+	var count int64
+	err := s.iteratePrefix(ctx, prefix, All, Asc, func(_, _ []byte) (bool, error) {
+		count++
+		return true, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// DeletePrefix removes all key-value pairs whose keys start with the given
+// prefix from the Shelf, and returns the number of removed items. The
+// details of the prefix matching are the same as for [Shelf.ItemsPrefix].
+//
+// The matching keys are collected before being removed one at a time, so the
+// operation is not atomic: if an error occurs, some of the items might have
+// already been removed.
+func (s *Shelf[K, V]) DeletePrefix(prefix K) (int64, error) {
+	return s.deletePrefix(context.Background(), prefix)
+}
+
+func (s *Shelf[K, V]) deletePrefix(ctx context.Context, prefix K) (int64, error) {
+	var keys [][]byte
+	err := s.iteratePrefix(ctx, prefix, All, Asc, func(k, _ []byte) (bool, error) {
+		// Copy, since some databases reuse the key buffer.
+		keys = append(keys, bytes.Clone(k))
+		return true, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var count int64
+	for _, k := range keys {
+		if err = s.deleteEncoded(ctx, k); err != nil {
+			return count, fmt.Errorf("delete: %w", err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// encodeItem encodes a key-value pair, adding the expiry header to the value
+// if the Shelf was opened with WithExpiryHeader. The encoded value is never
+// nil, since nil marks a removal in batches and transactions.
+func (s *Shelf[K, V]) encodeItem(key K, value V) ([]byte, []byte, error) {
+	data, err := s.keyCodec.Encode(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("encode key: %w", err)
+	}
+	vData, err := s.encodeValue(data, value, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("encode value: %w", err)
+	}
+	if vData == nil {
+		vData = []byte{}
+	}
+	return data, vData, nil
+}
+
+// putEncoded stores the encoded key-value pair in the database, updating the
+// indexes in a transaction if the Shelf has any.
+func (s *Shelf[K, V]) putEncoded(ctx context.Context, key, value []byte) error {
+	if err := s.checkWritable(); err != nil {
+		return err
+	}
+	if len(s.indexes) == 0 {
+		if s.isReserved(key) {
+			return ErrReservedKey
+		}
+		defer s.lockWrite()()
+		if err := s.dbPut(ctx, key, value); err != nil {
+			return err
+		}
+		s.notify([][]byte{key}, [][]byte{value})
+		return nil
+	}
+	return s.transact(ctx, func(tx *Tx[K, V]) error {
+		return tx.set(key, value)
+	})
+}
+
+// deleteEncoded removes the encoded key from the database, updating the
+// indexes in a transaction if the Shelf has any.
+func (s *Shelf[K, V]) deleteEncoded(ctx context.Context, key []byte) error {
+	if err := s.checkWritable(); err != nil {
+		return err
+	}
+	if len(s.indexes) == 0 {
+		if s.isReserved(key) {
+			return ErrReservedKey
+		}
+		defer s.lockWrite()()
+		if err := s.dbDelete(ctx, key); err != nil {
+			return err
+		}
+		s.notify([][]byte{key}, [][]byte{nil})
+		return nil
+	}
+	return s.transact(ctx, func(tx *Tx[K, V]) error {
+		return tx.set(key, nil)
+	})
+}
+
+// getEncoded returns the encoded value of the encoded key, without the expiry
+// header. It returns nil if the key is not found or expired.
+func (s *Shelf[K, V]) getEncoded(ctx context.Context, key []byte) ([]byte, error) {
+	vData, err := s.dbGet(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return s.unwrapValue(vData)
+}
+
+func (s *Shelf[K, V]) decodeItems(fn Yield[K, V]) func(k, v []byte) (bool, error) {
+	return func(k, v []byte) (bool, error) {
 		var key K
 		var value V
 		err := s.keyCodec.Decode(k, &key)
@@ -267,23 +683,17 @@ func (s *Shelf[K, V]) Items(start *K, n, step int, fn Yield[K, V]) error {
 			return false, fmt.Errorf("decode key: %w", err)
 		}
 		if len(v) != 0 {
-			err = s.codec.Decode(v, &value)
+			err = s.decodeValue(k, v, &value)
 			if err != nil {
 				return false, fmt.Errorf("decode value: %w", err)
 			}
 		}
 		return fn(key, value)
 	}
-	return s.iterate(start, n, step, dbFn)
 }
 
-// Keys iterates over all keys in the Shelf and calls the user-provided
-// function fn for each key. The details of the iteration are the same as
-// for [Shelf.Items].
-//
-// The value parameter for fn will always be the zero value for the type V.
-func (s *Shelf[K, V]) Keys(start *K, n, step int, fn Yield[K, V]) error {
-	dbFn := func(k, _ []byte) (bool, error) {
+func (s *Shelf[K, V]) decodeKeys(fn Yield[K, V]) func(k, v []byte) (bool, error) {
+	return func(k, _ []byte) (bool, error) {
 		var key K
 		var zero V
 		err := s.keyCodec.Decode(k, &key)
@@ -292,29 +702,23 @@ func (s *Shelf[K, V]) Keys(start *K, n, step int, fn Yield[K, V]) error {
 		}
 		return fn(key, zero)
 	}
-	return s.iterate(start, n, step, dbFn)
 }
 
-// Values iterates over all values in the Shelf and calls the user-provided
-// function fn for each value. The details of the iteration are the same as
-// for [Shelf.Items].
-//
-// The key parameter for fn will always be the zero value for the type K.
-func (s *Shelf[K, V]) Values(start *K, n, step int, fn Yield[K, V]) error {
-	dbFn := func(_, v []byte) (bool, error) {
+func (s *Shelf[K, V]) decodeValues(fn Yield[K, V]) func(k, v []byte) (bool, error) {
+	return func(k, v []byte) (bool, error) {
 		var zero K
 		var value V
-		err := s.codec.Decode(v, &value)
+		err := s.decodeValue(k, v, &value)
 		if err != nil {
 			return false, fmt.Errorf("decode: %w", err)
 		}
 		return fn(zero, value)
 	}
-	return s.iterate(start, n, step, dbFn)
 }
 
 func (s *Shelf[K, V]) iterate(
-	start *K,
+	ctx context.Context,
+	start, end *K,
 	n, step int,
 	fn func(k, v []byte) (bool, error),
 ) error {
@@ -327,6 +731,66 @@ func (s *Shelf[K, V]) iterate(
 		}
 	}
 
+	var to []byte = nil
+	if end != nil {
+		to, err = s.keyCodec.Encode(*end)
+		if err != nil {
+			return fmt.Errorf("encode end: %w", err)
+		}
+	}
+
+	return s.iterateEncoded(ctx, from, to, n, step, fn)
+}
+
+func (s *Shelf[K, V]) iterateEncoded(
+	ctx context.Context,
+	start, end []byte,
+	n, step int,
+	fn func(k, v []byte) (bool, error),
+) error {
+	order, fn := paginate(n, step, fn)
+	if order == 0 {
+		return nil
+	}
+	fn = checkContext(ctx, s.skipReserved(s.skipExpired(fn)))
+	return s.itemsRange(ctx, start, end, order, fn)
+}
+
+func (s *Shelf[K, V]) iteratePrefix(
+	ctx context.Context,
+	prefix K,
+	n, step int,
+	fn func(k, v []byte) (bool, error),
+) error {
+	p, err := s.keyCodec.Encode(prefix)
+	if err != nil {
+		return fmt.Errorf("encode prefix: %w", err)
+	}
+	return s.iteratePrefixEncoded(ctx, p, n, step, fn)
+}
+
+func (s *Shelf[K, V]) iteratePrefixEncoded(
+	ctx context.Context,
+	prefix []byte,
+	n, step int,
+	fn func(k, v []byte) (bool, error),
+) error {
+	order, fn := paginate(n, step, fn)
+	if order == 0 {
+		return nil
+	}
+	fn = checkContext(ctx, s.skipReserved(s.skipExpired(fn)))
+	return s.itemsPrefix(ctx, prefix, order, fn)
+}
+
+// paginate returns the iteration order for the given step and wraps fn so
+// that at most n items are yielded, skipping step-1 items between each one.
+// The returned order is 0 if step is 0, in which case nothing should be
+// iterated.
+func paginate(
+	n, step int,
+	fn func(k, v []byte) (bool, error),
+) (int, func(k, v []byte) (bool, error)) {
 	var order int
 	if step > 0 {
 		order = Asc
@@ -334,13 +798,13 @@ func (s *Shelf[K, V]) iterate(
 		order = Desc
 		step = -step
 	} else {
-		return nil
+		return 0, fn
 	}
 
 	var total int
 	var counter = step - 1 // 0, 1, ..., step - 1
 
-	return s.db.Items(from, order, func(k, v []byte) (bool, error) {
+	return order, func(k, v []byte) (bool, error) {
 		if n > 0 && total >= n {
 			return false, nil
 		}
@@ -354,9 +818,163 @@ func (s *Shelf[K, V]) iterate(
 
 		total++
 		return fn(k, v)
+	}
+}
+
+// itemsRange iterates over the encoded keys in the range [start, end),
+// delegating to the database if it implements RangeDB, or ContextDB when the
+// context can be cancelled (see contextDB).
+func (s *Shelf[K, V]) itemsRange(
+	ctx context.Context,
+	start, end []byte,
+	order int,
+	fn func(k, v []byte) (bool, error),
+) error {
+	if db, ok := s.contextDB(ctx); ok {
+		return db.ItemsRangeContext(ctx, start, end, order, fn)
+	}
+	if len(end) == 0 {
+		return s.db.Items(start, order, fn)
+	}
+	if db, ok := s.db.(RangeDB); ok {
+		return db.ItemsRange(start, end, order, fn)
+	}
+	return s.db.Items(start, order, func(k, v []byte) (bool, error) {
+		if !inRange(k, end, order) {
+			return false, nil
+		}
+		return fn(k, v)
 	})
 }
 
+// itemsPrefix iterates over the encoded keys that start with prefix,
+// delegating to the database if it implements PrefixDB, or ContextDB when
+// the context can be cancelled (see contextDB).
+func (s *Shelf[K, V]) itemsPrefix(
+	ctx context.Context,
+	prefix []byte,
+	order int,
+	fn func(k, v []byte) (bool, error),
+) error {
+	if len(prefix) == 0 {
+		return s.itemsRange(ctx, nil, nil, order, fn)
+	}
+	if db, ok := s.contextDB(ctx); ok {
+		return db.ItemsPrefixContext(ctx, prefix, order, fn)
+	}
+	if db, ok := s.db.(PrefixDB); ok {
+		return db.ItemsPrefix(prefix, order, fn)
+	}
+
+	// All the keys with the prefix are in the range [prefix, end), where
+	// end is the successor of the prefix (or nil, if there is none).
+	end := prefixEnd(prefix)
+
+	if order == Asc {
+		return s.itemsRange(ctx, prefix, end, order, func(k, v []byte) (bool, error) {
+			if !bytes.HasPrefix(k, prefix) {
+				return false, nil
+			}
+			return fn(k, v)
+		})
+	}
+
+	// Descending: start from the successor of the prefix, skipping it if
+	// it exists, and stop at the first key below the prefix.
+	return s.itemsRange(ctx, end, nil, order, func(k, v []byte) (bool, error) {
+		if !bytes.HasPrefix(k, prefix) {
+			return bytes.Compare(k, prefix) > 0, nil
+		}
+		return fn(k, v)
+	})
+}
+
+// rewriteValues rewrites the values for which stale returns true, replacing
+// them with the result of rewrite, and returns the number of values
+// rewritten. Both functions receive the key and the encoded value, without
+// the expiry header, which is kept. The rewrite function returns false to
+// leave a value unchanged.
+//
+// The keys are collected first, since the database can't be modified while
+// it is iterated, and then the values are rewritten in transactions of
+// rewriteBatchSize keys, which keep the expiration set by the database if it
+// implements ExpiryDB. The context is checked between the items and
+// before each transaction, so the rewrite can be stopped between chunks.
+func (s *Shelf[K, V]) rewriteValues(
+	ctx context.Context,
+	stale func(key, value []byte) (bool, error),
+	rewrite func(key, value []byte) ([]byte, bool, error),
+) (int64, error) {
+	var keys [][]byte
+	err := s.itemsRange(ctx, nil, nil, Asc, checkContext(ctx, s.skipReserved(func(k, v []byte) (bool, error) {
+		_, payload, err := s.splitStored(v)
+		if err != nil {
+			return false, err
+		}
+		ok, err := stale(k, payload)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			// Copy, since some databases reuse the key buffer.
+			keys = append(keys, slices.Clone(k))
+		}
+		return true, nil
+	})))
+	if err != nil {
+		return 0, fmt.Errorf("find values: %w", err)
+	}
+
+	var total int64
+	for chunk := range slices.Chunk(keys, rewriteBatchSize) {
+		var n int64
+		err = s.transact(ctx, func(tx *Tx[K, V]) error {
+			n = 0
+			tx.keepExpiry = true
+			for _, k := range chunk {
+				v, err := tx.loadRaw(k)
+				if err != nil {
+					return fmt.Errorf("get: %w", err)
+				}
+				if v == nil {
+					// Deleted in the meantime.
+					continue
+				}
+				header, payload, err := s.splitStored(v)
+				if err != nil {
+					return err
+				}
+				data, ok, err := rewrite(k, payload)
+				if err != nil {
+					return err
+				}
+				if ok {
+					tx.writes.add(k, append(slices.Clone(header), data...))
+					n++
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return total, fmt.Errorf("rewrite values: %w", err)
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// splitStored splits a value read from the database into the expiry header,
+// if the Shelf was opened with WithExpiryHeader, and the encoded value.
+func (s *Shelf[K, V]) splitStored(data []byte) (header, value []byte, err error) {
+	if !s.expiryHeader {
+		return nil, data, nil
+	}
+	if len(data) < expiryHeaderSize {
+		return nil, nil, errInvalidExpiryHeader
+	}
+	return data[:expiryHeaderSize], data[expiryHeaderSize:], nil
+}
+
 // Helpers
 
 func defaultKeyCodec(key any) (Codec, error) {
@@ -382,3 +1000,26 @@ func defaultKeyCodec(key any) (Codec, error) {
 		return nil, fmt.Errorf("unsupported key type %T: must explicitly set a key codec", key)
 	}
 }
+
+// prefixEnd returns the smallest key that is greater than all the keys
+// starting with prefix, or nil if there is no such key (i.e., the prefix
+// only contains 0xff bytes).
+func prefixEnd(prefix []byte) []byte {
+	end := bytes.Clone(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
+
+// inRange reports whether the encoded key is before the exclusive end bound,
+// considering the iteration order.
+func inRange(key, end []byte, order int) bool {
+	if order < 0 {
+		return bytes.Compare(key, end) > 0
+	}
+	return bytes.Compare(key, end) < 0
+}
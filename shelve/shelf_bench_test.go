@@ -75,3 +75,76 @@ func BenchmarkShelf_Get(b *testing.B) {
 
 	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "ops/sec")
 }
+
+// benchValue is a small struct, whose gob type description is larger than
+// its data.
+type benchValue struct {
+	ID     int64
+	Name   string
+	Email  string
+	Active bool
+}
+
+func benchCodecs(b *testing.B) []struct {
+	name  string
+	codec Codec
+} {
+	dict := GobDictionaryCodec()
+	if err := dict.(attachedCodec).attach(newMapDB()); err != nil {
+		b.Fatalf("attach codec: %v", err)
+	}
+	return []struct {
+		name  string
+		codec Codec
+	}{
+		{name: "Gob", codec: GobCodec()},
+		{name: "GobDictionary", codec: dict},
+		{name: "JSON", codec: JSONCodec(WithCompactJSON())},
+		{name: "JSONDecoder", codec: JSONCodec(WithCompactJSON(), WithJSONUseNumber())},
+	}
+}
+
+func BenchmarkCodec_Encode(b *testing.B) {
+	value := benchValue{ID: 42, Name: "Ada Lovelace", Email: "ada@example.com", Active: true}
+
+	for _, bc := range benchCodecs(b) {
+		b.Run(bc.name, func(b *testing.B) {
+			var size int
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				data, err := bc.codec.Encode(value)
+				if err != nil {
+					b.Fatalf("encode error: %v", err)
+				}
+				size = len(data)
+			}
+
+			b.ReportMetric(float64(size), "bytes/value")
+		})
+	}
+}
+
+func BenchmarkCodec_Decode(b *testing.B) {
+	value := benchValue{ID: 42, Name: "Ada Lovelace", Email: "ada@example.com", Active: true}
+
+	for _, bc := range benchCodecs(b) {
+		b.Run(bc.name, func(b *testing.B) {
+			data, err := bc.codec.Encode(value)
+			if err != nil {
+				b.Fatalf("encode error: %v", err)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				var v benchValue
+				if err := bc.codec.Decode(data, &v); err != nil {
+					b.Fatalf("decode error: %v", err)
+				}
+			}
+
+			b.ReportMetric(float64(len(data)), "bytes/value")
+		})
+	}
+}
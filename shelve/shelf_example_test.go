@@ -144,6 +144,72 @@ func ExampleShelf_Items() {
 	// c 3
 }
 
+func ExampleShelf_ItemsRange() {
+	path := filepath.Join(os.TempDir(), "go-shelve")
+	Clean(path) // Only for the example
+
+	shelf, err := shelve.Open[string, string](path)
+	if err != nil {
+		log.Printf("open: %s", err)
+		return
+	}
+	defer shelf.Close()
+
+	// Put some items
+	shelf.Put("a", "1")
+	shelf.Put("b", "2")
+	shelf.Put("c", "3")
+	shelf.Put("d", "4")
+
+	fn := func(key, value string) (bool, error) {
+		fmt.Println(key, value)
+		return true, nil
+	}
+	start, end := "d", "b"
+
+	// Iterate over the keys in the range (b, d], in descending order
+	err = shelf.ItemsRange(&start, &end, shelve.All, shelve.Desc, fn)
+	if err != nil {
+		log.Printf("items range: %s", err)
+		return
+	}
+
+	// Output:
+	// d 4
+	// c 3
+}
+
+func ExampleShelf_All() {
+	path := filepath.Join(os.TempDir(), "go-shelve")
+	Clean(path) // Only for the example
+
+	shelf, err := shelve.Open[string, string](path)
+	if err != nil {
+		log.Printf("open: %s", err)
+		return
+	}
+	defer shelf.Close()
+
+	// Put some items
+	shelf.Put("a", "1")
+	shelf.Put("b", "2")
+	shelf.Put("c", "3")
+
+	items, errf := shelf.All()
+	for key, value := range items {
+		fmt.Println(key, value)
+	}
+	if err = errf(); err != nil {
+		log.Printf("all: %s", err)
+		return
+	}
+
+	// Output:
+	// a 1
+	// b 2
+	// c 3
+}
+
 // Clean is a function used with example code to start with a clean storage.
 func Clean(path string) error {
 	return os.RemoveAll(path)
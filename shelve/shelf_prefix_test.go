@@ -0,0 +1,280 @@
+package shelve
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestShelf_ItemsPrefix(t *testing.T) {
+	seed := MakeItems(map[string]string{
+		"post/1": "p1", "post/2": "p2",
+		"user": "u", "user/1/email": "e1", "user/1/name": "n1",
+		"user/10/email": "e10", "user/2/email": "e2", "usera": "ua",
+	})
+	tests := []struct {
+		prefix string
+		n      int
+		step   int
+		want   []string
+	}{
+		{"user/1", All, Asc, []string{"user/1/email", "user/1/name", "user/10/email"}},
+		{"user/1/", All, Asc, []string{"user/1/email", "user/1/name"}},
+		{"user/", 2, Asc, []string{"user/1/email", "user/1/name"}},
+		{"user/", All, 2, []string{"user/1/email", "user/10/email"}},
+		{"user", All, Desc, []string{"usera", "user/2/email", "user/10/email", "user/1/name", "user/1/email", "user"}},
+		{"user/1", All, Desc, []string{"user/10/email", "user/1/name", "user/1/email"}},
+		{"post/", All, Desc, []string{"post/2", "post/1"}},
+		{"z", All, Asc, nil},
+		{"a", All, Desc, nil},
+		{"", All, Asc, []string{"post/1", "post/2", "user", "user/1/email", "user/1/name", "user/10/email", "user/2/email", "usera"}},
+		{"user", All, 0, nil},
+	}
+	for _, tt := range tests {
+		collect := func(t *testing.T, shelf *Shelf[string, string]) {
+			var got []string
+			err := shelf.ItemsPrefix(tt.prefix, tt.n, tt.step, func(key, _ string) (
+				bool, error,
+			) {
+				got = append(got, key)
+				return true, nil
+			})
+			if err != nil {
+				t.Errorf("Expected no error, but got %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("prefix=%q: Expected %v, but got %v", tt.prefix, tt.want, got)
+			}
+		}
+
+		t.Run("ItemsPrefix succeeds - fallback", func(t *testing.T) {
+			var db MockDB
+			db.ItemsFunc = newSortedItemsFunc(seed)
+			collect(t, NewTestShelf(t, WithDatabase(&db), WithCodec(TextCodec()), WithKeyCodec(TextCodec())))
+		})
+
+		t.Run("ItemsPrefix succeeds - PrefixDB", func(t *testing.T) {
+			var db MockPrefixDB
+			items := newSortedItemsFunc(seed)
+			db.ItemsPrefixFunc = func(prefix []byte, order int, fn YieldData) error {
+				return items(nil, order, func(k, v []byte) (bool, error) {
+					if !bytes.HasPrefix(k, prefix) {
+						return true, nil
+					}
+					return fn(k, v)
+				})
+			}
+			db.ItemsFunc = items
+			collect(t, NewTestShelf(t, WithDatabase(&db), WithCodec(TextCodec()), WithKeyCodec(TextCodec())))
+		})
+	}
+}
+
+func TestShelf_ItemsPrefix_NoSuccessor(t *testing.T) {
+	seed := MakeItems(map[string]string{
+		"a": "1", "\xff": "2", "\xff\xff": "3", "\xff\xffa": "4",
+	})
+	var db MockDB
+	db.ItemsFunc = newSortedItemsFunc(seed)
+	shelf := NewTestShelf(t, WithDatabase(&db), WithCodec(TextCodec()), WithKeyCodec(TextCodec()))
+
+	for _, step := range []int{Asc, Desc} {
+		var got []string
+		err := shelf.KeysPrefix("\xff\xff", All, step, func(key, _ string) (bool, error) {
+			got = append(got, key)
+			return true, nil
+		})
+		if err != nil {
+			t.Errorf("Expected no error, but got %v", err)
+		}
+		want := []string{"\xff\xff", "\xff\xffa"}
+		if step == Desc {
+			want = []string{"\xff\xffa", "\xff\xff"}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected %q, but got %q", want, got)
+		}
+	}
+}
+
+func TestShelf_LenPrefix(t *testing.T) {
+	var db MockDB
+	db.ItemsFunc = newSortedItemsFunc(MakeItems(map[string]string{
+		"user/1": "a", "user/2": "b", "post/1": "c",
+	}))
+	shelf := NewTestShelf(t, WithDatabase(&db), WithCodec(TextCodec()), WithKeyCodec(TextCodec()))
+
+	n, err := shelf.LenPrefix("user/")
+	if err != nil {
+		t.Errorf("Expected no error, but got %v", err)
+	}
+	if n != 2 {
+		t.Errorf("Expected 2, but got %d", n)
+	}
+}
+
+func TestShelf_DeletePrefix(t *testing.T) {
+	var db MockDB
+	db.ItemsFunc = newSortedItemsFunc(MakeItems(map[string]string{
+		"user/1": "a", "user/2": "b", "post/1": "c",
+	}))
+	var deleted []string
+	db.DeleteFunc = func(key []byte) error {
+		deleted = append(deleted, string(key))
+		return nil
+	}
+	shelf := NewTestShelf(t, WithDatabase(&db), WithCodec(TextCodec()), WithKeyCodec(TextCodec()))
+
+	n, err := shelf.DeletePrefix("user/")
+	if err != nil {
+		t.Errorf("Expected no error, but got %v", err)
+	}
+	if n != 2 {
+		t.Errorf("Expected 2, but got %d", n)
+	}
+	if !reflect.DeepEqual(deleted, []string{"user/1", "user/2"}) {
+		t.Errorf("Expected [user/1 user/2], but got %v", deleted)
+	}
+}
+
+func TestShelf_Prefix_Error(t *testing.T) {
+	t.Run("Encode prefix error", func(t *testing.T) {
+		var codec MockCodec
+		codec.EncodeFunc = func(any) ([]byte, error) {
+			return nil, TestError
+		}
+		shelf := NewTestShelf(t, WithKeyCodec(&codec))
+
+		err := shelf.ItemsPrefix("user/", All, Asc, func(_, _ string) (bool, error) {
+			return true, nil
+		})
+		if !errors.Is(err, TestError) {
+			t.Errorf("Expected error %v, but got %v", TestError, err)
+		}
+		if _, err = shelf.LenPrefix("user/"); !errors.Is(err, TestError) {
+			t.Errorf("Expected error %v, but got %v", TestError, err)
+		}
+		if _, err = shelf.DeletePrefix("user/"); !errors.Is(err, TestError) {
+			t.Errorf("Expected error %v, but got %v", TestError, err)
+		}
+	})
+
+	t.Run("Delete error", func(t *testing.T) {
+		var db MockDB
+		db.ItemsFunc = newSortedItemsFunc(MakeItems(map[string]string{
+			"user/1": "a", "user/2": "b",
+		}))
+		db.DeleteFunc = func([]byte) error {
+			return TestError
+		}
+		shelf := NewTestShelf(t, WithDatabase(&db), WithKeyCodec(TextCodec()))
+
+		n, err := shelf.DeletePrefix("user/")
+		if !errors.Is(err, TestError) {
+			t.Errorf("Expected error %v, but got %v", TestError, err)
+		}
+		if n != 0 {
+			t.Errorf("Expected 0, but got %d", n)
+		}
+	})
+}
+
+func TestShelf_ValuesPrefix(t *testing.T) {
+	var db MockDB
+	db.ItemsFunc = newSortedItemsFunc(MakeItems(map[string]string{
+		"user/1": "a", "user/2": "b", "post/1": "c",
+	}))
+	shelf := NewTestShelf(t, WithDatabase(&db), WithCodec(TextCodec()), WithKeyCodec(TextCodec()))
+
+	var got []string
+	err := shelf.ValuesPrefix("user/", All, Desc, func(k, v string) (bool, error) {
+		if k != "" {
+			t.Errorf("Expected an empty key, but got %q", k)
+		}
+		got = append(got, v)
+		return true, nil
+	})
+
+	if err != nil {
+		t.Errorf("Expected no error, but got %v", err)
+	}
+	if want := []string{"b", "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, but got %v", want, got)
+	}
+}
+
+func TestShelf_Prefix(t *testing.T) {
+	var db MockDB
+	db.ItemsFunc = newSortedItemsFunc(MakeItems(map[string]string{
+		"user/1": "a", "user/2": "b", "post/1": "c",
+	}))
+	shelf := NewTestShelf(t, WithDatabase(&db), WithCodec(TextCodec()), WithKeyCodec(TextCodec()))
+
+	var got []string
+	items, errf := shelf.Prefix("user/")
+	for k, v := range items {
+		got = append(got, k+"="+v)
+	}
+
+	want := []string{"user/1=a", "user/2=b"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, but got %v", want, got)
+	}
+	if err := errf(); err != nil {
+		t.Errorf("Expected no error, but got %v", err)
+	}
+}
+
+func TestPrefixEnd(t *testing.T) {
+	tests := []struct {
+		prefix []byte
+		want   []byte
+	}{
+		{[]byte("abc"), []byte("abd")},
+		{[]byte("ab\xff"), []byte("ac")},
+		{[]byte("\xff\xff"), nil},
+		{[]byte{}, nil},
+	}
+	for _, tt := range tests {
+		got := prefixEnd(tt.prefix)
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("prefixEnd(%q): Expected %q, but got %q", tt.prefix, tt.want, got)
+		}
+	}
+}
+
+// newSortedItemsFunc returns an ItemsFunc that yields the seed data sorted by
+// key, honouring the start key and the order, without encoding the items.
+func newSortedItemsFunc(seed []Item) func(
+	start []byte,
+	order int,
+	fn YieldData,
+) error {
+	return func(start []byte, order int, fn YieldData) error {
+		items := make([]Item, len(seed))
+		copy(items, seed)
+		sortItems(items, order)
+		for _, i := range items {
+			if len(start) != 0 {
+				c := strings.Compare(i.Key, string(start))
+				if (order == Asc && c < 0) || (order == Desc && c > 0) {
+					continue
+				}
+			}
+			ok, err := fn([]byte(i.Key), []byte(i.Value))
+			if err != nil || !ok {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func sortItems(items []Item, order int) {
+	slices.SortFunc(items, func(a, b Item) int {
+		return order * strings.Compare(a.Key, b.Key)
+	})
+}
@@ -84,6 +84,13 @@ func randString(length int) string {
 }
 
 func OpenTestShelf[K comparable, V any](t *testing.T) *Shelf[K, V] {
+	t.Helper()
+	return OpenTestShelfWith[K, V](t)
+}
+
+// OpenTestShelfWith works like OpenTestShelf, but applies the given options
+// when opening the Shelf.
+func OpenTestShelfWith[K comparable, V any](t *testing.T, opts ...Option) *Shelf[K, V] {
 	t.Helper()
 	path := TestDirectory
 
@@ -98,11 +105,8 @@ func OpenTestShelf[K comparable, V any](t *testing.T) *Shelf[K, V] {
 		t.Fatalf("open db: %s", err)
 	}
 
-	shelf, err := Open[K, V](
-		path,
-		WithDatabase(db),
-		WithCodec(&jsonCodec{}),
-	)
+	opts = append([]Option{WithDatabase(db), WithCodec(&jsonCodec{})}, opts...)
+	shelf, err := Open[K, V](path, opts...)
 	if err != nil {
 		t.Fatalf("open shelf: %s", err)
 	}
@@ -236,6 +240,79 @@ func TestShelf_SDB(t *testing.T) {
 	})
 }
 
+// TestShelf_SDB_OrderedCodec checks that numeric keys encoded with the
+// OrderedCodec are iterated in numeric order.
+func TestShelf_SDB_OrderedCodec(t *testing.T) {
+	keys := []int64{-1000, -20, -3, -1, 0, 1, 2, 10, 100, 1000}
+
+	open := func(t *testing.T) *Shelf[int64, string] {
+		t.Helper()
+		shelf := OpenTestShelf[int64, string](t)
+		shelf.keyCodec = OrderedCodec()
+		for _, k := range keys {
+			if err := shelf.Put(k, "value"); err != nil {
+				t.Fatalf("Expected no error, but got %v", err)
+			}
+		}
+		return shelf
+	}
+
+	t.Run("Asc", func(t *testing.T) {
+		shelf := open(t)
+		defer shelf.Close()
+
+		var got []int64
+		err := shelf.Keys(nil, All, Asc, func(key int64, _ string) (bool, error) {
+			got = append(got, key)
+			return true, nil
+		})
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		if !slices.Equal(got, keys) {
+			t.Errorf("Expected keys %v, but got %v", keys, got)
+		}
+	})
+
+	t.Run("Desc", func(t *testing.T) {
+		shelf := open(t)
+		defer shelf.Close()
+
+		var got []int64
+		err := shelf.Keys(nil, All, Desc, func(key int64, _ string) (bool, error) {
+			got = append(got, key)
+			return true, nil
+		})
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		want := slices.Clone(keys)
+		slices.Reverse(want)
+		if !slices.Equal(got, want) {
+			t.Errorf("Expected keys %v, but got %v", want, got)
+		}
+	})
+
+	t.Run("Range", func(t *testing.T) {
+		shelf := open(t)
+		defer shelf.Close()
+
+		var got []int64
+		start, end := int64(-3), int64(10)
+		err := shelf.KeysRange(&start, &end, All, Asc, func(key int64, _ string) (bool, error) {
+			got = append(got, key)
+			return true, nil
+		})
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		want := []int64{-3, -1, 0, 1, 2}
+		if !slices.Equal(got, want) {
+			t.Errorf("Expected keys %v, but got %v", want, got)
+		}
+	})
+}
+
 // ShelfSDBTests provides a set of tests for Shelf with the sdb database. The
 // keys and values provided must be of the same length and have at least 4
 // elements.
@@ -131,6 +131,7 @@ func TestShelf_Open(t *testing.T) {
 		if shelf == nil {
 			t.Fatalf("Expected shelf to be non-nil")
 		}
+		defer shelf.Close()
 		if shelf.db == nil {
 			t.Errorf("Expected shelf.db to be non-nil")
 		}
@@ -169,6 +170,7 @@ func TestShelf_Open(t *testing.T) {
 		if shelf == nil {
 			t.Fatalf("Expected shelf to be non-nil")
 		}
+		defer shelf.Close()
 		if _, ok := shelf.codec.(gobCodec); !ok {
 			t.Errorf("Expected codec to be gobCodec")
 		}
@@ -189,6 +191,7 @@ func TestShelf_Open(t *testing.T) {
 func TestShelf_DefaultKeyCodec(t *testing.T) {
 	t.Run("Int keys", func(t *testing.T) {
 		shelf, _ := Open[int, struct{}](TestDirectory)
+		defer shelf.Close()
 		if _, ok := shelf.keyCodec.(textCodec); !ok {
 			t.Errorf("Expected key codec to be textCodec")
 		}
@@ -196,6 +199,7 @@ func TestShelf_DefaultKeyCodec(t *testing.T) {
 
 	t.Run("Int64 keys", func(t *testing.T) {
 		shelf, _ := Open[int, struct{}](TestDirectory)
+		defer shelf.Close()
 		if _, ok := shelf.keyCodec.(textCodec); !ok {
 			t.Errorf("Expected key codec to be textCodec")
 		}
@@ -203,6 +207,7 @@ func TestShelf_DefaultKeyCodec(t *testing.T) {
 
 	t.Run("Uint keys", func(t *testing.T) {
 		shelf, _ := Open[uint, struct{}](TestDirectory)
+		defer shelf.Close()
 		if _, ok := shelf.keyCodec.(textCodec); !ok {
 			t.Errorf("Expected key codec to be textCodec")
 		}
@@ -210,6 +215,7 @@ func TestShelf_DefaultKeyCodec(t *testing.T) {
 
 	t.Run("Uint64 keys", func(t *testing.T) {
 		shelf, _ := Open[uint64, struct{}](TestDirectory)
+		defer shelf.Close()
 		if _, ok := shelf.keyCodec.(textCodec); !ok {
 			t.Errorf("Expected key codec to be textCodec")
 		}
@@ -217,6 +223,7 @@ func TestShelf_DefaultKeyCodec(t *testing.T) {
 
 	t.Run("String keys", func(t *testing.T) {
 		shelf, _ := Open[string, struct{}](TestDirectory)
+		defer shelf.Close()
 		if _, ok := shelf.keyCodec.(textCodec); !ok {
 			t.Errorf("Expected key codec to be textCodec")
 		}
@@ -228,6 +235,20 @@ func TestShelf_DefaultKeyCodec(t *testing.T) {
 			t.Errorf("Expected error opening shelf with struct key")
 		}
 	})
+
+	t.Run("Struct keys with key codec", func(t *testing.T) {
+		shelf, err := Open[struct{}, struct{}](
+			TestDirectory,
+			WithKeyCodec(TupleCodec()),
+		)
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		defer shelf.Close()
+		if _, ok := shelf.keyCodec.(tupleCodec); !ok {
+			t.Errorf("Expected key codec to be tupleCodec")
+		}
+	})
 }
 
 func TestShelf_Close(t *testing.T) {
@@ -260,6 +281,40 @@ func TestShelf_Len(t *testing.T) {
 			t.Errorf("Expected length to be 10, but got %d", length)
 		}
 	})
+
+	t.Run("Len with reserved entries", func(t *testing.T) {
+		// Arrange
+		seed := map[string]string{
+			indexPrefix + "a": "", indexPrefix + "b": "",
+		}
+		for i := range 10 {
+			seed[fmt.Sprintf("key-%d", i)] = "value"
+		}
+		var db MockDB
+		db.LenFunc = func() int64 { return int64(len(seed)) }
+		var visited int
+		items := newSortedItemsFunc(MakeItems(seed))
+		db.ItemsFunc = func(start []byte, order int, fn YieldData) error {
+			return items(start, order, func(k, v []byte) (bool, error) {
+				visited++
+				return fn(k, v)
+			})
+		}
+		shelf := NewTestShelf(t, WithDatabase(&db), WithCodec(TextCodec()),
+			WithKeyCodec(TextCodec()),
+			WithIndex("value", func(v string) []IndexKey { return []IndexKey{{v}} }))
+
+		// Act
+		length := shelf.Len()
+
+		// Assert
+		if length != 10 {
+			t.Errorf("Expected length to be 10, but got %d", length)
+		}
+		if visited != 2 {
+			t.Errorf("Expected only the 2 index entries to be visited, but got %d", visited)
+		}
+	})
 }
 
 func TestShelf_Sync(t *testing.T) {
@@ -667,6 +722,142 @@ func TestShelf_Items(t *testing.T) {
 	}
 }
 
+func TestShelf_ItemsRange(t *testing.T) {
+	seed := []Item{
+		{"key-01", "value-01"}, {"key-02", "value-02"},
+		{"key-03", "value-03"}, {"key-04", "value-04"},
+		{"key-05", "value-05"}, {"key-06", "value-06"},
+		{"key-07", "value-07"}, {"key-08", "value-08"},
+		{"key-09", "value-09"},
+	}
+	type args struct {
+		start *int
+		end   *int
+		n     int
+		step  int
+	}
+	p := func(i int) *int { return &i }
+	tests := []struct {
+		args args
+		want []int
+	}{
+		// Ascendant
+		{args{nil, nil, All, Asc}, []int{1, 2, 3, 4, 5, 6, 7, 8, 9}},
+		{args{nil, p(4), All, Asc}, []int{1, 2, 3}},
+		{args{p(3), p(7), All, Asc}, []int{3, 4, 5, 6}},
+		{args{p(3), p(7), 2, Asc}, []int{3, 4}},
+		{args{p(3), p(7), All, 2}, []int{3, 5}},
+		{args{p(5), p(5), All, Asc}, []int{}},
+		{args{p(7), p(3), All, Asc}, []int{}},
+
+		// Descendant
+		{args{nil, p(6), All, Desc}, []int{9, 8, 7}},
+		{args{p(7), p(3), All, Desc}, []int{7, 6, 5, 4}},
+		{args{p(7), p(3), All, -2}, []int{7, 5}},
+		{args{p(3), p(7), All, Desc}, []int{}},
+	}
+	for _, tt := range tests {
+		var start, end *string
+		if tt.args.start != nil {
+			k := fmt.Sprintf("key-%02d", *tt.args.start)
+			start = &k
+		}
+		if tt.args.end != nil {
+			k := fmt.Sprintf("key-%02d", *tt.args.end)
+			end = &k
+		}
+
+		var expectedItems []Item
+		for _, i := range tt.want {
+			key := fmt.Sprintf("key-%02d", i)
+			value := fmt.Sprintf("value-%02d", i)
+			expectedItems = append(expectedItems, Item{Key: key, Value: value})
+		}
+
+		collect := func(t *testing.T, shelf *Shelf[string, string]) {
+			var gotItems []Item
+			err := shelf.ItemsRange(start, end, tt.args.n, tt.args.step, func(
+				key, value string,
+			) (bool, error) {
+				gotItems = append(gotItems, Item{Key: key, Value: value})
+				return true, nil
+			})
+
+			if err != nil {
+				t.Errorf("Expected no error, but got %v", err)
+			}
+			if !reflect.DeepEqual(gotItems, expectedItems) {
+				t.Errorf("Expected %v, but got %v", expectedItems, gotItems)
+			}
+		}
+
+		t.Run("ItemsRange succeeds - fallback", func(t *testing.T) {
+			var db MockDB
+			db.ItemsFunc = NewMockItemsFunc(seed)
+			collect(t, NewTestShelf(t, WithDatabase(&db)))
+		})
+
+		t.Run("ItemsRange succeeds - RangeDB", func(t *testing.T) {
+			var db MockRangeDB
+			items := NewMockItemsFunc(seed)
+			db.ItemsRangeFunc = func(
+				start, end []byte, order int, fn YieldData,
+			) error {
+				return items(start, order, func(k, v []byte) (bool, error) {
+					if end != nil && !inRange(k, end, order) {
+						return false, nil
+					}
+					return fn(k, v)
+				})
+			}
+			db.ItemsFunc = func([]byte, int, YieldData) error {
+				t.Fatalf("Expected ItemsRange to be used")
+				return nil
+			}
+			if end == nil {
+				db.ItemsFunc = items
+			}
+			collect(t, NewTestShelf(t, WithDatabase(&db)))
+		})
+	}
+}
+
+func TestShelf_KeysRange(t *testing.T) {
+	var db MockDB
+	db.ItemsFunc = NewMockItemsFunc(MakeItems(map[string]string{
+		"a": "1", "b": "2", "c": "3", "d": "4",
+	}))
+	shelf := NewTestShelf(t, WithDatabase(&db))
+	start, end := "b", "d"
+
+	var keys, values []string
+	err := shelf.KeysRange(&start, &end, All, Asc, func(key, _ string) (
+		bool, error,
+	) {
+		keys = append(keys, key)
+		return true, nil
+	})
+	if err != nil {
+		t.Errorf("Expected no error, but got %v", err)
+	}
+	err = shelf.ValuesRange(&start, &end, All, Asc, func(_, value string) (
+		bool, error,
+	) {
+		values = append(values, value)
+		return true, nil
+	})
+	if err != nil {
+		t.Errorf("Expected no error, but got %v", err)
+	}
+
+	if !reflect.DeepEqual(keys, []string{"b", "c"}) {
+		t.Errorf("Expected keys [b c], but got %v", keys)
+	}
+	if !reflect.DeepEqual(values, []string{"2", "3"}) {
+		t.Errorf("Expected values [2 3], but got %v", values)
+	}
+}
+
 func TestShelf_Items_Error(t *testing.T) {
 	t.Run("DB error", func(t *testing.T) {
 		var db MockDB
@@ -731,6 +922,29 @@ func TestShelf_Items_Error(t *testing.T) {
 		}
 	})
 
+	t.Run("Encode end key error", func(t *testing.T) {
+		var db MockDB
+		var codec MockCodec
+		end := "key-9"
+		codec.EncodeFunc = func(x any) ([]byte, error) {
+			if x.(string) == end {
+				return nil, TestError
+			}
+			return []byte(x.(string)), nil
+		}
+		shelf := NewTestShelf(t, WithDatabase(&db), WithKeyCodec(&codec))
+
+		err := shelf.ItemsRange(nil, &end, All, Asc, func(_, _ string) (
+			bool, error,
+		) {
+			return true, nil
+		})
+
+		if !errors.Is(err, TestError) {
+			t.Errorf("Expected error %v, but got %v", TestError, err)
+		}
+	})
+
 	t.Run("Decode key error", func(t *testing.T) {
 		var db MockDB
 		var codec MockCodec
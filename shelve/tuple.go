@@ -0,0 +1,305 @@
+package shelve
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// Tuple holds the leading components of a composite key encoded with
+// [TupleCodec]. It is used to range or prefix-scan a Shelf on a subset of
+// the key components, with [Shelf.ItemsTupleRange] and
+// [Shelf.ItemsTuplePrefix].
+//
+// For example, with a key type like:
+//
+//	type EventKey struct {
+//		Tenant string
+//		Time   time.Time
+//		ID     int64
+//	}
+//
+// The tuple Tuple{"acme"} matches all the keys of the "acme" tenant, and
+// Tuple{"acme", t} all the keys of the tenant with the time t.
+//
+// The components must have the same types as the fields of the key.
+type Tuple []any
+
+// TupleCodec Returns a Codec for composite keys, like structs and arrays,
+// whose encoded form sorts in the same order as the key components, compared
+// one at a time from the first to the last.
+//
+// Struct keys are encoded from their exported fields, in declaration order,
+// and array keys from their elements. Unexported fields are not supported,
+// since they can't be decoded. The supported component types are strings,
+// booleans, integers, floats, []byte slices, [N]byte arrays, [time.Time]
+// (stored with nanosecond precision and decoded in UTC) and nested structs
+// or arrays of these.
+//
+// Numeric components are encoded like with [OrderedCodec]. Strings and byte
+// slices are escaped and terminated, so that the encoding is unambiguous and
+// a shorter string sorts before the longer strings it is a prefix of.
+//
+// A [Tuple] with the leading components of a key is encoded with the same
+// bytes that start the encoded key, which allows range and prefix scans on
+// part of the key.
+func TupleCodec() Codec { return tupleCodec{} }
+
+const (
+	// tupleEscape marks a 0x00 byte inside a string component, which is
+	// encoded as 0x00 0xff.
+	tupleEscape = 0xff
+
+	// tupleTerminator marks the end of a string component, which is
+	// terminated by 0x00 0x01. It sorts before any escaped 0x00 byte.
+	tupleTerminator = 0x01
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+type tupleCodec struct{}
+
+func (tupleCodec) Encode(value any) ([]byte, error) {
+	if t, ok := value.(Tuple); ok {
+		var buf []byte
+		var err error
+		for i, c := range t {
+			buf, err = appendTupleComponent(buf, reflect.ValueOf(c))
+			if err != nil {
+				return nil, fmt.Errorf("tupleCodec: component %d: %w", i, err)
+			}
+		}
+		return buf, nil
+	}
+
+	buf, err := appendTupleComponent(nil, reflect.ValueOf(value))
+	if err != nil {
+		return nil, fmt.Errorf("tupleCodec: %w", err)
+	}
+	return buf, nil
+}
+
+func (tupleCodec) Decode(data []byte, value any) error {
+	val := reflect.ValueOf(value)
+	if val.Kind() != reflect.Ptr || val.IsNil() {
+		return fmt.Errorf("tupleCodec: unsupported decode target %T", value)
+	}
+
+	rest, err := decodeTupleComponent(data, val.Elem())
+	if err != nil {
+		return fmt.Errorf("tupleCodec: %w", err)
+	}
+	if len(rest) != 0 {
+		return fmt.Errorf("tupleCodec: %d bytes of trailing data", len(rest))
+	}
+	return nil
+}
+
+func appendTupleComponent(buf []byte, v reflect.Value) ([]byte, error) {
+	if !v.IsValid() {
+		return nil, fmt.Errorf("unsupported nil component")
+	}
+	if v.Type() == timeType {
+		t := v.Interface().(time.Time)
+		buf = append(buf, encodeInt(t.Unix(), 8)...)
+		return append(buf, encodeUint(uint64(t.Nanosecond()), 4)...), nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return appendTupleBytes(buf, []byte(v.String())), nil
+	case reflect.Bool:
+		if v.Bool() {
+			return append(buf, 1), nil
+		}
+		return append(buf, 0), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return append(buf, encodeInt(v.Int(), tupleIntSize(v.Type()))...), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return append(buf, encodeUint(v.Uint(), tupleIntSize(v.Type()))...), nil
+	case reflect.Float32:
+		return append(buf, encodeUint(uint64(orderedFloat32(float32(v.Float()))), 4)...), nil
+	case reflect.Float64:
+		return append(buf, encodeUint(orderedFloat64(v.Float()), 8)...), nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			break
+		}
+		return appendTupleBytes(buf, v.Bytes()), nil
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			start := len(buf)
+			buf = append(buf, make([]byte, v.Len())...)
+			reflect.Copy(reflect.ValueOf(buf[start:]), v)
+			return buf, nil
+		}
+		var err error
+		for i := 0; i < v.Len(); i++ {
+			buf, err = appendTupleComponent(buf, v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	case reflect.Struct:
+		var err error
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				return nil, fmt.Errorf("unexported field %s in %s", field.Name, v.Type())
+			}
+			buf, err = appendTupleComponent(buf, v.Field(i))
+			if err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	case reflect.Interface:
+		return appendTupleComponent(buf, v.Elem())
+	}
+	return nil, fmt.Errorf("unsupported type %s", v.Type())
+}
+
+// tupleIntSize returns the size of the encoded integer type t. Like with the
+// OrderedCodec, int and uint use 8 bytes regardless of the platform.
+func tupleIntSize(t reflect.Type) int {
+	if t.Kind() == reflect.Int || t.Kind() == reflect.Uint {
+		return 8
+	}
+	return int(t.Size())
+}
+
+// appendTupleBytes appends b to buf, escaping the 0x00 bytes and adding the
+// terminator.
+func appendTupleBytes(buf, b []byte) []byte {
+	for _, c := range b {
+		buf = append(buf, c)
+		if c == 0x00 {
+			buf = append(buf, tupleEscape)
+		}
+	}
+	return append(buf, 0x00, tupleTerminator)
+}
+
+func decodeTupleComponent(data []byte, v reflect.Value) ([]byte, error) {
+	if v.Type() == timeType {
+		if len(data) < 12 {
+			return nil, fmt.Errorf("invalid length for %s", v.Type())
+		}
+		sec, _ := decodeInt(data[:8], 8)
+		nsec, _ := decodeUint(data[8:12], 4)
+		v.Set(reflect.ValueOf(time.Unix(sec, int64(nsec)).UTC()))
+		return data[12:], nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		b, rest, err := readTupleBytes(data)
+		if err != nil {
+			return nil, err
+		}
+		v.SetString(string(b))
+		return rest, nil
+	case reflect.Bool:
+		if len(data) < 1 {
+			return nil, fmt.Errorf("invalid length for %s", v.Type())
+		}
+		v.SetBool(data[0] != 0)
+		return data[1:], nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		size := tupleIntSize(v.Type())
+		if len(data) < size {
+			return nil, fmt.Errorf("invalid length for %s", v.Type())
+		}
+		i, _ := decodeInt(data[:size], size)
+		v.SetInt(i)
+		return data[size:], nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		size := tupleIntSize(v.Type())
+		if len(data) < size {
+			return nil, fmt.Errorf("invalid length for %s", v.Type())
+		}
+		u, _ := decodeUint(data[:size], size)
+		v.SetUint(u)
+		return data[size:], nil
+	case reflect.Float32:
+		if len(data) < 4 {
+			return nil, fmt.Errorf("invalid length for %s", v.Type())
+		}
+		u, _ := decodeUint(data[:4], 4)
+		v.SetFloat(float64(float32FromOrdered(uint32(u))))
+		return data[4:], nil
+	case reflect.Float64:
+		if len(data) < 8 {
+			return nil, fmt.Errorf("invalid length for %s", v.Type())
+		}
+		u, _ := decodeUint(data[:8], 8)
+		v.SetFloat(float64FromOrdered(u))
+		return data[8:], nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			break
+		}
+		b, rest, err := readTupleBytes(data)
+		if err != nil {
+			return nil, err
+		}
+		v.SetBytes(b)
+		return rest, nil
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			if len(data) < v.Len() {
+				return nil, fmt.Errorf("invalid length for %s", v.Type())
+			}
+			reflect.Copy(v, reflect.ValueOf(data[:v.Len()]))
+			return data[v.Len():], nil
+		}
+		var err error
+		for i := 0; i < v.Len(); i++ {
+			data, err = decodeTupleComponent(data, v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+		}
+		return data, nil
+	case reflect.Struct:
+		var err error
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				return nil, fmt.Errorf("unexported field %s in %s", field.Name, v.Type())
+			}
+			data, err = decodeTupleComponent(data, v.Field(i))
+			if err != nil {
+				return nil, err
+			}
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("unsupported decode type %s", v.Type())
+}
+
+// readTupleBytes reads an escaped and terminated component from data,
+// returning it unescaped together with the remaining data.
+func readTupleBytes(data []byte) (b, rest []byte, err error) {
+	b = make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		if data[i] != 0x00 {
+			b = append(b, data[i])
+			continue
+		}
+		if i+1 >= len(data) {
+			break
+		}
+		switch data[i+1] {
+		case tupleTerminator:
+			return b, data[i+2:], nil
+		case tupleEscape:
+			b = append(b, 0x00)
+			i++
+		default:
+			return nil, nil, fmt.Errorf("invalid escape sequence 0x00 0x%02x", data[i+1])
+		}
+	}
+	return nil, nil, fmt.Errorf("unterminated string component")
+}
@@ -0,0 +1,373 @@
+package shelve
+
+import (
+	"bytes"
+	"cmp"
+	"math"
+	"math/rand"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testTupleKey struct {
+	Tenant string
+	Time   time.Time
+	ID     int64
+}
+
+func compareTestTupleKeys(a, b testTupleKey) int {
+	if c := strings.Compare(a.Tenant, b.Tenant); c != 0 {
+		return c
+	}
+	if c := a.Time.Compare(b.Time); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.ID, b.ID)
+}
+
+func TestTupleCodec_RoundTrip(t *testing.T) {
+	type nested struct {
+		Name  string
+		Inner struct {
+			B     bool
+			F32   float32
+			Bytes []byte
+		}
+		Arr [2]uint16
+	}
+	n := nested{Name: "a\x00b"}
+	n.Inner.B = true
+	n.Inner.F32 = -1.5
+	n.Inner.Bytes = []byte{0x00, 0xff, 0x00, 0x01}
+	n.Arr = [2]uint16{1, math.MaxUint16}
+
+	tests := []struct {
+		name  string
+		input any
+	}{
+		{name: "String", input: "hello"},
+		{name: "Int", input: int(-42)},
+		{name: "Float64", input: math.Inf(-1)},
+		{name: "Time", input: time.Date(2024, 5, 1, 10, 0, 0, 123, time.UTC)},
+		{name: "Struct", input: testTupleKey{
+			Tenant: "acme",
+			Time:   time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC),
+			ID:     -7,
+		}},
+		{name: "Nested Struct", input: n},
+		{name: "Array", input: [3]string{"", "x", "\x00"}},
+		{name: "Byte Array", input: [4]byte{0, 1, 2, 3}},
+		{name: "Named Types", input: struct {
+			D time.Duration
+			M time.Month
+		}{D: -time.Second, M: time.March}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var codec tupleCodec
+
+			data, err := codec.Encode(tc.input)
+			if err != nil {
+				t.Fatalf("Encode failed: %v", err)
+			}
+
+			ptr := reflect.New(reflect.TypeOf(tc.input)).Interface()
+			if err = codec.Decode(data, ptr); err != nil {
+				t.Fatalf("Decode failed: %v", err)
+			}
+
+			got := reflect.ValueOf(ptr).Elem().Interface()
+			if !reflect.DeepEqual(got, tc.input) {
+				t.Errorf("Decode() = %v, want %v", got, tc.input)
+			}
+		})
+	}
+}
+
+func TestTupleCodec_Order(t *testing.T) {
+	var codec tupleCodec
+
+	tenants := []string{"", "a", "a\x00", "a\x00b", "a\x01", "ab", "b", "\xff"}
+	times := []time.Time{
+		time.Date(1960, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 1, 0, 0, 0, 1, time.UTC),
+	}
+	ids := []int64{math.MinInt64, -1, 0, 1, math.MaxInt64}
+
+	var keys []testTupleKey
+	for _, tenant := range tenants {
+		for _, tm := range times {
+			for _, id := range ids {
+				keys = append(keys, testTupleKey{Tenant: tenant, Time: tm, ID: id})
+			}
+		}
+	}
+	rand.Shuffle(len(keys), func(i, j int) { keys[i], keys[j] = keys[j], keys[i] })
+
+	encoded := make(map[testTupleKey][]byte)
+	for _, k := range keys {
+		data, err := codec.Encode(k)
+		if err != nil {
+			t.Fatalf("Encode failed: %v", err)
+		}
+		encoded[k] = data
+	}
+
+	byValue := slices.Clone(keys)
+	slices.SortFunc(byValue, compareTestTupleKeys)
+
+	byEncoding := slices.Clone(keys)
+	slices.SortFunc(byEncoding, func(a, b testTupleKey) int {
+		return bytes.Compare(encoded[a], encoded[b])
+	})
+
+	if !slices.Equal(byValue, byEncoding) {
+		t.Errorf("Expected the encoded keys to sort like the keys")
+	}
+}
+
+func TestTupleCodec_Tuple(t *testing.T) {
+	var codec tupleCodec
+	tm := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	key, err := codec.Encode(testTupleKey{Tenant: "acme", Time: tm, ID: 3})
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+
+	t.Run("Leading Components", func(t *testing.T) {
+		for _, tuple := range []Tuple{{}, {"acme"}, {"acme", tm}, {"acme", tm, int64(3)}} {
+			prefix, err := codec.Encode(tuple)
+			if err != nil {
+				t.Fatalf("Encode failed: %v", err)
+			}
+			if !bytes.HasPrefix(key, prefix) {
+				t.Errorf("Expected %v to be a prefix of the key", tuple)
+			}
+		}
+	})
+
+	t.Run("String Prefix Is Not Matched", func(t *testing.T) {
+		prefix, err := codec.Encode(Tuple{"ac"})
+		if err != nil {
+			t.Fatalf("Encode failed: %v", err)
+		}
+		if bytes.HasPrefix(key, prefix) {
+			t.Errorf("Expected Tuple{\"ac\"} to not be a prefix of the key")
+		}
+	})
+
+	t.Run("Unsupported Component", func(t *testing.T) {
+		_, err := codec.Encode(Tuple{"acme", nil})
+		if err == nil || !strings.Contains(err.Error(), "component 1") {
+			t.Errorf("Expected component error, but got %v", err)
+		}
+	})
+}
+
+func TestTupleCodec_Errors(t *testing.T) {
+	var codec tupleCodec
+
+	t.Run("Encode Unsupported", func(t *testing.T) {
+		for _, v := range []any{
+			nil,
+			map[string]int{},
+			[]int{1},
+			struct{ a int }{},
+			struct{ F func() }{},
+		} {
+			if _, err := codec.Encode(v); err == nil {
+				t.Errorf("Expected error encoding %T, but got nil", v)
+			}
+		}
+	})
+
+	t.Run("Decode Unsupported", func(t *testing.T) {
+		var m map[string]int
+		var s []int
+		var u struct{ a int }
+		for _, v := range []any{nil, 1, &m, &s, &u} {
+			if err := codec.Decode([]byte{0, 0, 0, 0, 0, 0, 0, 0}, v); err == nil {
+				t.Errorf("Expected error decoding into %T, but got nil", v)
+			}
+		}
+	})
+
+	t.Run("Decode Truncated", func(t *testing.T) {
+		data, err := codec.Encode(testTupleKey{Tenant: "acme", ID: 1})
+		if err != nil {
+			t.Fatalf("Encode failed: %v", err)
+		}
+		for i := 0; i < len(data); i++ {
+			var key testTupleKey
+			if err = codec.Decode(data[:i], &key); err == nil {
+				t.Errorf("Expected error decoding %d bytes, but got nil", i)
+			}
+		}
+	})
+
+	t.Run("Decode Truncated Components", func(t *testing.T) {
+		var b bool
+		var f32 float32
+		var f64 float64
+		var u16 uint16
+		var arr [4]byte
+		for _, v := range []any{&b, &f32, &f64, &u16, &arr} {
+			if err := codec.Decode(nil, v); err == nil {
+				t.Errorf("Expected error decoding into %T, but got nil", v)
+			}
+		}
+	})
+
+	t.Run("Decode Invalid Escape", func(t *testing.T) {
+		var s string
+		err := codec.Decode([]byte{'a', 0x00, 0x02}, &s)
+		if err == nil || !strings.Contains(err.Error(), "invalid escape") {
+			t.Errorf("Expected invalid escape error, but got %v", err)
+		}
+	})
+
+	t.Run("Decode Trailing Data", func(t *testing.T) {
+		var s string
+		err := codec.Decode([]byte{'a', 0x00, 0x01, 'b'}, &s)
+		if err == nil || !strings.Contains(err.Error(), "trailing data") {
+			t.Errorf("Expected trailing data error, but got %v", err)
+		}
+	})
+}
+
+func TestShelf_TupleKeys(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var keys []testTupleKey
+	for _, tenant := range []string{"a", "acme", "b"} {
+		for i := 0; i < 3; i++ {
+			keys = append(keys, testTupleKey{
+				Tenant: tenant,
+				Time:   base.Add(time.Duration(i) * time.Hour),
+				ID:     int64(i),
+			})
+		}
+	}
+
+	open := func(t *testing.T) *Shelf[testTupleKey, string] {
+		t.Helper()
+		if err := os.RemoveAll(TestDirectory); err != nil {
+			t.Fatalf("remove shelf: %s", err)
+		}
+		shelf, err := Open[testTupleKey, string](
+			TestDirectory,
+			WithKeyCodec(TupleCodec()),
+		)
+		if err != nil {
+			t.Fatalf("open shelf: %s", err)
+		}
+		for _, k := range keys {
+			if err := shelf.Put(k, k.Tenant); err != nil {
+				t.Fatalf("Expected no error, but got %v", err)
+			}
+		}
+		return shelf
+	}
+
+	collect := func(
+		t *testing.T,
+		iterate func(fn Yield[testTupleKey, string]) error,
+	) []testTupleKey {
+		t.Helper()
+		var got []testTupleKey
+		err := iterate(func(key testTupleKey, _ string) (bool, error) {
+			got = append(got, key)
+			return true, nil
+		})
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		return got
+	}
+
+	t.Run("Items", func(t *testing.T) {
+		shelf := open(t)
+		defer shelf.Close()
+
+		got := collect(t, func(fn Yield[testTupleKey, string]) error {
+			return shelf.Items(nil, All, Asc, fn)
+		})
+		if !slices.Equal(got, keys) {
+			t.Errorf("Expected keys %v, but got %v", keys, got)
+		}
+	})
+
+	t.Run("ItemsTuplePrefix", func(t *testing.T) {
+		shelf := open(t)
+		defer shelf.Close()
+
+		got := collect(t, func(fn Yield[testTupleKey, string]) error {
+			return shelf.ItemsTuplePrefix(Tuple{"a"}, All, Asc, fn)
+		})
+		if !slices.Equal(got, keys[:3]) {
+			t.Errorf("Expected keys %v, but got %v", keys[:3], got)
+		}
+
+		got = collect(t, func(fn Yield[testTupleKey, string]) error {
+			return shelf.ItemsTuplePrefix(Tuple{"acme", base.Add(time.Hour)}, All, Desc, fn)
+		})
+		if !slices.Equal(got, keys[4:5]) {
+			t.Errorf("Expected keys %v, but got %v", keys[4:5], got)
+		}
+	})
+
+	t.Run("ItemsTupleRange", func(t *testing.T) {
+		shelf := open(t)
+		defer shelf.Close()
+
+		got := collect(t, func(fn Yield[testTupleKey, string]) error {
+			return shelf.ItemsTupleRange(
+				Tuple{"acme", base.Add(time.Hour)},
+				Tuple{"acme", base.Add(2 * time.Hour)},
+				All, Asc, fn,
+			)
+		})
+		if !slices.Equal(got, keys[4:5]) {
+			t.Errorf("Expected keys %v, but got %v", keys[4:5], got)
+		}
+
+		got = collect(t, func(fn Yield[testTupleKey, string]) error {
+			return shelf.ItemsTupleRange(Tuple{"acme"}, nil, All, Asc, fn)
+		})
+		if !slices.Equal(got, keys[3:]) {
+			t.Errorf("Expected keys %v, but got %v", keys[3:], got)
+		}
+
+		got = collect(t, func(fn Yield[testTupleKey, string]) error {
+			return shelf.ItemsTupleRange(Tuple{"b"}, Tuple{"a"}, All, Desc, fn)
+		})
+		want := slices.Clone(keys[:6])
+		slices.Reverse(want)
+		if !slices.Equal(got, want) {
+			t.Errorf("Expected keys %v, but got %v", want, got)
+		}
+	})
+
+	t.Run("Encode Errors", func(t *testing.T) {
+		shelf := open(t)
+		defer shelf.Close()
+
+		fn := func(testTupleKey, string) (bool, error) { return true, nil }
+		if err := shelf.ItemsTupleRange(Tuple{nil}, nil, All, Asc, fn); err == nil {
+			t.Errorf("Expected start error, but got nil")
+		}
+		if err := shelf.ItemsTupleRange(nil, Tuple{nil}, All, Asc, fn); err == nil {
+			t.Errorf("Expected end error, but got nil")
+		}
+		if err := shelf.ItemsTuplePrefix(Tuple{nil}, All, Asc, fn); err == nil {
+			t.Errorf("Expected prefix error, but got nil")
+		}
+	})
+}
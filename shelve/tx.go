@@ -0,0 +1,254 @@
+package shelve
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+)
+
+// maxTxAttempts is the number of times a transaction is run by Shelf.Transact
+// before giving up with ErrConflict.
+const maxTxAttempts = 16
+
+// ErrConflict is returned by [Shelf.Transact] when a transaction couldn't be
+// committed because the keys it read were modified concurrently, even after
+// being retried.
+var ErrConflict = errors.New("transaction conflict")
+
+// Tx is a read-modify-write transaction on a Shelf, created by
+// [Shelf.Transact]. The writes done in a Tx are buffered and only applied to
+// the Shelf when the transaction commits, and the reads see the writes done
+// previously in the same Tx.
+//
+// A Tx is not safe for concurrent use by multiple goroutines, and must not be
+// used after the function passed to [Shelf.Transact] returns.
+type Tx[K comparable, V any] struct {
+	shelf  *Shelf[K, V]
+	ctx    context.Context
+	reads  map[string][]byte // values read from the database
+	writes *Batch[K, V]
+
+	// keepExpiry commits the writes with ExpiryDB.ReplaceBatchIf, if the
+	// database implements it, to keep the expiration of the keys.
+	keepExpiry bool
+}
+
+// Transact runs fn in a transaction. The reads and writes done with the
+// transaction are isolated from concurrent mutations of the Shelf: when fn
+// returns, the transaction commits its writes atomically, but only if none
+// of the keys it read were modified in the meantime. Otherwise, the
+// transaction is discarded and fn is called again, with a new transaction.
+//
+// Since fn might be called more than once, it shouldn't have side effects
+// other than the ones done with the transaction. If fn returns an error, the
+// transaction is discarded and the error is returned unchanged. If the
+// transaction can't be committed after some attempts, Transact returns
+// [ErrConflict].
+//
+// The concurrency control is optimistic and done with the encoded keys and
+// values. If the database implements [TxDB], the transaction is committed
+// with a conditional batch, which the database checks and writes
+// atomically. Otherwise, the commit holds a lock of the Shelf, which
+// excludes the other transactions and writes of the same Shelf, and it is
+// atomic only if the database implements [BatchDB]. Writes done to the
+// database by other means, like by another Shelf or process, are not
+// detected in this case.
+func (s *Shelf[K, V]) Transact(fn func(tx *Tx[K, V]) error) error {
+	return s.transact(context.Background(), fn)
+}
+
+// transact runs fn in a transaction, like Transact. The context is checked
+// before each attempt and before the commit, and passed to the database
+// reads and writes of the transaction.
+func (s *Shelf[K, V]) transact(ctx context.Context, fn func(tx *Tx[K, V]) error) error {
+	for range maxTxAttempts {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		tx := &Tx[K, V]{
+			shelf:  s,
+			ctx:    ctx,
+			reads:  make(map[string][]byte),
+			writes: s.NewBatch(),
+		}
+		if err := fn(tx); err != nil {
+			return err
+		}
+		ok, err := tx.commit()
+		if err != nil {
+			return fmt.Errorf("commit: %w", err)
+		}
+		if ok {
+			return nil
+		}
+	}
+	return ErrConflict
+}
+
+// Has reports whether a key exists in the Shelf, as seen by the transaction.
+func (tx *Tx[K, V]) Has(key K) (bool, error) {
+	data, err := tx.shelf.keyCodec.Encode(key)
+	if err != nil {
+		return false, fmt.Errorf("encode: %w", err)
+	}
+	vData, err := tx.load(data)
+	if err != nil {
+		return false, fmt.Errorf("has: %w", err)
+	}
+	return vData != nil, nil
+}
+
+// Get retrieves the value associated with a key from the Shelf, as seen by
+// the transaction. If the key is not found, it returns false.
+func (tx *Tx[K, V]) Get(key K) (value V, ok bool, err error) {
+	data, err := tx.shelf.keyCodec.Encode(key)
+	if err != nil {
+		return *new(V), false, fmt.Errorf("encode: %w", err)
+	}
+	vData, err := tx.load(data)
+	if err != nil {
+		return *new(V), false, fmt.Errorf("get: %w", err)
+	}
+	if vData == nil {
+		return *new(V), false, nil
+	}
+	var v V
+	err = tx.shelf.decodeValue(data, vData, &v)
+	return v, true, err
+}
+
+// Put adds a key-value pair to the transaction. It is written to the Shelf
+// when the transaction commits.
+func (tx *Tx[K, V]) Put(key K, value V) error {
+	data, vData, err := tx.shelf.encodeItem(key, value)
+	if err != nil {
+		return err
+	}
+	return tx.set(data, vData)
+}
+
+// Delete adds the removal of a key to the transaction. It is applied to the
+// Shelf when the transaction commits.
+func (tx *Tx[K, V]) Delete(key K) error {
+	data, err := tx.shelf.keyCodec.Encode(key)
+	if err != nil {
+		return fmt.Errorf("encode: %w", err)
+	}
+	return tx.set(data, nil)
+}
+
+// set adds the write of an encoded value to the transaction, or the removal
+// of the key if value is nil, together with the changes to its index entries.
+func (tx *Tx[K, V]) set(key, value []byte) error {
+	if tx.shelf.isReserved(key) {
+		return ErrReservedKey
+	}
+	if len(tx.shelf.indexes) > 0 {
+		if err := tx.updateIndexes(key, value); err != nil {
+			return err
+		}
+	}
+	tx.writes.add(key, value)
+	return nil
+}
+
+// load returns the encoded value of the key, without the expiry header,
+// looking first at the writes of the transaction and then at the database.
+// It returns nil if the key is not found or expired.
+func (tx *Tx[K, V]) load(key []byte) ([]byte, error) {
+	v, err := tx.loadRaw(key)
+	if err != nil {
+		return nil, err
+	}
+	return tx.shelf.unwrapValue(v)
+}
+
+// loadRaw works like load, but returns the value as stored in the database.
+// The values read from the database are recorded, so that they can be
+// checked at the commit.
+func (tx *Tx[K, V]) loadRaw(key []byte) ([]byte, error) {
+	if i, ok := tx.writes.index[string(key)]; ok {
+		return tx.writes.values[i], nil
+	}
+	if v, ok := tx.reads[string(key)]; ok {
+		return v, nil
+	}
+	v, err := tx.shelf.dbGet(tx.ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	// Copy, since some databases reuse the value buffer.
+	v = bytes.Clone(v)
+	tx.reads[string(key)] = v
+	return v, nil
+}
+
+// commit writes the transaction to the database if the values it read are
+// unchanged, and reports whether it did.
+func (tx *Tx[K, V]) commit() (bool, error) {
+	if len(tx.reads) == 0 && tx.writes.Len() == 0 {
+		return true, nil
+	}
+	if err := tx.ctx.Err(); err != nil {
+		return false, err
+	}
+	if tx.writes.Len() > 0 {
+		if err := tx.shelf.checkWritable(); err != nil {
+			return false, err
+		}
+	}
+
+	checkKeys := make([][]byte, 0, len(tx.reads))
+	checkValues := make([][]byte, 0, len(tx.reads))
+	for k, v := range tx.reads {
+		checkKeys = append(checkKeys, []byte(k))
+		checkValues = append(checkValues, v)
+	}
+
+	if db, ok := tx.shelf.db.(TxDB); ok {
+		writeBatchIf := db.WriteBatchIf
+		if edb, ok := db.(ExpiryDB); ok && tx.keepExpiry {
+			writeBatchIf = edb.ReplaceBatchIf
+		}
+		ok, err := writeBatchIf(checkKeys, checkValues, tx.writes.keys, tx.writes.values)
+		if ok && err == nil {
+			tx.shelf.notify(tx.writes.keys, tx.writes.values)
+		}
+		return ok, err
+	}
+
+	// Fallback: check the values and write the batch while holding the
+	// Shelf lock, which also excludes the writes done outside of
+	// transactions.
+	tx.shelf.txMu.Lock()
+	defer tx.shelf.txMu.Unlock()
+
+	for i, k := range checkKeys {
+		v, err := tx.shelf.dbGet(tx.ctx, k)
+		if err != nil {
+			return false, fmt.Errorf("get: %w", err)
+		}
+		if !sameValue(v, checkValues[i]) {
+			return false, nil
+		}
+	}
+	return true, tx.writes.write(tx.ctx)
+}
+
+// lockWrite locks the Shelf for a write done outside of a transaction, if the
+// DB doesn't implement TxDB, so that it doesn't interleave with the commits.
+// It returns the function that releases the lock.
+func (s *Shelf[K, V]) lockWrite() func() {
+	if _, ok := s.db.(TxDB); ok {
+		return func() {}
+	}
+	s.txMu.RLock()
+	return s.txMu.RUnlock
+}
+
+// sameValue reports whether two values are equal, considering a nil value
+// (a missing key) to be different from an empty one.
+func sameValue(a, b []byte) bool {
+	return (a == nil) == (b == nil) && bytes.Equal(a, b)
+}
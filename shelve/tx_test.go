@@ -0,0 +1,353 @@
+package shelve
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestShelf_Transact(t *testing.T) {
+	t.Run("Commits with TxDB", func(t *testing.T) {
+		// Arrange
+		var db MockTxDB
+		db.GetFunc = func(key []byte) ([]byte, error) {
+			if string(key) == "encoded_key-1" {
+				return []byte("encoded_value-1"), nil
+			}
+			return nil, nil
+		}
+		var checkKeys, checkValues, keys, values [][]byte
+		db.WriteBatchIfFunc = func(ck, cv, k, v [][]byte) (bool, error) {
+			checkKeys, checkValues, keys, values = ck, cv, k, v
+			return true, nil
+		}
+		shelf := NewTestShelf(t, WithDatabase(&db))
+
+		// Act
+		err := shelf.Transact(func(tx *Tx[string, string]) error {
+			value, ok, err := tx.Get("key-1")
+			if err != nil || !ok || value != "value-1" {
+				t.Errorf("Expected value-1, but got %v, %v, %v", value, ok, err)
+			}
+			if err = tx.Put("key-1", value+"b"); err != nil {
+				return err
+			}
+			return tx.Delete("key-2")
+		})
+
+		// Assert
+		if err != nil {
+			t.Errorf("Expected no error, but got %v", err)
+		}
+		assertBytes(t, checkKeys, [][]byte{[]byte("encoded_key-1")})
+		assertBytes(t, checkValues, [][]byte{[]byte("encoded_value-1")})
+		assertBytes(t, keys, [][]byte{[]byte("encoded_key-1"), []byte("encoded_key-2")})
+		assertBytes(t, values, [][]byte{[]byte("encoded_value-1b"), nil})
+	})
+
+	t.Run("Retries on conflict", func(t *testing.T) {
+		// Arrange
+		var db MockTxDB
+		var calls, commits int
+		db.WriteBatchIfFunc = func(_, _, _, _ [][]byte) (bool, error) {
+			commits++
+			return commits == 3, nil
+		}
+		shelf := NewTestShelf(t, WithDatabase(&db))
+
+		// Act
+		err := shelf.Transact(func(tx *Tx[string, string]) error {
+			calls++
+			return tx.Put("key", "value")
+		})
+
+		// Assert
+		if err != nil {
+			t.Errorf("Expected no error, but got %v", err)
+		}
+		if calls != 3 {
+			t.Errorf("Expected 3 calls, but got %d", calls)
+		}
+	})
+
+	t.Run("Too many conflicts", func(t *testing.T) {
+		var db MockTxDB
+		db.WriteBatchIfFunc = func(_, _, _, _ [][]byte) (bool, error) {
+			return false, nil
+		}
+		shelf := NewTestShelf(t, WithDatabase(&db))
+
+		var calls int
+		err := shelf.Transact(func(tx *Tx[string, string]) error {
+			calls++
+			return tx.Put("key", "value")
+		})
+		if !errors.Is(err, ErrConflict) {
+			t.Errorf("Expected ErrConflict, but got %v", err)
+		}
+		if calls != maxTxAttempts {
+			t.Errorf("Expected %d calls, but got %d", maxTxAttempts, calls)
+		}
+	})
+
+	t.Run("Function error", func(t *testing.T) {
+		var db MockTxDB
+		db.WriteBatchIfFunc = func(_, _, _, _ [][]byte) (bool, error) {
+			t.Errorf("Expected WriteBatchIf to not be called")
+			return true, nil
+		}
+		shelf := NewTestShelf(t, WithDatabase(&db))
+
+		err := shelf.Transact(func(tx *Tx[string, string]) error {
+			_ = tx.Put("key", "value")
+			return TestError
+		})
+		if err != TestError {
+			t.Errorf("Expected error %v, but got %v", TestError, err)
+		}
+	})
+
+	t.Run("Commit error", func(t *testing.T) {
+		var db MockTxDB
+		db.WriteBatchIfFunc = func(_, _, _, _ [][]byte) (bool, error) {
+			return false, TestError
+		}
+		shelf := NewTestShelf(t, WithDatabase(&db))
+
+		err := shelf.Transact(func(tx *Tx[string, string]) error {
+			return tx.Put("key", "value")
+		})
+		if !errors.Is(err, TestError) {
+			t.Errorf("Expected error %v, but got %v", TestError, err)
+		}
+	})
+
+	t.Run("Read your writes", func(t *testing.T) {
+		var db MockTxDB
+		db.GetFunc = func(key []byte) ([]byte, error) {
+			return []byte("encoded_stored"), nil
+		}
+		var checkKeys [][]byte
+		db.WriteBatchIfFunc = func(ck, _, _, _ [][]byte) (bool, error) {
+			checkKeys = ck
+			return true, nil
+		}
+		shelf := NewTestShelf(t, WithDatabase(&db))
+
+		err := shelf.Transact(func(tx *Tx[string, string]) error {
+			_ = tx.Put("key-1", "written")
+			_ = tx.Delete("key-2")
+
+			value, ok, _ := tx.Get("key-1")
+			if !ok || value != "written" {
+				t.Errorf("Expected written, but got %v, %v", value, ok)
+			}
+			if ok, _ = tx.Has("key-2"); ok {
+				t.Errorf("Expected key-2 to be deleted")
+			}
+			if ok, _ = tx.Has("key-3"); !ok {
+				t.Errorf("Expected key-3 to exist")
+			}
+			return nil
+		})
+		if err != nil {
+			t.Errorf("Expected no error, but got %v", err)
+		}
+		assertBytes(t, checkKeys, [][]byte{[]byte("encoded_key-3")})
+	})
+
+	t.Run("Get error", func(t *testing.T) {
+		var db MockTxDB
+		db.GetFunc = func(key []byte) ([]byte, error) { return nil, TestError }
+		shelf := NewTestShelf(t, WithDatabase(&db))
+
+		err := shelf.Transact(func(tx *Tx[string, string]) error {
+			if _, err := tx.Has("key"); !errors.Is(err, TestError) {
+				t.Errorf("Expected error %v, but got %v", TestError, err)
+			}
+			_, _, err := tx.Get("key")
+			return err
+		})
+		if !errors.Is(err, TestError) {
+			t.Errorf("Expected error %v, but got %v", TestError, err)
+		}
+	})
+
+	t.Run("Encode error", func(t *testing.T) {
+		var codec MockCodec
+		codec.EncodeFunc = func(any) ([]byte, error) { return nil, TestError }
+		shelf := NewTestShelf(t, WithKeyCodec(&codec))
+
+		err := shelf.Transact(func(tx *Tx[string, string]) error {
+			if _, err := tx.Has("key"); !errors.Is(err, TestError) {
+				t.Errorf("Expected error %v, but got %v", TestError, err)
+			}
+			_, _, err := tx.Get("key")
+			return err
+		})
+		if !errors.Is(err, TestError) {
+			t.Errorf("Expected error %v, but got %v", TestError, err)
+		}
+	})
+}
+
+func TestShelf_Transact_Fallback(t *testing.T) {
+	t.Run("Conflict", func(t *testing.T) {
+		// Arrange: The stored value changes between the read in the
+		// first attempt and its commit.
+		var db MockDB
+		var gets int
+		db.GetFunc = func(key []byte) ([]byte, error) {
+			gets++
+			if gets == 1 {
+				return []byte("encoded_old"), nil
+			}
+			return []byte("encoded_new"), nil
+		}
+		var puts []string
+		db.PutFunc = func(key, value []byte) error {
+			puts = append(puts, string(value))
+			return nil
+		}
+		shelf := NewTestShelf(t, WithDatabase(&db))
+
+		// Act
+		err := shelf.Transact(func(tx *Tx[string, string]) error {
+			value, _, err := tx.Get("key")
+			if err != nil {
+				return err
+			}
+			return tx.Put("key", value+"-updated")
+		})
+
+		// Assert
+		if err != nil {
+			t.Errorf("Expected no error, but got %v", err)
+		}
+		expected := []string{"encoded_new-updated"}
+		if !reflect.DeepEqual(puts, expected) {
+			t.Errorf("Expected %v, but got %v", expected, puts)
+		}
+	})
+
+	t.Run("Excludes the writes", func(t *testing.T) {
+		// Arrange: A Put is started while the transaction checks the
+		// values it read, before the commit writes.
+		var db MockDB
+		var shelf *Shelf[string, string]
+		var gets int
+		var wg sync.WaitGroup
+		db.GetFunc = func(key []byte) ([]byte, error) {
+			gets++
+			if gets == 2 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_ = shelf.Put("key", "other")
+				}()
+				time.Sleep(50 * time.Millisecond)
+			}
+			return []byte("encoded_value"), nil
+		}
+		var mu sync.Mutex
+		var puts []string
+		db.PutFunc = func(key, value []byte) error {
+			mu.Lock()
+			defer mu.Unlock()
+			puts = append(puts, string(value))
+			return nil
+		}
+		shelf = NewTestShelf(t, WithDatabase(&db))
+
+		// Act
+		err := shelf.Transact(func(tx *Tx[string, string]) error {
+			value, _, err := tx.Get("key")
+			if err != nil {
+				return err
+			}
+			return tx.Put("key", value+"-updated")
+		})
+		wg.Wait()
+
+		// Assert
+		if err != nil {
+			t.Errorf("Expected no error, but got %v", err)
+		}
+		expected := []string{"encoded_value-updated", "encoded_other"}
+		if !reflect.DeepEqual(puts, expected) {
+			t.Errorf("Expected %v, but got %v", expected, puts)
+		}
+	})
+
+	t.Run("Get error", func(t *testing.T) {
+		var db MockDB
+		var gets int
+		db.GetFunc = func(key []byte) ([]byte, error) {
+			gets++
+			if gets > 1 {
+				return nil, TestError
+			}
+			return nil, nil
+		}
+		shelf := NewTestShelf(t, WithDatabase(&db))
+
+		err := shelf.Transact(func(tx *Tx[string, string]) error {
+			_, err := tx.Has("key")
+			return err
+		})
+		if !errors.Is(err, TestError) {
+			t.Errorf("Expected error %v, but got %v", TestError, err)
+		}
+	})
+}
+
+func TestShelf_Transact_SDB(t *testing.T) {
+	shelf := OpenTestShelf[string, int](t)
+	defer shelf.Close()
+
+	const workers, increments = 8, 25
+
+	var committed atomic.Int64
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range increments {
+				err := shelf.Transact(func(tx *Tx[string, int]) error {
+					n, _, err := tx.Get("counter")
+					if err != nil {
+						return err
+					}
+					return tx.Put("counter", n+1)
+				})
+				if err == nil {
+					committed.Add(1)
+				} else if !errors.Is(err, ErrConflict) {
+					t.Errorf("Expected no error, but got %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Each increment is either committed or fails with ErrConflict, but
+	// no increment is lost.
+	n, _, err := shelf.Get("counter")
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if int64(n) != committed.Load() {
+		t.Errorf("Expected %d, but got %d", committed.Load(), n)
+	}
+}
+
+func assertBytes(t *testing.T, got, expected [][]byte) {
+	t.Helper()
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %q, but got %q", expected, got)
+	}
+}
@@ -0,0 +1,334 @@
+package shelve
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// defaultWatchBuffer is the default number of events buffered by a Watcher.
+const defaultWatchBuffer = 64
+
+// ErrClosed is returned by [Shelf.Watch] when the Shelf is closed.
+var ErrClosed = errors.New("shelf closed")
+
+// EventType is the kind of change reported by an [Event].
+type EventType int
+
+const (
+	// EventPut reports that a key was stored or overwritten.
+	EventPut EventType = iota + 1
+
+	// EventDelete reports that a key was removed.
+	EventDelete
+)
+
+// String returns the name of the event type.
+func (t EventType) String() string {
+	switch t {
+	case EventPut:
+		return "put"
+	case EventDelete:
+		return "delete"
+	default:
+		return fmt.Sprintf("EventType(%d)", int(t))
+	}
+}
+
+// Event is a change to a Shelf, delivered by a [Watcher]. For deletes, the
+// Value is the zero value of V.
+type Event[K, V any] struct {
+	Type  EventType
+	Key   K
+	Value V
+}
+
+// Backpressure defines what a [Watcher] does when its buffer is full and a
+// new event arrives.
+type Backpressure int
+
+const (
+	// Block makes the writers of the Shelf wait until the event can be
+	// buffered. It is the default, and no events are lost, but a slow
+	// consumer slows down the writes.
+	Block Backpressure = iota
+
+	// DropNewest discards the new event.
+	DropNewest
+
+	// DropOldest discards the oldest buffered event to make room for the
+	// new one.
+	DropOldest
+)
+
+// WatchOption is passed to [Shelf.Watch] to customize a Watcher.
+type WatchOption func(*watchOptions)
+
+type watchOptions struct {
+	Buffer       int
+	Backpressure Backpressure
+}
+
+// WithWatchBuffer sets the number of events buffered by the Watcher. The
+// default is 64.
+func WithWatchBuffer(n int) WatchOption {
+	return func(o *watchOptions) {
+		o.Buffer = max(n, 0)
+	}
+}
+
+// WithBackpressure sets what the Watcher does when its buffer is full. The
+// default is [Block].
+func WithBackpressure(b Backpressure) WatchOption {
+	return func(o *watchOptions) {
+		o.Backpressure = b
+	}
+}
+
+// A Watcher delivers the changes to the keys of a Shelf that start with a
+// prefix. It is created with [Shelf.Watch].
+type Watcher[K comparable, V any] struct {
+	hub          *watchHub[K, V]
+	prefix       []byte
+	backpressure Backpressure
+	ch           chan Event[K, V]
+	done         chan struct{}
+	once         sync.Once
+	dropped      atomic.Uint64
+
+	// Held for reading while sending and for writing while closing the
+	// channel, so that it isn't closed during a send.
+	mu     sync.RWMutex
+	closed bool
+}
+
+// Events returns the channel that delivers the events. The channel is closed
+// when the Watcher or the Shelf is closed.
+func (w *Watcher[K, V]) Events() <-chan Event[K, V] {
+	return w.ch
+}
+
+// Dropped returns the number of events that were discarded, either by the
+// backpressure policy or because they couldn't be decoded.
+func (w *Watcher[K, V]) Dropped() uint64 {
+	return w.dropped.Load()
+}
+
+// Close stops the Watcher and closes its channel. Writers blocked on the
+// Watcher are released. It is safe to call Close more than once.
+func (w *Watcher[K, V]) Close() {
+	w.hub.mu.Lock()
+	w.hub.remove(w)
+	w.hub.mu.Unlock()
+	w.closeChannel()
+}
+
+// stop releases the writers blocked on the Watcher.
+func (w *Watcher[K, V]) stop() {
+	w.once.Do(func() { close(w.done) })
+}
+
+// closeChannel stops the Watcher and closes its channel once the writers
+// sending to it are done, if it wasn't already closed.
+func (w *Watcher[K, V]) closeChannel() {
+	w.stop()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.closed {
+		w.closed = true
+		close(w.ch)
+	}
+}
+
+// send delivers the event, following the backpressure policy. It is called
+// without the hub lock, so that a blocked send doesn't stop the other
+// writers of the Shelf, including the consumers of the events.
+func (w *Watcher[K, V]) send(e Event[K, V]) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return
+	}
+
+	switch w.backpressure {
+	case DropNewest:
+		select {
+		case w.ch <- e:
+		default:
+			w.dropped.Add(1)
+		}
+	case DropOldest:
+		for {
+			select {
+			case w.ch <- e:
+				return
+			default:
+			}
+			select {
+			case <-w.ch:
+				w.dropped.Add(1)
+			default:
+				if cap(w.ch) == 0 {
+					// Nothing to discard without a buffer.
+					w.dropped.Add(1)
+					return
+				}
+			}
+		}
+	default:
+		select {
+		case w.ch <- e:
+		case <-w.done:
+		case <-w.hub.stop:
+		}
+	}
+}
+
+// Watch returns a Watcher that delivers an [Event] after each successful
+// write to a key that starts with the given prefix. The prefix is compared
+// with the keys using their encoded representation, like with
+// [Shelf.ItemsPrefix]. Use [Shelf.WatchAll] to watch all the keys.
+//
+// The events are emitted by the writes made with this Shelf, after they are
+// applied to the database, including the writes of batches and
+// transactions. Writes made by other processes, or by other Shelf instances,
+// aren't reported, nor are the expirations of keys written with
+// [Shelf.PutWithTTL]. A delete event is emitted even if the key didn't exist.
+// The events of concurrent writes might be delivered in a different order
+// than the writes were applied.
+//
+// The Watcher buffers the events and, when the buffer is full, follows the
+// [Backpressure] policy set with the options. A consumer can write to the
+// Shelf while handling the events, but with [Block], its writes to the keys
+// it watches wait for it to receive their events. The Watcher must be closed
+// when no longer used, and it is closed when the Shelf is closed.
+func (s *Shelf[K, V]) Watch(prefix K, opts ...WatchOption) (*Watcher[K, V], error) {
+	p, err := s.keyCodec.Encode(prefix)
+	if err != nil {
+		return nil, fmt.Errorf("encode prefix: %w", err)
+	}
+	return s.watch(p, opts)
+}
+
+// WatchAll works like [Shelf.Watch], but the Watcher delivers the events of
+// all the keys.
+func (s *Shelf[K, V]) WatchAll(opts ...WatchOption) (*Watcher[K, V], error) {
+	return s.watch(nil, opts)
+}
+
+func (s *Shelf[K, V]) watch(prefix []byte, opts []WatchOption) (*Watcher[K, V], error) {
+	o := watchOptions{Buffer: defaultWatchBuffer}
+	for _, option := range opts {
+		option(&o)
+	}
+
+	w := &Watcher[K, V]{
+		hub:          s.watchers,
+		prefix:       prefix,
+		backpressure: o.Backpressure,
+		ch:           make(chan Event[K, V], o.Buffer),
+		done:         make(chan struct{}),
+	}
+
+	h := s.watchers
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil, ErrClosed
+	}
+	h.watchers = append(h.watchers, w)
+	return w, nil
+}
+
+// notify emits the events of the encoded keys and values written to the
+// database, with a nil value for deletes.
+func (s *Shelf[K, V]) notify(keys, values [][]byte) {
+	h := s.watchers
+	h.mu.Lock()
+	watchers := slices.Clone(h.watchers)
+	h.mu.Unlock()
+	if len(watchers) == 0 {
+		return
+	}
+
+	for i, k := range keys {
+		if s.isReserved(k) {
+			continue
+		}
+		var e Event[K, V]
+		decoded := false
+		var err error
+		for _, w := range watchers {
+			if !bytes.HasPrefix(k, w.prefix) {
+				continue
+			}
+			if !decoded {
+				e, err = s.decodeEvent(k, values[i])
+				decoded = true
+			}
+			if err != nil {
+				w.dropped.Add(1)
+				continue
+			}
+			w.send(e)
+		}
+	}
+}
+
+func (s *Shelf[K, V]) decodeEvent(key, value []byte) (Event[K, V], error) {
+	e := Event[K, V]{Type: EventDelete}
+	if err := s.keyCodec.Decode(key, &e.Key); err != nil {
+		return e, fmt.Errorf("decode key: %w", err)
+	}
+	v, ok, err := s.decodeStored(key, value)
+	if err != nil {
+		return e, err
+	}
+	if ok {
+		e.Type, e.Value = EventPut, v
+	}
+	return e, nil
+}
+
+// watchHub holds the Watchers of a Shelf.
+type watchHub[K comparable, V any] struct {
+	// Guards the fields below. It isn't held while the events are sent.
+	mu       sync.Mutex
+	closed   bool
+	watchers []*Watcher[K, V]
+
+	// Closed when the Shelf is closed, to release the blocked writers.
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func newWatchHub[K comparable, V any]() *watchHub[K, V] {
+	return &watchHub[K, V]{stop: make(chan struct{})}
+}
+
+// remove removes the Watcher, if it wasn't already removed. The caller must
+// hold the lock.
+func (h *watchHub[K, V]) remove(w *Watcher[K, V]) {
+	h.watchers = slices.DeleteFunc(h.watchers, func(x *Watcher[K, V]) bool {
+		return x == w
+	})
+}
+
+// close closes all the Watchers.
+func (h *watchHub[K, V]) close() {
+	// Release the blocked writers first, so the channels can be closed.
+	h.stopOnce.Do(func() { close(h.stop) })
+
+	h.mu.Lock()
+	h.closed = true
+	watchers := h.watchers
+	h.watchers = nil
+	h.mu.Unlock()
+
+	for _, w := range watchers {
+		w.closeChannel()
+	}
+}
@@ -0,0 +1,302 @@
+package shelve
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// receive returns the next event of the Watcher, failing the test if none
+// arrives in time.
+func receive[K comparable, V any](t *testing.T, w *Watcher[K, V]) Event[K, V] {
+	t.Helper()
+	select {
+	case e, ok := <-w.Events():
+		if !ok {
+			t.Fatalf("Expected an event, but the channel is closed")
+		}
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected an event, but got none")
+	}
+	return Event[K, V]{}
+}
+
+func checkNoEvent[K comparable, V any](t *testing.T, w *Watcher[K, V]) {
+	t.Helper()
+	select {
+	case e := <-w.Events():
+		t.Errorf("Expected no event, but got %v", e)
+	default:
+	}
+}
+
+func TestShelf_Watch(t *testing.T) {
+	t.Run("Delivers the events", func(t *testing.T) {
+		// Arrange
+		shelf := OpenTestShelf[string, string](t)
+		defer shelf.Close()
+		w, err := shelf.Watch("user-")
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		defer w.Close()
+
+		// Act
+		_ = shelf.Put("user-1", "ann")
+		_ = shelf.Put("other", "value")
+		_ = shelf.Delete("user-1")
+		b := shelf.NewBatch()
+		_ = b.Put("user-2", "bob")
+		_ = b.Delete("user-3")
+		_ = b.Write()
+		_, _ = shelf.Update("user-2", func(old string, _ bool) (string, error) {
+			return old + "!", nil
+		})
+
+		// Assert
+		expected := []Event[string, string]{
+			{Type: EventPut, Key: "user-1", Value: "ann"},
+			{Type: EventDelete, Key: "user-1"},
+			{Type: EventPut, Key: "user-2", Value: "bob"},
+			{Type: EventDelete, Key: "user-3"},
+			{Type: EventPut, Key: "user-2", Value: "bob!"},
+		}
+		for _, e := range expected {
+			if got := receive(t, w); got != e {
+				t.Errorf("Expected %v, but got %v", e, got)
+			}
+		}
+		checkNoEvent(t, w)
+	})
+
+	t.Run("WatchAll", func(t *testing.T) {
+		shelf := OpenTestShelfWith[string, string](t, WithIndex("value",
+			func(v string) []IndexKey { return []IndexKey{{v}} },
+		))
+		defer shelf.Close()
+		w, _ := shelf.WatchAll()
+		defer w.Close()
+
+		_ = shelf.Put("key", "value")
+
+		// The index entries aren't reported.
+		e := receive(t, w)
+		if e.Type != EventPut || e.Key != "key" || e.Value != "value" {
+			t.Errorf("Expected a put of key, but got %v", e)
+		}
+		checkNoEvent(t, w)
+	})
+
+	t.Run("Failed write", func(t *testing.T) {
+		var db MockDB
+		db.PutFunc = func(_, _ []byte) error { return TestError }
+		shelf := NewTestShelf(t, WithDatabase(&db))
+		w, _ := shelf.WatchAll()
+		defer w.Close()
+
+		_ = shelf.Put("key", "value")
+
+		checkNoEvent(t, w)
+	})
+
+	t.Run("Decode error", func(t *testing.T) {
+		var codec MockCodec
+		codec.DecodeFunc = func([]byte, any) error { return TestError }
+		shelf := NewTestShelf(t, WithCodec(&codec))
+		w, _ := shelf.WatchAll()
+		defer w.Close()
+
+		_ = shelf.Put("key", "value")
+
+		checkNoEvent(t, w)
+		if n := w.Dropped(); n != 1 {
+			t.Errorf("Expected 1 dropped event, but got %d", n)
+		}
+	})
+
+	t.Run("Encode error", func(t *testing.T) {
+		var codec MockCodec
+		codec.EncodeFunc = func(any) ([]byte, error) { return nil, TestError }
+		shelf := NewTestShelf(t, WithKeyCodec(&codec))
+
+		_, err := shelf.Watch("prefix")
+		if !errors.Is(err, TestError) {
+			t.Errorf("Expected error %v, but got %v", TestError, err)
+		}
+	})
+}
+
+func TestShelf_Watch_Backpressure(t *testing.T) {
+	t.Run("DropNewest", func(t *testing.T) {
+		shelf := NewTestShelf(t)
+		w, _ := shelf.WatchAll(WithWatchBuffer(1), WithBackpressure(DropNewest))
+		defer w.Close()
+
+		_ = shelf.Put("key-1", "value")
+		_ = shelf.Put("key-2", "value")
+
+		if e := receive(t, w); e.Key != "key-1" {
+			t.Errorf("Expected key-1, but got %v", e.Key)
+		}
+		if n := w.Dropped(); n != 1 {
+			t.Errorf("Expected 1 dropped event, but got %d", n)
+		}
+	})
+
+	t.Run("DropOldest", func(t *testing.T) {
+		shelf := NewTestShelf(t)
+		w, _ := shelf.WatchAll(WithWatchBuffer(1), WithBackpressure(DropOldest))
+		defer w.Close()
+
+		_ = shelf.Put("key-1", "value")
+		_ = shelf.Put("key-2", "value")
+
+		if e := receive(t, w); e.Key != "key-2" {
+			t.Errorf("Expected key-2, but got %v", e.Key)
+		}
+		if n := w.Dropped(); n != 1 {
+			t.Errorf("Expected 1 dropped event, but got %d", n)
+		}
+	})
+
+	t.Run("DropOldest without buffer", func(t *testing.T) {
+		shelf := NewTestShelf(t)
+		w, _ := shelf.WatchAll(WithWatchBuffer(0), WithBackpressure(DropOldest))
+		defer w.Close()
+
+		_ = shelf.Put("key", "value")
+
+		if n := w.Dropped(); n != 1 {
+			t.Errorf("Expected 1 dropped event, but got %d", n)
+		}
+	})
+
+	t.Run("Block", func(t *testing.T) {
+		// Arrange
+		shelf := NewTestShelf(t)
+		w, _ := shelf.WatchAll(WithWatchBuffer(1))
+		_ = shelf.Put("key-1", "value")
+
+		// Act
+		done := make(chan error)
+		go func() { done <- shelf.Put("key-2", "value") }()
+
+		// Assert
+		select {
+		case <-done:
+			t.Fatalf("Expected the write to block")
+		case <-time.After(10 * time.Millisecond):
+		}
+		if e := receive(t, w); e.Key != "key-1" {
+			t.Errorf("Expected key-1, but got %v", e.Key)
+		}
+		if err := <-done; err != nil {
+			t.Errorf("Expected no error, but got %v", err)
+		}
+		if e := receive(t, w); e.Key != "key-2" {
+			t.Errorf("Expected key-2, but got %v", e.Key)
+		}
+		w.Close()
+	})
+
+	t.Run("Block with a consumer writing to the Shelf", func(t *testing.T) {
+		// Arrange
+		shelf := NewTestShelf(t)
+		w, _ := shelf.Watch("a-", WithWatchBuffer(0))
+		others, _ := shelf.Watch("b-", WithWatchBuffer(0))
+		consumed := make(chan error)
+		go func() {
+			for e := range w.Events() {
+				consumed <- shelf.Put("b-"+e.Key, e.Value)
+			}
+		}()
+		go func() {
+			for range others.Events() {
+			}
+		}()
+
+		// Act
+		done := make(chan error)
+		go func() {
+			for _, key := range []string{"a-1", "a-2", "a-3"} {
+				if err := shelf.Put(key, "value"); err != nil {
+					done <- err
+					return
+				}
+			}
+			done <- nil
+		}()
+
+		// Assert
+		for i := 0; i < 3; i++ {
+			select {
+			case err := <-consumed:
+				if err != nil {
+					t.Errorf("Expected no error, but got %v", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("Expected the consumer to write, but it is blocked")
+			}
+		}
+		if err := <-done; err != nil {
+			t.Errorf("Expected no error, but got %v", err)
+		}
+		w.Close()
+		others.Close()
+	})
+
+	t.Run("Close releases the writers", func(t *testing.T) {
+		for _, closeShelf := range []bool{false, true} {
+			shelf := NewTestShelf(t)
+			w, _ := shelf.WatchAll(WithWatchBuffer(0))
+
+			done := make(chan error)
+			go func() { done <- shelf.Put("key", "value") }()
+			time.Sleep(10 * time.Millisecond)
+
+			if closeShelf {
+				_ = shelf.Close()
+			} else {
+				w.Close()
+			}
+
+			if err := <-done; err != nil {
+				t.Errorf("Expected no error, but got %v", err)
+			}
+			if _, ok := <-w.Events(); ok {
+				t.Errorf("Expected the channel to be closed")
+			}
+			w.Close()
+		}
+	})
+}
+
+func TestShelf_Watch_Close(t *testing.T) {
+	shelf := NewTestShelf(t)
+	w, _ := shelf.WatchAll()
+
+	if err := shelf.Close(); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	if _, ok := <-w.Events(); ok {
+		t.Errorf("Expected the channel to be closed")
+	}
+	if _, err := shelf.WatchAll(); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed, but got %v", err)
+	}
+}
+
+func TestEventType_String(t *testing.T) {
+	tests := map[EventType]string{
+		EventPut:     "put",
+		EventDelete:  "delete",
+		EventType(9): "EventType(9)",
+	}
+	for typ, expected := range tests {
+		if got := typ.String(); got != expected {
+			t.Errorf("Expected %q, but got %q", expected, got)
+		}
+	}
+}